@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
 	"github.com/spf13/cobra"
 )
 
@@ -15,60 +20,193 @@ var balanceCmd = &cobra.Command{
 	RunE:  runBalance,
 }
 
+// walletBalance is the balance of one configured wallet.
+type walletBalance struct {
+	Protocol string `json:"protocol"`
+	Wallet   string `json:"wallet"`
+	Account  string `json:"account,omitempty"`
+	Sats     *int64 `json:"sats,omitempty"`
+	// OnchainSats is confirmed on-chain funds, for nodes that report them.
+	OnchainSats *int64 `json:"onchain_sats,omitempty"`
+	// Balances is the wallet's own per-token report, for AgentWallet.
+	Balances json.RawMessage `json:"balances,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
 func runBalance(cmd *cobra.Command, args []string) error {
-	cfg, err := loadConfig()
+	var balances []walletBalance
+	err := callDaemon("balance", nil, &balances)
+	if errors.Is(err, errNoDaemon) {
+		var cfg *AppConfig
+		if cfg, err = loadConfig(); err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		balances = walletBalances(context.Background(), cfg)
+	}
 	if err != nil {
-		return fmt.Errorf("load config: %w", err)
+		return err
 	}
 
 	fmt.Println("AgentPay Wallet Balances")
 	fmt.Println("========================")
+	printBalances(os.Stdout, balances)
+	return nil
+}
+
+// walletBalances asks every wallet configured in cfg for its balance. A
+// wallet that can't be reached is reported with its error.
+func walletBalances(ctx context.Context, cfg *AppConfig) []walletBalance {
+	var out []walletBalance
+	sats := func(wb walletBalance, msat router.Amount, err error) walletBalance {
+		if err != nil {
+			wb.Error = err.Error()
+			return wb
+		}
+		n := msat.Units / 1000
+		wb.Sats = &n
+		return wb
+	}
 
 	// Check AgentWallet balances
 	if cfg.AgentWallet.Username != "" {
+		wb := walletBalance{Protocol: "x402", Wallet: "AgentWallet", Account: cfg.AgentWallet.Username}
 		balURL := fmt.Sprintf("%s/api/wallets/%s/balances",
 			cfg.AgentWallet.APIBase, cfg.AgentWallet.Username)
-		req, _ := http.NewRequest("GET", balURL, nil)
+		req, _ := http.NewRequestWithContext(ctx, "GET", balURL, nil)
 		req.Header.Set("Authorization", "Bearer "+cfg.AgentWallet.Token)
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			fmt.Printf("\nx402 (AgentWallet): ERROR - %v\n", err)
+			wb.Error = err.Error()
 		} else {
-			defer resp.Body.Close()
 			body, _ := io.ReadAll(resp.Body)
-			var balances interface{}
-			json.Unmarshal(body, &balances)
-			prettyJSON, _ := json.MarshalIndent(balances, "  ", "  ")
-			fmt.Printf("\nx402 (AgentWallet - %s):\n  %s\n", cfg.AgentWallet.Username, string(prettyJSON))
+			resp.Body.Close()
+			if json.Valid(body) {
+				wb.Balances = body
+			} else {
+				wb.Balances = json.RawMessage("null")
+			}
 		}
-	} else {
-		fmt.Println("\nx402: not configured")
+		out = append(out, wb)
 	}
 
 	// Check LNbits balance
 	if cfg.LNbits.URL != "" {
+		wb := walletBalance{Protocol: "L402", Wallet: "LNbits"}
 		walURL := fmt.Sprintf("%s/api/v1/wallet", cfg.LNbits.URL)
-		req, _ := http.NewRequest("GET", walURL, nil)
+		req, _ := http.NewRequestWithContext(ctx, "GET", walURL, nil)
 		req.Header.Set("X-Api-Key", cfg.LNbits.AdminKey)
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			fmt.Printf("\nL402 (LNbits): ERROR - %v\n", err)
+			wb.Error = err.Error()
 		} else {
-			defer resp.Body.Close()
 			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
 			var wallet struct {
 				Name    string `json:"name"`
 				Balance int64  `json:"balance"`
 			}
 			json.Unmarshal(body, &wallet)
 			sats := wallet.Balance / 1000 // msats to sats
-			fmt.Printf("\nL402 (LNbits - %s): %d sats\n", wallet.Name, sats)
+			wb.Account, wb.Sats = wallet.Name, &sats
 		}
-	} else {
-		fmt.Println("\nL402: not configured")
+		out = append(out, wb)
 	}
 
-	return nil
+	// Check LND balance
+	if cfg.LND.URL != "" {
+		lnd, err := newLNDProvider(cfg)
+		var msat router.Amount
+		if err == nil {
+			msat, err = lnd.Backend().Balance(ctx)
+		}
+		out = append(out, sats(walletBalance{Protocol: "L402", Wallet: "LND", Account: cfg.LND.URL}, msat, err))
+	}
+
+	// Check Core Lightning: outbound liquidity plus confirmed on-chain funds
+	if cfg.CLN.URL != "" {
+		out = append(out, clnBalance(ctx, cfg))
+	}
+
+	// Check Nostr Wallet Connect
+	if cfg.NWC.URI != "" {
+		nwc, err := newNWCProvider(cfg)
+		var msat router.Amount
+		if err == nil {
+			msat, err = nwc.Backend().Balance(ctx)
+		}
+		out = append(out, sats(walletBalance{Protocol: "L402", Wallet: "NWC"}, msat, err))
+	}
+
+	// Check phoenixd
+	if cfg.Phoenixd.URL != "" {
+		msat, err := newPhoenixdProvider(cfg).Backend().Balance(ctx)
+		out = append(out, sats(walletBalance{Protocol: "L402", Wallet: "phoenixd", Account: cfg.Phoenixd.URL}, msat, err))
+	}
+	return out
+}
+
+func clnBalance(ctx context.Context, cfg *AppConfig) walletBalance {
+	wb := walletBalance{Protocol: "L402", Wallet: "Core Lightning", Account: cfg.CLN.URL}
+	p, err := newCLNProvider(cfg)
+	if err != nil {
+		wb.Error = err.Error()
+		return wb
+	}
+	cln := p.Backend().(*providers.CLNBackend)
+
+	outbound, err := cln.Balance(ctx)
+	if err != nil {
+		wb.Error = err.Error()
+		return wb
+	}
+	sats := outbound.Units / 1000
+	wb.Sats = &sats
+	if onchain, err := cln.OnchainBalance(ctx); err == nil {
+		n := onchain.Units / 1000
+		wb.OnchainSats = &n
+	}
+	return wb
+}
+
+// printBalances writes balances in the balance command's format.
+func printBalances(out io.Writer, balances []walletBalance) {
+	configured := make(map[string]bool)
+	for _, wb := range balances {
+		configured[wb.Protocol] = true
+	}
+	if !configured["x402"] {
+		fmt.Fprintln(out, "\nx402: not configured")
+	}
+
+	for _, wb := range balances {
+		if wb.Error != "" {
+			fmt.Fprintf(out, "\n%s (%s): ERROR - %s\n", wb.Protocol, wb.Wallet, wb.Error)
+			continue
+		}
+		label := wb.Wallet
+		if wb.Account != "" {
+			label += " - " + wb.Account
+		}
+		switch {
+		case wb.Balances != nil:
+			var balances interface{}
+			json.Unmarshal(wb.Balances, &balances)
+			prettyJSON, _ := json.MarshalIndent(balances, "  ", "  ")
+			fmt.Fprintf(out, "\n%s (%s):\n  %s\n", wb.Protocol, label, string(prettyJSON))
+		case wb.Wallet == "Core Lightning":
+			fmt.Fprintf(out, "\n%s (%s): %d sats spendable", wb.Protocol, label, *wb.Sats)
+			if wb.OnchainSats != nil {
+				fmt.Fprintf(out, ", %d sats on-chain", *wb.OnchainSats)
+			}
+			fmt.Fprintln(out)
+		case wb.Sats != nil:
+			fmt.Fprintf(out, "\n%s (%s): %d sats\n", wb.Protocol, label, *wb.Sats)
+		}
+	}
+
+	if !configured["L402"] {
+		fmt.Fprintln(out, "\nL402: not configured")
+	}
 }
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joelklabo/agentpay/router"
+	"github.com/spf13/cobra"
+)
+
+var budgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Show spending limits and what the session has spent",
+	Long: `Shows the per-request and session limits and what has been spent
+against them. With a daemon running, the session is the daemon's and spans
+every invocation since it started. Without one, each invocation is its own
+session, so this shows the configured limits with nothing spent.`,
+	Args: cobra.NoArgs,
+	RunE: runBudget,
+}
+
+var budgetJSON bool
+
+func init() {
+	rootCmd.AddCommand(budgetCmd)
+	budgetCmd.Flags().BoolVar(&budgetJSON, "json", false, "Print the budget as JSON")
+}
+
+func runBudget(cmd *cobra.Command, args []string) error {
+	var res budgetResult
+	err := callDaemon("budget", nil, &res)
+	if errors.Is(err, errNoDaemon) {
+		var cfg *AppConfig
+		if cfg, err = loadConfig(); err != nil {
+			return fmt.Errorf("load config: %w (run 'agentpay init' to set up)", err)
+		}
+		res.Budget = router.New(router.Config{
+			MaxPerRequestUSD: cfg.Budget.MaxPerRequestUSD,
+			MaxSessionUSD:    cfg.Budget.MaxSessionUSD,
+		}).Budget()
+	}
+	if err != nil {
+		return err
+	}
+
+	if budgetJSON {
+		report := struct {
+			budgetReport
+			Daemon bool       `json:"daemon"`
+			Since  *time.Time `json:"since,omitempty"`
+		}{budgetReport: newBudgetReport(res.Budget), Daemon: !res.Since.IsZero()}
+		if report.Daemon {
+			report.Since = &res.Since
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	fmt.Println(budgetSummary(res.Budget))
+	if res.Since.IsZero() {
+		fmt.Println("No daemon running: each invocation starts a fresh session.")
+	} else {
+		fmt.Printf("Session: the daemon's, since %s.\n", res.Since.Local().Format(time.DateTime))
+	}
+	return nil
+}
+
+// checkMaxUSD rejects a spending cap below the smallest amount the router
+// counts, which would otherwise round to zero and lift the limit.
+func checkMaxUSD(name string, usd float64) error {
+	if router.FromUSD(usd).Units <= 0 {
+		return fmt.Errorf("%s must be at least $0.000001, got %v", name, usd)
+	}
+	return nil
+}
+
+// budgetReport is a router.Budget in USD for display.
+type budgetReport struct {
+	MaxPerRequestUSD float64  `json:"max_per_request_usd"`
+	MaxSessionUSD    float64  `json:"max_session_usd"`
+	SpentUSD         float64  `json:"spent_usd"`
+	RemainingUSD     *float64 `json:"remaining_usd,omitempty"`
+	Payments         int      `json:"payments"`
+}
+
+func newBudgetReport(b router.Budget) budgetReport {
+	report := budgetReport{
+		MaxPerRequestUSD: b.MaxPerRequest.Float64(),
+		MaxSessionUSD:    b.MaxSession.Float64(),
+		SpentUSD:         b.Spent.Float64(),
+		Payments:         b.Payments,
+	}
+	if b.Remaining != nil {
+		remaining := b.Remaining.Float64()
+		report.RemainingUSD = &remaining
+	}
+	return report
+}
+
+// budgetSummary describes b in a sentence.
+func budgetSummary(b router.Budget) string {
+	perRequest := "no per-request limit"
+	if !b.MaxPerRequest.IsZero() {
+		perRequest = fmt.Sprintf("$%s per request", b.MaxPerRequest.Decimal())
+	}
+	if b.Remaining == nil {
+		return fmt.Sprintf("Budget: %s; $%s spent this session, no session limit.", perRequest, b.Spent.Decimal())
+	}
+	return fmt.Sprintf("Budget: %s; $%s of $%s spent this session, $%s left.",
+		perRequest, b.Spent.Decimal(), b.MaxSession.Decimal(), b.Remaining.Decimal())
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var cashuCmd = &cobra.Command{
+	Use:   "cashu",
+	Short: "Manage the Cashu ecash wallet for NUT-24 payments",
+}
+
+var cashuBalanceCmd = &cobra.Command{
+	Use:   "balance",
+	Short: "Show the ecash held at the configured mint",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadCashuConfig()
+		if err != nil {
+			return err
+		}
+		unit, _ := cmd.Flags().GetString("unit")
+
+		balance, err := newCashuProvider(cfg).WalletBalance(unit)
+		if err != nil {
+			return err
+		}
+
+		result := map[string]interface{}{
+			"mint":    cfg.Cashu.MintURL,
+			"unit":    unit,
+			"balance": balance,
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	},
+}
+
+var cashuMintCmd = &cobra.Command{
+	Use:   "mint <amount>",
+	Short: "Buy ecash by paying the mint's Lightning invoice from the configured wallet",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadCashuConfig()
+		if err != nil {
+			return err
+		}
+		amount, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %q: %w", args[0], err)
+		}
+		unit, _ := cmd.Flags().GetString("unit")
+
+		p := newCashuProvider(cfg)
+		if err := p.Mint(context.Background(), amount, unit); err != nil {
+			return fmt.Errorf("mint ecash: %w", err)
+		}
+		balance, err := p.WalletBalance(unit)
+		if err != nil {
+			return err
+		}
+
+		result := map[string]interface{}{
+			"mint":    cfg.Cashu.MintURL,
+			"minted":  amount,
+			"unit":    unit,
+			"balance": balance,
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	},
+}
+
+func loadCashuConfig() (*AppConfig, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Cashu.MintURL == "" {
+		return nil, fmt.Errorf("no Cashu mint configured (set cashu.mint_url in %s)", configPath())
+	}
+	return cfg, nil
+}
+
+func init() {
+	cashuBalanceCmd.Flags().String("unit", "sat", "ecash unit")
+	cashuMintCmd.Flags().String("unit", "sat", "ecash unit")
+
+	cashuCmd.AddCommand(cashuBalanceCmd)
+	cashuCmd.AddCommand(cashuMintCmd)
+
+	rootCmd.AddCommand(cashuCmd)
+}
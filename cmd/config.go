@@ -11,8 +11,17 @@ import (
 type AppConfig struct {
 	AgentWallet AgentWalletConfig `json:"agent_wallet"`
 	LNbits      LNbitsConfig      `json:"lnbits"`
+	LND         LNDConfig         `json:"lnd"`
+	CLN         CLNConfig         `json:"cln"`
+	NWC         NWCConfig         `json:"nwc"`
+	Phoenixd    PhoenixdConfig    `json:"phoenixd"`
+	CDP         CDPConfig         `json:"cdp"`
+	EVMKey      EVMKeyConfig      `json:"evm_key"`
+	SVMKey      SVMKeyConfig      `json:"svm_key"`
+	Cashu       CashuConfig       `json:"cashu"`
 	WoT         WoTConfig         `json:"wot"`
 	Budget      BudgetConfig      `json:"budget"`
+	Routing     RoutingConfig     `json:"routing"`
 }
 
 // AgentWalletConfig holds AgentWallet (x402/Solana) settings.
@@ -21,12 +30,82 @@ type AgentWalletConfig struct {
 	Username       string `json:"username"`
 	Token          string `json:"token"`
 	PreferredChain string `json:"preferred_chain"` // "evm", "solana", "auto"
+	Priority       int    `json:"priority,omitempty"`
 }
 
 // LNbitsConfig holds LNbits (Lightning/L402) settings.
 type LNbitsConfig struct {
 	URL      string `json:"url"`
 	AdminKey string `json:"admin_key"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// LNDConfig connects an LND node over REST as an L402 (Lightning) backend.
+type LNDConfig struct {
+	URL            string `json:"url,omitempty"`             // REST endpoint, e.g. "https://localhost:8080"
+	Macaroon       string `json:"macaroon,omitempty"`        // path to admin.macaroon (binary or hex)
+	TLSCert        string `json:"tls_cert,omitempty"`        // path to tls.cert
+	MaxFeeSat      int64  `json:"max_fee_sat,omitempty"`     // routing fee cap; default 1% of the amount
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // default 60
+	Priority       int    `json:"priority,omitempty"`
+}
+
+// CLNConfig connects a Core Lightning node through the CLNRest plugin as an
+// L402 (Lightning) backend.
+type CLNConfig struct {
+	URL             string  `json:"url,omitempty"`               // CLNRest endpoint, e.g. "https://localhost:3010"
+	Rune            string  `json:"rune,omitempty"`              // from `lightning-cli createrune`
+	TLSCert         string  `json:"tls_cert,omitempty"`          // path to the plugin's ca.pem
+	MaxFeePercent   float64 `json:"max_fee_percent,omitempty"`   // default 0.5
+	RetryForSeconds int     `json:"retry_for_seconds,omitempty"` // default 60
+	Priority        int     `json:"priority,omitempty"`
+}
+
+// NWCConfig connects a Nostr Wallet Connect (NIP-47) wallet as an L402
+// (Lightning) backend.
+type NWCConfig struct {
+	URI            string `json:"uri,omitempty"`             // nostr+walletconnect://...
+	Encryption     string `json:"encryption,omitempty"`      // "nip44_v2" or "nip04"; default detected
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // default 60
+	Priority       int    `json:"priority,omitempty"`
+}
+
+// PhoenixdConfig connects a phoenixd node as an L402 (Lightning) backend.
+type PhoenixdConfig struct {
+	URL      string `json:"url,omitempty"`      // HTTP API, e.g. "http://localhost:9740"
+	Password string `json:"password,omitempty"` // http-password from phoenix.conf
+	Priority int    `json:"priority,omitempty"`
+}
+
+// CDPConfig enables the Coinbase CDP wallet as an x402 provider. Credentials
+// come from the CDP_* environment variables.
+type CDPConfig struct {
+	Wallet   string `json:"wallet,omitempty"` // CDP account name, e.g. "agentpay"
+	Priority int    `json:"priority,omitempty"`
+}
+
+// EVMKeyConfig enables a local keystore as a self-custodial x402 signer on
+// EVM chains. The password comes from AGENTPAY_KEYSTORE_PASSWORD.
+type EVMKeyConfig struct {
+	Keystore string `json:"keystore,omitempty"` // Web3 Secret Storage (v3) file
+	Priority int    `json:"priority,omitempty"`
+}
+
+// SVMKeyConfig enables a Solana CLI keypair file as a self-custodial signer
+// for x402 and Solana Pay on Solana.
+type SVMKeyConfig struct {
+	Keypair  string            `json:"keypair,omitempty"` // Solana CLI keypair JSON
+	RPC      map[string]string `json:"rpc,omitempty"`     // cluster -> RPC URL, e.g. {"devnet": "..."}
+	Priority int               `json:"priority,omitempty"`
+}
+
+// CashuConfig holds Cashu ecash (NUT-24) settings. The wallet is topped up
+// over Lightning through the first configured Lightning backend (LNbits, LND,
+// Core Lightning, NWC or phoenixd, in that order).
+type CashuConfig struct {
+	MintURL    string `json:"mint_url,omitempty"`
+	WalletFile string `json:"wallet_file,omitempty"` // defaults to cashu.json next to the config
+	Priority   int    `json:"priority,omitempty"`
 }
 
 // WoTConfig holds Web of Trust scoring settings.
@@ -39,6 +118,16 @@ type WoTConfig struct {
 type BudgetConfig struct {
 	MaxPerRequestUSD float64 `json:"max_per_request_usd"`
 	MaxSessionUSD    float64 `json:"max_session_usd"`
+	// LowBalanceUSD warns when a wallet drops below this amount. Zero disables.
+	LowBalanceUSD float64 `json:"low_balance_usd,omitempty"`
+}
+
+// RoutingConfig controls how the router picks between payment options when a
+// server offers several rails.
+type RoutingConfig struct {
+	Strategy       string   `json:"strategy,omitempty"`        // "cheapest", "preferred", "fastest", "balance"
+	PreferredRails []string `json:"preferred_rails,omitempty"` // e.g. ["lightning", "base", "solana"]
+	Discover       bool     `json:"discover,omitempty"`        // consult /.well-known/agentpay before paying
 }
 
 func loadConfig() (*AppConfig, error) {
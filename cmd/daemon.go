@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/joelklabo/agentpay/router"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Share one router between CLI invocations over a local socket",
+	Long: `Holds one long-lived router and serves it on a Unix domain socket, so
+the session budget, receipts and cached wallet balances carry across CLI
+invocations. While it runs, 'fetch', 'pay', 'balance', 'receipts'
+and 'budget' go through it; otherwise they run in-process.
+
+fetch and pay still run in-process when given flags that configure a router
+of their own (--strategy, --discover, --wot, or pay's --receipts). Their
+--budget can only lower the daemon's per-request limit.
+
+The socket is agentpay.sock next to the config, or $AGENTPAY_SOCKET. Pass
+--no-daemon to any command to bypass it.`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+var (
+	daemonSocketFlag string
+	daemonBudget     float64
+	daemonWoT        bool
+	daemonStrategy   string
+	daemonDiscover   bool
+	daemonReceipts   string
+
+	noDaemon bool
+)
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.PersistentFlags().BoolVar(&noDaemon, "no-daemon", false, "Run in-process even if an agentpay daemon is running")
+	daemonCmd.Flags().StringVar(&daemonSocketFlag, "socket", "", "Socket path (default agentpay.sock next to the config, or $AGENTPAY_SOCKET, which clients also read)")
+	daemonCmd.Flags().Float64Var(&daemonBudget, "budget", 0, "Maximum USD per request (default: the config's); the session may spend ten times this")
+	daemonCmd.Flags().BoolVar(&daemonWoT, "wot", false, "Enable Web of Trust trust scoring before payments")
+	daemonCmd.Flags().StringVar(&daemonStrategy, "strategy", "", "Routing strategy: cheapest, preferred, fastest, balance")
+	daemonCmd.Flags().BoolVar(&daemonDiscover, "discover", false, "Check services' /.well-known/agentpay prices and payee pins before paying")
+	daemonCmd.Flags().StringVar(&daemonReceipts, "receipts", "", "File to append receipts to (default receipts.jsonl next to the config)")
+}
+
+// daemonSocket returns the path the daemon listens on.
+func daemonSocket() string {
+	if p := os.Getenv("AGENTPAY_SOCKET"); p != "" {
+		return p
+	}
+	return filepath.Join(filepath.Dir(configPath()), "agentpay.sock")
+}
+
+// The daemon protocol is one JSON request and one JSON response per
+// connection, each on its own line.
+type daemonRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type daemonResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// fetchRequest is a request for agentpay fetch to make through the daemon.
+type fetchRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Body    string            `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	DryRun  bool              `json:"dry_run,omitempty"`
+	// MaxUSD lowers the router's per-request limit; zero leaves it.
+	MaxUSD float64 `json:"max_usd,omitempty"`
+}
+
+type fetchResult struct {
+	Body    []byte          `json:"body"`
+	Receipt *router.Receipt `json:"receipt,omitempty"`
+}
+
+// receiptsResult is the daemon's answer to receipts.
+type receiptsResult struct {
+	Since    time.Time        `json:"since"`
+	Receipts []router.Receipt `json:"receipts"`
+}
+
+// budgetResult is the daemon's answer to budget.
+type budgetResult struct {
+	Budget router.Budget `json:"budget"`
+	Since  time.Time     `json:"since"`
+}
+
+// Daemon connections time out rather than hang: dialing and each side's
+// reading or writing of one request must finish within daemonIOTimeout, and a
+// client waits up to daemonCallTimeout for the payment behind its answer.
+const (
+	daemonIOTimeout   = 10 * time.Second
+	daemonCallTimeout = 5 * time.Minute
+)
+
+// errNoDaemon reports that no daemon is listening, so the caller should run
+// in-process.
+var errNoDaemon = errors.New("no agentpay daemon running")
+
+// callDaemon sends method with params to the daemon and decodes its result
+// into result. It returns errNoDaemon if none is listening or --no-daemon
+// is set.
+func callDaemon(method string, params, result any) error {
+	if noDaemon {
+		return errNoDaemon
+	}
+	conn, err := net.DialTimeout("unix", daemonSocket(), daemonIOTimeout)
+	if err != nil {
+		return errNoDaemon
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(daemonCallTimeout))
+
+	req := daemonRequest{Method: method}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+	data, _ := json.Marshal(req)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	var resp daemonResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	if result != nil {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w (run 'agentpay init' to set up)", err)
+	}
+	rc := router.Config{
+		MaxPerRequestUSD: cfg.Budget.MaxPerRequestUSD,
+		MaxSessionUSD:    cfg.Budget.MaxSessionUSD,
+		Strategy:         router.Strategy(daemonStrategy),
+		Discover:         daemonDiscover,
+	}
+	if cmd.Flags().Changed("budget") {
+		if err := checkMaxUSD("--budget", daemonBudget); err != nil {
+			return err
+		}
+		rc.MaxPerRequestUSD = daemonBudget
+		rc.MaxSessionUSD = daemonBudget * 10
+	}
+	r, err := newRouter(cfg, rc)
+	if err != nil {
+		return err
+	}
+	if daemonWoT {
+		r.SetWoTChecker(router.NewWoTChecker("https://maximumsats.joel-dfd.workers.dev/wot/score"))
+	}
+
+	receiptsPath := daemonReceipts
+	if receiptsPath == "" {
+		receiptsPath = defaultReceiptsPath()
+	}
+	ledger, err := openReceiptLog(receiptsPath)
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+
+	path := daemonSocketFlag
+	if path == "" {
+		path = daemonSocket()
+	}
+	ln, err := listenDaemon(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	d := &daemon{
+		router:   r,
+		balances: func(ctx context.Context) []walletBalance { return walletBalances(ctx, cfg) },
+		ledger:   ledger,
+		started:  time.Now(),
+	}
+	log.Printf("agentpay daemon listening on %s", path)
+	log.Printf("Budget: $%.2f per request, $%.2f per session", rc.MaxPerRequestUSD, rc.MaxSessionUSD)
+	log.Printf("Receipts: %s", receiptsPath)
+	err = d.serve(ctx, ln)
+	log.Printf("agentpay daemon stopped; spent %s over %d payments", r.SessionSpend(), len(r.Receipts()))
+	return err
+}
+
+// listenDaemon listens on the Unix socket at path, replacing a stale socket
+// left by a daemon that exited without cleaning up.
+//
+// Anyone who can connect can spend, so the socket is created in a private
+// directory, restricted to the owner and only then moved to path.
+func listenDaemon(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, daemonIOTimeout); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create socket dir: %w", err)
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".agentpay-sock-")
+	if err != nil {
+		return nil, fmt.Errorf("create socket dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "agentpay.sock")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	os.Remove(path)
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return &socketListener{Listener: ln, path: path}, nil
+}
+
+// socketListener removes its socket when closed, which the listener can't
+// do itself once the socket has moved.
+type socketListener struct {
+	net.Listener
+	path string
+}
+
+func (l *socketListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// daemon serves one router to CLI invocations.
+type daemon struct {
+	router   *router.Router
+	balances func(ctx context.Context) []walletBalance
+	ledger   *receiptLog // nil keeps receipts in memory only
+	started  time.Time
+}
+
+// serve accepts connections on ln until ctx is done, then closes ln and
+// the open connections and waits for their handlers to return.
+func (d *daemon) serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (d *daemon) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var resp daemonResponse
+	conn.SetDeadline(time.Now().Add(daemonIOTimeout))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	var req daemonRequest
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	var result any
+	if err == nil {
+		result, err = d.handle(ctx, req)
+	}
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	data, _ := json.Marshal(resp)
+	conn.SetDeadline(time.Now().Add(daemonIOTimeout))
+	conn.Write(append(data, '\n'))
+}
+
+func (d *daemon) handle(ctx context.Context, req daemonRequest) (any, error) {
+	params := func(v any) error {
+		if len(req.Params) == 0 {
+			return fmt.Errorf("%s: missing params", req.Method)
+		}
+		return json.Unmarshal(req.Params, v)
+	}
+
+	switch req.Method {
+	case "fetch":
+		var p fetchRequest
+		if err := params(&p); err != nil {
+			return nil, err
+		}
+		return d.fetch(ctx, p)
+	case "pay":
+		var p payRequest
+		if err := params(&p); err != nil {
+			return nil, err
+		}
+		return payTarget(ctx, d.router, p, d.ledger)
+	case "balance":
+		return d.balances(ctx), nil
+	case "receipts":
+		return receiptsResult{Since: d.started, Receipts: d.router.Receipts()}, nil
+	case "budget":
+		return budgetResult{Budget: d.router.Budget(), Since: d.started}, nil
+	}
+	return nil, fmt.Errorf("unknown method %q", req.Method)
+}
+
+func (d *daemon) fetch(ctx context.Context, p fetchRequest) (*fetchResult, error) {
+	if p.DryRun {
+		ctx = router.WithDryRun(ctx)
+	}
+	if p.MaxUSD != 0 {
+		if err := checkMaxUSD("max_usd", p.MaxUSD); err != nil {
+			return nil, err
+		}
+		ctx = router.WithMaxCost(ctx, router.FromUSD(p.MaxUSD))
+	}
+	var body io.Reader
+	if p.Body != "" {
+		body = strings.NewReader(p.Body)
+	}
+
+	respBody, receipt, err := d.router.Fetch(ctx, p.Method, p.URL, body, p.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: %w", err)
+	}
+	if receipt != nil && !p.DryRun && d.ledger != nil {
+		if err := d.ledger.Append(*receipt); err != nil {
+			log.Printf("write receipt: %v", err)
+		}
+	}
+	return &fetchResult{Body: respBody, Receipt: receipt}, nil
+}
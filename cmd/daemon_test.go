@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+func TestDaemon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " ok"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "agentpay.sock")
+	t.Setenv("AGENTPAY_SOCKET", path)
+	if err := callDaemon("budget", nil, nil); !errors.Is(err, errNoDaemon) {
+		t.Fatalf("no daemon: got %v", err)
+	}
+
+	ln, err := listenDaemon(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenDaemon(path); err == nil {
+		t.Error("second daemon listened on the same socket")
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v; want 0600", fi.Mode().Perm(), err)
+	}
+	sats := int64(2100)
+	d := &daemon{
+		router: router.New(router.Config{MaxPerRequestUSD: 1, MaxSessionUSD: 10}),
+		balances: func(context.Context) []walletBalance {
+			return []walletBalance{{Protocol: "L402", Wallet: "NWC", Sats: &sats}}
+		},
+		started: time.Now(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- d.serve(ctx, ln) }()
+
+	var fetched fetchResult
+	if err := callDaemon("fetch", fetchRequest{Method: "POST", URL: srv.URL}, &fetched); err != nil {
+		t.Fatal(err)
+	}
+	if string(fetched.Body) != "POST ok" || fetched.Receipt != nil {
+		t.Errorf("fetch = %+v", fetched)
+	}
+
+	var budget budgetResult
+	if err := callDaemon("budget", nil, &budget); err != nil {
+		t.Fatal(err)
+	}
+	if budget.Budget.MaxSession != router.FromUSD(10) || budget.Since.IsZero() {
+		t.Errorf("budget = %+v", budget)
+	}
+
+	var balances []walletBalance
+	if err := callDaemon("balance", nil, &balances); err != nil || len(balances) != 1 || *balances[0].Sats != 2100 {
+		t.Errorf("balance = %+v, %v", balances, err)
+	}
+
+	var receipts receiptsResult
+	if err := callDaemon("receipts", nil, &receipts); err != nil || len(receipts.Receipts) != 0 {
+		t.Errorf("receipts = %+v, %v", receipts, err)
+	}
+
+	// Errors come back as the command's error
+	err = callDaemon("pay", payRequest{Target: "hello"}, nil)
+	if err == nil || errors.Is(err, errNoDaemon) || !strings.Contains(err.Error(), "don't know how to pay") {
+		t.Errorf("pay error = %v", err)
+	}
+	// A cap below a micro-dollar would round to no cap at all
+	err = callDaemon("fetch", fetchRequest{Method: "GET", URL: srv.URL, MaxUSD: 0.0000001}, nil)
+	if err == nil || !strings.Contains(err.Error(), "max_usd") {
+		t.Errorf("tiny max_usd error = %v", err)
+	}
+	if err := callDaemon("nope", nil, nil); err == nil || !strings.Contains(err.Error(), "unknown method") {
+		t.Errorf("unknown method error = %v", err)
+	}
+
+	// A client that never sends its request doesn't hold up shutdown
+	idle, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("daemon did not stop with a connection open")
+	}
+	if err := callDaemon("budget", nil, nil); !errors.Is(err, errNoDaemon) {
+		t.Errorf("stopped daemon still answers: %v", err)
+	}
+}
+
+func TestReadReceipts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "receipts.jsonl")
+	if receipts, err := readReceipts(path); err != nil || receipts != nil {
+		t.Fatalf("missing ledger = %v, %v", receipts, err)
+	}
+
+	ledger, err := openReceiptLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{"https://a.example", "https://b.example"} {
+		ledger.Append(router.Receipt{URL: url, Protocol: "x402", Cost: router.FromUSD(0.01), USDCost: 0.01})
+	}
+	ledger.Close()
+
+	receipts, err := readReceipts(path)
+	if err != nil || len(receipts) != 2 || receipts[1].URL != "https://b.example" || receipts[0].Cost != router.FromUSD(0.01) {
+		t.Fatalf("receipts = %+v, %v", receipts, err)
+	}
+	var out strings.Builder
+	printReceipts(&out, receipts)
+	if !strings.Contains(out.String(), "https://b.example") || !strings.Contains(out.String(), "Total shown: $0.0200") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,702 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/base58"
+	"github.com/joelklabo/agentpay/internal/bolt11"
+	"github.com/joelklabo/agentpay/internal/bolt12"
+	"github.com/joelklabo/agentpay/internal/keystore"
+	"github.com/joelklabo/agentpay/internal/macaroon"
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(decodeCmd)
+	decodeCmd.AddCommand(decodeMacaroonCmd)
+	decodeCmd.Flags().BoolVar(&decodeVerify, "verify", false, "Check signatures, hashes and expiry offline")
+	decodeCmd.Flags().StringVar(&decodePreimage, "preimage", "", "Preimage (hex) to check against an invoice's or macaroon's payment hash")
+	decodeCmd.Flags().StringVar(&decodeRootKey, "root-key", "", "Root key (hex) to check a macaroon's signature")
+	decodeCmd.Flags().StringVar(&decodeRequirement, "requirement", "", "x402 Payment-Required to check an x402 payment against")
+}
+
+var (
+	decodeVerify      bool
+	decodePreimage    string
+	decodeRootKey     string
+	decodeRequirement string
+)
+
+var decodeCmd = &cobra.Command{
+	Use:   "decode <blob|->",
+	Short: "Decode payment artifacts",
+	Long: `Recognizes a payment artifact and prints it as JSON:
+
+  BOLT11 invoices and BOLT12 offers
+  L402 macaroons, credentials ("L402 <macaroon>:<preimage>") and
+    WWW-Authenticate challenges
+  x402 Payment-Required challenges, X-PAYMENT / Payment-Signature payloads
+    (with the EIP-712 authorization they sign) and settlement responses
+  Solana Pay URIs, Cashu payment requests, LNURLs and Lightning addresses
+
+Blobs may be base64 or JSON, and may keep their "Header-Name:" prefix.
+Pass - to read the blob from stdin.
+
+With --verify, checks what can be checked offline: invoice signatures and
+expiry, preimages against payment hashes, macaroon signatures (with
+--root-key), x402 payment signatures and validity windows, and address
+encodings. It exits non-zero if any check fails.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDecode,
+}
+
+var decodeMacaroonCmd = &cobra.Command{
+	Use:   "macaroon <macaroon|L402 credential>",
+	Short: "Show the identifier and caveats of an L402 macaroon",
+	Long: `Decodes a v2 binary macaroon given as base64 or hex, or the macaroon of an
+"L402 <macaroon>:<preimage>" credential, and prints its location, L402
+identifier, caveats and signature. The signature cannot be checked without
+the issuer's root key.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := macaroon.Decode(macaroonArg(args[0]))
+		if err != nil {
+			return fmt.Errorf("decode macaroon: %w", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(describeMacaroon(m))
+	},
+}
+
+// verifyCheck is the outcome of one --verify check.
+type verifyCheck struct {
+	Check  string `json:"check"`
+	Result string `json:"result"` // "ok", "failed" or "skipped"
+	Detail string `json:"detail,omitempty"`
+}
+
+func passed(name, detail string) verifyCheck  { return verifyCheck{name, "ok", detail} }
+func failed(name, detail string) verifyCheck  { return verifyCheck{name, "failed", detail} }
+func skipped(name, detail string) verifyCheck { return verifyCheck{name, "skipped", detail} }
+
+// artifact is a decoded payment artifact. verify, if set, runs the offline
+// checks for --verify.
+type artifact struct {
+	fields map[string]interface{}
+	verify func() []verifyCheck
+}
+
+func runDecode(cmd *cobra.Command, args []string) error {
+	blob := args[0]
+	if blob == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		blob = string(data)
+	}
+
+	a, err := decodeArtifact(blob)
+	if err != nil {
+		return err
+	}
+	out := a.fields
+	var checks []verifyCheck
+	if decodeVerify {
+		if a.verify != nil {
+			checks = a.verify()
+		}
+		if len(checks) == 0 {
+			checks = []verifyCheck{skipped("verify", "nothing to check offline")}
+		}
+		out["verify"] = checks
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	for _, c := range checks {
+		if c.Result == "failed" {
+			cmd.SilenceUsage = true // the input was fine; it just didn't verify
+			return fmt.Errorf("verification failed: %s", c.Check)
+		}
+	}
+	return nil
+}
+
+// decodeArtifact recognizes blob and decodes it.
+func decodeArtifact(blob string) (*artifact, error) {
+	s := stripHeaderName(strings.TrimSpace(blob))
+	lower := strings.ToLower(s)
+	bare := strings.TrimPrefix(lower, "lightning:")
+
+	switch {
+	case strings.HasPrefix(lower, "l402 ") || strings.HasPrefix(lower, "lsat "):
+		if strings.Contains(s, "macaroon=") || strings.Contains(s, "invoice=") {
+			return decodeL402Challenge(s)
+		}
+		return decodeL402Credential(s)
+	case strings.HasPrefix(bare, "lnurl1"), strings.HasPrefix(bare, "lnurlp://"),
+		!strings.ContainsAny(s, "{}") && router.IsLNURLPay(s):
+		return decodeLNURL(s)
+	case bolt12.IsOffer(s):
+		return decodeOffer(s)
+	case strings.HasPrefix(bare, "ln"):
+		return decodeInvoice(s)
+	case strings.HasPrefix(lower, "solana:"):
+		return decodeSolanaPay(s)
+	case strings.HasPrefix(s, "creqA"):
+		return decodeCashuRequest(s)
+	}
+
+	if obj, raw, ok := jsonObject(s); ok {
+		switch {
+		case obj["accepts"] != nil:
+			return decodePaymentRequired(obj), nil
+		case obj["payload"] != nil:
+			return decodeX402Payment(obj, raw)
+		case obj["success"] != nil || obj["transaction"] != nil:
+			return decodeSettlement(obj), nil
+		}
+		return nil, errors.New("unrecognized JSON: not an x402 requirement, payment or settlement response")
+	}
+
+	if m, err := macaroon.Decode(s); err == nil {
+		return macaroonArtifact(m, ""), nil
+	}
+	return nil, errors.New("unrecognized payment artifact")
+}
+
+// stripHeaderName removes a "Header-Name:" prefix copied along with a header
+// value.
+func stripHeaderName(s string) string {
+	name, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return s
+	}
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "payment-required", "x-payment-required", "x-payment", "payment-signature", "payment",
+		"x-payment-response", "payment-response", "authorization", "www-authenticate":
+		return strings.TrimSpace(value)
+	}
+	return s
+}
+
+// jsonObject parses s as a JSON object, directly or after base64 decoding.
+func jsonObject(s string) (map[string]interface{}, []byte, bool) {
+	raw := []byte(s)
+	if !strings.HasPrefix(s, "{") {
+		var err error
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if raw, err = enc.DecodeString(s); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, nil, false
+		}
+	}
+	var obj map[string]interface{}
+	if json.Unmarshal(raw, &obj) != nil {
+		return nil, nil, false
+	}
+	return obj, raw, true
+}
+
+func decodeInvoice(s string) (*artifact, error) {
+	inv, err := bolt11.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	fields := describeInvoice(inv)
+	fields["type"] = "bolt11"
+	return &artifact{fields: fields, verify: func() []verifyCheck {
+		checks := invoiceChecks(inv)
+		if decodePreimage != "" {
+			checks = append(checks, preimageCheck(decodePreimage, inv.PaymentHash))
+		}
+		return checks
+	}}, nil
+}
+
+// invoiceChecks reports the signature check bolt11.Decode already made and
+// whether the invoice has expired.
+func invoiceChecks(inv *bolt11.Invoice) []verifyCheck {
+	checks := []verifyCheck{passed("invoice signature", "signed by "+inv.Payee)}
+	if expires := inv.ExpiresAt(); time.Now().After(expires) {
+		checks = append(checks, failed("invoice expiry", "expired at "+expires.UTC().Format(time.RFC3339)))
+	} else {
+		checks = append(checks, passed("invoice expiry", "expires at "+expires.UTC().Format(time.RFC3339)))
+	}
+	return checks
+}
+
+// preimageCheck checks that the hex preimage hashes to the hex paymentHash.
+func preimageCheck(preimage, paymentHash string) verifyCheck {
+	b, err := hex.DecodeString(preimage)
+	if err != nil || len(b) != 32 {
+		return failed("preimage", "not 32 bytes of hex")
+	}
+	sum := sha256.Sum256(b)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(paymentHash) {
+		return failed("preimage", "does not hash to the payment hash")
+	}
+	return passed("preimage", "hashes to the payment hash")
+}
+
+func decodeOffer(s string) (*artifact, error) {
+	o, err := bolt12.DecodeOffer(s)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{
+		"type":     "bolt12_offer",
+		"offer_id": o.ID,
+	}
+	if o.Currency != "" {
+		fields["currency"] = o.Currency
+		fields["amount"] = o.Amount
+	} else if o.Amount > 0 {
+		fields["amount_msat"] = o.Amount
+	}
+	for k, v := range map[string]string{"description": o.Description, "issuer": o.Issuer, "issuer_id": o.IssuerID} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	if len(o.Chains) > 0 {
+		fields["chains"] = o.Chains
+	}
+	fields["blinded_paths"] = o.HasPaths
+	if !o.Expiry.IsZero() {
+		fields["expires_at"] = o.Expiry.UTC().Format(time.RFC3339)
+	}
+	return &artifact{fields: fields, verify: func() []verifyCheck {
+		if o.Expiry.IsZero() {
+			return nil
+		}
+		if time.Now().After(o.Expiry) {
+			return []verifyCheck{failed("offer expiry", "expired")}
+		}
+		return []verifyCheck{passed("offer expiry", "")}
+	}}, nil
+}
+
+func decodeLNURL(s string) (*artifact, error) {
+	target := strings.TrimSpace(s)
+	if len(target) > 10 && strings.EqualFold(target[:10], "lightning:") {
+		target = target[10:]
+	}
+	u, err := providers.LNURLPayURL(target)
+	if err != nil {
+		return nil, err
+	}
+	kind := "lnurl"
+	if strings.Contains(target, "@") {
+		kind = "lightning_address"
+	}
+	return &artifact{
+		fields: map[string]interface{}{"type": kind, "target": target, "url": u},
+		verify: func() []verifyCheck {
+			if kind == "lnurl" && strings.HasPrefix(strings.ToLower(target), "lnurl1") {
+				return []verifyCheck{passed("bech32 checksum", "")}
+			}
+			return nil
+		},
+	}, nil
+}
+
+func decodeL402Challenge(s string) (*artifact, error) {
+	params := map[string]string{}
+	for _, part := range strings.Split(s[5:], ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			params[strings.ToLower(k)] = strings.Trim(v, `"`)
+		}
+	}
+	fields := map[string]interface{}{"type": "l402_challenge"}
+	var mac *macaroon.Macaroon
+	if token := params["macaroon"]; token != "" {
+		m, err := macaroon.Decode(token)
+		if err != nil {
+			return nil, fmt.Errorf("decode macaroon: %w", err)
+		}
+		mac = m
+		fields["macaroon"] = describeMacaroon(m)
+	}
+	var inv *bolt11.Invoice
+	if bolt := params["invoice"]; bolt != "" {
+		i, err := bolt11.Decode(bolt)
+		if err != nil {
+			return nil, err
+		}
+		inv = i
+		fields["invoice"] = describeInvoice(i)
+	}
+	if mac == nil && inv == nil {
+		return nil, errors.New("L402 challenge has neither macaroon nor invoice")
+	}
+	return &artifact{fields: fields, verify: func() []verifyCheck {
+		var checks []verifyCheck
+		if inv != nil {
+			checks = append(checks, invoiceChecks(inv)...)
+		}
+		if mac != nil {
+			checks = append(checks, macaroonChecks(mac, decodePreimage)...)
+			if inv != nil {
+				checks = append(checks, paymentHashCheck(mac, inv.PaymentHash))
+			}
+		}
+		return checks
+	}}, nil
+}
+
+func decodeL402Credential(s string) (*artifact, error) {
+	token, preimage, _ := strings.Cut(strings.TrimSpace(s[5:]), ":")
+	m, err := macaroon.Decode(token)
+	if err != nil {
+		return nil, fmt.Errorf("decode macaroon: %w", err)
+	}
+	a := macaroonArtifact(m, preimage)
+	a.fields["type"] = "l402_credential"
+	if preimage != "" {
+		a.fields["preimage"] = preimage
+	}
+	return a, nil
+}
+
+func macaroonArtifact(m *macaroon.Macaroon, preimage string) *artifact {
+	fields := describeMacaroon(m)
+	fields["type"] = "macaroon"
+	return &artifact{fields: fields, verify: func() []verifyCheck {
+		if preimage == "" {
+			preimage = decodePreimage
+		}
+		return macaroonChecks(m, preimage)
+	}}
+}
+
+// macaroonChecks checks the macaroon's signature if --root-key is given, and
+// preimage against its L402 payment hash if there is one.
+func macaroonChecks(m *macaroon.Macaroon, preimage string) []verifyCheck {
+	var checks []verifyCheck
+	if decodeRootKey == "" {
+		checks = append(checks, skipped("macaroon signature", "needs the issuer's --root-key"))
+	} else if key, err := hex.DecodeString(decodeRootKey); err != nil {
+		checks = append(checks, failed("macaroon signature", "--root-key is not hex"))
+	} else if err := m.Verify(key, func(string) error { return nil }); err != nil {
+		checks = append(checks, failed("macaroon signature", err.Error()))
+	} else {
+		checks = append(checks, passed("macaroon signature", "signed with the root key"))
+	}
+
+	if preimage != "" {
+		if id, err := macaroon.DecodeIdentifier(m.ID); err == nil {
+			checks = append(checks, preimageCheck(preimage, hex.EncodeToString(id.PaymentHash[:])))
+		} else {
+			checks = append(checks, skipped("preimage", "macaroon has no L402 identifier"))
+		}
+	}
+	return checks
+}
+
+// paymentHashCheck checks that a challenge's macaroon is bound to its
+// invoice.
+func paymentHashCheck(m *macaroon.Macaroon, paymentHash string) verifyCheck {
+	id, err := macaroon.DecodeIdentifier(m.ID)
+	if err != nil {
+		return skipped("macaroon payment hash", "macaroon has no L402 identifier")
+	}
+	if hex.EncodeToString(id.PaymentHash[:]) != strings.ToLower(paymentHash) {
+		return failed("macaroon payment hash", "does not match the invoice")
+	}
+	return passed("macaroon payment hash", "matches the invoice")
+}
+
+func decodePaymentRequired(obj map[string]interface{}) *artifact {
+	obj["type"] = "x402_payment_required"
+	return &artifact{fields: obj, verify: func() []verifyCheck {
+		data, _ := json.Marshal(obj)
+		var req router.X402Requirement
+		json.Unmarshal(data, &req)
+		var checks []verifyCheck
+		for i, a := range req.Accepts {
+			name := fmt.Sprintf("accepts[%d]", i)
+			if _, err := router.ParseUnits(a.MaxAmountRequired, router.USDC); err != nil {
+				checks = append(checks, failed(name+" amount", err.Error()))
+			}
+			for _, addr := range []struct{ field, value string }{{"payTo", a.PayTo}, {"asset", a.Asset}} {
+				if addr.value != "" {
+					checks = append(checks, addressCheck(name+" "+addr.field, a.Network, addr.value))
+				}
+			}
+		}
+		return checks
+	}}
+}
+
+// addressCheck checks that addr is well formed for network: a 20-byte hex
+// address with a valid EIP-55 checksum if it is mixed case on EVM chains, or
+// a base58 32-byte key on Solana.
+func addressCheck(name, network, addr string) verifyCheck {
+	switch {
+	case strings.HasPrefix(network, "eip155:"):
+		b, err := hex.DecodeString(strings.TrimPrefix(addr, "0x"))
+		if err != nil || len(b) != 20 || !strings.HasPrefix(addr, "0x") {
+			return failed(name, addr+" is not an EVM address")
+		}
+		body := addr[2:]
+		if body == strings.ToLower(body) || body == strings.ToUpper(body) {
+			return passed(name, "valid address (no checksum)")
+		}
+		if keystore.ChecksumAddress(addr) != addr {
+			return failed(name, addr+" has a bad EIP-55 checksum")
+		}
+		return passed(name, "valid EIP-55 checksum")
+	case strings.HasPrefix(network, "solana"):
+		if b, err := base58.Decode(addr); err != nil || len(b) != 32 {
+			return failed(name, addr+" is not a Solana public key")
+		}
+		return passed(name, "valid Solana public key")
+	}
+	return skipped(name, "unknown network "+network)
+}
+
+func decodeX402Payment(obj map[string]interface{}, raw []byte) (*artifact, error) {
+	obj["type"] = "x402_payment"
+	p, err := providers.DecodeX402Payment(base64.StdEncoding.EncodeToString(raw))
+	if err != nil {
+		// Solana payments carry a signed transaction, not an authorization
+		return &artifact{fields: obj}, nil
+	}
+
+	accept, domainSource := paymentAccept(p)
+	auth := map[string]interface{}{
+		"from":        p.From,
+		"to":          p.To,
+		"value":       p.Value,
+		"validAfter":  p.ValidAfter,
+		"validBefore": p.ValidBefore,
+		"nonce":       p.Nonce,
+	}
+	if before, err := strconv.ParseInt(p.ValidBefore, 10, 64); err == nil {
+		auth["validBeforeTime"] = time.Unix(before, 0).UTC().Format(time.RFC3339)
+	}
+	if value, err := router.ParseUnits(p.Value, router.USDC); err == nil {
+		auth["amount"] = value.String()
+	}
+	obj["authorization"] = auth
+	if accept != nil {
+		obj["eip712"] = p.TypedData(accept)
+		obj["eip712_domain_source"] = domainSource
+	}
+
+	return &artifact{fields: obj, verify: func() []verifyCheck {
+		var checks []verifyCheck
+		if accept == nil {
+			checks = append(checks, skipped("payment signature", "token contract unknown: pass --requirement"))
+		} else if signer, err := p.Signer(accept); err != nil {
+			checks = append(checks, failed("payment signature", err.Error()))
+		} else if !strings.EqualFold(signer, p.From) {
+			checks = append(checks, failed("payment signature", fmt.Sprintf("signed by %s, not %s (domain from %s)", signer, p.From, domainSource)))
+		} else {
+			checks = append(checks, passed("payment signature", "signed by "+signer))
+		}
+
+		after, err1 := strconv.ParseInt(p.ValidAfter, 10, 64)
+		before, err2 := strconv.ParseInt(p.ValidBefore, 10, 64)
+		now := time.Now().Unix()
+		switch {
+		case err1 != nil || err2 != nil:
+			checks = append(checks, failed("validity window", "not unix timestamps"))
+		case now <= after:
+			checks = append(checks, failed("validity window", "not valid yet"))
+		case now >= before:
+			checks = append(checks, failed("validity window", "expired"))
+		default:
+			checks = append(checks, passed("validity window", fmt.Sprintf("valid for %s", time.Duration(before-now)*time.Second)))
+		}
+
+		if decodeRequirement != "" && accept != nil && domainSource == "--requirement" {
+			if err := p.Verify(accept, time.Now()); err != nil {
+				checks = append(checks, failed("requirement", err.Error()))
+			} else {
+				checks = append(checks, passed("requirement", "pays the requirement"))
+			}
+		}
+		return checks
+	}}, nil
+}
+
+// paymentAccept finds the requirement whose token domain p was signed
+// under: the matching accepts entry of --requirement, the one a v2 payload
+// repeats, or USDC on the payment's network. It also says which.
+func paymentAccept(p *providers.X402Payment) (*router.X402Accept, string) {
+	if decodeRequirement != "" {
+		if obj, raw, ok := jsonObject(stripHeaderName(strings.TrimSpace(decodeRequirement))); ok && obj["accepts"] != nil {
+			var req router.X402Requirement
+			json.Unmarshal(raw, &req)
+			for i := range req.Accepts {
+				a := &req.Accepts[i]
+				if a.Network == p.Network && strings.EqualFold(a.PayTo, p.To) {
+					return a, "--requirement"
+				}
+			}
+		}
+	}
+	if p.Accepted != nil && p.Accepted.Asset != "" {
+		return p.Accepted, "payload"
+	}
+	if usdc := router.USDCContract(p.Network); usdc != "" {
+		return &router.X402Accept{Scheme: p.Scheme, Network: p.Network, Asset: usdc, PayTo: p.To}, "USDC on " + p.Network
+	}
+	return nil, ""
+}
+
+func decodeSettlement(obj map[string]interface{}) *artifact {
+	obj["type"] = "x402_settlement"
+	return &artifact{fields: obj, verify: func() []verifyCheck {
+		tx, _ := obj["transaction"].(string)
+		network, _ := obj["network"].(string)
+		if tx == "" {
+			return nil
+		}
+		switch {
+		case strings.HasPrefix(network, "eip155:"):
+			if b, err := hex.DecodeString(strings.TrimPrefix(tx, "0x")); err != nil || len(b) != 32 {
+				return []verifyCheck{failed("transaction", "not a 32-byte transaction hash")}
+			}
+			return []verifyCheck{passed("transaction", "well-formed transaction hash")}
+		case strings.HasPrefix(network, "solana"):
+			if b, err := base58.Decode(tx); err != nil || len(b) != 64 {
+				return []verifyCheck{failed("transaction", "not a Solana transaction signature")}
+			}
+			return []verifyCheck{passed("transaction", "well-formed transaction signature")}
+		}
+		return nil
+	}}
+}
+
+func decodeSolanaPay(s string) (*artifact, error) {
+	req, err := router.ParseSolanaPayURL(s)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{
+		"type":      "solana_pay",
+		"recipient": req.Recipient,
+		"amount":    req.Amount,
+	}
+	if req.SPLToken != "" {
+		fields["spl_token"] = req.SPLToken
+	}
+	if len(req.References) > 0 {
+		fields["references"] = req.References
+	}
+	for k, v := range map[string]string{"label": req.Label, "message": req.Message, "memo": req.Memo} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	return &artifact{fields: fields, verify: func() []verifyCheck {
+		// ParseSolanaPayURL rejects malformed keys
+		return []verifyCheck{passed("keys", "recipient, token and references are valid public keys")}
+	}}, nil
+}
+
+func decodeCashuRequest(s string) (*artifact, error) {
+	req, err := router.ParseCashuRequest(s)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{
+		"type":       "cashu_request",
+		"amount":     req.Amount,
+		"unit":       req.Unit,
+		"mints":      req.Mints,
+		"single_use": req.SingleUse,
+	}
+	if req.ID != "" {
+		fields["id"] = req.ID
+	}
+	if req.Description != "" {
+		fields["description"] = req.Description
+	}
+	return &artifact{fields: fields}, nil
+}
+
+// macaroonArg strips the scheme and preimage from an L402 credential.
+func macaroonArg(s string) string {
+	s = strings.TrimSpace(s)
+	for _, scheme := range []string{"L402 ", "LSAT "} {
+		s = strings.TrimPrefix(s, scheme)
+	}
+	s, _, _ = strings.Cut(s, ":")
+	return s
+}
+
+func describeMacaroon(m *macaroon.Macaroon) map[string]interface{} {
+	result := map[string]interface{}{
+		"location":  m.Location,
+		"signature": hex.EncodeToString(m.Sig[:]),
+	}
+	if id, err := macaroon.DecodeIdentifier(m.ID); err == nil {
+		result["identifier"] = map[string]interface{}{
+			"version":      id.Version,
+			"payment_hash": hex.EncodeToString(id.PaymentHash[:]),
+			"token_id":     hex.EncodeToString(id.TokenID[:]),
+		}
+	} else {
+		result["identifier"] = hex.EncodeToString(m.ID)
+	}
+
+	caveats := []interface{}{}
+	for _, c := range m.Caveats {
+		if c.FirstParty() {
+			caveats = append(caveats, string(c.ID))
+			continue
+		}
+		caveats = append(caveats, map[string]string{
+			"location": c.Location,
+			"id":       hex.EncodeToString(c.ID),
+		})
+	}
+	result["caveats"] = caveats
+	return result
+}
+
+func describeInvoice(inv *bolt11.Invoice) map[string]interface{} {
+	result := map[string]interface{}{
+		"currency":       inv.Currency,
+		"amount_msat":    inv.AmountMsat,
+		"timestamp":      inv.Timestamp.UTC().Format(time.RFC3339),
+		"expires_at":     inv.ExpiresAt().UTC().Format(time.RFC3339),
+		"payment_hash":   inv.PaymentHash,
+		"payee":          inv.Payee,
+		"min_final_cltv": inv.MinFinalCLTV,
+	}
+	if inv.PaymentSecret != "" {
+		result["payment_secret"] = inv.PaymentSecret
+	}
+	if inv.Description != "" {
+		result["description"] = inv.Description
+	}
+	if inv.DescriptionHash != "" {
+		result["description_hash"] = inv.DescriptionHash
+	}
+	return result
+}
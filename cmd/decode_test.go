@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/joelklabo/agentpay/internal/macaroon"
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+)
+
+func decodeTestAccept() *router.X402Accept {
+	return &router.X402Accept{
+		Scheme:            "exact",
+		Network:           "eip155:8453",
+		MaxAmountRequired: "10000",
+		PayTo:             "0x209693Bc6afc0C5328bA36FaF03C514EF312287C",
+		MaxTimeoutSeconds: 60,
+		Asset:             router.USDCContract("eip155:8453"),
+	}
+}
+
+func decodeTestPayment(t *testing.T) string {
+	t.Helper()
+	p, _ := providers.NewEVMKeyProvider(big.NewInt(0xC0FFEE))
+	_, header, err := p.Pay(context.Background(), &router.PaymentRequirement{Protocol: router.ProtocolX402, X402Accept: decodeTestAccept()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+// results maps each check of a to its result.
+func results(t *testing.T, a *artifact) map[string]string {
+	t.Helper()
+	out := make(map[string]string)
+	for _, c := range a.verify() {
+		out[c.Check] = c.Result
+	}
+	return out
+}
+
+func TestDecodeArtifact_Types(t *testing.T) {
+	required, _ := json.Marshal(router.X402Requirement{Accepts: []router.X402Accept{*decodeTestAccept()}})
+	mac := macaroon.New([]byte("root"), make([]byte, 66), "agentpay")
+
+	lnurl := "LNURL1DP68GURN8GHJ7UM9WFMXJCM99E3K7MF0V9CXJ0M385EKVCENXC6R2C35XVUKXEFCV5MKVV34X5EKZD3EV56NYD3HXQURZEPEXEJXXEPNXSCRVWFNV9NXZCN9XQ6XYEFHVGCXXCMYXYMNSERXFQ5FNS"
+	settlement := `{"success":true,"transaction":"0x` + strings.Repeat("ab", 32) + `","network":"eip155:8453"}`
+	challenge := `L402 macaroon="` + mac.Encode() + `", invoice="` + probeInvoice + `"`
+
+	tests := []struct{ blob, want string }{
+		{probeInvoice, "bolt11"},
+		{"lightning:" + probeInvoice, "bolt11"},
+		{"agent@example.com", "lightning_address"},
+		{lnurl, "lnurl"},
+		{"solana:9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM?amount=0.01", "solana_pay"},
+		{base64.StdEncoding.EncodeToString(required), "x402_payment_required"},
+		{"Payment-Required: " + base64.StdEncoding.EncodeToString(required), "x402_payment_required"},
+		{string(required), "x402_payment_required"},
+		{decodeTestPayment(t), "x402_payment"},
+		{settlement, "x402_settlement"},
+		{mac.Encode(), "macaroon"},
+		{"L402 " + mac.Encode() + ":00", "l402_credential"},
+		{challenge, "l402_challenge"},
+	}
+	for _, tt := range tests {
+		a, err := decodeArtifact(tt.blob)
+		if err != nil {
+			t.Errorf("%.40s: %v", tt.blob, err)
+			continue
+		}
+		if got := a.fields["type"]; got != tt.want {
+			t.Errorf("%.40s: type %v, want %s", tt.blob, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"hello", `{"foo":1}`, "lnbc1invalid"} {
+		if _, err := decodeArtifact(bad); err == nil {
+			t.Errorf("%s: decoded", bad)
+		}
+	}
+}
+
+func TestDecodeArtifact_VerifyPayment(t *testing.T) {
+	header := decodeTestPayment(t)
+	a, err := decodeArtifact(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.fields["eip712"] == nil {
+		t.Error("no EIP-712 typed data")
+	}
+	if got := results(t, a); got["payment signature"] != "ok" || got["validity window"] != "ok" {
+		t.Errorf("checks = %v", got)
+	}
+
+	// The signature covers the amount
+	raw, _ := base64.StdEncoding.DecodeString(header)
+	tampered := strings.Replace(string(raw), `"value":"10000"`, `"value":"20000"`, 1)
+	a, err = decodeArtifact(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := results(t, a); got["payment signature"] != "failed" {
+		t.Errorf("tampered checks = %v", got)
+	}
+}
+
+func TestDecodeArtifact_VerifyL402(t *testing.T) {
+	preimage := make([]byte, 32)
+	preimage[0] = 7
+	hash := sha256.Sum256(preimage)
+	var id macaroon.Identifier
+	copy(id.PaymentHash[:], hash[:])
+	rootKey := []byte("root-key")
+	mac := macaroon.New(rootKey, id.Bytes(), "agentpay")
+
+	defer func() { decodeRootKey = "" }()
+	decodeRootKey = hex.EncodeToString(rootKey)
+	a, err := decodeArtifact("L402 " + mac.Encode() + ":" + hex.EncodeToString(preimage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := results(t, a); got["preimage"] != "ok" || got["macaroon signature"] != "ok" {
+		t.Errorf("checks = %v", got)
+	}
+
+	decodeRootKey = hex.EncodeToString([]byte("other"))
+	a, _ = decodeArtifact("L402 " + mac.Encode() + ":" + strings.Repeat("00", 32))
+	if got := results(t, a); got["preimage"] != "failed" || got["macaroon signature"] != "failed" {
+		t.Errorf("bad credential checks = %v", got)
+	}
+
+	// The challenge's macaroon is not bound to the spec invoice
+	a, _ = decodeArtifact(`L402 macaroon="` + mac.Encode() + `", invoice="` + probeInvoice + `"`)
+	if got := results(t, a); got["macaroon payment hash"] != "failed" || got["invoice signature"] != "ok" {
+		t.Errorf("challenge checks = %v", got)
+	}
+}
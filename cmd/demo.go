@@ -182,8 +182,8 @@ func (p *mockL402Provider) Pay(ctx context.Context, req *router.PaymentRequireme
 	return "Authorization", fmt.Sprintf("L402 %s:demo_preimage", req.L402Hash), nil
 }
 
-func (p *mockL402Provider) EstimateCost(req *router.PaymentRequirement) (float64, string, error) {
-	return 0.000007, "10 sats (~$0.000007)", nil
+func (p *mockL402Provider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	return router.NewAmount(7, router.USD), "10 sats (~$0.000007)", nil
 }
 
 // mockX402Provider auto-pays x402 invoices in demo mode.
@@ -195,8 +195,8 @@ func (p *mockX402Provider) Pay(ctx context.Context, req *router.PaymentRequireme
 	return "X-Payment", "demo_payment_proof_" + time.Now().Format("150405"), nil
 }
 
-func (p *mockX402Provider) EstimateCost(req *router.PaymentRequirement) (float64, string, error) {
-	return 0.001, "$0.001 USDC", nil
+func (p *mockX402Provider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	return router.NewAmount(1000, router.USD), "$0.001 USDC", nil
 }
 
 func runDemo(cmd *cobra.Command, args []string) error {
@@ -285,7 +285,7 @@ func runDemo(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  %d. %s → %s (%s)\n", i+1, rcpt.Protocol, rcpt.Amount, rcpt.URL)
 	}
 
-	fmt.Printf("\n  Total spent:  $%.6f across %d payment(s)\n", r.SessionSpend(), len(receipts))
+	fmt.Printf("\n  Total spent:  $%.6f across %d payment(s)\n", r.SessionSpend().Float64(), len(receipts))
 	fmt.Printf("  Protocols:    %s\n", protocolSummary(receipts))
 	fmt.Printf("  Duration:     %s\n", elapsed.Round(time.Millisecond))
 	fmt.Println()
@@ -110,7 +110,7 @@ func TestDemoMockServerCrossProtocol(t *testing.T) {
 	}
 
 	// Verify session spend
-	if r.SessionSpend() == 0 {
+	if r.SessionSpend().IsZero() {
 		t.Error("session spend should be > 0")
 	}
 }
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/joelklabo/agentpay/internal/keystore"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/spf13/cobra"
+)
+
+var evmCmd = &cobra.Command{
+	Use:   "evm",
+	Short: "Manage the local EVM keystore used to sign x402 payments",
+}
+
+var evmNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Generate a key and write it to an encrypted keystore file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("keystore")
+		if path == "" {
+			path = filepath.Join(filepath.Dir(configPath()), "evm-key.json")
+		}
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("keystore %s already exists", path)
+		}
+		password, err := keystorePassword()
+		if err != nil {
+			return err
+		}
+
+		priv, err := rand.Int(rand.Reader, new(big.Int).Sub(secp256k1.N, big.NewInt(1)))
+		if err != nil {
+			return err
+		}
+		priv.Add(priv, big.NewInt(1))
+		data, err := keystore.Encrypt(priv, password, keystore.StandardScryptN)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("write keystore: %w", err)
+		}
+
+		result := map[string]string{
+			"keystore": path,
+			"address":  keystore.Address(secp256k1.ScalarBaseMult(priv)),
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	},
+}
+
+var evmAddressCmd = &cobra.Command{
+	Use:   "address",
+	Short: "Unlock the configured keystore and show its address",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if cfg.EVMKey.Keystore == "" {
+			return fmt.Errorf("no keystore configured (set evm_key.keystore in %s)", configPath())
+		}
+		p, err := newEVMKeyProvider(cfg)
+		if err != nil {
+			return err
+		}
+
+		result := map[string]string{
+			"keystore": cfg.EVMKey.Keystore,
+			"address":  p.Address(),
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	},
+}
+
+// newEVMKeyProvider unlocks the keystore named in cfg.
+func newEVMKeyProvider(cfg *AppConfig) (*providers.EVMKeyProvider, error) {
+	password, err := keystorePassword()
+	if err != nil {
+		return nil, err
+	}
+	return providers.LoadEVMKeyProvider(cfg.EVMKey.Keystore, password)
+}
+
+func keystorePassword() (string, error) {
+	password := os.Getenv("AGENTPAY_KEYSTORE_PASSWORD")
+	if password == "" {
+		return "", fmt.Errorf("AGENTPAY_KEYSTORE_PASSWORD is not set")
+	}
+	return password, nil
+}
+
+func init() {
+	evmNewCmd.Flags().String("keystore", "", "keystore file to create (default evm-key.json next to the config)")
+
+	evmCmd.AddCommand(evmNewCmd)
+	evmCmd.AddCommand(evmAddressCmd)
+
+	rootCmd.AddCommand(evmCmd)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/joelklabo/agentpay/facilitator"
+	"github.com/spf13/cobra"
+)
+
+var facilitatorCmd = &cobra.Command{
+	Use:   "facilitator",
+	Short: "Run a local x402 facilitator backed by an in-memory ledger",
+	Long: `Starts an x402 facilitator serving /verify, /settle and /supported.
+It checks each EIP-3009 authorization's signer, validity window, amount and
+payee, and that its nonce is unused. Settled payments are recorded in an
+in-memory ledger rather than on chain, so sellers and CI can run the full
+x402 loop offline.
+
+Point a paywall at it:
+  agentpay facilitator --listen 127.0.0.1:4021
+  paywall.FacilitatorClient{URL: "http://127.0.0.1:4021"}`,
+	RunE: runFacilitator,
+}
+
+var (
+	facilitatorListen   string
+	facilitatorNetworks []string
+)
+
+func init() {
+	rootCmd.AddCommand(facilitatorCmd)
+	facilitatorCmd.Flags().StringVar(&facilitatorListen, "listen", "127.0.0.1:4021", "Address to listen on")
+	facilitatorCmd.Flags().StringArrayVar(&facilitatorNetworks, "network", nil, "CAIP-2 network to serve, e.g. eip155:84532 (repeatable; default the networks with a known USDC contract)")
+}
+
+func runFacilitator(cmd *cobra.Command, args []string) error {
+	srv := facilitator.New(facilitator.Config{Networks: facilitatorNetworks})
+	log.Printf("x402 facilitator listening on http://%s (in-memory ledger)", facilitatorListen)
+	return http.ListenAndServe(facilitatorListen, srv)
+}
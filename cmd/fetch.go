@@ -3,11 +3,12 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
-	"github.com/joelklabo/agentpay/providers"
 	"github.com/joelklabo/agentpay/router"
 	"github.com/spf13/cobra"
 )
@@ -23,13 +24,15 @@ payment, and retries the request with proof. Returns the final response.`,
 }
 
 var (
-	fetchMethod  string
-	fetchBody    string
-	fetchDryRun  bool
-	fetchBudget  float64
-	fetchVerbose bool
-	fetchHeaders []string
-	fetchWoT     bool
+	fetchMethod   string
+	fetchBody     string
+	fetchDryRun   bool
+	fetchBudget   float64
+	fetchVerbose  bool
+	fetchHeaders  []string
+	fetchWoT      bool
+	fetchStrategy string
+	fetchDiscover bool
 )
 
 func init() {
@@ -40,40 +43,77 @@ func init() {
 	fetchCmd.Flags().BoolVarP(&fetchVerbose, "verbose", "v", false, "Verbose output")
 	fetchCmd.Flags().StringArrayVarP(&fetchHeaders, "header", "H", nil, "HTTP headers (key: value)")
 	fetchCmd.Flags().BoolVar(&fetchWoT, "wot", false, "Enable Web of Trust trust scoring before payments")
+	fetchCmd.Flags().StringVar(&fetchStrategy, "strategy", "", "Routing strategy when several rails are offered: cheapest, preferred, fastest, balance")
+	fetchCmd.Flags().BoolVar(&fetchDiscover, "discover", false, "Check the service's /.well-known/agentpay prices and payee pins before paying")
 }
 
 func runFetch(cmd *cobra.Command, args []string) error {
-	url := args[0]
+	req := fetchRequest{Method: fetchMethod, URL: args[0], Body: fetchBody, DryRun: fetchDryRun}
+
+	// Parse headers
+	req.Headers = make(map[string]string)
+	for _, h := range fetchHeaders {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) == 2 {
+			req.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	// A running daemon fetches with its shared router, unless this
+	// invocation configures a router of its own
+	var res *fetchResult
+	err := errNoDaemon
+	if !cmd.Flags().Changed("strategy") && !cmd.Flags().Changed("discover") && !cmd.Flags().Changed("wot") {
+		if cmd.Flags().Changed("budget") {
+			if err := checkMaxUSD("--budget", fetchBudget); err != nil {
+				return err
+			}
+			req.MaxUSD = fetchBudget
+		}
+		res = new(fetchResult)
+		err = callDaemon("fetch", req, res)
+		if err == nil && fetchVerbose {
+			fmt.Fprintf(os.Stderr, "Fetched through the daemon on %s\n", daemonSocket())
+		}
+	}
+	if errors.Is(err, errNoDaemon) {
+		res, err = fetchInProcess(req)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Print receipt if payment was made
+	if res.Receipt != nil {
+		receiptJSON, _ := json.MarshalIndent(res.Receipt, "", "  ")
+		fmt.Fprintf(os.Stderr, "\n--- Payment Receipt ---\n%s\n-----------------------\n\n", receiptJSON)
+	}
+
+	// Print response body
+	fmt.Print(string(res.Body))
+	return nil
+}
+
+// fetchInProcess makes req with a router of its own and appends any payment
+// to the receipts ledger.
+func fetchInProcess(req fetchRequest) (*fetchResult, error) {
 	ctx := context.Background()
 
 	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("load config: %w (run 'agentpay init' to set up)", err)
+		return nil, fmt.Errorf("load config: %w (run 'agentpay init' to set up)", err)
 	}
 
-	r := router.New(router.Config{
+	r, err := newRouter(cfg, router.Config{
 		MaxPerRequestUSD: fetchBudget,
 		MaxSessionUSD:    fetchBudget * 10,
-		DryRun:           fetchDryRun,
+		DryRun:           req.DryRun,
 		Verbose:          fetchVerbose,
+		Strategy:         router.Strategy(fetchStrategy),
+		Discover:         fetchDiscover,
 	})
-
-	// Register providers based on config
-	if cfg.AgentWallet.Username != "" {
-		x402 := providers.NewX402Provider(
-			cfg.AgentWallet.APIBase,
-			cfg.AgentWallet.Username,
-			cfg.AgentWallet.Token,
-		)
-		if cfg.AgentWallet.PreferredChain != "" {
-			x402.PreferredChain = cfg.AgentWallet.PreferredChain
-		}
-		r.RegisterProvider(x402)
-	}
-
-	if cfg.LNbits.URL != "" {
-		l402 := providers.NewL402Provider(cfg.LNbits.URL, cfg.LNbits.AdminKey)
-		r.RegisterProvider(l402)
+	if err != nil {
+		return nil, err
 	}
 
 	if fetchWoT {
@@ -84,38 +124,26 @@ func runFetch(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Parse headers
-	hdrs := make(map[string]string)
-	for _, h := range fetchHeaders {
-		parts := strings.SplitN(h, ":", 2)
-		if len(parts) == 2 {
-			hdrs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-		}
-	}
-
 	// Build body reader
-	var bodyReader *strings.Reader
-	if fetchBody != "" {
-		bodyReader = strings.NewReader(fetchBody)
-	}
-
-	var bodyIO interface{ Read([]byte) (int, error) }
-	if bodyReader != nil {
-		bodyIO = bodyReader
+	var bodyIO io.Reader
+	if req.Body != "" {
+		bodyIO = strings.NewReader(req.Body)
 	}
 
-	respBody, receipt, err := r.Fetch(ctx, fetchMethod, url, bodyIO, hdrs)
+	respBody, receipt, err := r.Fetch(ctx, req.Method, req.URL, bodyIO, req.Headers)
 	if err != nil {
-		return fmt.Errorf("fetch: %w", err)
+		return nil, fmt.Errorf("fetch: %w", err)
 	}
 
-	// Print receipt if payment was made
-	if receipt != nil {
-		receiptJSON, _ := json.MarshalIndent(receipt, "", "  ")
-		fmt.Fprintf(os.Stderr, "\n--- Payment Receipt ---\n%s\n-----------------------\n\n", receiptJSON)
+	if receipt != nil && !req.DryRun {
+		ledger, err := openReceiptLog(defaultReceiptsPath())
+		if err == nil {
+			err = ledger.Append(*receipt)
+			ledger.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: write receipt: %v\n", err)
+		}
 	}
-
-	// Print response body
-	fmt.Print(string(respBody))
-	return nil
+	return &fetchResult{Body: respBody, Receipt: receipt}, nil
 }
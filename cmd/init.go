@@ -18,6 +18,7 @@ var initCmd = &cobra.Command{
 Supports:
   - AgentWallet (x402/USDC on EVM and Solana)
   - LNbits (L402/Lightning Network)
+  - phoenixd (L402/Lightning Network, self-custodial)
   - Web of Trust scoring for payment safety`,
 	RunE: runInit,
 }
@@ -28,6 +29,8 @@ var (
 	initAWChain string
 	initLNURL   string
 	initLNKey   string
+	initPhxURL  string
+	initPhxPass string
 	initWoT     bool
 	initWoTURL  string
 )
@@ -38,11 +41,17 @@ func init() {
 	initCmd.Flags().StringVar(&initAWChain, "aw-chain", "auto", "Preferred chain: evm, solana, auto")
 	initCmd.Flags().StringVar(&initLNURL, "lnbits-url", "", "LNbits URL")
 	initCmd.Flags().StringVar(&initLNKey, "lnbits-key", "", "LNbits admin key")
+	initCmd.Flags().StringVar(&initPhxURL, "phoenixd-url", "", "phoenixd HTTP API URL, e.g. http://localhost:9740")
+	initCmd.Flags().StringVar(&initPhxPass, "phoenixd-password", "", "phoenixd http-password from phoenix.conf")
 	initCmd.Flags().BoolVar(&initWoT, "wot", false, "Enable WoT trust scoring")
 	initCmd.Flags().StringVar(&initWoTURL, "wot-url", "https://maximumsats.joel-dfd.workers.dev/wot/score", "WoT API endpoint")
 }
 
 func runInit(cmd *cobra.Command, args []string) error {
+	if initPhxURL != "" && initPhxPass == "" {
+		return fmt.Errorf("--phoenixd-password is required with --phoenixd-url")
+	}
+
 	cfg := &AppConfig{
 		AgentWallet: AgentWalletConfig{
 			APIBase:        "https://agentwallet.mcpay.tech",
@@ -54,6 +63,10 @@ func runInit(cmd *cobra.Command, args []string) error {
 			URL:      initLNURL,
 			AdminKey: initLNKey,
 		},
+		Phoenixd: PhoenixdConfig{
+			URL:      initPhxURL,
+			Password: initPhxPass,
+		},
 		WoT: WoTConfig{
 			Enabled:  initWoT,
 			Endpoint: initWoTURL,
@@ -76,6 +89,9 @@ func runInit(cmd *cobra.Command, args []string) error {
 	if cfg.LNbits.URL != "" {
 		fmt.Printf("  L402: LNbits (%s)\n", cfg.LNbits.URL)
 	}
+	if cfg.Phoenixd.URL != "" {
+		fmt.Printf("  L402: phoenixd (%s)\n", cfg.Phoenixd.URL)
+	}
 	if cfg.WoT.Enabled {
 		fmt.Printf("  WoT:  %s\n", cfg.WoT.Endpoint)
 	}
@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joelklabo/agentpay/mcp"
+	"github.com/joelklabo/agentpay/router"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve paid fetch to LLM agents over the Model Context Protocol",
+	Long: `Runs a Model Context Protocol server on stdin and stdout, so tool-calling
+agents can pay for APIs. It exposes these tools:
+
+  paid_fetch      fetch a URL, paying any 402 within budget
+  probe_price     show what a URL charges and whether policy allows it
+  get_balance     wallet balances across all rails
+  get_budget      spending limits and what this session has spent
+  list_receipts   payments made this session
+
+All calls share one router, so the session budget and cached wallet
+balances carry across calls. Every payment is appended to the receipts
+file as a JSON line.
+
+Example client configuration:
+  {"mcpServers": {"agentpay": {"command": "agentpay", "args": ["mcp"]}}}`,
+	Args: cobra.NoArgs,
+	RunE: runMCP,
+}
+
+var (
+	mcpBudget   float64
+	mcpWoT      bool
+	mcpStrategy string
+	mcpDiscover bool
+	mcpDryRun   bool
+	mcpReceipts string
+	mcpMaxBody  int
+)
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.Flags().Float64Var(&mcpBudget, "budget", 0, "Maximum USD per request (default: the config's); the session may spend ten times this")
+	mcpCmd.Flags().BoolVar(&mcpWoT, "wot", false, "Enable Web of Trust trust scoring before payments")
+	mcpCmd.Flags().StringVar(&mcpStrategy, "strategy", "", "Routing strategy: cheapest, preferred, fastest, balance")
+	mcpCmd.Flags().BoolVar(&mcpDiscover, "discover", false, "Check services' /.well-known/agentpay prices and payee pins before paying")
+	mcpCmd.Flags().BoolVar(&mcpDryRun, "dry-run", false, "Report what each fetch would pay without paying")
+	mcpCmd.Flags().StringVar(&mcpReceipts, "receipts", "", "File to append receipts to (default receipts.jsonl next to the config)")
+	mcpCmd.Flags().IntVar(&mcpMaxBody, "max-body", 100_000, "Maximum response body bytes returned to the model")
+}
+
+func runMCP(cmd *cobra.Command, args []string) error {
+	if mcpMaxBody <= 0 {
+		return fmt.Errorf("--max-body must be at least 1, got %d", mcpMaxBody)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w (run 'agentpay init' to set up)", err)
+	}
+	rc := router.Config{
+		MaxPerRequestUSD: cfg.Budget.MaxPerRequestUSD,
+		MaxSessionUSD:    cfg.Budget.MaxSessionUSD,
+		DryRun:           mcpDryRun,
+		Strategy:         router.Strategy(mcpStrategy),
+		Discover:         mcpDiscover,
+	}
+	if cmd.Flags().Changed("budget") {
+		if err := checkMaxUSD("--budget", mcpBudget); err != nil {
+			return err
+		}
+		rc.MaxPerRequestUSD = mcpBudget
+		rc.MaxSessionUSD = mcpBudget * 10
+	}
+	// stdout carries the protocol, so the router's warnings go to stderr
+	r, err := newRouter(cfg, rc)
+	if err != nil {
+		return err
+	}
+	if mcpWoT {
+		r.SetWoTChecker(router.NewWoTChecker("https://maximumsats.joel-dfd.workers.dev/wot/score"))
+	}
+
+	path := mcpReceipts
+	if path == "" {
+		path = defaultReceiptsPath()
+	}
+	ledger, err := openReceiptLog(path)
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+
+	strategy := rc.Strategy
+	if strategy == "" {
+		strategy, _ = router.ParseStrategy(cfg.Routing.Strategy)
+	}
+	t := &mcpTools{
+		router: r,
+		policy: probePolicy{
+			MaxPerRequestUSD: rc.MaxPerRequestUSD,
+			MaxSessionUSD:    rc.MaxSessionUSD,
+			Strategy:         strategy,
+			WoT:              mcpWoT,
+			Configured:       true,
+		},
+		balances: func(ctx context.Context) []walletBalance { return walletBalances(ctx, cfg) },
+		ledger:   ledger,
+		dryRun:   mcpDryRun,
+		maxBody:  mcpMaxBody,
+	}
+	fmt.Fprintf(os.Stderr, "agentpay MCP server on stdio; receipts: %s\n", path)
+	return t.server().Serve(cmd.Context(), os.Stdin, os.Stdout)
+}
+
+// mcpTools implements the agentpay MCP tools over one router.
+type mcpTools struct {
+	router   *router.Router
+	policy   probePolicy
+	balances func(ctx context.Context) []walletBalance
+	ledger   *receiptLog // nil keeps receipts in memory only
+	dryRun   bool
+	maxBody  int
+}
+
+// mcpRequest is the request part of the paid_fetch and probe_price arguments.
+type mcpRequest struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Body    string            `json:"body"`
+	Headers map[string]string `json:"headers"`
+}
+
+const mcpRequestSchema = `
+		"url": {"type": "string", "description": "URL to request"},
+		"method": {"type": "string", "description": "HTTP method", "default": "GET"},
+		"body": {"type": "string", "description": "Request body"},
+		"headers": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Request headers"}`
+
+func (t *mcpTools) server() *mcp.Server {
+	s := mcp.NewServer("agentpay", "0.1.0")
+	s.AddTool(&mcp.Tool{
+		Name: "paid_fetch",
+		Description: "Fetch a URL over HTTP. If the server answers 402 Payment Required, pay it " +
+			"over x402, L402, Cashu or Solana Pay within the budget and return the paid response " +
+			"with its receipt. Set max_usd to cap what this call may spend.",
+		InputSchema: json.RawMessage(`{"type": "object", "properties": {` + mcpRequestSchema + `,
+		"max_usd": {"type": "number", "description": "Most this call may spend in USD; never more than the configured per-request limit"}},
+		"required": ["url"]}`),
+		Handler: t.paidFetch,
+	})
+	s.AddTool(&mcp.Tool{
+		Name: "probe_price",
+		Description: "Request a URL without paying and report every payment option it offers: " +
+			"its USD cost, and whether the budget and trust policy allow paying it.",
+		InputSchema: json.RawMessage(`{"type": "object", "properties": {` + mcpRequestSchema + `}, "required": ["url"]}`),
+		Handler:     t.probePrice,
+	})
+	s.AddTool(&mcp.Tool{
+		Name:        "get_balance",
+		Description: "Show the balance of every configured wallet.",
+		InputSchema: json.RawMessage(`{"type": "object", "properties": {}}`),
+		Handler:     t.getBalance,
+	})
+	s.AddTool(&mcp.Tool{
+		Name:        "get_budget",
+		Description: "Show the per-request and session spending limits, what this session has spent, and what is left.",
+		InputSchema: json.RawMessage(`{"type": "object", "properties": {}}`),
+		Handler:     t.getBudget,
+	})
+	s.AddTool(&mcp.Tool{
+		Name:        "list_receipts",
+		Description: "List the payments made this session, most recent last, with their cost in USD.",
+		InputSchema: json.RawMessage(`{"type": "object", "properties": {
+		"limit": {"type": "integer", "description": "Return only the most recent receipts"}}}`),
+		Handler: t.listReceipts,
+	})
+	return s
+}
+
+func decodeArgs(args json.RawMessage, v any) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func (req *mcpRequest) validate() error {
+	if req.URL == "" {
+		return errors.New("url is required")
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	req.Method = strings.ToUpper(req.Method)
+	return nil
+}
+
+func (req *mcpRequest) body() io.Reader {
+	if req.Body == "" {
+		return nil
+	}
+	return strings.NewReader(req.Body)
+}
+
+func (t *mcpTools) paidFetch(ctx context.Context, args json.RawMessage) (*mcp.Result, error) {
+	var a struct {
+		mcpRequest
+		MaxUSD *float64 `json:"max_usd"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	if a.MaxUSD != nil {
+		if err := checkMaxUSD("max_usd", *a.MaxUSD); err != nil {
+			return nil, err
+		}
+		ctx = router.WithMaxCost(ctx, router.FromUSD(*a.MaxUSD))
+	}
+
+	body, receipt, err := t.router.Fetch(ctx, a.Method, a.URL, a.body(), a.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w\n%s", a.URL, err, budgetSummary(t.router.Budget()))
+	}
+	if receipt != nil && !t.dryRun && t.ledger != nil {
+		if err := t.ledger.Append(*receipt); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: write receipt: %v\n", err)
+		}
+	}
+
+	out := map[string]any{"url": a.URL, "budget": newBudgetReport(t.router.Budget())}
+	if receipt != nil {
+		out["receipt"] = receipt
+	}
+	text := body
+	if len(text) > t.maxBody {
+		// Cut at a rune boundary so UTF-8 text stays valid
+		n := t.maxBody
+		for i := 0; i < utf8.UTFMax-1 && n > 0 && !utf8.RuneStart(text[n]); i++ {
+			n--
+		}
+		text, out["truncated"] = text[:n], true
+	}
+	var shown string
+	if utf8.Valid(text) {
+		shown = string(text)
+		out["body"] = shown
+	} else {
+		out["body_base64"] = base64.StdEncoding.EncodeToString(text)
+		shown = fmt.Sprintf("(%d bytes of binary content, returned base64-encoded in body_base64)", len(body))
+	}
+
+	payment := "No payment was needed."
+	if receipt != nil {
+		payment = receiptSummary(receipt)
+		data, _ := json.MarshalIndent(receipt, "", "  ")
+		payment += "\n" + string(data)
+	}
+	return &mcp.Result{
+		Text:       []string{shown, payment + "\n" + budgetSummary(t.router.Budget())},
+		Structured: out,
+	}, nil
+}
+
+func (t *mcpTools) probePrice(ctx context.Context, args json.RawMessage) (*mcp.Result, error) {
+	var a mcpRequest
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	result, err := t.router.Probe(ctx, a.Method, a.URL, a.body(), a.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("probe %s: %w", a.URL, err)
+	}
+	report := newProbeReport(result)
+	report.Policy = t.policy
+
+	var out bytes.Buffer
+	printProbeReport(&out, report)
+	return &mcp.Result{Text: []string{out.String()}, Structured: report}, nil
+}
+
+func (t *mcpTools) getBalance(ctx context.Context, args json.RawMessage) (*mcp.Result, error) {
+	balances := t.balances(ctx)
+	var out bytes.Buffer
+	printBalances(&out, balances)
+	if balances == nil {
+		balances = []walletBalance{}
+	}
+	return &mcp.Result{
+		Text:       []string{strings.TrimSpace(out.String())},
+		Structured: map[string]any{"wallets": balances},
+	}, nil
+}
+
+func (t *mcpTools) getBudget(ctx context.Context, args json.RawMessage) (*mcp.Result, error) {
+	b := t.router.Budget()
+	return &mcp.Result{Text: []string{budgetSummary(b)}, Structured: newBudgetReport(b)}, nil
+}
+
+func (t *mcpTools) listReceipts(ctx context.Context, args json.RawMessage) (*mcp.Result, error) {
+	var a struct {
+		Limit int `json:"limit"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	receipts := t.router.Receipts()
+	if a.Limit > 0 && len(receipts) > a.Limit {
+		receipts = receipts[len(receipts)-a.Limit:]
+	}
+
+	lines := []string{fmt.Sprintf("%d payments this session.", len(t.router.Receipts()))}
+	for _, r := range receipts {
+		lines = append(lines, receiptSummary(&r))
+	}
+	lines = append(lines, budgetSummary(t.router.Budget()))
+	return &mcp.Result{
+		Text:       []string{strings.Join(lines, "\n")},
+		Structured: map[string]any{"receipts": receipts, "budget": newBudgetReport(t.router.Budget())},
+	}, nil
+}
+
+// receiptSummary describes a payment in a sentence.
+func receiptSummary(r *router.Receipt) string {
+	rail := r.Protocol
+	if r.Rail != "" {
+		rail += " on " + r.Rail
+	}
+	s := fmt.Sprintf("Paid $%.4f (%s) via %s for %s.", r.USDCost, r.Amount, rail, r.URL)
+	if strings.HasPrefix(r.Description, "DRY RUN") {
+		s = fmt.Sprintf("Dry run: would pay $%.4f (%s) via %s for %s.", r.USDCost, r.Amount, rail, r.URL)
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/joelklabo/agentpay/mcp"
+	"github.com/joelklabo/agentpay/router"
+)
+
+func TestMCPTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/paid" {
+			w.Header().Set("WWW-Authenticate", `L402 macaroon="AGIAJEemVQUTEyNCR0exk7ek90Cg==", invoice="`+probeInvoice+`"`)
+			w.WriteHeader(http.StatusPaymentRequired)
+			return
+		}
+		if r.URL.Path == "/utf8" {
+			w.Write([]byte("ééé"))
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	r := router.New(router.Config{MaxPerRequestUSD: 1, MaxSessionUSD: 10})
+	sats := int64(2100)
+	tools := &mcpTools{
+		router: r,
+		policy: probePolicy{MaxPerRequestUSD: 1, MaxSessionUSD: 10},
+		balances: func(context.Context) []walletBalance {
+			return []walletBalance{{Protocol: "L402", Wallet: "NWC", Sats: &sats}}
+		},
+		maxBody: 5,
+	}
+	call := func(name, args string) (text string, structured map[string]any) {
+		t.Helper()
+		tool := map[string]func(context.Context, json.RawMessage) (*mcp.Result, error){
+			"paid_fetch":    tools.paidFetch,
+			"probe_price":   tools.probePrice,
+			"get_balance":   tools.getBalance,
+			"get_budget":    tools.getBudget,
+			"list_receipts": tools.listReceipts,
+		}[name]
+		res, err := tool(context.Background(), json.RawMessage(args))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		data, _ := json.Marshal(res.Structured)
+		json.Unmarshal(data, &structured)
+		return strings.Join(res.Text, "\n"), structured
+	}
+
+	text, out := call("paid_fetch", `{"url":"`+srv.URL+`/free"}`)
+	if out["body"] != `{"sta` || out["truncated"] != true || out["receipt"] != nil {
+		t.Errorf("paid_fetch = %v", out)
+	}
+	if !strings.Contains(text, "No payment was needed") || !strings.Contains(text, "$0 of $10 spent") {
+		t.Errorf("paid_fetch text = %q", text)
+	}
+	// Truncation doesn't split a character and turn the text into binary
+	if _, out := call("paid_fetch", `{"url":"`+srv.URL+`/utf8"}`); out["body"] != "éé" {
+		t.Errorf("truncated UTF-8 = %v", out)
+	}
+
+	// No wallet can pay the $2000 invoice, and a max_usd cap is below it anyway
+	if _, err := tools.paidFetch(context.Background(), json.RawMessage(`{"url":"`+srv.URL+`/paid","max_usd":0.5}`)); err == nil {
+		t.Error("paid_fetch paid without a wallet")
+	}
+	// A cap that rounds to zero would otherwise mean no cap at all
+	for _, max := range []string{"0", "-1", "0.0000001"} {
+		_, err := tools.paidFetch(context.Background(), json.RawMessage(`{"url":"`+srv.URL+`/free","max_usd":`+max+`}`))
+		if err == nil || !strings.Contains(err.Error(), "max_usd") {
+			t.Errorf("max_usd %s: err = %v", max, err)
+		}
+	}
+	if _, err := tools.paidFetch(context.Background(), json.RawMessage(`{"method":"GET"}`)); err == nil {
+		t.Error("paid_fetch without a url")
+	}
+
+	text, out = call("probe_price", `{"url":"`+srv.URL+`/paid"}`)
+	opts := out["options"].([]any)
+	if len(opts) != 1 || opts[0].(map[string]any)["usd_cost"] != 2000.0 || !strings.Contains(text, "refused:") {
+		t.Errorf("probe_price = %v\n%s", out, text)
+	}
+
+	text, out = call("get_balance", `{}`)
+	if !strings.Contains(text, "L402 (NWC): 2100 sats") || len(out["wallets"].([]any)) != 1 {
+		t.Errorf("get_balance = %v\n%s", out, text)
+	}
+
+	_, out = call("get_budget", `{}`)
+	if out["max_per_request_usd"] != 1.0 || out["remaining_usd"] != 10.0 {
+		t.Errorf("get_budget = %v", out)
+	}
+
+	text, out = call("list_receipts", `{"limit":5}`)
+	if !strings.HasPrefix(text, "0 payments this session.") || len(out["receipts"].([]any)) != 0 {
+		t.Errorf("list_receipts = %v\n%s", out, text)
+	}
+}
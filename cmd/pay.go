@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joelklabo/agentpay/internal/bolt11"
+	"github.com/joelklabo/agentpay/internal/bolt12"
+	"github.com/joelklabo/agentpay/paywall"
+	"github.com/joelklabo/agentpay/router"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(payCmd)
+	payCmd.Flags().StringVar(&payNetwork, "network", "eip155:8453", "CAIP-2 network for USDC payments to an EVM address")
+	payCmd.Flags().StringVar(&payFacilitator, "facilitator", "https://x402.org/facilitator", "x402 facilitator that submits USDC payments")
+	payCmd.Flags().Float64Var(&payBudget, "budget", 0, "Maximum USD to spend (default: the config's per-request limit)")
+	payCmd.Flags().BoolVar(&payDryRun, "dry-run", false, "Check the payment against policy without paying")
+	payCmd.Flags().BoolVar(&payWoT, "wot", false, "Enable Web of Trust trust scoring before paying")
+	payCmd.Flags().StringVar(&payReceipts, "receipts", "", "File to append the receipt to (default receipts.jsonl next to the config)")
+}
+
+var (
+	payNetwork     string
+	payFacilitator string
+	payBudget      float64
+	payDryRun      bool
+	payWoT         bool
+	payReceipts    string
+)
+
+var payCmd = &cobra.Command{
+	Use:   "pay <target> [amount]",
+	Short: "Pay an invoice, address or payment request directly",
+	Long: `Pays a target outside a 402 exchange, with the same provider selection,
+budget, balance and trust checks as 'agentpay fetch'. The target may be:
+
+  a BOLT11 invoice                 amount from the invoice
+  a BOLT12 offer                   amount in sats if the offer leaves it open
+  a Lightning address or LNURL     amount in sats
+  a Solana Pay URI                 amount from the URI
+  an EVM address                   amount in USDC, on --network
+  an x402 Payment-Required value   amount from the requirement
+
+Amounts may carry a unit: 50sat, 1500msat, 0.25usd. USDC payments are
+signed locally and submitted through --facilitator.
+
+Each payment is appended to the receipts file as a JSON line.
+
+Examples:
+  agentpay pay agent@getalby.com 100
+  agentpay pay lnbc10u1p...
+  agentpay pay 0x209693Bc6afc0C5328bA36FaF03C514EF312287C 0.50`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runPay,
+}
+
+var evmAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+func runPay(cmd *cobra.Command, args []string) error {
+	req := payRequest{Target: args[0], Network: payNetwork, Facilitator: payFacilitator, DryRun: payDryRun}
+	if len(args) == 2 {
+		req.Amount = args[1]
+	}
+	if _, err := payOptions(req.Target, req.Amount, req.Network); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("budget") {
+		if err := checkMaxUSD("--budget", payBudget); err != nil {
+			return err
+		}
+	}
+
+	// A running daemon pays against its shared session budget, unless
+	// this invocation asks for a router of its own
+	if !cmd.Flags().Changed("wot") && !cmd.Flags().Changed("receipts") {
+		if cmd.Flags().Changed("budget") {
+			req.MaxUSD = payBudget
+		}
+		var receipt router.Receipt
+		err := callDaemon("pay", req, &receipt)
+		if err == nil {
+			return printPayReceipt(&receipt)
+		}
+		if !errors.Is(err, errNoDaemon) {
+			return err
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w (run 'agentpay init' to set up)", err)
+	}
+	rc := router.Config{
+		MaxPerRequestUSD: cfg.Budget.MaxPerRequestUSD,
+		MaxSessionUSD:    cfg.Budget.MaxSessionUSD,
+	}
+	if cmd.Flags().Changed("budget") {
+		rc.MaxPerRequestUSD = payBudget
+		rc.MaxSessionUSD = payBudget * 10
+	}
+	r, err := newRouter(cfg, rc)
+	if err != nil {
+		return err
+	}
+	if payWoT {
+		r.SetWoTChecker(router.NewWoTChecker("https://maximumsats.joel-dfd.workers.dev/wot/score"))
+	}
+
+	path := payReceipts
+	if path == "" {
+		path = defaultReceiptsPath()
+	}
+	ledger, err := openReceiptLog(path)
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+
+	receipt, err := payTarget(context.Background(), r, req, ledger)
+	if err != nil {
+		return err
+	}
+	return printPayReceipt(receipt)
+}
+
+func printPayReceipt(receipt *router.Receipt) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(receipt)
+}
+
+// payRequest is a payment for agentpay pay to make, in this process or in
+// the daemon.
+type payRequest struct {
+	Target      string `json:"target"`
+	Amount      string `json:"amount,omitempty"`
+	Network     string `json:"network,omitempty"`
+	Facilitator string `json:"facilitator,omitempty"`
+	DryRun      bool   `json:"dry_run,omitempty"`
+	// MaxUSD lowers the router's per-request limit; zero leaves it.
+	MaxUSD float64 `json:"max_usd,omitempty"`
+}
+
+// payTarget pays req through r, submits x402 payments to the facilitator
+// and appends the receipt to ledger. A dry run records nothing.
+func payTarget(ctx context.Context, r *router.Router, req payRequest, ledger *receiptLog) (*router.Receipt, error) {
+	network := req.Network
+	if network == "" {
+		network = "eip155:8453"
+	}
+	options, err := payOptions(req.Target, req.Amount, network)
+	if err != nil {
+		return nil, err
+	}
+	if req.DryRun {
+		ctx = router.WithDryRun(ctx)
+	}
+	if req.MaxUSD != 0 {
+		if err := checkMaxUSD("max_usd", req.MaxUSD); err != nil {
+			return nil, err
+		}
+		ctx = router.WithMaxCost(ctx, router.FromUSD(req.MaxUSD))
+	}
+
+	// An x402 payment is a signed authorization until a facilitator
+	// submits it, so it isn't recorded before then
+	submit := func(ctx context.Context, receipt *router.Receipt, settlement *router.Settlement) error {
+		if receipt.Protocol != router.ProtocolX402.String() {
+			return nil
+		}
+		url := req.Facilitator
+		if url == "" {
+			url = "https://x402.org/facilitator"
+		}
+		accept := chosenAccept(options, receipt.Rail)
+		facilitator := &paywall.FacilitatorClient{URL: url}
+		settled, err := facilitator.Settle(ctx, settlement.HeaderValue, accept)
+		if err != nil {
+			return fmt.Errorf("settle: %w", err)
+		}
+		receipt.TxID = settled.Transaction
+		return nil
+	}
+	receipt, settlement, err := r.PayWith(ctx, req.Target, options, submit)
+	if err != nil {
+		return nil, fmt.Errorf("pay: %w", err)
+	}
+	if settlement == nil {
+		return receipt, nil
+	}
+
+	if err := ledger.Append(*receipt); err != nil {
+		return nil, fmt.Errorf("write receipt: %w", err)
+	}
+	return receipt, nil
+}
+
+// payOptions turns a pay target and optional amount into the payment
+// options the router chooses from.
+func payOptions(target, amount, network string) ([]*router.PaymentRequirement, error) {
+	target = stripHeaderName(strings.TrimSpace(target))
+	lower := strings.ToLower(target)
+	bare := strings.TrimPrefix(lower, "lightning:")
+
+	noAmount := func(kind string) error {
+		if amount != "" {
+			return fmt.Errorf("%s sets its own amount; drop %q", kind, amount)
+		}
+		return nil
+	}
+
+	switch {
+	case evmAddressPattern.MatchString(target):
+		if amount == "" {
+			return nil, fmt.Errorf("paying an EVM address needs a USDC amount")
+		}
+		usdc, err := parseUSDC(amount)
+		if err != nil {
+			return nil, err
+		}
+		asset := router.USDCContract(network)
+		if asset == "" {
+			return nil, fmt.Errorf("no known USDC contract on %s", network)
+		}
+		// Providers such as AgentWallet sign the raw requirement, so
+		// write the one a seller would have sent
+		requirement, _ := json.Marshal(map[string]any{
+			"x402Version": 1,
+			"accepts": []router.X402Accept{{
+				Scheme:            "exact",
+				Network:           network,
+				MaxAmountRequired: strconv.FormatInt(usdc.Units, 10),
+				Resource:          target,
+				PayTo:             target,
+				MaxTimeoutSeconds: 60,
+				Asset:             asset,
+				Extra:             json.RawMessage(`{"name":"USD Coin","version":"2"}`),
+			}},
+		})
+		return router.ParseX402Requirement(base64.StdEncoding.EncodeToString(requirement))
+
+	case strings.HasPrefix(bare, "lnurl1"), strings.HasPrefix(bare, "lnurlp://"),
+		!strings.ContainsAny(target, "{}") && router.IsLNURLPay(target):
+		if amount == "" {
+			return nil, fmt.Errorf("paying %s needs an amount in sats", target)
+		}
+		msat, err := parseMsat(amount)
+		if err != nil {
+			return nil, err
+		}
+		if len(target) > 10 && strings.EqualFold(target[:10], "lightning:") {
+			target = target[10:]
+		}
+		return []*router.PaymentRequirement{{
+			Protocol: router.ProtocolL402,
+			Raw:      target,
+			Details:  &router.LNURLPay{Target: target, AmountMsat: msat},
+		}}, nil
+
+	case bolt12.IsOffer(target):
+		offer := strings.TrimPrefix(strings.TrimPrefix(target, "lightning:"), "LIGHTNING:")
+		o, err := bolt12.DecodeOffer(offer)
+		if err != nil {
+			return nil, err
+		}
+		var msat int64
+		if amount != "" {
+			if msat, err = parseMsat(amount); err != nil {
+				return nil, err
+			}
+		}
+		return []*router.PaymentRequirement{{
+			Protocol: router.ProtocolL402,
+			Raw:      offer,
+			Details: &router.BOLT12Offer{
+				Offer:      offer,
+				ID:         o.ID,
+				IssuerID:   o.IssuerID,
+				Currency:   o.Currency,
+				OfferMsat:  o.AmountMsat(),
+				AmountMsat: msat,
+			},
+		}}, nil
+
+	case strings.HasPrefix(bare, "ln"):
+		inv, err := bolt11.Decode(target)
+		if err != nil {
+			return nil, err
+		}
+		if inv.AmountMsat == 0 {
+			return nil, fmt.Errorf("invoices without an amount are not supported")
+		}
+		if amount != "" {
+			msat, err := parseMsat(amount)
+			if err != nil {
+				return nil, err
+			}
+			if msat != inv.AmountMsat {
+				return nil, fmt.Errorf("invoice is for %d msat, not %d", inv.AmountMsat, msat)
+			}
+		}
+		invoice := target
+		if len(invoice) > 10 && strings.EqualFold(invoice[:10], "lightning:") {
+			invoice = invoice[10:]
+		}
+		return []*router.PaymentRequirement{{
+			Protocol:    router.ProtocolL402,
+			Raw:         invoice,
+			L402Invoice: invoice,
+		}}, nil
+
+	case strings.HasPrefix(lower, "solana:"):
+		if err := noAmount("a Solana Pay URI"); err != nil {
+			return nil, err
+		}
+		spr, err := router.ParseSolanaPayURL(target)
+		if err != nil {
+			return nil, err
+		}
+		return []*router.PaymentRequirement{{Protocol: router.ProtocolSolanaPay, Raw: target, Details: spr}}, nil
+	}
+
+	if obj, _, ok := jsonObject(target); ok && obj["accepts"] != nil {
+		if err := noAmount("an x402 requirement"); err != nil {
+			return nil, err
+		}
+		return router.ParseX402Requirement(target)
+	}
+	return nil, fmt.Errorf("don't know how to pay %q", target)
+}
+
+// parseMsat parses a Lightning amount: whole sats by default, or with a sat
+// or msat suffix.
+func parseMsat(s string) (int64, error) {
+	a := strings.ToLower(strings.TrimSpace(s))
+	scale := int64(1000)
+	switch {
+	case strings.HasSuffix(a, "msat"):
+		a, scale = strings.TrimSuffix(a, "msat"), 1
+	case strings.HasSuffix(a, "sats"):
+		a = strings.TrimSuffix(a, "sats")
+	case strings.HasSuffix(a, "sat"):
+		a = strings.TrimSuffix(a, "sat")
+	}
+	n, err := strconv.ParseInt(a, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid amount %q: want a whole number of sats", s)
+	}
+	return n * scale, nil
+}
+
+// parseUSDC parses a USDC amount in dollars, with an optional usd or usdc
+// suffix.
+func parseUSDC(s string) (router.Amount, error) {
+	a := strings.ToLower(strings.TrimSpace(s))
+	a = strings.TrimSuffix(strings.TrimSuffix(a, "c"), "usd")
+	usdc, err := router.ParseDecimal(strings.TrimPrefix(a, "$"), router.USDC)
+	if err != nil || usdc.Units <= 0 {
+		return router.Amount{}, fmt.Errorf("invalid amount %q: want USDC, e.g. 0.50", s)
+	}
+	return usdc, nil
+}
+
+// chosenAccept returns the x402 option the router paid on rail.
+func chosenAccept(options []*router.PaymentRequirement, rail string) *router.X402Accept {
+	for _, opt := range options {
+		if opt.X402Accept != nil && opt.Rail() == rail {
+			return opt.X402Accept
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+func TestPayOptions(t *testing.T) {
+	required, _ := json.Marshal(router.X402Requirement{Accepts: []router.X402Accept{*decodeTestAccept()}})
+	payTo := decodeTestAccept().PayTo
+
+	tests := []struct {
+		target, amount string
+		protocol       router.Protocol
+		rail           string
+	}{
+		{probeInvoice, "", router.ProtocolL402, "lightning"},
+		{"lightning:" + probeInvoice, "2000000sat", router.ProtocolL402, "lightning"},
+		{"agent@example.com", "100", router.ProtocolL402, "lightning"},
+		{"solana:9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM?amount=0.01", "", router.ProtocolSolanaPay, ""},
+		{payTo, "0.50", router.ProtocolX402, "eip155:8453"},
+		{base64.StdEncoding.EncodeToString(required), "", router.ProtocolX402, "eip155:8453"},
+	}
+	for _, tt := range tests {
+		options, err := payOptions(tt.target, tt.amount, "eip155:8453")
+		if err != nil {
+			t.Errorf("%.40s: %v", tt.target, err)
+			continue
+		}
+		if len(options) != 1 || options[0].Protocol != tt.protocol {
+			t.Errorf("%.40s: options = %+v", tt.target, options)
+			continue
+		}
+		if tt.rail != "" && options[0].Rail() != tt.rail {
+			t.Errorf("%.40s: rail %s, want %s", tt.target, options[0].Rail(), tt.rail)
+		}
+	}
+
+	options, _ := payOptions(payTo, "0.50", "eip155:8453")
+	if a := options[0].X402Accept; a.MaxAmountRequired != "500000" || a.PayTo != payTo {
+		t.Errorf("accept = %+v", a)
+	}
+	// Providers that sign the raw requirement get the one a seller would send
+	if raw, err := router.ParseX402Requirement(options[0].Raw); err != nil || raw[0].X402Accept.PayTo != payTo {
+		t.Errorf("raw requirement %q: %v", options[0].Raw, err)
+	}
+	options, _ = payOptions("agent@example.com", "1500msat", "eip155:8453")
+	if lp := options[0].Details.(*router.LNURLPay); lp.AmountMsat != 1500 {
+		t.Errorf("lnurl = %+v", lp)
+	}
+
+	for _, bad := range []struct{ target, amount string }{
+		{"agent@example.com", ""}, // no amount
+		{payTo, ""},               // no amount
+		{payTo, "-1"},             // bad amount
+		{probeInvoice, "100"},     // amount disagrees with the invoice
+		{"hello", ""},             // unknown target
+	} {
+		if _, err := payOptions(bad.target, bad.amount, "eip155:8453"); err == nil {
+			t.Errorf("%.40s %s: no error", bad.target, bad.amount)
+		}
+	}
+	if _, err := payOptions(payTo, "1", "eip155:1"); err == nil {
+		t.Error("unknown USDC network accepted")
+	}
+}
+
+// signingProvider signs any x402 option for $0.50.
+type signingProvider struct{}
+
+func (signingProvider) Protocol() router.Protocol { return router.ProtocolX402 }
+
+func (signingProvider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	return router.FromUSD(0.50), "0.50 USDC", nil
+}
+
+func (signingProvider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
+	return "Payment-Signature", "signed", nil
+}
+
+func TestPayTarget_FacilitatorFailure(t *testing.T) {
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer facilitator.Close()
+
+	r := router.New(router.Config{MaxPerRequestUSD: 1, MaxSessionUSD: 10})
+	r.RegisterProvider(signingProvider{})
+	path := filepath.Join(t.TempDir(), "receipts.jsonl")
+	ledger, err := openReceiptLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	req := payRequest{Target: decodeTestAccept().PayTo, Amount: "0.50", Facilitator: facilitator.URL}
+	if _, err := payTarget(context.Background(), r, req, ledger); err == nil {
+		t.Fatal("expected the settlement to fail")
+	}
+	// An authorization nobody submitted is not a payment
+	if b := r.Budget(); !b.Spent.IsZero() || b.Payments != 0 {
+		t.Errorf("budget = %+v", b)
+	}
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("ledger = %s", data)
+	}
+}
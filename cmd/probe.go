@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/bolt11"
+	"github.com/joelklabo/agentpay/internal/macaroon"
+	"github.com/joelklabo/agentpay/router"
+	"github.com/spf13/cobra"
+)
+
+var probeCmd = &cobra.Command{
+	Use:   "probe <url>",
+	Short: "Show what a paid URL charges without paying",
+	Long: `Sends the request once and decodes every payment option in the 402
+challenge: each x402 accepts entry, and each L402 invoice with its macaroon
+caveats. Each option is priced in USD and checked against the budget, payee
+pins and trust scoring that 'agentpay fetch' would apply, and the option
+fetch would pay is marked.
+
+No config is needed. Without one, or for rails no wallet is configured for,
+options are valued at face value: USDC at par and bitcoin at $100,000.
+
+Example:
+  agentpay probe https://api.example.com/v1/chat --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProbe,
+}
+
+var (
+	probeMethod   string
+	probeBody     string
+	probeHeaders  []string
+	probeBudget   float64
+	probeWoT      bool
+	probeStrategy string
+	probeDiscover bool
+	probeJSON     bool
+)
+
+func init() {
+	rootCmd.AddCommand(probeCmd)
+	probeCmd.Flags().StringVarP(&probeMethod, "method", "X", "GET", "HTTP method")
+	probeCmd.Flags().StringVarP(&probeBody, "data", "d", "", "Request body")
+	probeCmd.Flags().StringArrayVarP(&probeHeaders, "header", "H", nil, "HTTP headers (key: value)")
+	probeCmd.Flags().Float64Var(&probeBudget, "budget", 0, "Maximum USD per request to check against (default: the config's, else 1.00)")
+	probeCmd.Flags().BoolVar(&probeWoT, "wot", false, "Check payees against Web of Trust scoring")
+	probeCmd.Flags().StringVar(&probeStrategy, "strategy", "", "Routing strategy: cheapest, preferred, fastest, balance")
+	probeCmd.Flags().BoolVar(&probeDiscover, "discover", false, "Check the service's /.well-known/agentpay prices and payee pins")
+	probeCmd.Flags().BoolVar(&probeJSON, "json", false, "Print the result as JSON")
+}
+
+// probeReport is the output of agentpay probe.
+type probeReport struct {
+	URL        string              `json:"url"`
+	Method     string              `json:"method"`
+	Status     int                 `json:"status"`
+	Policy     probePolicy         `json:"policy"`
+	Route      *router.PricedRoute `json:"route,omitempty"`
+	RouteError string              `json:"route_error,omitempty"`
+	Options    []probeOption       `json:"options"`
+}
+
+type probePolicy struct {
+	MaxPerRequestUSD float64         `json:"max_per_request_usd"`
+	MaxSessionUSD    float64         `json:"max_session_usd"`
+	Strategy         router.Strategy `json:"strategy"`
+	WoT              bool            `json:"wot"`
+	Configured       bool            `json:"configured"`
+}
+
+type probeOption struct {
+	Protocol string `json:"protocol"`
+	Rail     string `json:"rail"`
+	Provider string `json:"provider,omitempty"`
+	// Cost is the USD estimate; Estimated means it is a face value rather
+	// than a provider's quote.
+	Cost        *router.Amount `json:"cost,omitempty"`
+	USDCost     float64        `json:"usd_cost,omitempty"`
+	Estimated   bool           `json:"estimated,omitempty"`
+	Description string         `json:"description,omitempty"`
+	// Allowed reports that policy permits paying the option, whether or
+	// not a wallet for its rail is configured.
+	Allowed bool   `json:"allowed"`
+	Chosen  bool   `json:"chosen,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+
+	X402     *router.X402Accept     `json:"x402,omitempty"`
+	Invoice  map[string]interface{} `json:"invoice,omitempty"`
+	Macaroon map[string]interface{} `json:"macaroon,omitempty"`
+	Details  interface{}            `json:"details,omitempty"`
+}
+
+func runProbe(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	// Probing never pays, so a missing config just means no wallets
+	cfg, err := loadConfig()
+	configured := err == nil
+	if !configured {
+		cfg = &AppConfig{}
+		cfg.Budget.MaxPerRequestUSD = 1.0
+		cfg.Budget.MaxSessionUSD = 10.0
+	}
+	rc := router.Config{
+		MaxPerRequestUSD: cfg.Budget.MaxPerRequestUSD,
+		MaxSessionUSD:    cfg.Budget.MaxSessionUSD,
+		Strategy:         router.Strategy(probeStrategy),
+		Discover:         probeDiscover,
+	}
+	if cmd.Flags().Changed("budget") {
+		rc.MaxPerRequestUSD = probeBudget
+		rc.MaxSessionUSD = probeBudget * 10
+	}
+	r, err := newRouter(cfg, rc)
+	if err != nil {
+		return err
+	}
+	if probeWoT {
+		r.SetWoTChecker(router.NewWoTChecker("https://maximumsats.joel-dfd.workers.dev/wot/score"))
+	}
+
+	hdrs := make(map[string]string)
+	for _, h := range probeHeaders {
+		if k, v, ok := strings.Cut(h, ":"); ok {
+			hdrs[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	var body io.Reader
+	if probeBody != "" {
+		body = strings.NewReader(probeBody)
+	}
+
+	result, err := r.Probe(ctx, probeMethod, args[0], body, hdrs)
+	if err != nil {
+		return fmt.Errorf("probe: %w", err)
+	}
+	report := newProbeReport(result)
+	report.Policy = probePolicy{
+		MaxPerRequestUSD: rc.MaxPerRequestUSD,
+		MaxSessionUSD:    rc.MaxSessionUSD,
+		Strategy:         router.Strategy(probeStrategy),
+		WoT:              probeWoT,
+		Configured:       configured,
+	}
+	if report.Policy.Strategy == "" {
+		report.Policy.Strategy, _ = router.ParseStrategy(cfg.Routing.Strategy)
+	}
+
+	if probeJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printProbeReport(os.Stdout, report)
+	return nil
+}
+
+// newProbeReport decodes every option of result for display.
+func newProbeReport(result *router.ProbeResult) *probeReport {
+	report := &probeReport{
+		URL:     result.URL,
+		Method:  result.Method,
+		Status:  result.StatusCode,
+		Route:   result.Route,
+		Options: []probeOption{},
+	}
+	if result.RouteErr != nil {
+		report.RouteError = result.RouteErr.Error()
+	}
+
+	for _, o := range result.Options {
+		req := o.Requirement
+		opt := probeOption{
+			Protocol:    req.Protocol.String(),
+			Rail:        req.Rail(),
+			Provider:    o.Provider,
+			Estimated:   o.Estimated,
+			Description: o.Description,
+			Allowed:     o.Err == nil || errors.Is(o.Err, router.ErrNoProvider),
+			Chosen:      o.Chosen,
+			X402:        req.X402Accept,
+		}
+		if o.Priced {
+			cost := o.Cost
+			opt.Cost = &cost
+			opt.USDCost = cost.Float64()
+		}
+		if o.Err != nil {
+			opt.Reason = o.Err.Error()
+		}
+		if req.L402Invoice != "" {
+			if inv, err := bolt11.Decode(req.L402Invoice); err == nil {
+				opt.Invoice = describeInvoice(inv)
+			} else {
+				opt.Invoice = map[string]interface{}{"bolt11": req.L402Invoice, "error": err.Error()}
+			}
+		}
+		if req.Protocol == router.ProtocolL402 && req.L402Hash != "" {
+			if m, err := macaroon.Decode(req.L402Hash); err == nil {
+				opt.Macaroon = describeMacaroon(m)
+			}
+		}
+		if req.X402Accept == nil && req.L402Invoice == "" {
+			opt.Details = req.Details
+		}
+		report.Options = append(report.Options, opt)
+	}
+	return report
+}
+
+func printProbeReport(out io.Writer, report *probeReport) {
+	fmt.Fprintf(out, "%s %s → HTTP %d\n", report.Method, report.URL, report.Status)
+	p := report.Policy
+	wot := "off"
+	if p.WoT {
+		wot = "on"
+	}
+	fmt.Fprintf(out, "Policy: $%.2f per request, $%.2f per session, %s, WoT %s", p.MaxPerRequestUSD, p.MaxSessionUSD, p.Strategy, wot)
+	if !p.Configured {
+		fmt.Fprint(out, " (no config: face-value estimates)")
+	}
+	fmt.Fprintln(out)
+	if report.Route != nil {
+		fmt.Fprintf(out, "Published price: %s\n", strings.TrimSpace(report.Route.Method+" "+report.Route.Path+" "+report.Route.Description))
+		for _, rp := range report.Route.Prices {
+			fmt.Fprintf(out, "  %s on %s: %s\n", rp.Protocol, rp.Rail, rp.Amount)
+		}
+		if report.RouteError != "" {
+			fmt.Fprintf(out, "  refused: %s\n", report.RouteError)
+		}
+	}
+	if len(report.Options) == 0 {
+		if report.Status == 402 {
+			fmt.Fprintln(out, "\nNo payment options found.")
+		} else {
+			fmt.Fprintln(out, "\nNo payment required.")
+		}
+		return
+	}
+
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tPROTOCOL\tRAIL\tPROVIDER\tUSD\tVERDICT")
+	for i, o := range report.Options {
+		provider := o.Provider
+		if provider == "" {
+			provider = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, o.Protocol, o.Rail, provider, probeCost(o), probeVerdict(o))
+	}
+	tw.Flush()
+
+	for i, o := range report.Options {
+		fmt.Fprintf(out, "\nOption %d: %s on %s\n", i+1, o.Protocol, o.Rail)
+		tw := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+		field := func(name string, value interface{}) {
+			if s := fmt.Sprint(value); s != "" && s != "0" {
+				fmt.Fprintf(tw, "  %s:\t%s\n", name, s)
+			}
+		}
+		if o.Description != "" {
+			field("quote", o.Description)
+		}
+		if a := o.X402; a != nil {
+			field("scheme", a.Scheme)
+			field("network", a.Network)
+			field("amount", a.MaxAmountRequired+" (atomic units)")
+			field("asset", a.Asset)
+			field("pay to", a.PayTo)
+			if a.MaxTimeoutSeconds > 0 {
+				field("timeout", time.Duration(a.MaxTimeoutSeconds)*time.Second)
+			}
+			field("resource", a.Resource)
+			field("description", a.Description)
+			field("mime type", a.MimeType)
+			if len(a.Extra) > 0 {
+				field("extra", string(a.Extra))
+			}
+		}
+		if inv := o.Invoice; inv != nil {
+			for _, k := range []string{"bolt11", "error", "currency", "amount_msat", "payment_hash", "payment_secret", "payee", "description", "description_hash", "timestamp", "expires_at", "min_final_cltv"} {
+				if v, ok := inv[k]; ok {
+					field(strings.ReplaceAll(k, "_", " "), v)
+				}
+			}
+		}
+		if m := o.Macaroon; m != nil {
+			field("macaroon location", m["location"])
+			if id, ok := m["identifier"].(map[string]interface{}); ok {
+				field("macaroon hash", id["payment_hash"])
+				field("macaroon token", id["token_id"])
+			}
+			for _, c := range m["caveats"].([]interface{}) {
+				field("caveat", c)
+			}
+		}
+		if o.Details != nil {
+			data, _ := json.Marshal(o.Details)
+			field("details", string(data))
+		}
+		tw.Flush()
+	}
+}
+
+// probeCost formats an option's USD cost; "~" marks a face-value estimate.
+func probeCost(o probeOption) string {
+	if o.Cost == nil {
+		return "?"
+	}
+	s := fmt.Sprintf("$%.4f", o.USDCost)
+	if o.Estimated {
+		s = "~" + s
+	}
+	return s
+}
+
+func probeVerdict(o probeOption) string {
+	switch {
+	case o.Chosen:
+		return "would pay"
+	case o.Reason == "":
+		return "allowed"
+	case o.Allowed:
+		return "allowed (no wallet for this rail)"
+	default:
+		return "refused: " + o.Reason
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/joelklabo/agentpay/internal/macaroon"
+	"github.com/joelklabo/agentpay/router"
+)
+
+const probeInvoice = "lnbc20m1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqs9qrsgq7ea976txfraylvgzuxs8kgcw23ezlrszfnh8r6qtfpr6cxga50aj6txm9rxrydzd06dfeawfk6swupvz4erwnyutnjq7x39ymw6j38gp7ynn44"
+
+func TestNewProbeReport(t *testing.T) {
+	mac := macaroon.New([]byte("root"), make([]byte, 66), "agentpay")
+	mac.AddFirstPartyCaveat(macaroon.Services("api"))
+	result := &router.ProbeResult{
+		URL:        "https://api.example.com/gen",
+		Method:     "GET",
+		StatusCode: 402,
+		Options: []router.ProbeOption{{
+			Requirement: &router.PaymentRequirement{
+				Protocol:    router.ProtocolL402,
+				L402Invoice: probeInvoice,
+				L402Hash:    mac.Encode(),
+			},
+			Cost:      router.FromUSD(2000),
+			Priced:    true,
+			Estimated: true,
+			Err:       router.ErrBudgetExceeded,
+		}},
+	}
+
+	report := newProbeReport(result)
+	opt := report.Options[0]
+	if opt.Allowed || opt.Reason == "" || opt.USDCost != 2000 {
+		t.Errorf("option = %+v", opt)
+	}
+	if opt.Invoice["amount_msat"] != int64(2_000_000_000) || opt.Invoice["payment_hash"] == "" {
+		t.Errorf("invoice = %v", opt.Invoice)
+	}
+	if caveats := opt.Macaroon["caveats"].([]interface{}); len(caveats) != 1 || caveats[0] != "services=api:0" {
+		t.Errorf("caveats = %v", caveats)
+	}
+
+	var out bytes.Buffer
+	printProbeReport(&out, report)
+	for _, want := range []string{"~$2000.0000", "refused:", "caveat:", "services=api:0", "payee:"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// newRouter builds a router from rc, applies the routing settings from cfg
+// (flags set on rc take precedence) and registers every configured provider.
+func newRouter(cfg *AppConfig, rc router.Config) (*router.Router, error) {
+	name := string(rc.Strategy)
+	if name == "" {
+		name = cfg.Routing.Strategy
+	}
+	strategy, err := router.ParseStrategy(name)
+	if err != nil {
+		return nil, err
+	}
+	rc.Strategy = strategy
+	if len(rc.PreferredRails) == 0 {
+		rc.PreferredRails = cfg.Routing.PreferredRails
+	}
+	if rc.LowBalanceUSD == 0 {
+		rc.LowBalanceUSD = cfg.Budget.LowBalanceUSD
+	}
+	rc.Discover = rc.Discover || cfg.Routing.Discover
+
+	r := router.New(rc)
+	r.RegisterDetector(router.CashuDetector{})
+	r.RegisterDetector(router.SolanaPayDetector{})
+	r.OnEvent(func(e router.Event) {
+		if e.Type == router.EventLowBalance {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", e.Message)
+		}
+	})
+	registerProviders(r, cfg)
+	return r, nil
+}
+
+// registerProviders adds a provider for every payment backend present in cfg.
+// Several providers may serve the same protocol; lower priority values are
+// tried first and the router fails over between them.
+func registerProviders(r *router.Router, cfg *AppConfig) {
+	if cfg.AgentWallet.Username != "" {
+		x402 := providers.NewX402Provider(
+			cfg.AgentWallet.APIBase,
+			cfg.AgentWallet.Username,
+			cfg.AgentWallet.Token,
+		)
+		if cfg.AgentWallet.PreferredChain != "" {
+			x402.PreferredChain = cfg.AgentWallet.PreferredChain
+		}
+		r.RegisterProvider(x402, router.WithPriority(cfg.AgentWallet.Priority))
+		r.RegisterProvider(newSolanaProvider(cfg), router.WithPriority(cfg.AgentWallet.Priority))
+	}
+
+	if cfg.CDP.Wallet != "" {
+		cdp, err := newCDPProvider()
+		if err == nil {
+			err = cdp.Init(context.Background(), cfg.CDP.Wallet)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: CDP wallet disabled: %v\n", err)
+		} else {
+			r.RegisterProvider(cdp, router.WithPriority(cfg.CDP.Priority))
+		}
+	}
+
+	if cfg.EVMKey.Keystore != "" {
+		evm, err := newEVMKeyProvider(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: EVM keystore disabled: %v\n", err)
+		} else {
+			r.RegisterProvider(evm, router.WithPriority(cfg.EVMKey.Priority))
+		}
+	}
+
+	if cfg.SVMKey.Keypair != "" {
+		svm, err := providers.LoadSVMKeyProvider(cfg.SVMKey.Keypair)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: Solana keypair disabled: %v\n", err)
+		} else {
+			rpc := &providers.RPCBlockhashSource{Endpoints: cfg.SVMKey.RPC}
+			svm.Blockhash = rpc
+			r.RegisterProvider(svm, router.WithPriority(cfg.SVMKey.Priority))
+			r.RegisterProvider(svm.SolanaPay(rpc), router.WithPriority(cfg.SVMKey.Priority))
+		}
+	}
+
+	if cfg.LNbits.URL != "" {
+		l402 := providers.NewL402Provider(cfg.LNbits.URL, cfg.LNbits.AdminKey)
+		r.RegisterProvider(l402, router.WithPriority(cfg.LNbits.Priority))
+	}
+
+	if cfg.LND.URL != "" {
+		lnd, err := newLNDProvider(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: LND disabled: %v\n", err)
+		} else {
+			r.RegisterProvider(lnd, router.WithPriority(cfg.LND.Priority))
+		}
+	}
+
+	if cfg.CLN.URL != "" {
+		cln, err := newCLNProvider(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: Core Lightning disabled: %v\n", err)
+		} else {
+			r.RegisterProvider(cln, router.WithPriority(cfg.CLN.Priority))
+		}
+	}
+
+	if cfg.NWC.URI != "" {
+		nwc, err := newNWCProvider(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: Nostr Wallet Connect disabled: %v\n", err)
+		} else {
+			r.RegisterProvider(nwc, router.WithPriority(cfg.NWC.Priority))
+		}
+	}
+
+	if cfg.Phoenixd.URL != "" {
+		r.RegisterProvider(newPhoenixdProvider(cfg), router.WithPriority(cfg.Phoenixd.Priority))
+	}
+
+	if cfg.Cashu.MintURL != "" {
+		r.RegisterProvider(newCashuProvider(cfg), router.WithPriority(cfg.Cashu.Priority))
+	}
+}
+
+// newLNDProvider builds an L402 provider that pays through the LND node in cfg.
+func newLNDProvider(cfg *AppConfig) (*providers.L402Provider, error) {
+	if cfg.LND.Macaroon == "" {
+		return nil, fmt.Errorf("lnd.macaroon is not set")
+	}
+	lnd, err := providers.LoadLNDBackend(cfg.LND.URL, cfg.LND.Macaroon, cfg.LND.TLSCert)
+	if err != nil {
+		return nil, err
+	}
+	lnd.FeeLimitSat = cfg.LND.MaxFeeSat
+	if cfg.LND.TimeoutSeconds > 0 {
+		lnd.Timeout = time.Duration(cfg.LND.TimeoutSeconds) * time.Second
+	}
+	return providers.NewL402ProviderWithBackend(lnd), nil
+}
+
+// newCLNProvider builds an L402 provider that pays through the Core Lightning
+// node in cfg.
+func newCLNProvider(cfg *AppConfig) (*providers.L402Provider, error) {
+	if cfg.CLN.Rune == "" {
+		return nil, fmt.Errorf("cln.rune is not set")
+	}
+	cln, err := providers.LoadCLNBackend(cfg.CLN.URL, cfg.CLN.Rune, cfg.CLN.TLSCert)
+	if err != nil {
+		return nil, err
+	}
+	cln.MaxFeePercent = cfg.CLN.MaxFeePercent
+	if cfg.CLN.RetryForSeconds > 0 {
+		cln.RetryFor = time.Duration(cfg.CLN.RetryForSeconds) * time.Second
+	}
+	return providers.NewL402ProviderWithBackend(cln), nil
+}
+
+// newNWCProvider builds an L402 provider that pays through the Nostr Wallet
+// Connect wallet in cfg.
+func newNWCProvider(cfg *AppConfig) (*providers.L402Provider, error) {
+	nwc, err := providers.NewNWCBackend(cfg.NWC.URI)
+	if err != nil {
+		return nil, err
+	}
+	nwc.Encryption = cfg.NWC.Encryption
+	if cfg.NWC.TimeoutSeconds > 0 {
+		nwc.Timeout = time.Duration(cfg.NWC.TimeoutSeconds) * time.Second
+	}
+	return providers.NewL402ProviderWithBackend(nwc), nil
+}
+
+// newPhoenixdProvider builds an L402 provider that pays through the phoenixd
+// node in cfg.
+func newPhoenixdProvider(cfg *AppConfig) *providers.L402Provider {
+	return providers.NewL402ProviderWithBackend(providers.NewPhoenixdBackend(cfg.Phoenixd.URL, cfg.Phoenixd.Password))
+}
+
+// newLightningProvider returns an L402 provider for the first configured
+// Lightning backend, for paying invoices outside a 402 flow.
+func newLightningProvider(cfg *AppConfig) (*providers.L402Provider, error) {
+	switch {
+	case cfg.LNbits.URL != "":
+		return providers.NewL402Provider(cfg.LNbits.URL, cfg.LNbits.AdminKey), nil
+	case cfg.LND.URL != "":
+		return newLNDProvider(cfg)
+	case cfg.CLN.URL != "":
+		return newCLNProvider(cfg)
+	case cfg.NWC.URI != "":
+		return newNWCProvider(cfg)
+	case cfg.Phoenixd.URL != "":
+		return newPhoenixdProvider(cfg), nil
+	}
+	return nil, fmt.Errorf("no Lightning wallet configured (lnbits, lnd, cln, nwc or phoenixd)")
+}
+
+// newCashuProvider builds the Cashu wallet from cfg, funded over Lightning by
+// the first configured Lightning backend.
+func newCashuProvider(cfg *AppConfig) *providers.CashuProvider {
+	walletFile := cfg.Cashu.WalletFile
+	if walletFile == "" {
+		walletFile = filepath.Join(filepath.Dir(configPath()), "cashu.json")
+	}
+	cashu := providers.NewCashuProvider(cfg.Cashu.MintURL, walletFile)
+	if ln, err := newLightningProvider(cfg); err == nil {
+		cashu.Payer = ln
+	}
+	return cashu
+}
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/joelklabo/agentpay/providers"
 	"github.com/joelklabo/agentpay/router"
 	"github.com/spf13/cobra"
 )
@@ -44,23 +43,13 @@ func runProxy(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("load config: %w", err)
 	}
 
-	r := router.New(router.Config{
+	r, err := newRouter(cfg, router.Config{
 		MaxPerRequestUSD: cfg.Budget.MaxPerRequestUSD,
 		MaxSessionUSD:    proxyBudget,
 		Verbose:          true,
 	})
-
-	if cfg.AgentWallet.Username != "" {
-		x402 := providers.NewX402Provider(
-			cfg.AgentWallet.APIBase,
-			cfg.AgentWallet.Username,
-			cfg.AgentWallet.Token,
-		)
-		r.RegisterProvider(x402)
-	}
-	if cfg.LNbits.URL != "" {
-		l402 := providers.NewL402Provider(cfg.LNbits.URL, cfg.LNbits.AdminKey)
-		r.RegisterProvider(l402)
+	if err != nil {
+		return err
 	}
 
 	mux := http.NewServeMux()
@@ -115,7 +104,7 @@ func runProxy(cmd *cobra.Command, args []string) error {
 		w.Header().Set("Content-Type", "application/json")
 		receipts := r.Receipts()
 		fmt.Fprintf(w, `{"session_spend_usd":%.4f,"payment_count":%d,"receipts":%d}`,
-			r.SessionSpend(), len(receipts), len(receipts))
+			r.SessionSpend().Float64(), len(receipts), len(receipts))
 	})
 
 	addr := fmt.Sprintf(":%d", proxyPort)
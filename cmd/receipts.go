@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joelklabo/agentpay/router"
+	"github.com/spf13/cobra"
+)
+
+var receiptsCmd = &cobra.Command{
+	Use:   "receipts",
+	Short: "List payments made",
+	Long: `Lists payment receipts, oldest first. With a daemon running, these
+are the payments it has made since it started. Without one, they are read
+from the receipts ledger (receipts.jsonl next to the config) that fetch, pay,
+mcp and the daemon append to.`,
+	Args: cobra.NoArgs,
+	RunE: runReceipts,
+}
+
+var (
+	receiptsLimit int
+	receiptsFile  string
+	receiptsJSON  bool
+)
+
+func init() {
+	rootCmd.AddCommand(receiptsCmd)
+	receiptsCmd.Flags().IntVarP(&receiptsLimit, "limit", "n", 20, "Show only the most recent receipts (0 for all)")
+	receiptsCmd.Flags().StringVar(&receiptsFile, "file", "", "Ledger to read without a daemon (default receipts.jsonl next to the config)")
+	receiptsCmd.Flags().BoolVar(&receiptsJSON, "json", false, "Print the receipts as JSON")
+}
+
+func runReceipts(cmd *cobra.Command, args []string) error {
+	var res receiptsResult
+	source := "daemon"
+	err := errNoDaemon
+	if !cmd.Flags().Changed("file") {
+		err = callDaemon("receipts", nil, &res)
+	}
+	if errors.Is(err, errNoDaemon) {
+		source = receiptsFile
+		if source == "" {
+			source = defaultReceiptsPath()
+		}
+		res.Receipts, err = readReceipts(source)
+	}
+	if err != nil {
+		return err
+	}
+
+	total := len(res.Receipts)
+	receipts := res.Receipts
+	if receiptsLimit > 0 && len(receipts) > receiptsLimit {
+		receipts = receipts[len(receipts)-receiptsLimit:]
+	}
+	if receipts == nil {
+		receipts = []router.Receipt{}
+	}
+
+	if receiptsJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(receipts)
+	}
+	if source == "daemon" {
+		fmt.Printf("%d payments by the daemon since %s\n", total, res.Since.Local().Format(time.DateTime))
+	} else {
+		fmt.Printf("%d payments in %s\n", total, source)
+	}
+	printReceipts(os.Stdout, receipts)
+	return nil
+}
+
+// readReceipts reads a receipts ledger. A missing ledger has no receipts.
+func readReceipts(path string) ([]router.Receipt, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open receipts: %w", err)
+	}
+	defer f.Close()
+
+	var receipts []router.Receipt
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r router.Receipt
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, scanner.Err()
+}
+
+func printReceipts(out io.Writer, receipts []router.Receipt) {
+	if len(receipts) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tPROTOCOL\tRAIL\tUSD\tAMOUNT\tURL")
+	var total float64
+	for _, r := range receipts {
+		rail := r.Rail
+		if rail == "" {
+			rail = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t$%.4f\t%s\t%s\n",
+			r.Timestamp.Local().Format(time.DateTime), r.Protocol, rail, r.USDCost, r.Amount, r.URL)
+		total += r.USDCost
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "\nTotal shown: $%.4f\n", total)
+}
@@ -1,10 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/joelklabo/agentpay/router"
 	"github.com/spf13/cobra"
 )
 
@@ -26,9 +33,21 @@ var registryAddCmd = &cobra.Command{
 	RunE:  runRegistryAdd,
 }
 
+var registryDiscoverCmd = &cobra.Command{
+	Use:   "discover <base-url>",
+	Short: "Import a service's paid routes from its /.well-known/agentpay document",
+	Long: `Fetches and validates the pricing document a service publishes at
+/.well-known/agentpay, then adds one registry entry per priced route with
+its method, cost hint and pinned payees. Entries already in the registry for
+the same method and URL are replaced.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRegistryDiscover,
+}
+
 func init() {
 	registryCmd.AddCommand(registryListCmd)
 	registryCmd.AddCommand(registryAddCmd)
+	registryCmd.AddCommand(registryDiscoverCmd)
 }
 
 // APIEntry represents a known paid API.
@@ -38,6 +57,9 @@ type APIEntry struct {
 	Protocol    string `json:"protocol"` // "x402", "l402", "auto"
 	Description string `json:"description,omitempty"`
 	CostHint    string `json:"cost_hint,omitempty"`
+	Method      string `json:"method,omitempty"`
+	// Pins are the payees the service's pricing document lists.
+	Pins []string `json:"pins,omitempty"`
 }
 
 func registryPath() string {
@@ -101,6 +123,9 @@ func runRegistryList(cmd *cobra.Command, args []string) error {
 		if e.CostHint != "" {
 			fmt.Printf("    Cost: %s\n", e.CostHint)
 		}
+		if len(e.Pins) > 0 {
+			fmt.Printf("    Pins: %s\n", strings.Join(e.Pins, ", "))
+		}
 	}
 	return nil
 }
@@ -125,3 +150,76 @@ func runRegistryAdd(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Added %s (%s) → %s\n", entry.Name, entry.Protocol, entry.URL)
 	return nil
 }
+
+func runRegistryDiscover(cmd *cobra.Command, args []string) error {
+	base := strings.TrimRight(args[0], "/")
+	doc, err := router.FetchPricing(context.Background(), &http.Client{Timeout: 30 * time.Second}, base)
+	if err != nil {
+		return err
+	}
+	u, _ := url.Parse(base)
+	discovered := discoveredEntries(u.Scheme+"://"+u.Host, doc)
+	if len(discovered) == 0 {
+		fmt.Printf("%s lists no paid routes\n", u.Host)
+		return nil
+	}
+
+	entries, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	for _, d := range discovered {
+		entries = slices.DeleteFunc(entries, func(e APIEntry) bool {
+			return e.URL == d.URL && e.Method == d.Method
+		})
+		entries = append(entries, d)
+		fmt.Printf("Added %s (%s) → %s %s, %s\n", d.Name, d.Protocol, d.Method, d.URL, d.CostHint)
+	}
+	return saveRegistry(entries)
+}
+
+// discoveredEntries turns the routes of a pricing document into registry
+// entries under origin.
+func discoveredEntries(origin string, doc *router.PricingDocument) []APIEntry {
+	host := strings.TrimPrefix(strings.TrimPrefix(origin, "https://"), "http://")
+	var entries []APIEntry
+	for _, route := range doc.Routes {
+		entry := APIEntry{
+			Name:        host + strings.TrimRight(route.Path, "/"),
+			URL:         origin + route.Path,
+			Method:      route.Method,
+			Description: route.Description,
+		}
+		protocols := map[string]bool{}
+		var hints []string
+		for _, p := range route.Prices {
+			protocols[strings.ToLower(p.Protocol)] = true
+			hints = append(hints, costHint(p))
+			if p.PayTo != "" && !slices.Contains(entry.Pins, p.PayTo) {
+				entry.Pins = append(entry.Pins, p.PayTo)
+			}
+		}
+		entry.Protocol = "auto"
+		if len(protocols) == 1 {
+			for p := range protocols {
+				entry.Protocol = p
+			}
+		}
+		entry.CostHint = strings.Join(hints, " or ")
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// costHint describes a price the way the default registry does, e.g.
+// "$0.01 USDC" or "10 sats".
+func costHint(p router.RoutePrice) string {
+	if p.Amount.Asset == router.USDC {
+		return "$" + p.Amount.Decimal() + " USDC"
+	}
+	sats := p.Amount
+	if sats.Asset == router.Msat {
+		sats = router.NewAmount(sats.Units/1000, router.Sat)
+	}
+	return fmt.Sprintf("%d sats", sats.Units)
+}
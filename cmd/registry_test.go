@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+func TestDiscoveredEntries(t *testing.T) {
+	doc := &router.PricingDocument{Version: 1, Routes: []router.PricedRoute{
+		{Path: "/v1/", Description: "chat", Prices: []router.RoutePrice{
+			{Protocol: "x402", Rail: "eip155:8453", Amount: router.NewAmount(10000, router.USDC), PayTo: "0xabc"},
+			{Protocol: "L402", Rail: "lightning", Amount: router.NewAmount(50000, router.Msat)},
+		}},
+		{Method: "POST", Path: "/gen", Prices: []router.RoutePrice{
+			{Protocol: "L402", Rail: "lightning", Amount: router.NewAmount(21, router.Sat)},
+		}},
+	}}
+	entries := discoveredEntries("https://api.example.com", doc)
+	if len(entries) != 2 {
+		t.Fatalf("entries %+v", entries)
+	}
+	chat, gen := entries[0], entries[1]
+	if chat.Name != "api.example.com/v1" || chat.URL != "https://api.example.com/v1/" || chat.Protocol != "auto" ||
+		chat.CostHint != "$0.01 USDC or 50 sats" || len(chat.Pins) != 1 || chat.Pins[0] != "0xabc" || chat.Description != "chat" {
+		t.Errorf("chat = %+v", chat)
+	}
+	if gen.Method != "POST" || gen.Protocol != "l402" || gen.CostHint != "21 sats" || gen.Pins != nil {
+		t.Errorf("gen = %+v", gen)
+	}
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joelklabo/agentpay/paywall"
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Sell an existing HTTP service to agents over x402 and L402",
+	Long: `Runs a reverse proxy in front of an upstream HTTP service and charges
+for the routes given with --price. Unpaid requests get a 402 challenge;
+paid ones are forwarded to the upstream. Prices are published at
+/.well-known/agentpay, and each payment received is appended to the
+receipts file as a JSON line in the same receipt format as payments made.
+
+A price is a route pattern and one or more amounts:
+  --price '/v1/*=0.01usd'        x402, USDC to --pay-to
+  --price 'POST /gen=50sat'      L402, invoiced by the configured Lightning wallet
+  --price '/img/*=0.02usd,20sat' either rail
+
+Example:
+  agentpay serve --upstream http://localhost:3000 --pay-to 0xYou \
+    --price '/v1/*=0.01usd' --price /gen=50sat`,
+	RunE: runServe,
+}
+
+var (
+	serveUpstream    string
+	serveListen      string
+	servePrices      []string
+	servePayTo       string
+	serveNetwork     string
+	serveAsset       string
+	serveFacilitator string
+	serveReceipts    string
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveUpstream, "upstream", "", "Upstream service URL, e.g. http://localhost:3000")
+	serveCmd.Flags().StringVar(&serveListen, "listen", ":8080", "Address to listen on")
+	serveCmd.Flags().StringArrayVar(&servePrices, "price", nil, "Route price as pattern=amount[,amount], e.g. /v1/*=0.01usd (repeatable)")
+	serveCmd.Flags().StringVar(&servePayTo, "pay-to", "", "Address that receives x402 payments (enables x402)")
+	serveCmd.Flags().StringVar(&serveNetwork, "network", "eip155:8453", "CAIP-2 network for x402 payments")
+	serveCmd.Flags().StringVar(&serveAsset, "asset", "", "USDC contract for x402 payments (default: USDC on --network)")
+	serveCmd.Flags().StringVar(&serveFacilitator, "facilitator", "https://x402.org/facilitator", "x402 facilitator that settles payments")
+	serveCmd.Flags().StringVar(&serveReceipts, "receipts", "", "File to append incoming-payment receipts to (default receipts-incoming.jsonl next to the config)")
+	serveCmd.MarkFlagRequired("upstream")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	upstream, err := url.Parse(serveUpstream)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		return fmt.Errorf("invalid --upstream %q", serveUpstream)
+	}
+	prices, err := parsePrices(servePrices)
+	if err != nil {
+		return err
+	}
+	if len(prices) == 0 {
+		return fmt.Errorf("nothing to sell: give at least one --price")
+	}
+
+	var wantUSDC, wantSats bool
+	for _, p := range prices {
+		wantUSDC = wantUSDC || !p.USDC.IsZero()
+		wantSats = wantSats || p.Msat > 0
+	}
+
+	pcfg := paywall.Config{}
+	if wantUSDC {
+		if servePayTo == "" {
+			return fmt.Errorf("USD prices need --pay-to")
+		}
+		asset := serveAsset
+		if asset == "" {
+			if asset = router.USDCContract(serveNetwork); asset == "" {
+				return fmt.Errorf("no known USDC contract on %s: set --asset", serveNetwork)
+			}
+		}
+		pcfg.X402 = &paywall.X402Config{
+			Network:     serveNetwork,
+			Asset:       asset,
+			PayTo:       servePayTo,
+			Facilitator: &paywall.FacilitatorClient{URL: serveFacilitator},
+		}
+	}
+	btcPrice := int64(0)
+	if wantSats {
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("load config: %w (sat prices need a Lightning wallet; run 'agentpay init')", err)
+		}
+		ln, err := newLightningProvider(cfg)
+		if err != nil {
+			return err
+		}
+		invoicer, ok := ln.Backend().(providers.Invoicer)
+		if !ok {
+			return fmt.Errorf("Lightning backend %T cannot create invoices", ln.Backend())
+		}
+		pcfg.Lightning = invoicer
+		btcPrice = ln.BTCPriceUSD
+	}
+
+	receiptsPath := serveReceipts
+	if receiptsPath == "" {
+		receiptsPath = filepath.Join(filepath.Dir(configPath()), "receipts-incoming.jsonl")
+	}
+	receipts, err := openReceiptLog(receiptsPath)
+	if err != nil {
+		return err
+	}
+	defer receipts.Close()
+	pcfg.OnPayment = func(p paywall.Payment) {
+		receipt := incomingReceipt(p, btcPrice)
+		log.Printf("PAID: %s %s (%s)", receipt.Protocol, receipt.Amount, receipt.URL)
+		if err := receipts.Append(receipt); err != nil {
+			log.Printf("ERROR: write receipt: %v", err)
+		}
+	}
+
+	pw, err := paywall.New(pcfg)
+	if err != nil {
+		return err
+	}
+	for pattern, price := range prices {
+		pw.SetPrice(pattern, price)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = upstream.Host
+		// Payment proofs are for us, not the upstream
+		if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "L402 ") || strings.HasPrefix(auth, "LSAT ") {
+			req.Header.Del("Authorization")
+		}
+		for _, h := range []string{"Payment-Signature", "X-Payment", "Payment"} {
+			req.Header.Del(h)
+		}
+	}
+
+	log.Printf("AgentPay selling %s on %s", upstream, serveListen)
+	for _, route := range pw.Pricing().Routes {
+		for _, p := range route.Prices {
+			log.Printf("  %s: %s via %s", strings.TrimSpace(route.Method+" "+route.Path), p.Amount, p.Protocol)
+		}
+	}
+	log.Printf("Receipts: %s", receiptsPath)
+	return http.ListenAndServe(serveListen, pw.Handler(proxy))
+}
+
+// parsePrices reads --price flags into paywall prices by ServeMux pattern.
+// A trailing "/*" covers the subtree; amounts end in usd, sat or msat.
+func parsePrices(flags []string) (map[string]paywall.Price, error) {
+	prices := make(map[string]paywall.Price)
+	for _, f := range flags {
+		i := strings.LastIndex(f, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid --price %q: want pattern=amount", f)
+		}
+		pattern, amounts := strings.TrimSpace(f[:i]), f[i+1:]
+		if strings.HasSuffix(pattern, "/*") {
+			pattern = strings.TrimSuffix(pattern, "*")
+		}
+		if strings.Contains(pattern, "*") {
+			return nil, fmt.Errorf("invalid --price %q: * is only allowed as a final path segment", f)
+		}
+		if !strings.Contains(pattern, "/") {
+			return nil, fmt.Errorf("invalid --price %q: pattern must contain a path", f)
+		}
+
+		price := prices[pattern]
+		for _, a := range strings.Split(amounts, ",") {
+			a = strings.ToLower(strings.TrimSpace(a))
+			switch {
+			case strings.HasSuffix(a, "usdc"), strings.HasSuffix(a, "usd"):
+				usdc, err := router.ParseDecimal(strings.TrimSuffix(strings.TrimSuffix(a, "c"), "usd"), router.USDC)
+				if err != nil || usdc.Units <= 0 {
+					return nil, fmt.Errorf("invalid --price %q: bad USD amount %q", f, a)
+				}
+				price.USDC = usdc
+			case strings.HasSuffix(a, "msat"):
+				msat, err := strconv.ParseInt(strings.TrimSuffix(a, "msat"), 10, 64)
+				if err != nil || msat <= 0 {
+					return nil, fmt.Errorf("invalid --price %q: bad msat amount %q", f, a)
+				}
+				price.Msat = msat
+			case strings.HasSuffix(a, "sats"), strings.HasSuffix(a, "sat"):
+				sats, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSuffix(a, "s"), "sat"), 10, 64)
+				if err != nil || sats <= 0 {
+					return nil, fmt.Errorf("invalid --price %q: bad sat amount %q", f, a)
+				}
+				price.Msat = sats * 1000
+			default:
+				return nil, fmt.Errorf("invalid --price %q: amount %q needs a usd, sat or msat suffix", f, a)
+			}
+		}
+		prices[pattern] = price
+	}
+	return prices, nil
+}
+
+// incomingReceipt records a received payment as a router.Receipt, valued in
+// USD the way outgoing payments are.
+func incomingReceipt(p paywall.Payment, btcPriceUSD int64) router.Receipt {
+	receipt := router.Receipt{
+		Timestamp:   time.Now(),
+		URL:         p.Resource,
+		Protocol:    p.Protocol.String(),
+		Description: p.Description,
+		TxID:        p.TxID,
+		Preimage:    p.Preimage,
+		Reference:   p.PaymentHash,
+		Rail:        p.Rail,
+		Direction:   "incoming",
+	}
+	if receipt.Description == "" {
+		receipt.Description = p.Pattern
+	}
+	if p.Amount.Asset == router.USDC {
+		receipt.Cost = router.NewAmount(p.Amount.Units, router.USD)
+		receipt.Amount = fmt.Sprintf("%s on %s from %s", p.Amount, p.Rail, p.Payer)
+	} else {
+		receipt.Cost = router.BTCToUSD(p.Amount, btcPriceUSD)
+		receipt.Amount = fmt.Sprintf("%d sats ($%.4f)", p.Amount.Units/1000, receipt.Cost.Float64())
+	}
+	receipt.USDCost = receipt.Cost.Float64()
+	return receipt
+}
+
+// defaultReceiptsPath is the ledger that agentpay's own payments are
+// appended to: receipts.jsonl next to the config.
+func defaultReceiptsPath() string {
+	return filepath.Join(filepath.Dir(configPath()), "receipts.jsonl")
+}
+
+// receiptLog appends receipts to a file, one JSON object per line.
+type receiptLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openReceiptLog(path string) (*receiptLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create receipts dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open receipts: %w", err)
+	}
+	return &receiptLog{file: f}, nil
+}
+
+func (l *receiptLog) Append(receipt router.Receipt) error {
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(data, '\n'))
+	return err
+}
+
+func (l *receiptLog) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/joelklabo/agentpay/paywall"
+	"github.com/joelklabo/agentpay/router"
+)
+
+func TestParsePrices(t *testing.T) {
+	prices, err := parsePrices([]string{"/v1/*=0.01usd", "POST /gen=50sat", "/img/*=0.02usdc, 20sats", "/gen=1msat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]paywall.Price{
+		"/v1/":      {USDC: router.NewAmount(10000, router.USDC)},
+		"POST /gen": {Msat: 50000},
+		"/img/":     {USDC: router.NewAmount(20000, router.USDC), Msat: 20000},
+		"/gen":      {Msat: 1},
+	}
+	if len(prices) != len(want) {
+		t.Fatalf("prices %+v", prices)
+	}
+	for pattern, w := range want {
+		if got := prices[pattern]; got != w {
+			t.Errorf("%s = %+v, want %+v", pattern, got, w)
+		}
+	}
+
+	for _, bad := range []string{"/v1", "/v1=", "/v1=10", "/v1/*/x=1sat", "v1=1sat", "/v1=-1sat", "/v1=0.0000001usd"} {
+		if _, err := parsePrices([]string{bad}); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+func TestIncomingReceipt(t *testing.T) {
+	r := incomingReceipt(paywall.Payment{
+		Protocol:    router.ProtocolL402,
+		Rail:        "lightning",
+		Amount:      router.NewAmount(50000, router.Msat),
+		Pattern:     "POST /gen",
+		Resource:    "http://localhost:8080/gen",
+		PaymentHash: "ab",
+	}, 100000)
+	if r.Direction != "incoming" || r.Cost != router.NewAmount(50000, router.USD) || r.Amount != "50 sats ($0.0500)" ||
+		r.Description != "POST /gen" || r.Reference != "ab" {
+		t.Errorf("receipt %+v", r)
+	}
+}
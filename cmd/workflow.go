@@ -8,7 +8,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/joelklabo/agentpay/providers"
 	"github.com/joelklabo/agentpay/router"
 	"github.com/spf13/cobra"
 )
@@ -37,28 +36,14 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("load config: %w (run 'agentpay init' first)", err)
 	}
 
-	r := router.New(router.Config{
+	r, err := newRouter(cfg, router.Config{
 		MaxPerRequestUSD: cfg.Budget.MaxPerRequestUSD,
 		MaxSessionUSD:    cfg.Budget.MaxSessionUSD,
 		DryRun:           fetchDryRun,
 		Verbose:          true,
 	})
-
-	// Register providers
-	if cfg.AgentWallet.Username != "" {
-		x402 := providers.NewX402Provider(
-			cfg.AgentWallet.APIBase,
-			cfg.AgentWallet.Username,
-			cfg.AgentWallet.Token,
-		)
-		if cfg.AgentWallet.PreferredChain != "" {
-			x402.PreferredChain = cfg.AgentWallet.PreferredChain
-		}
-		r.RegisterProvider(x402)
-	}
-	if cfg.LNbits.URL != "" {
-		l402 := providers.NewL402Provider(cfg.LNbits.URL, cfg.LNbits.AdminKey)
-		r.RegisterProvider(l402)
+	if err != nil {
+		return err
 	}
 
 	// Enable WoT trust scoring
@@ -120,7 +105,7 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println("━━━ Cost Breakdown ━━━")
 	elapsed := time.Since(start)
 	receipts := r.Receipts()
-	totalUSD := r.SessionSpend()
+	totalUSD := r.SessionSpend().Float64()
 
 	for i, receipt := range receipts {
 		fmt.Printf("  %d. %s via %s — %s\n", i+1, receipt.URL, receipt.Protocol, receipt.Amount)
@@ -0,0 +1,192 @@
+// Package facilitator is an x402 facilitator: an HTTP service that verifies
+// EIP-3009 payment authorizations on /verify and settles them on /settle
+// through a pluggable Chain. With the in-memory Ledger it runs the whole
+// x402 loop offline, for tests and self-hosted selling.
+package facilitator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// Chain executes EIP-3009 transfers on behalf of the facilitator.
+type Chain interface {
+	// AuthorizationUsed reports whether from has already used nonce on
+	// the token contract asset.
+	AuthorizationUsed(ctx context.Context, network, asset, from, nonce string) (bool, error)
+	// TransferWithAuthorization executes a verified payment of asset and
+	// returns its transaction hash. It must fail if the nonce was used.
+	TransferWithAuthorization(ctx context.Context, network, asset string, p *providers.X402Payment) (string, error)
+}
+
+// Config configures a Server.
+type Config struct {
+	// Chain settles payments. Defaults to a new Ledger.
+	Chain Chain
+	// Networks lists the CAIP-2 networks served. Empty means the networks
+	// with a known USDC contract (router.USDCNetworks).
+	Networks []string
+	// Logger receives a line per settlement. Defaults to log.Default().
+	Logger *log.Logger
+}
+
+// Server serves the facilitator endpoints.
+type Server struct {
+	cfg Config
+	mux *http.ServeMux
+	now func() time.Time
+}
+
+// New creates a Server.
+func New(cfg Config) *Server {
+	if cfg.Chain == nil {
+		cfg.Chain = NewLedger()
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	if len(cfg.Networks) == 0 {
+		cfg.Networks = router.USDCNetworks()
+	}
+	s := &Server{cfg: cfg, mux: http.NewServeMux(), now: time.Now}
+	s.mux.HandleFunc("POST /verify", s.handleVerify)
+	s.mux.HandleFunc("POST /settle", s.handleSettle)
+	s.mux.HandleFunc("GET /supported", s.handleSupported)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// request is the body of /verify and /settle. Clients send the payment as
+// the base64 header, the decoded payload, or both.
+type request struct {
+	X402Version         int                `json:"x402Version"`
+	PaymentHeader       string             `json:"paymentHeader"`
+	PaymentPayload      json.RawMessage    `json:"paymentPayload"`
+	PaymentRequirements *router.X402Accept `json:"paymentRequirements"`
+}
+
+func decodeRequest(r *http.Request) (*providers.X402Payment, *router.X402Accept, error) {
+	var req request
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		return nil, nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.PaymentRequirements == nil {
+		return nil, nil, errors.New("invalid request: missing paymentRequirements")
+	}
+	header := req.PaymentHeader
+	if header == "" && len(req.PaymentPayload) > 0 {
+		header = base64.StdEncoding.EncodeToString(req.PaymentPayload)
+	}
+	if header == "" {
+		return nil, nil, errors.New("invalid request: missing payment")
+	}
+	payment, err := providers.DecodeX402Payment(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payment, req.PaymentRequirements, nil
+}
+
+// verify checks payment against accept and that its nonce is unused.
+func (s *Server) verify(ctx context.Context, payment *providers.X402Payment, accept *router.X402Accept) error {
+	if err := s.supports(accept.Network); err != nil {
+		return err
+	}
+	if err := payment.Verify(accept, s.now()); err != nil {
+		return err
+	}
+	used, err := s.cfg.Chain.AuthorizationUsed(ctx, accept.Network, accept.Asset, payment.From, payment.Nonce)
+	if err != nil {
+		return fmt.Errorf("check nonce: %w", err)
+	}
+	if used {
+		return errors.New("authorization nonce has already been used")
+	}
+	return nil
+}
+
+func (s *Server) supports(network string) error {
+	if !strings.HasPrefix(network, "eip155:") {
+		return fmt.Errorf("unsupported network %q", network)
+	}
+	for _, n := range s.cfg.Networks {
+		if n == network {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported network %q", network)
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	payment, accept, err := decodeRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"isValid": false, "invalidReason": err.Error()})
+		return
+	}
+	if err := s.verify(r.Context(), payment, accept); err != nil {
+		writeJSON(w, http.StatusOK, map[string]any{"isValid": false, "invalidReason": err.Error(), "payer": payment.From})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"isValid": true, "payer": payment.From})
+}
+
+func (s *Server) handleSettle(w http.ResponseWriter, r *http.Request) {
+	payment, accept, err := decodeRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "errorReason": err.Error()})
+		return
+	}
+	fail := func(err error) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"success":     false,
+			"errorReason": err.Error(),
+			"network":     accept.Network,
+			"payer":       payment.From,
+		})
+	}
+	if err := s.verify(r.Context(), payment, accept); err != nil {
+		fail(err)
+		return
+	}
+	tx, err := s.cfg.Chain.TransferWithAuthorization(r.Context(), accept.Network, accept.Asset, payment)
+	if err != nil {
+		fail(fmt.Errorf("settle: %w", err))
+		return
+	}
+	s.cfg.Logger.Printf("facilitator: settled %s from %s to %s on %s: %s", payment.Value, payment.From, payment.To, accept.Network, tx)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success":     true,
+		"transaction": tx,
+		"network":     accept.Network,
+		"payer":       payment.From,
+	})
+}
+
+func (s *Server) handleSupported(w http.ResponseWriter, r *http.Request) {
+	kinds := []map[string]any{}
+	for _, n := range s.cfg.Networks {
+		kinds = append(kinds, map[string]any{"x402Version": 1, "scheme": "exact", "network": n})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"kinds": kinds})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,198 @@
+package facilitator
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/joelklabo/agentpay/paywall"
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+)
+
+const (
+	testNetwork = "eip155:8453"
+	testAsset   = "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"
+	testPayTo   = "0x5049CaCF18346ee22EBA390B9B6309cb3f03abFB"
+)
+
+func newTestServer(t *testing.T, cfg Config) (*Ledger, *httptest.Server) {
+	t.Helper()
+	ledger := NewLedger()
+	cfg.Chain = ledger
+	cfg.Logger = log.New(io.Discard, "", 0)
+	srv := httptest.NewServer(New(cfg))
+	t.Cleanup(srv.Close)
+	return ledger, srv
+}
+
+func testAccept() *router.X402Accept {
+	return &router.X402Accept{
+		Scheme:            "exact",
+		Network:           testNetwork,
+		MaxAmountRequired: "10000",
+		PayTo:             testPayTo,
+		MaxTimeoutSeconds: 60,
+		Asset:             testAsset,
+	}
+}
+
+// signedPayment returns a payment header signed by a fixed test key.
+func signedPayment(t *testing.T, accept *router.X402Accept) string {
+	t.Helper()
+	p, _ := providers.NewEVMKeyProvider(big.NewInt(0xC0FFEE))
+	_, header, err := p.Pay(context.Background(), &router.PaymentRequirement{Protocol: router.ProtocolX402, X402Accept: accept})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+func post(t *testing.T, url string, body any) (int, map[string]any) {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result map[string]any
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestFacilitator_PaywallLoop(t *testing.T) {
+	ledger, fac := newTestServer(t, Config{})
+
+	pw, err := paywall.New(paywall.Config{X402: &paywall.X402Config{
+		Network:     testNetwork,
+		Asset:       testAsset,
+		PayTo:       testPayTo,
+		Facilitator: &paywall.FacilitatorClient{URL: fac.URL},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.SetPrice("GET /paid", paywall.Price{USDC: router.NewAmount(10000, router.USDC)})
+	api := httptest.NewServer(pw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})))
+	defer api.Close()
+
+	buyer, _ := providers.NewEVMKeyProvider(big.NewInt(0xC0FFEE))
+	r := router.New(router.Config{MaxPerRequestUSD: 1, MaxSessionUSD: 1})
+	r.RegisterProvider(buyer)
+	body, receipt, err := r.Fetch(context.Background(), "GET", api.URL+"/paid", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "ok" || receipt == nil {
+		t.Fatalf("body %q, receipt %+v", body, receipt)
+	}
+
+	transfers := ledger.Transfers()
+	if len(transfers) != 1 || transfers[0].From != buyer.Address() || transfers[0].Value.Int64() != 10000 {
+		t.Fatalf("transfers %+v", transfers)
+	}
+	if got := ledger.Balance(testNetwork, testAsset, testPayTo); got.Int64() != 10000 {
+		t.Errorf("payee balance %s", got)
+	}
+	if got := ledger.Balance(testNetwork, testAsset, buyer.Address()); got.Int64() != -10000 {
+		t.Errorf("payer balance %s", got)
+	}
+}
+
+func TestFacilitator_VerifyAndSettle(t *testing.T) {
+	_, fac := newTestServer(t, Config{})
+	accept := testAccept()
+	header := signedPayment(t, accept)
+	req := map[string]any{"x402Version": 1, "paymentHeader": header, "paymentRequirements": accept}
+
+	if _, res := post(t, fac.URL+"/verify", req); res["isValid"] != true {
+		t.Fatalf("verify: %v", res)
+	}
+	_, res := post(t, fac.URL+"/settle", req)
+	if res["success"] != true || res["transaction"] == "" || res["network"] != testNetwork {
+		t.Fatalf("settle: %v", res)
+	}
+
+	// The nonce is spent now
+	if _, res := post(t, fac.URL+"/verify", req); res["isValid"] != false {
+		t.Errorf("verify after settle: %v", res)
+	}
+	if _, res := post(t, fac.URL+"/settle", req); res["success"] != false {
+		t.Errorf("second settle: %v", res)
+	}
+}
+
+func TestFacilitator_PaymentPayload(t *testing.T) {
+	_, fac := newTestServer(t, Config{})
+	accept := testAccept()
+	raw, _ := base64.StdEncoding.DecodeString(signedPayment(t, accept))
+
+	req := map[string]any{"x402Version": 1, "paymentPayload": json.RawMessage(raw), "paymentRequirements": accept}
+	if _, res := post(t, fac.URL+"/verify", req); res["isValid"] != true {
+		t.Errorf("verify: %v", res)
+	}
+}
+
+func TestFacilitator_Rejects(t *testing.T) {
+	_, fac := newTestServer(t, Config{Networks: []string{"eip155:84532"}})
+	accept := testAccept()
+	header := signedPayment(t, accept)
+
+	if _, res := post(t, fac.URL+"/verify", map[string]any{"paymentHeader": header, "paymentRequirements": accept}); res["isValid"] != false {
+		t.Errorf("unsupported network: %v", res)
+	}
+
+	other := testAccept()
+	other.Network = "eip155:84532"
+	if _, res := post(t, fac.URL+"/verify", map[string]any{"paymentHeader": header, "paymentRequirements": other}); res["isValid"] != false {
+		t.Errorf("payment for another network: %v", res)
+	}
+
+	if code, _ := post(t, fac.URL+"/settle", map[string]any{"paymentHeader": header}); code != http.StatusBadRequest {
+		t.Errorf("missing requirements: HTTP %d", code)
+	}
+}
+
+func TestFacilitator_SupportedMatchesVerify(t *testing.T) {
+	_, fac := newTestServer(t, Config{})
+
+	resp, err := http.Get(fac.URL + "/supported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var supported struct {
+		Kinds []struct {
+			Network string `json:"network"`
+		} `json:"kinds"`
+	}
+	json.NewDecoder(resp.Body).Decode(&supported)
+	var networks []string
+	for _, k := range supported.Kinds {
+		networks = append(networks, k.Network)
+	}
+	if strings.Join(networks, ",") != "eip155:8453,eip155:84532" {
+		t.Errorf("supported networks = %v", networks)
+	}
+
+	// A network it doesn't list is refused rather than settled
+	header := signedPayment(t, testAccept())
+	accept := testAccept()
+	accept.Network = "eip155:1"
+	_, res := post(t, fac.URL+"/verify", map[string]any{"paymentHeader": header, "paymentRequirements": accept})
+	if reason, _ := res["invalidReason"].(string); res["isValid"] != false || !strings.Contains(reason, "unsupported network") {
+		t.Errorf("verify on an unlisted network: %v", res)
+	}
+}
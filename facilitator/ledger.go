@@ -0,0 +1,115 @@
+package facilitator
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/keccak"
+	"github.com/joelklabo/agentpay/providers"
+)
+
+// Ledger is an in-memory Chain. It accepts each authorization once and
+// records the transfer, but does not model payers running out of funds:
+// balances start at zero and may go negative.
+type Ledger struct {
+	mu        sync.Mutex
+	used      map[string]bool     // network/asset/from/nonce
+	balances  map[string]*big.Int // network/asset/address
+	transfers []Transfer
+}
+
+// Transfer is a payment the Ledger settled.
+type Transfer struct {
+	TxHash  string
+	Network string
+	Asset   string
+	From    string
+	To      string
+	Value   *big.Int
+	Time    time.Time
+}
+
+// NewLedger creates an empty Ledger.
+func NewLedger() *Ledger {
+	return &Ledger{used: make(map[string]bool), balances: make(map[string]*big.Int)}
+}
+
+func ledgerKey(parts ...string) string {
+	return strings.ToLower(strings.Join(parts, "/"))
+}
+
+// AuthorizationUsed implements Chain.
+func (l *Ledger) AuthorizationUsed(ctx context.Context, network, asset, from, nonce string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.used[ledgerKey(network, asset, from, nonce)], nil
+}
+
+// TransferWithAuthorization implements Chain. The transaction hash is the
+// keccak256 of the payment's signature, so it is unique per authorization.
+func (l *Ledger) TransferWithAuthorization(ctx context.Context, network, asset string, p *providers.X402Payment) (string, error) {
+	value, ok := new(big.Int).SetString(p.Value, 10)
+	if !ok || value.Sign() < 0 {
+		return "", fmt.Errorf("invalid value %q", p.Value)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(p.Signature, "0x"))
+	if err != nil {
+		return "", errors.New("malformed signature")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := ledgerKey(network, asset, p.From, p.Nonce)
+	if l.used[key] {
+		return "", errors.New("authorization is used or canceled")
+	}
+	l.used[key] = true
+	l.add(ledgerKey(network, asset, p.From), new(big.Int).Neg(value))
+	l.add(ledgerKey(network, asset, p.To), value)
+
+	hash := keccak.Sum256(sig)
+	tx := "0x" + hex.EncodeToString(hash[:])
+	l.transfers = append(l.transfers, Transfer{
+		TxHash:  tx,
+		Network: network,
+		Asset:   asset,
+		From:    p.From,
+		To:      p.To,
+		Value:   value,
+		Time:    time.Now(),
+	})
+	return tx, nil
+}
+
+func (l *Ledger) add(key string, delta *big.Int) {
+	b, ok := l.balances[key]
+	if !ok {
+		b = new(big.Int)
+		l.balances[key] = b
+	}
+	b.Add(b, delta)
+}
+
+// Balance returns the net amount of asset address has received on network,
+// in the token's minor units.
+func (l *Ledger) Balance(network, asset, address string) *big.Int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.balances[ledgerKey(network, asset, address)]; ok {
+		return new(big.Int).Set(b)
+	}
+	return new(big.Int)
+}
+
+// Transfers returns the settled transfers in order.
+func (l *Ledger) Transfers() []Transfer {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Transfer(nil), l.transfers...)
+}
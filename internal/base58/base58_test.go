@@ -0,0 +1,39 @@
+package base58
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want string
+	}{
+		{"", ""},
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"00000000000000000000", "1111111111"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+		// Solana system program
+		{"0000000000000000000000000000000000000000000000000000000000000000", "11111111111111111111111111111111"},
+	}
+	for _, tt := range tests {
+		b, _ := hex.DecodeString(tt.hex)
+		if got := Encode(b); got != tt.want {
+			t.Errorf("Encode(%s) = %s, want %s", tt.hex, got, tt.want)
+		}
+		got, err := Decode(tt.want)
+		if err != nil {
+			t.Fatalf("Decode(%s): %v", tt.want, err)
+		}
+		if !bytes.Equal(got, b) {
+			t.Errorf("Decode(%s) = %x, want %s", tt.want, got, tt.hex)
+		}
+	}
+
+	if _, err := Decode("0OIl"); err != ErrInvalid {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+}
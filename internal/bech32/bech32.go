@@ -0,0 +1,141 @@
+// Package bech32 implements BIP-173 bech32 encoding without the 90
+// character limit, as used by BOLT11 invoices and LNURLs.
+package bech32
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	out := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+// Decode splits a bech32 string into its human-readable part and 5-bit data
+// groups, verifying the checksum.
+func Decode(s string) (hrp string, data []byte, err error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("bech32: mixed case")
+	}
+	s = strings.ToLower(s)
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, errors.New("bech32: missing separator or checksum")
+	}
+	hrp = s[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, errors.New("bech32: invalid character in prefix")
+		}
+	}
+	for _, c := range s[sep+1:] {
+		idx := strings.IndexRune(charset, c)
+		if idx < 0 {
+			return "", nil, fmt.Errorf("bech32: invalid character %q", c)
+		}
+		data = append(data, byte(idx))
+	}
+	if polymod(append(hrpExpand(hrp), data...)) != 1 {
+		return "", nil, errors.New("bech32: invalid checksum")
+	}
+	return hrp, data[:len(data)-6], nil
+}
+
+// Encode returns the bech32 string for hrp and 5-bit data groups.
+func Encode(hrp string, data []byte) string {
+	values := append(hrpExpand(hrp), data...)
+	mod := polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, d := range data {
+		b.WriteByte(charset[d])
+	}
+	for i := 0; i < 6; i++ {
+		b.WriteByte(charset[(mod>>(5*(5-i)))&31])
+	}
+	return b.String()
+}
+
+// ConvertBits regroups data from fromBits-wide to toBits-wide groups. With
+// pad, a trailing partial group is zero-padded; without it, leftover bits
+// must be zero padding.
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	var out []byte
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, errors.New("bech32: invalid data value")
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, errors.New("bech32: invalid padding")
+	}
+	return out, nil
+}
+
+// DecodeNoChecksum decodes a BOLT12 string: bech32 characters without a
+// checksum, which may be split into chunks joined by "+" and whitespace.
+func DecodeNoChecksum(s string) (hrp string, data []byte, err error) {
+	s = strings.Join(strings.Fields(s), "")
+	if strings.HasPrefix(s, "+") || strings.HasSuffix(s, "+") || strings.Contains(s, "++") {
+		return "", nil, errors.New("bech32: invalid + join")
+	}
+	s = strings.ReplaceAll(s, "+", "")
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("bech32: mixed case")
+	}
+	s = strings.ToLower(s)
+	sep := strings.IndexByte(s, '1')
+	if sep < 1 || sep == len(s)-1 {
+		return "", nil, errors.New("bech32: missing separator")
+	}
+	for _, c := range s[sep+1:] {
+		idx := strings.IndexRune(charset, c)
+		if idx < 0 {
+			return "", nil, fmt.Errorf("bech32: invalid character %q", c)
+		}
+		data = append(data, byte(idx))
+	}
+	return s[:sep], data, nil
+}
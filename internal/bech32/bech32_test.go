@@ -0,0 +1,41 @@
+package bech32
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeBIP173(t *testing.T) {
+	for _, s := range []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	} {
+		if _, _, err := Decode(s); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+	for _, s := range []string{"a12uel5L", "a12uel5m", "1pzry9x0s0muk", "abc1rzg"} {
+		if _, _, err := Decode(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	msg := []byte("https://service.com/api?q=3fc3645b439ce8e7f2553a69e5267081d96dcd340693afabe04be7b0ccd178df")
+	data, err := ConvertBits(msg, 8, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Encode("lnurl", data)
+	hrp, got, err := Decode(s)
+	if err != nil || hrp != "lnurl" {
+		t.Fatalf("decode %s: %q %v", s, hrp, err)
+	}
+	back, err := ConvertBits(got, 5, 8, false)
+	if err != nil || !bytes.Equal(back, msg) {
+		t.Errorf("round trip = %q, %v", back, err)
+	}
+}
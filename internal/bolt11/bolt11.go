@@ -0,0 +1,250 @@
+// Package bolt11 decodes BOLT11 Lightning invoices.
+package bolt11
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/bech32"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+)
+
+// Invoice is a decoded BOLT11 payment request.
+type Invoice struct {
+	Currency        string // "bc", "tb", "bcrt", "tbs"
+	AmountMsat      int64  // 0 for an any-amount invoice
+	Timestamp       time.Time
+	PaymentHash     string // hex
+	PaymentSecret   string // hex
+	Description     string
+	DescriptionHash string // hex
+	Expiry          time.Duration
+	MinFinalCLTV    int64
+	Payee           string // hex compressed pubkey, recovered from the signature if not tagged
+}
+
+// ExpiresAt returns when the invoice stops being payable.
+func (inv *Invoice) ExpiresAt() time.Time {
+	return inv.Timestamp.Add(inv.Expiry)
+}
+
+// Tagged field types.
+const (
+	tagPaymentHash     = 1
+	tagPaymentSecret   = 16
+	tagDescription     = 13
+	tagPayee           = 19
+	tagDescriptionHash = 23
+	tagExpiry          = 6
+	tagMinFinalCLTV    = 24
+)
+
+// signatureGroups is the 520-bit signature and recovery id in 5-bit groups.
+const signatureGroups = 104
+
+// Decode parses a BOLT11 invoice, with or without a "lightning:" prefix, and
+// checks its signature.
+func Decode(s string) (*Invoice, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 10 && strings.EqualFold(s[:10], "lightning:") {
+		s = s[10:]
+	}
+	hrp, data, err := bech32.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("bolt11: %w", err)
+	}
+	if !strings.HasPrefix(hrp, "ln") {
+		return nil, errors.New("bolt11: not a Lightning invoice")
+	}
+	if len(data) < 7+signatureGroups {
+		return nil, errors.New("bolt11: invoice too short")
+	}
+
+	inv := &Invoice{Expiry: time.Hour, MinFinalCLTV: 18}
+	if err := inv.parsePrefix(hrp[2:]); err != nil {
+		return nil, err
+	}
+	inv.Timestamp = time.Unix(int64(groupsToUint(data[:7])), 0)
+
+	fields := data[7 : len(data)-signatureGroups]
+	for len(fields) > 0 {
+		if len(fields) < 3 {
+			return nil, errors.New("bolt11: truncated tagged field")
+		}
+		typ := fields[0]
+		n := int(fields[1])<<5 | int(fields[2])
+		if len(fields) < 3+n {
+			return nil, errors.New("bolt11: truncated tagged field")
+		}
+		value := fields[3 : 3+n]
+		fields = fields[3+n:]
+		if err := inv.setField(typ, value); err != nil {
+			return nil, err
+		}
+	}
+	if inv.PaymentHash == "" {
+		return nil, errors.New("bolt11: missing payment hash")
+	}
+
+	payee, err := recoverPayee(hrp, data)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Payee != "" && inv.Payee != payee {
+		return nil, errors.New("bolt11: signature does not match the payee")
+	}
+	inv.Payee = payee
+	return inv, nil
+}
+
+// AmountMsat reads the amount of invoice s, in millisatoshis, from its human
+// readable prefix without checking the rest of the invoice. It returns 0 for
+// an any-amount invoice.
+func AmountMsat(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "lightning:")
+	// '1' is not in the bech32 alphabet, so the last one ends the prefix
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 0 || !strings.HasPrefix(s, "ln") {
+		return 0, errors.New("bolt11: not a Lightning invoice")
+	}
+	var inv Invoice
+	if err := inv.parsePrefix(s[2:sep]); err != nil {
+		return 0, err
+	}
+	return inv.AmountMsat, nil
+}
+
+// parsePrefix reads the currency and amount from the part of the human
+// readable prefix after "ln".
+func (inv *Invoice) parsePrefix(rest string) error {
+	i := 0
+	for i < len(rest) && (rest[i] < '0' || rest[i] > '9') {
+		i++
+	}
+	inv.Currency = rest[:i]
+	amount := rest[i:]
+	if inv.Currency == "" {
+		return errors.New("bolt11: missing currency")
+	}
+	if amount == "" {
+		return nil
+	}
+
+	multiplier := amount[len(amount)-1]
+	digits := amount
+	if multiplier < '0' || multiplier > '9' {
+		digits = amount[:len(amount)-1]
+	} else {
+		multiplier = 0
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("bolt11: invalid amount %q", amount)
+	}
+	// Amounts are in BTC; 1 BTC = 10^11 msat
+	var scale int64
+	switch multiplier {
+	case 0:
+		scale = 100_000_000_000
+	case 'm':
+		scale = 100_000_000
+	case 'u':
+		scale = 100_000
+	case 'n':
+		scale = 100
+	case 'p':
+		if n%10 != 0 {
+			return fmt.Errorf("bolt11: amount %q is not a whole msat", amount)
+		}
+		inv.AmountMsat = n / 10
+		return nil
+	default:
+		return fmt.Errorf("bolt11: unknown multiplier %q", multiplier)
+	}
+	if n > math.MaxInt64/scale {
+		return fmt.Errorf("bolt11: amount %q is too large", amount)
+	}
+	inv.AmountMsat = n * scale
+	return nil
+}
+
+func (inv *Invoice) setField(typ byte, value []byte) error {
+	switch typ {
+	case tagPaymentHash, tagPaymentSecret, tagDescriptionHash:
+		// Fields of the wrong length must be skipped, not rejected
+		if len(value) != 52 {
+			return nil
+		}
+		b, err := bech32.ConvertBits(value, 5, 8, false)
+		if err != nil {
+			return fmt.Errorf("bolt11: %w", err)
+		}
+		switch typ {
+		case tagPaymentHash:
+			inv.PaymentHash = hex.EncodeToString(b)
+		case tagPaymentSecret:
+			inv.PaymentSecret = hex.EncodeToString(b)
+		default:
+			inv.DescriptionHash = hex.EncodeToString(b)
+		}
+	case tagPayee:
+		if len(value) != 53 {
+			return nil
+		}
+		b, err := bech32.ConvertBits(value, 5, 8, false)
+		if err != nil {
+			return fmt.Errorf("bolt11: %w", err)
+		}
+		inv.Payee = hex.EncodeToString(b)
+	case tagDescription:
+		b, err := bech32.ConvertBits(value, 5, 8, false)
+		if err != nil {
+			return fmt.Errorf("bolt11: %w", err)
+		}
+		inv.Description = string(b)
+	case tagExpiry:
+		inv.Expiry = time.Duration(groupsToUint(value)) * time.Second
+	case tagMinFinalCLTV:
+		inv.MinFinalCLTV = int64(groupsToUint(value))
+	}
+	return nil
+}
+
+// recoverPayee recovers the node key that signed the invoice.
+func recoverPayee(hrp string, data []byte) (string, error) {
+	signed, err := bech32.ConvertBits(data[:len(data)-signatureGroups], 5, 8, true)
+	if err != nil {
+		return "", fmt.Errorf("bolt11: %w", err)
+	}
+	sigBytes, err := bech32.ConvertBits(data[len(data)-signatureGroups:], 5, 8, false)
+	if err != nil || len(sigBytes) != 65 {
+		return "", errors.New("bolt11: malformed signature")
+	}
+	hash := sha256.Sum256(append([]byte(hrp), signed...))
+	sig := &secp256k1.Signature{
+		R: new(big.Int).SetBytes(sigBytes[:32]),
+		S: new(big.Int).SetBytes(sigBytes[32:64]),
+		V: sigBytes[64],
+	}
+	pub, err := secp256k1.RecoverPublicKey(hash[:], sig)
+	if err != nil {
+		return "", errors.New("bolt11: invalid signature")
+	}
+	return hex.EncodeToString(pub.Compressed()), nil
+}
+
+func groupsToUint(groups []byte) uint64 {
+	var n uint64
+	for _, g := range groups {
+		n = n<<5 | uint64(g)
+	}
+	return n
+}
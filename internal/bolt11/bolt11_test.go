@@ -0,0 +1,56 @@
+package bolt11
+
+import (
+	"testing"
+)
+
+// Examples from the BOLT11 specification.
+const (
+	specPayee       = "03e7156ae33b0a208d0744199163177e909e80176e55d97a2f221ede0f934dd9ad"
+	specPaymentHash = "0001020304050607080900010203040506070809000102030405060708090102"
+)
+
+func TestDecode_Donation(t *testing.T) {
+	inv, err := Decode("lnbc1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq9qrsgq357wnc5r2ueh7ck6q93dj32dlqnls087fxdwk8qakdyafkq3yap9us6v52vjjsrvywa6rt52cm9r9zqt8r2t7mlcwspyetp5h2tztugp9lfyql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Currency != "bc" || inv.AmountMsat != 0 || inv.Timestamp.Unix() != 1496314658 {
+		t.Errorf("invoice = %+v", inv)
+	}
+	if inv.PaymentHash != specPaymentHash || inv.Payee != specPayee {
+		t.Errorf("hash %s payee %s", inv.PaymentHash, inv.Payee)
+	}
+	if inv.Description != "Please consider supporting this project" {
+		t.Errorf("description = %q", inv.Description)
+	}
+}
+
+func TestDecode_DescriptionHash(t *testing.T) {
+	inv, err := Decode("lnbc20m1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqs9qrsgq7ea976txfraylvgzuxs8kgcw23ezlrszfnh8r6qtfpr6cxga50aj6txm9rxrydzd06dfeawfk6swupvz4erwnyutnjq7x39ymw6j38gp7ynn44")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.AmountMsat != 2_000_000_000 {
+		t.Errorf("amount = %d msat, want 2000000000", inv.AmountMsat)
+	}
+	if inv.DescriptionHash != "3925b6f67e2c340036ed12093dd44e0368df1b6ea26c53dbe4811f58fd5db8c1" {
+		t.Errorf("description hash = %s", inv.DescriptionHash)
+	}
+	if inv.Payee != specPayee {
+		t.Errorf("payee = %s", inv.Payee)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"lnbc20u1ptest",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		// Bad checksum: last character changed
+		"lnbc1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq9qrsgq357wnc5r2ueh7ck6q93dj32dlqnls087fxdwk8qakdyafkq3yap9us6v52vjjsrvywa6rt52cm9r9zqt8r2t7mlcwspyetp5h2tztugp9lfyqm",
+	} {
+		if _, err := Decode(s); err == nil {
+			t.Errorf("%.30s...: expected an error", s)
+		}
+	}
+}
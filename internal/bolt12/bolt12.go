@@ -0,0 +1,268 @@
+// Package bolt12 decodes BOLT12 offers and computes their offer IDs.
+package bolt12
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/bech32"
+)
+
+// Offer TLV types.
+const (
+	typeChains         = 2
+	typeMetadata       = 4
+	typeCurrency       = 6
+	typeAmount         = 8
+	typeDescription    = 10
+	typeFeatures       = 12
+	typeAbsoluteExpiry = 14
+	typePaths          = 16
+	typeIssuer         = 18
+	typeQuantityMax    = 20
+	typeIssuerID       = 22
+)
+
+// Offer is a decoded BOLT12 offer.
+type Offer struct {
+	ID          string   // hex merkle root of the offer's TLV stream
+	Chains      []string // hex genesis hashes; empty means Bitcoin
+	Currency    string   // ISO 4217 code; empty when Amount is in msat
+	Amount      uint64   // msat, or minor units of Currency; 0 if the payer chooses
+	Description string
+	Issuer      string
+	IssuerID    string // hex compressed pubkey
+	HasPaths    bool
+	Expiry      time.Time // zero if the offer does not expire
+}
+
+// AmountMsat returns the offer's amount in msat, or 0 when it is
+// denominated in a fiat currency or left to the payer.
+func (o *Offer) AmountMsat() int64 {
+	if o.Currency != "" {
+		return 0
+	}
+	return int64(o.Amount)
+}
+
+// IsOffer reports whether s looks like a BOLT12 offer.
+func IsOffer(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.HasPrefix(strings.TrimPrefix(s, "lightning:"), "lno1")
+}
+
+// DecodeOffer parses an "lno1..." offer.
+func DecodeOffer(s string) (*Offer, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 10 && strings.EqualFold(s[:10], "lightning:") {
+		s = s[10:]
+	}
+	hrp, data, err := bech32.DecodeNoChecksum(s)
+	if err != nil {
+		return nil, fmt.Errorf("bolt12: %w", err)
+	}
+	if hrp != "lno" {
+		return nil, fmt.Errorf("bolt12: %q is not an offer prefix", hrp)
+	}
+	raw, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return nil, fmt.Errorf("bolt12: %w", err)
+	}
+	records, err := parseTLV(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Offer{ID: hex.EncodeToString(merkleRoot(records))}
+	for _, r := range records {
+		switch r.typ {
+		case typeChains:
+			if len(r.value) == 0 || len(r.value)%32 != 0 {
+				return nil, errors.New("bolt12: invalid offer_chains")
+			}
+			for i := 0; i < len(r.value); i += 32 {
+				o.Chains = append(o.Chains, hex.EncodeToString(r.value[i:i+32]))
+			}
+		case typeCurrency:
+			o.Currency = string(r.value)
+		case typeAmount:
+			if o.Amount, err = tu64(r.value); err != nil {
+				return nil, err
+			}
+		case typeDescription:
+			o.Description = string(r.value)
+		case typeAbsoluteExpiry:
+			secs, err := tu64(r.value)
+			if err != nil {
+				return nil, err
+			}
+			o.Expiry = time.Unix(int64(secs), 0)
+		case typeMetadata, typeFeatures, typeQuantityMax:
+			// Nothing a payer needs to act on
+		case typePaths:
+			o.HasPaths = len(r.value) > 0
+		case typeIssuer:
+			o.Issuer = string(r.value)
+		case typeIssuerID:
+			if len(r.value) != 33 {
+				return nil, errors.New("bolt12: invalid offer_issuer_id")
+			}
+			o.IssuerID = hex.EncodeToString(r.value)
+		default:
+			// Offers only use types 1-79 and 1000000000-1999999999; an
+			// unknown even type is one we are required to understand
+			if r.typ%2 == 0 || !(r.typ < 80 || (r.typ >= 1000000000 && r.typ < 2000000000)) {
+				return nil, fmt.Errorf("bolt12: unsupported offer field %d", r.typ)
+			}
+		}
+	}
+
+	if o.Currency != "" && o.Amount == 0 {
+		return nil, errors.New("bolt12: offer_currency without offer_amount")
+	}
+	if o.Amount > 0 && o.Description == "" {
+		return nil, errors.New("bolt12: offer with an amount must have a description")
+	}
+	if o.IssuerID == "" && !o.HasPaths {
+		return nil, errors.New("bolt12: offer has neither an issuer id nor paths")
+	}
+	return o, nil
+}
+
+// tlvRecord is one TLV record with its raw encoding kept for hashing.
+type tlvRecord struct {
+	typ     uint64
+	value   []byte
+	typeRaw []byte // the encoded type
+	raw     []byte // type || length || value
+}
+
+func parseTLV(b []byte) ([]tlvRecord, error) {
+	var records []tlvRecord
+	for len(b) > 0 {
+		start := b
+		typ, tn, err := bigSize(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[tn:]
+		length, n, err := bigSize(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+		if uint64(len(b)) < length {
+			return nil, errors.New("bolt12: truncated TLV record")
+		}
+		if len(records) > 0 && typ <= records[len(records)-1].typ {
+			return nil, errors.New("bolt12: TLV records out of order")
+		}
+		value := b[:length]
+		b = b[length:]
+		records = append(records, tlvRecord{
+			typ:     typ,
+			value:   value,
+			typeRaw: start[:tn],
+			raw:     start[:len(start)-len(b)],
+		})
+	}
+	return records, nil
+}
+
+// bigSize decodes a minimally encoded BOLT1 BigSize integer and returns its
+// encoded length.
+func bigSize(b []byte) (uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, errors.New("bolt12: truncated BigSize")
+	}
+	var n int
+	switch b[0] {
+	case 0xfd:
+		n = 3
+	case 0xfe:
+		n = 5
+	case 0xff:
+		n = 9
+	default:
+		return uint64(b[0]), 1, nil
+	}
+	if len(b) < n {
+		return 0, 0, errors.New("bolt12: truncated BigSize")
+	}
+	var buf [8]byte
+	copy(buf[8-(n-1):], b[1:n])
+	v := binary.BigEndian.Uint64(buf[:])
+	if (n == 3 && v < 0xfd) || (n == 5 && v <= 0xffff) || (n == 9 && v <= 0xffffffff) {
+		return 0, 0, errors.New("bolt12: non-minimal BigSize")
+	}
+	return v, n, nil
+}
+
+// tu64 decodes a truncated big-endian integer.
+func tu64(b []byte) (uint64, error) {
+	if len(b) > 8 || (len(b) > 0 && b[0] == 0) {
+		return 0, errors.New("bolt12: invalid truncated integer")
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func taggedHash(tag, msg []byte) []byte {
+	t := sha256.Sum256(tag)
+	h := sha256.New()
+	h.Write(t[:])
+	h.Write(t[:])
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+func branch(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return taggedHash([]byte("LnBranch"), append(append([]byte(nil), a...), b...))
+}
+
+// merkleRoot computes the BOLT12 merkle root of a TLV stream, leaving out
+// the signature range (types 240 to 1000). Each record contributes a leaf
+// and a nonce leaf; unpaired nodes move up a level unchanged, so the tree is
+// deepest on the lowest-order records.
+func merkleRoot(records []tlvRecord) []byte {
+	var nodes [][]byte
+	var first []byte
+	for _, r := range records {
+		if r.typ >= 240 && r.typ <= 1000 {
+			continue
+		}
+		if first == nil {
+			first = r.raw
+		}
+		leaf := taggedHash([]byte("LnLeaf"), r.raw)
+		nonce := taggedHash(append([]byte("LnNonce"), first...), r.typeRaw)
+		nodes = append(nodes, branch(leaf, nonce))
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	for len(nodes) > 1 {
+		var next [][]byte
+		for i := 0; i < len(nodes); i += 2 {
+			if i+1 < len(nodes) {
+				next = append(next, branch(nodes[i], nodes[i+1]))
+			} else {
+				next = append(next, nodes[i])
+			}
+		}
+		nodes = next
+	}
+	return nodes[0]
+}
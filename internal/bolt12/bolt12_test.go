@@ -0,0 +1,97 @@
+package bolt12
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/joelklabo/agentpay/internal/bech32"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	// From the BOLT12 signature test vectors
+	tests := []struct {
+		tlv, root string
+	}{
+		{"010203e8", "b013756c8fee86503a0b4abdab4cddeb1af5d344ca6fc2fa8b6c08938caa6f93"},
+		{"010203e802080000010000020003", "c3774abbf4815aa54ccaa026bff6581f01f3be5fe814c620a252534f434bc0d1"},
+		{"010203e802080000010000020003" +
+			"03310266e4598d1d3c415f572a8488830b60f7e744ed9235eb0b1ba93283b315c0351800000000000000010000000000000002",
+			"ab2e79b1283b0b31e0b035258de23782df6b89a38cfa7237bde69aed1a658c5d"},
+	}
+	for _, tt := range tests {
+		raw, _ := hex.DecodeString(tt.tlv)
+		records, err := parseTLV(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(merkleRoot(records)); got != tt.root {
+			t.Errorf("root of %s = %s, want %s", tt.tlv, got, tt.root)
+		}
+	}
+}
+
+func TestDecodeOffer_Minimal(t *testing.T) {
+	// Minimal offer from the BOLT12 offer test vectors
+	o, err := DecodeOffer("lno1pgx9getnwss8vetrw3hhyuckyypwa3eyt44h6txtxquqh7lz5djge4afgfjn7k4rgrkuag0jsd5xvxg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Description != "Test vectors" || o.Amount != 0 {
+		t.Errorf("offer = %+v", o)
+	}
+	if o.IssuerID != "02eec7245d6b7d2ccb30380bfbe2a3648cd7a942653f5aa340edcea1f283686619" {
+		t.Errorf("issuer id = %s", o.IssuerID)
+	}
+	if len(o.ID) != 64 {
+		t.Errorf("offer id = %q", o.ID)
+	}
+}
+
+// encodeOffer builds an offer string from raw TLV hex.
+func encodeOffer(t *testing.T, tlvHex string) string {
+	t.Helper()
+	raw, _ := hex.DecodeString(tlvHex)
+	data, _ := bech32.ConvertBits(raw, 8, 5, true)
+	s := bech32.Encode("lno", data)
+	return s[:len(s)-6] // offers carry no checksum
+}
+
+func TestDecodeOffer_Amount(t *testing.T) {
+	issuer := "162102eec7245d6b7d2ccb30380bfbe2a3648cd7a942653f5aa340edcea1f283686619"
+	// amount 21000 msat, description "coffee"
+	s := encodeOffer(t, "08025208"+"0a06636f66666565"+issuer)
+	o, err := DecodeOffer(s[:20] + "+\n  " + s[20:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.AmountMsat() != 21000 || o.Description != "coffee" {
+		t.Errorf("offer = %+v", o)
+	}
+
+	// 150 cents of USD
+	o, err = DecodeOffer(encodeOffer(t, "0603555344"+"080196"+"0a06636f66666565"+issuer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Currency != "USD" || o.Amount != 150 || o.AmountMsat() != 0 {
+		t.Errorf("offer = %+v", o)
+	}
+}
+
+func TestDecodeOffer_Invalid(t *testing.T) {
+	issuer := "162102eec7245d6b7d2ccb30380bfbe2a3648cd7a942653f5aa340edcea1f283686619"
+	for name, tlv := range map[string]string{
+		"no issuer or paths":     "0a06636f66666565",
+		"amount without desc":    "08025208" + issuer,
+		"out of order":           issuer + "0a06636f66666565",
+		"unknown even field":     "0a06636f66666565" + issuer + "3000",
+		"non-minimal truncation": "0803005208" + "0a06636f66666565" + issuer,
+	} {
+		if _, err := DecodeOffer(encodeOffer(t, tlv)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := DecodeOffer("lnbc1pvjluez"); err == nil {
+		t.Error("expected a non-offer to be rejected")
+	}
+}
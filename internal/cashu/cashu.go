@@ -0,0 +1,162 @@
+// Package cashu implements the client-side cryptography and token encoding of
+// the Cashu ecash protocol: blind Diffie-Hellman key exchange (NUT-00) and
+// V3/V4 token serialization.
+package cashu
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+)
+
+// Proof is an unblinded signature on a secret, spendable at the mint that
+// issued it.
+type Proof struct {
+	Amount uint64 `json:"amount"`
+	ID     string `json:"id"` // keyset ID
+	Secret string `json:"secret"`
+	C      string `json:"C"` // hex-encoded compressed point
+}
+
+// BlindedMessage is an output sent to the mint for signing.
+type BlindedMessage struct {
+	Amount uint64 `json:"amount"`
+	ID     string `json:"id"`
+	B      string `json:"B_"`
+}
+
+// BlindSignature is the mint's signature on a BlindedMessage.
+type BlindSignature struct {
+	Amount uint64 `json:"amount"`
+	ID     string `json:"id"`
+	C      string `json:"C_"`
+}
+
+// domainSeparator prefixes hash_to_curve input per NUT-00.
+var domainSeparator = []byte("Secp256k1_HashToCurve_Cashu_")
+
+// HashToCurve maps a secret to a curve point Y deterministically.
+func HashToCurve(msg []byte) (*secp256k1.Point, error) {
+	h := sha256.Sum256(append(append([]byte(nil), domainSeparator...), msg...))
+	var counter [4]byte
+	for i := uint32(0); i < 1<<16; i++ {
+		binary.LittleEndian.PutUint32(counter[:], i)
+		x := sha256.Sum256(append(h[:], counter[:]...))
+		if p, err := secp256k1.ParsePoint(append([]byte{0x02}, x[:]...)); err == nil {
+			return p, nil
+		}
+	}
+	return nil, errors.New("cashu: no valid point found")
+}
+
+// Output is a blinded message together with the secret and blinding factor
+// needed to unblind the mint's signature.
+type Output struct {
+	Message BlindedMessage `json:"message"`
+	Secret  string         `json:"secret"`
+	R       *big.Int       `json:"r"`
+}
+
+// NewOutput creates a blinded message for amount in keyset id with a fresh
+// random secret: B_ = Y + r·G.
+func NewOutput(amount uint64, id string) (*Output, error) {
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return nil, err
+	}
+	secret := hex.EncodeToString(raw[:])
+
+	r, err := randScalar()
+	if err != nil {
+		return nil, err
+	}
+	B, err := Blind([]byte(secret), r)
+	if err != nil {
+		return nil, err
+	}
+	return &Output{
+		Message: BlindedMessage{Amount: amount, ID: id, B: hex.EncodeToString(B.Compressed())},
+		Secret:  secret,
+		R:       r,
+	}, nil
+}
+
+// Blind returns B_ = hash_to_curve(secret) + r·G.
+func Blind(secret []byte, r *big.Int) (*secp256k1.Point, error) {
+	Y, err := HashToCurve(secret)
+	if err != nil {
+		return nil, err
+	}
+	return secp256k1.Add(Y, secp256k1.ScalarBaseMult(r)), nil
+}
+
+// Unblind turns the mint's signature into a proof: C = C_ - r·K, where K is
+// the mint's public key for the output's amount.
+func (o *Output) Unblind(sig BlindSignature, mintKey string) (Proof, error) {
+	if sig.Amount != o.Message.Amount {
+		return Proof{}, fmt.Errorf("cashu: signature for %d, expected %d", sig.Amount, o.Message.Amount)
+	}
+	C_, err := parseHexPoint(sig.C)
+	if err != nil {
+		return Proof{}, fmt.Errorf("cashu: blind signature: %w", err)
+	}
+	K, err := parseHexPoint(mintKey)
+	if err != nil {
+		return Proof{}, fmt.Errorf("cashu: mint key: %w", err)
+	}
+	C := secp256k1.Add(C_, secp256k1.ScalarMult(K, o.R).Neg())
+	return Proof{
+		Amount: sig.Amount,
+		ID:     sig.ID,
+		Secret: o.Secret,
+		C:      hex.EncodeToString(C.Compressed()),
+	}, nil
+}
+
+// SplitAmount decomposes n into the powers of two mints issue, smallest first.
+func SplitAmount(n uint64) []uint64 {
+	var out []uint64
+	for bit := uint64(1); n > 0; bit <<= 1 {
+		if n&bit != 0 {
+			out = append(out, bit)
+			n &^= bit
+		}
+	}
+	return out
+}
+
+// Sum returns the total value of proofs.
+func Sum(proofs []Proof) uint64 {
+	var total uint64
+	for _, p := range proofs {
+		total += p.Amount
+	}
+	return total
+}
+
+func parseHexPoint(s string) (*secp256k1.Point, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return secp256k1.ParsePoint(b)
+}
+
+func randScalar() (*big.Int, error) {
+	for {
+		var b [32]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		k := new(big.Int).SetBytes(b[:])
+		if k.Sign() > 0 && k.Cmp(secp256k1.N) < 0 {
+			return k, nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package cashu
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+)
+
+func TestHashToCurve(t *testing.T) {
+	// NUT-00 test vectors
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"0000000000000000000000000000000000000000000000000000000000000000", "024cce997d3b518f739663b757deaec95bcd9473c30a14ac2fd04023a739d1a725"},
+		{"0000000000000000000000000000000000000000000000000000000000000001", "022e7158e11c9506f1aa4248bf531298daa7febd6194f003edcd9b93ade6253acf"},
+		{"0000000000000000000000000000000000000000000000000000000000000002", "026cdbe15362df59cd1dd3c9c11de8aedac2106eca69236ecd9fbe117af897be4f"},
+	}
+	for _, tt := range tests {
+		msg, _ := hex.DecodeString(tt.msg)
+		Y, err := HashToCurve(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(Y.Compressed()); got != tt.want {
+			t.Errorf("HashToCurve(%s) = %s, want %s", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestSplitAmount(t *testing.T) {
+	got := SplitAmount(13)
+	want := []uint64{1, 4, 8}
+	if len(got) != len(want) {
+		t.Fatalf("SplitAmount(13) = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SplitAmount(13) = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBlindSignatureRoundTrip(t *testing.T) {
+	k := big.NewInt(0x1234567)
+	K := hex.EncodeToString(secp256k1.ScalarBaseMult(k).Compressed())
+
+	out, err := NewOutput(8, "009a1f293253e41e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	C_, err := SignBlinded(out.Message.B, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := out.Unblind(BlindSignature{Amount: 8, ID: out.Message.ID, C: C_}, K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(proof, k) {
+		t.Error("unblinded proof does not verify against the mint key")
+	}
+	if Verify(proof, big.NewInt(7)) {
+		t.Error("proof verified against the wrong key")
+	}
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	tok := &Token{
+		Mint: "https://mint.example.com",
+		Unit: "sat",
+		Proofs: []Proof{
+			{Amount: 1, ID: "009a1f293253e41e", Secret: "a", C: "02" + strings.Repeat("11", 32)},
+			{Amount: 4, ID: "009a1f293253e41e", Secret: "b", C: "03" + strings.Repeat("22", 32)},
+			{Amount: 2, ID: "00ad268c4d1f5826", Secret: "c", C: "02" + strings.Repeat("33", 32)},
+		},
+	}
+	s, err := tok.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s, "cashuB") {
+		t.Fatalf("expected a V4 token, got %s", s)
+	}
+
+	got, err := DecodeToken(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Mint != tok.Mint || got.Unit != tok.Unit || Sum(got.Proofs) != 7 || len(got.Proofs) != 3 {
+		t.Errorf("round trip changed the token: %+v", got)
+	}
+	for i, p := range got.Proofs {
+		if p != tok.Proofs[i] {
+			t.Errorf("proof %d = %+v, want %+v", i, p, tok.Proofs[i])
+		}
+	}
+}
+
+func TestDecodeTokenV3(t *testing.T) {
+	v3 := `{"token":[{"mint":"https://8333.space:3338","proofs":[{"amount":2,"id":"009a1f293253e41e","secret":"407915bc","C":"02bc9097997d81afb2cc7346b5e4345a9346bd2a506eb7958598a72f0cf85163ea"}]}],"unit":"sat","memo":"Thank you."}`
+	tok, err := DecodeToken("cashuA" + base64.URLEncoding.EncodeToString([]byte(v3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Mint != "https://8333.space:3338" || tok.Memo != "Thank you." || Sum(tok.Proofs) != 2 {
+		t.Errorf("unexpected token %+v", tok)
+	}
+}
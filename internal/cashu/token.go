@@ -0,0 +1,185 @@
+package cashu
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/joelklabo/agentpay/internal/cbor"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+)
+
+// Token is a set of proofs from one mint, as passed between wallets.
+type Token struct {
+	Mint   string
+	Unit   string
+	Memo   string
+	Proofs []Proof
+}
+
+// Encode serializes the token in the V4 "cashuB" CBOR format.
+func (t *Token) Encode() (string, error) {
+	// V4 groups proofs by keyset
+	var groups []any
+	byID := map[string]int{}
+	for _, p := range t.Proofs {
+		id, err := hex.DecodeString(p.ID)
+		if err != nil {
+			return "", fmt.Errorf("cashu: keyset id %q: %w", p.ID, err)
+		}
+		c, err := hex.DecodeString(p.C)
+		if err != nil {
+			return "", fmt.Errorf("cashu: proof signature: %w", err)
+		}
+		proof := map[string]any{"a": p.Amount, "s": p.Secret, "c": c}
+
+		i, ok := byID[p.ID]
+		if !ok {
+			i = len(groups)
+			byID[p.ID] = i
+			groups = append(groups, map[string]any{"i": id, "p": []any{}})
+		}
+		g := groups[i].(map[string]any)
+		g["p"] = append(g["p"].([]any), proof)
+	}
+
+	m := map[string]any{"m": t.Mint, "u": t.Unit, "t": groups}
+	if t.Memo != "" {
+		m["d"] = t.Memo
+	}
+	data, err := cbor.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return "cashuB" + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeToken parses a V4 "cashuB" or V3 "cashuA" token.
+func DecodeToken(s string) (*Token, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "cashu:")
+	if len(s) < 6 {
+		return nil, errors.New("cashu: token too short")
+	}
+	prefix, payload := s[:6], strings.TrimRight(s[6:], "=")
+
+	switch prefix {
+	case "cashuB":
+		data, err := base64.RawURLEncoding.DecodeString(payload)
+		if err != nil {
+			return nil, fmt.Errorf("cashu: token: %w", err)
+		}
+		return decodeV4(data)
+	case "cashuA":
+		data, err := base64.RawURLEncoding.DecodeString(payload)
+		if err != nil {
+			// V3 tokens are often standard base64
+			if data, err = base64.RawStdEncoding.DecodeString(payload); err != nil {
+				return nil, fmt.Errorf("cashu: token: %w", err)
+			}
+		}
+		return decodeV3(data)
+	default:
+		return nil, fmt.Errorf("cashu: unsupported token prefix %q", prefix)
+	}
+}
+
+func decodeV4(data []byte) (*Token, error) {
+	v, err := cbor.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("cashu: token: %w", err)
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("cashu: token is not a map")
+	}
+
+	t := &Token{}
+	t.Mint, _ = m["m"].(string)
+	t.Unit, _ = m["u"].(string)
+	t.Memo, _ = m["d"].(string)
+
+	groups, _ := m["t"].([]any)
+	for _, g := range groups {
+		gm, ok := g.(map[string]any)
+		if !ok {
+			return nil, errors.New("cashu: malformed keyset group")
+		}
+		id, _ := gm["i"].([]byte)
+		proofs, _ := gm["p"].([]any)
+		for _, p := range proofs {
+			pm, ok := p.(map[string]any)
+			if !ok {
+				return nil, errors.New("cashu: malformed proof")
+			}
+			amount, _ := pm["a"].(uint64)
+			secret, _ := pm["s"].(string)
+			c, _ := pm["c"].([]byte)
+			t.Proofs = append(t.Proofs, Proof{
+				Amount: amount,
+				ID:     hex.EncodeToString(id),
+				Secret: secret,
+				C:      hex.EncodeToString(c),
+			})
+		}
+	}
+	if t.Mint == "" || len(t.Proofs) == 0 {
+		return nil, errors.New("cashu: token has no mint or proofs")
+	}
+	return t, nil
+}
+
+func decodeV3(data []byte) (*Token, error) {
+	var v3 struct {
+		Token []struct {
+			Mint   string  `json:"mint"`
+			Proofs []Proof `json:"proofs"`
+		} `json:"token"`
+		Unit string `json:"unit"`
+		Memo string `json:"memo"`
+	}
+	if err := json.Unmarshal(data, &v3); err != nil {
+		return nil, fmt.Errorf("cashu: token: %w", err)
+	}
+	if len(v3.Token) == 0 || len(v3.Token[0].Proofs) == 0 {
+		return nil, errors.New("cashu: token has no mint or proofs")
+	}
+	t := &Token{Mint: v3.Token[0].Mint, Unit: v3.Unit, Memo: v3.Memo}
+	for _, entry := range v3.Token {
+		if entry.Mint != t.Mint {
+			return nil, errors.New("cashu: multi-mint tokens are not supported")
+		}
+		t.Proofs = append(t.Proofs, entry.Proofs...)
+	}
+	if t.Unit == "" {
+		t.Unit = "sat"
+	}
+	return t, nil
+}
+
+// SignBlinded is the mint side of the exchange: C_ = k·B_. It lets tests and
+// self-hosted mints issue signatures.
+func SignBlinded(B string, k *big.Int) (string, error) {
+	p, err := parseHexPoint(B)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secp256k1.ScalarMult(p, k).Compressed()), nil
+}
+
+// Verify checks a proof against the mint's private key: k·hash_to_curve(secret) == C.
+func Verify(p Proof, k *big.Int) bool {
+	Y, err := HashToCurve([]byte(p.Secret))
+	if err != nil {
+		return false
+	}
+	C, err := parseHexPoint(p.C)
+	if err != nil {
+		return false
+	}
+	return secp256k1.ScalarMult(Y, k).Equal(C)
+}
@@ -0,0 +1,379 @@
+// Package cbor encodes and decodes the subset of CBOR (RFC 8949) used by
+// Cashu tokens and payment requests: unsigned and negative integers, byte and
+// text strings, arrays, maps, booleans, null and floats.
+//
+// Decoded values use Go types: uint64 or int64, []byte, string, []any,
+// map[string]any (maps must have text keys), bool, nil and float64. Tags are
+// skipped and their content returned.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// maxDepth bounds nesting so hostile input cannot exhaust the stack.
+const maxDepth = 32
+
+var errTruncated = errors.New("cbor: unexpected end of data")
+
+// Marshal encodes v. Map keys are written in sorted order so output is
+// deterministic.
+func Marshal(v any) ([]byte, error) {
+	var buf []byte
+	return appendValue(buf, v)
+}
+
+func appendValue(buf []byte, v any) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return append(buf, 0xf6), nil
+	case bool:
+		if val {
+			return append(buf, 0xf5), nil
+		}
+		return append(buf, 0xf4), nil
+	case int:
+		return appendInt(buf, int64(val)), nil
+	case int64:
+		return appendInt(buf, val), nil
+	case uint64:
+		return appendHead(buf, majorUint, val), nil
+	case float64:
+		buf = append(buf, 0xfb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(val)), nil
+	case string:
+		buf = appendHead(buf, majorText, uint64(len(val)))
+		return append(buf, val...), nil
+	case []byte:
+		buf = appendHead(buf, majorBytes, uint64(len(val)))
+		return append(buf, val...), nil
+	case []any:
+		buf = appendHead(buf, majorArray, uint64(len(val)))
+		for _, item := range val {
+			var err error
+			if buf, err = appendValue(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case []string:
+		buf = appendHead(buf, majorArray, uint64(len(val)))
+		for _, item := range val {
+			buf = appendHead(buf, majorText, uint64(len(item)))
+			buf = append(buf, item...)
+		}
+		return buf, nil
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf = appendHead(buf, majorMap, uint64(len(val)))
+		for _, k := range keys {
+			buf = appendHead(buf, majorText, uint64(len(k)))
+			buf = append(buf, k...)
+			var err error
+			if buf, err = appendValue(buf, val[k]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported type %T", v)
+	}
+}
+
+func appendInt(buf []byte, v int64) []byte {
+	if v < 0 {
+		return appendHead(buf, majorNegInt, uint64(-(v + 1)))
+	}
+	return appendHead(buf, majorUint, uint64(v))
+}
+
+func appendHead(buf []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return append(buf, m|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, m|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, m|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, m|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, m|27), n)
+	}
+}
+
+// Unmarshal decodes a single CBOR value that must span all of data.
+func Unmarshal(data []byte) (any, error) {
+	d := decoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("cbor: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) value(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+	if d.pos >= len(d.data) {
+		return nil, errTruncated
+	}
+	ib := d.data[d.pos]
+	d.pos++
+	major, info := ib>>5, ib&0x1f
+
+	if major == majorSimple {
+		return d.simple(info)
+	}
+
+	if info == 31 {
+		return d.indefinite(major, depth)
+	}
+	n, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		return n, nil
+	case majorNegInt:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer overflows int64")
+		}
+		return -1 - int64(n), nil
+	case majorBytes:
+		b, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case majorText:
+		b, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case majorArray:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errTruncated
+		}
+		arr := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+		}
+		return arr, nil
+	case majorMap:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errTruncated
+		}
+		m := make(map[string]any, n)
+		for i := uint64(0); i < n; i++ {
+			if err := d.entry(m, depth); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default: // majorTag
+		return d.value(depth + 1)
+	}
+}
+
+// indefinite decodes an indefinite-length string, array or map.
+func (d *decoder) indefinite(major byte, depth int) (any, error) {
+	switch major {
+	case majorBytes, majorText:
+		var buf []byte
+		for !d.atBreak() {
+			chunk, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch c := chunk.(type) {
+			case []byte:
+				buf = append(buf, c...)
+			case string:
+				buf = append(buf, c...)
+			}
+		}
+		if major == majorText {
+			return string(buf), nil
+		}
+		return buf, nil
+	case majorArray:
+		var arr []any
+		for !d.atBreak() {
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+		}
+		return arr, nil
+	case majorMap:
+		m := make(map[string]any)
+		for !d.atBreak() {
+			if err := d.entry(m, depth); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cbor: indefinite length not allowed for major type %d", major)
+	}
+}
+
+// atBreak consumes a break byte if one is next. Running out of data is
+// reported by the following value call.
+func (d *decoder) atBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == 0xff {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func (d *decoder) entry(m map[string]any, depth int) error {
+	k, err := d.value(depth + 1)
+	if err != nil {
+		return err
+	}
+	key, ok := k.(string)
+	if !ok {
+		return fmt.Errorf("cbor: map key %v is not text", k)
+	}
+	v, err := d.value(depth + 1)
+	if err != nil {
+		return err
+	}
+	m[key] = v
+	return nil
+}
+
+func (d *decoder) simple(info byte) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		b, err := d.take(2)
+		if err != nil {
+			return nil, err
+		}
+		return halfToFloat(binary.BigEndian.Uint16(b)), nil
+	case 26:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 27:
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+	}
+}
+
+func (d *decoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		b, err := d.take(1)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(b[0]), nil
+	case info == 25:
+		b, err := d.take(2)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case info == 26:
+		b, err := d.take(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	case info == 27:
+		b, err := d.take(8)
+		if err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(b), nil
+	default:
+		return 0, fmt.Errorf("cbor: invalid additional info %d", info)
+	}
+}
+
+func (d *decoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,73 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{1000, "1903e8"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{"IETF", "6449455446"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]any{1, []any{2, 3}}, "8201820203"},
+		{map[string]any{"b": 2, "a": 1}, "a2616101616202"},
+		{true, "f5"},
+		{nil, "f6"},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.v)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", tt.v, err)
+		}
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("Marshal(%v) = %x, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want any
+	}{
+		{"1903e8", uint64(1000)},
+		{"3903e7", int64(-1000)},
+		{"6449455446", "IETF"},
+		{"a2616101616202", map[string]any{"a": uint64(1), "b": uint64(2)}},
+		{"9f018202039f0405ffff", []any{uint64(1), []any{uint64(2), uint64(3)}, []any{uint64(4), uint64(5)}}},
+		{"bf616101616202ff", map[string]any{"a": uint64(1), "b": uint64(2)}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"f93e00", 1.5},
+		{"c11a514b67b0", uint64(1363896240)}, // tagged epoch time
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.in)
+		got, err := Unmarshal(data)
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Unmarshal(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, in := range []string{"", "19", "62616263", "a10102", "9bffffffffffffffff", "0000"} {
+		data, _ := hex.DecodeString(in)
+		if _, err := Unmarshal(data); err == nil {
+			t.Errorf("Unmarshal(%s): expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package keccak implements Keccak-256 as used by Ethereum. It differs from
+// SHA3-256 only in the padding byte (0x01 rather than 0x06).
+package keccak
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const rate = 136 // bytes absorbed per permutation for a 256-bit output
+
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+var rotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+type state struct {
+	a   [25]uint64
+	buf []byte
+}
+
+// New256 returns a Keccak-256 hash.Hash.
+func New256() hash.Hash {
+	return &state{}
+}
+
+// Sum256 returns the Keccak-256 digest of the concatenated inputs.
+func Sum256(data ...[]byte) [32]byte {
+	h := &state{}
+	for _, d := range data {
+		h.Write(d)
+	}
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+func (s *state) Write(p []byte) (int, error) {
+	n := len(p)
+	s.buf = append(s.buf, p...)
+	for len(s.buf) >= rate {
+		s.absorb(s.buf[:rate])
+		s.buf = s.buf[rate:]
+	}
+	return n, nil
+}
+
+func (s *state) Sum(b []byte) []byte {
+	// Pad a copy so Sum does not change the running state
+	dup := *s
+	block := make([]byte, rate)
+	copy(block, s.buf)
+	block[len(s.buf)] ^= 0x01
+	block[rate-1] ^= 0x80
+	dup.absorb(block)
+
+	out := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(out[i*8:], dup.a[i])
+	}
+	return append(b, out...)
+}
+
+func (s *state) Reset()         { *s = state{} }
+func (s *state) Size() int      { return 32 }
+func (s *state) BlockSize() int { return rate }
+
+func (s *state) absorb(block []byte) {
+	for i := 0; i < rate/8; i++ {
+		s.a[i] ^= binary.LittleEndian.Uint64(block[i*8:])
+	}
+	permute(&s.a)
+}
+
+// permute applies Keccak-f[1600].
+func permute(a *[25]uint64) {
+	var c [5]uint64
+	var b [25]uint64
+	for round := 0; round < 24; round++ {
+		// θ
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[x+y] ^= d
+			}
+		}
+		// ρ and π
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], rotations[x+5*y])
+			}
+		}
+		// χ
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[x+y] = b[x+y] ^ (^b[(x+1)%5+y] & b[(x+2)%5+y])
+			}
+		}
+		// ι
+		a[0] ^= roundConstants[round]
+	}
+}
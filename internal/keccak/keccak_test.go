@@ -0,0 +1,42 @@
+package keccak
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSum256(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"abc", "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+		{"transfer(address,uint256)", "a9059cbb2ab09eb219583f4a59a5d0623ade346d962bcd4e46b11da047c9049b"},
+	}
+	for _, tt := range tests {
+		got := Sum256([]byte(tt.in))
+		if hex.EncodeToString(got[:]) != tt.want {
+			t.Errorf("Keccak256(%q) = %x, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	// Inputs longer than the rate must match whether written at once or in pieces
+	msg := []byte(strings.Repeat("agentpay", 50))
+	want := Sum256(msg)
+
+	h := New256()
+	for i := 0; i < len(msg); i += 7 {
+		end := i + 7
+		if end > len(msg) {
+			end = len(msg)
+		}
+		h.Write(msg[i:end])
+	}
+	if got := h.Sum(nil); hex.EncodeToString(got) != hex.EncodeToString(want[:]) {
+		t.Errorf("streamed digest %x != one-shot %x", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+// Package keystore reads and writes Ethereum keystore files (Web3 Secret
+// Storage version 3): a secp256k1 private key encrypted with AES-128-CTR under
+// a scrypt or PBKDF2 derived key.
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/joelklabo/agentpay/internal/keccak"
+	"github.com/joelklabo/agentpay/internal/scrypt"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+)
+
+// Scrypt cost parameters. StandardScryptN matches geth's default and takes
+// about a second to unlock; LightScryptN is for tests and low-value keys.
+const (
+	StandardScryptN = 1 << 18
+	LightScryptN    = 1 << 12
+)
+
+// ErrDecrypt is returned when the password does not match the keystore.
+var ErrDecrypt = errors.New("keystore: could not decrypt key with given password")
+
+// Key is a decrypted keystore key.
+type Key struct {
+	PrivateKey *big.Int
+	Address    string // EIP-55 checksummed
+}
+
+type keystoreJSON struct {
+	Address string      `json:"address"`
+	Crypto  cryptoJSON  `json:"crypto"`
+	Legacy  *cryptoJSON `json:"Crypto,omitempty"` // some wallets capitalize it
+	ID      string      `json:"id"`
+	Version int         `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string            `json:"cipher"`
+	CipherText   string            `json:"ciphertext"`
+	CipherParams map[string]string `json:"cipherparams"`
+	KDF          string            `json:"kdf"`
+	KDFParams    map[string]any    `json:"kdfparams"`
+	MAC          string            `json:"mac"`
+}
+
+// Decrypt unlocks a keystore file with password.
+func Decrypt(data []byte, password string) (*Key, error) {
+	var ks keystoreJSON
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("keystore: %w", err)
+	}
+	if ks.Version != 3 {
+		return nil, fmt.Errorf("keystore: unsupported version %d", ks.Version)
+	}
+	c := ks.Crypto
+	if c.Cipher == "" && ks.Legacy != nil {
+		c = *ks.Legacy
+	}
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("keystore: unsupported cipher %q", c.Cipher)
+	}
+
+	derived, err := deriveKey(c, password)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: ciphertext: %w", err)
+	}
+	mac, err := hex.DecodeString(c.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: mac: %w", err)
+	}
+	want := keccak.Sum256(derived[16:32], ciphertext)
+	if !bytes.Equal(want[:], mac) {
+		return nil, ErrDecrypt
+	}
+
+	iv, err := hex.DecodeString(c.CipherParams["iv"])
+	if err != nil {
+		return nil, fmt.Errorf("keystore: iv: %w", err)
+	}
+	plain, err := aesCTR(derived[:16], iv, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	priv := new(big.Int).SetBytes(plain)
+	if priv.Sign() == 0 || priv.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("keystore: invalid private key")
+	}
+	key := &Key{PrivateKey: priv, Address: Address(secp256k1.ScalarBaseMult(priv))}
+	if ks.Address != "" && !strings.EqualFold(strings.TrimPrefix(ks.Address, "0x"), key.Address[2:]) {
+		return nil, fmt.Errorf("keystore: key does not match address %s", ks.Address)
+	}
+	return key, nil
+}
+
+// Encrypt produces a version 3 keystore for priv using scrypt with cost N.
+func Encrypt(priv *big.Int, password string, N int) ([]byte, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	id := make([]byte, 16)
+	for _, b := range [][]byte{salt, iv, id} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+	derived, err := scrypt.Key([]byte(password), salt, N, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]byte, 32)
+	priv.FillBytes(plain)
+	ciphertext, err := aesCTR(derived[:16], iv, plain)
+	if err != nil {
+		return nil, err
+	}
+	mac := keccak.Sum256(derived[16:32], ciphertext)
+
+	id[6] = id[6]&0x0f | 0x40 // UUID version 4
+	id[8] = id[8]&0x3f | 0x80
+	addr := Address(secp256k1.ScalarBaseMult(priv))
+
+	return json.MarshalIndent(keystoreJSON{
+		Address: strings.ToLower(addr[2:]),
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(ciphertext),
+			CipherParams: map[string]string{"iv": hex.EncodeToString(iv)},
+			KDF:          "scrypt",
+			KDFParams: map[string]any{
+				"dklen": 32, "n": N, "r": 8, "p": 1, "salt": hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(mac[:]),
+		},
+		ID:      fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		Version: 3,
+	}, "", "  ")
+}
+
+func deriveKey(c cryptoJSON, password string) ([]byte, error) {
+	salt, err := hex.DecodeString(paramString(c.KDFParams, "salt"))
+	if err != nil {
+		return nil, fmt.Errorf("keystore: salt: %w", err)
+	}
+	dklen := paramInt(c.KDFParams, "dklen")
+	if dklen < 32 {
+		return nil, fmt.Errorf("keystore: derived key length %d is too short", dklen)
+	}
+
+	switch c.KDF {
+	case "scrypt":
+		return scrypt.Key([]byte(password), salt,
+			paramInt(c.KDFParams, "n"), paramInt(c.KDFParams, "r"), paramInt(c.KDFParams, "p"), dklen)
+	case "pbkdf2":
+		if prf := paramString(c.KDFParams, "prf"); prf != "hmac-sha256" {
+			return nil, fmt.Errorf("keystore: unsupported PBKDF2 PRF %q", prf)
+		}
+		return pbkdf2.Key(sha256.New, password, salt, paramInt(c.KDFParams, "c"), dklen)
+	default:
+		return nil, fmt.Errorf("keystore: unsupported KDF %q", c.KDF)
+	}
+}
+
+func paramInt(params map[string]any, key string) int {
+	f, _ := params[key].(float64)
+	return int(f)
+}
+
+func paramString(params map[string]any, key string) string {
+	s, _ := params[key].(string)
+	return s
+}
+
+func aesCTR(key, iv, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: %w", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, errors.New("keystore: invalid iv length")
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
+
+// Address returns the EIP-55 checksummed Ethereum address of pub.
+func Address(pub *secp256k1.Point) string {
+	h := keccak.Sum256(pub.Uncompressed()[1:])
+	return ChecksumAddress(hex.EncodeToString(h[12:]))
+}
+
+// ChecksumAddress applies EIP-55 mixed-case checksumming to a hex address.
+func ChecksumAddress(addr string) string {
+	lower := strings.ToLower(strings.TrimPrefix(addr, "0x"))
+	h := keccak.Sum256([]byte(lower))
+	out := []byte(lower)
+	for i, c := range out {
+		if c >= 'a' && c <= 'f' && (h[i/2]>>(4*(1-uint(i%2))))&0x0f >= 8 {
+			out[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out)
+}
@@ -0,0 +1,79 @@
+package keystore
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+)
+
+// PBKDF2 test vector from the Web3 Secret Storage definition.
+const pbkdf2Vector = `{
+  "crypto": {
+    "cipher": "aes-128-ctr",
+    "cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+    "ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+    "kdf": "pbkdf2",
+    "kdfparams": {
+      "c": 262144,
+      "dklen": 32,
+      "prf": "hmac-sha256",
+      "salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"
+    },
+    "mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+  },
+  "id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+  "version": 3
+}`
+
+func TestDecryptPBKDF2Vector(t *testing.T) {
+	key, err := Decrypt([]byte(pbkdf2Vector), "testpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+	if got := key.PrivateKey.Text(16); got != want {
+		t.Errorf("private key = %s, want %s", got, want)
+	}
+
+	if _, err := Decrypt([]byte(pbkdf2Vector), "wrong"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	priv, _ := new(big.Int).SetString("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", 16)
+	data, err := Encrypt(priv, "hunter2", LightScryptN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := Decrypt(data, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.PrivateKey.Cmp(priv) != 0 {
+		t.Error("round trip changed the private key")
+	}
+	if key.Address != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("address = %s", key.Address)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	// Private key 1
+	if got := Address(secp256k1.G()); got != "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf" {
+		t.Errorf("address of key 1 = %s", got)
+	}
+	// EIP-55 examples
+	for _, addr := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+	} {
+		if got := ChecksumAddress(addr); got != addr {
+			t.Errorf("ChecksumAddress(%s) = %s", addr, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package macaroon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// L402 caveat conditions. Each is written "key=value"; a service's
+// capabilities and expiry are keyed by its name, as in Aperture.
+const (
+	CondServices     = "services"      // services=name:tier,...
+	CondCapabilities = "_capabilities" // <service>_capabilities=cap,...
+	CondValidUntil   = "_valid_until"  // <service>_valid_until=<unix seconds>
+	CondPath         = "path"          // path=<prefix>
+	CondMaxCalls     = "max_calls"     // max_calls=<n>
+)
+
+// Services restricts a macaroon to the named services at tier 0.
+func Services(names ...string) string {
+	tiers := make([]string, len(names))
+	for i, n := range names {
+		tiers[i] = n + ":0"
+	}
+	return CondServices + "=" + strings.Join(tiers, ",")
+}
+
+// Capabilities restricts what a macaroon may do within service.
+func Capabilities(service string, caps ...string) string {
+	return service + CondCapabilities + "=" + strings.Join(caps, ",")
+}
+
+// ValidUntil expires a macaroon for service at t.
+func ValidUntil(service string, t time.Time) string {
+	return service + CondValidUntil + "=" + strconv.FormatInt(t.Unix(), 10)
+}
+
+// PathPrefix restricts a macaroon to request paths under prefix.
+func PathPrefix(prefix string) string {
+	return CondPath + "=" + prefix
+}
+
+// MaxCalls limits how many requests a macaroon buys.
+func MaxCalls(n int) string {
+	return CondMaxCalls + "=" + strconv.Itoa(n)
+}
+
+// Request is what a request presents to satisfy caveats.
+type Request struct {
+	Service    string
+	Capability string // empty when the route needs none
+	Path       string
+	Now        time.Time
+}
+
+// Check accepts condition if it holds for r. max_calls is left to the
+// caller, which has to count calls; see Macaroon.MaxCalls. Unknown
+// conditions are rejected, since a caveat that is not understood cannot be
+// enforced.
+func (r Request) Check(condition string) error {
+	key, value, ok := strings.Cut(condition, "=")
+	if !ok {
+		return fmt.Errorf("malformed caveat %q", condition)
+	}
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+	switch {
+	case key == CondServices:
+		for _, s := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(s), ":")
+			if name == r.Service {
+				return nil
+			}
+		}
+		return fmt.Errorf("macaroon is not valid for service %q", r.Service)
+	case strings.HasSuffix(key, CondCapabilities):
+		if strings.TrimSuffix(key, CondCapabilities) != r.Service || r.Capability == "" {
+			return nil
+		}
+		caps := strings.Split(value, ",")
+		for i := range caps {
+			caps[i] = strings.TrimSpace(caps[i])
+		}
+		if !slices.Contains(caps, r.Capability) {
+			return fmt.Errorf("macaroon does not grant %q", r.Capability)
+		}
+		return nil
+	case strings.HasSuffix(key, CondValidUntil):
+		if strings.TrimSuffix(key, CondValidUntil) != r.Service {
+			return nil
+		}
+		unix, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed caveat %q", condition)
+		}
+		if !r.Now.Before(time.Unix(unix, 0)) {
+			return errors.New("macaroon has expired")
+		}
+		return nil
+	case key == CondPath:
+		if !pathHasPrefix(r.Path, value) {
+			return fmt.Errorf("macaroon is not valid for path %s", r.Path)
+		}
+		return nil
+	case key == CondMaxCalls:
+		if n, err := strconv.Atoi(value); err != nil || n < 1 {
+			return fmt.Errorf("malformed caveat %q", condition)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown caveat %q", condition)
+}
+
+// pathHasPrefix matches whole path segments, so "/v1" covers "/v1" and
+// "/v1/chat" but not "/v10".
+func pathHasPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// MaxCalls returns the tightest max_calls caveat of m, or false if calls
+// are unlimited.
+func (m *Macaroon) MaxCalls() (int, bool) {
+	limit, found := 0, false
+	for _, c := range m.Conditions() {
+		key, value, _ := strings.Cut(c, "=")
+		if strings.TrimSpace(key) != CondMaxCalls {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			continue // Check rejects it
+		}
+		if !found || n < limit {
+			limit, found = n, true
+		}
+	}
+	return limit, found
+}
+
+// Identifier is the L402 macaroon identifier: a version, the payment hash
+// the macaroon is bound to, and a random token ID.
+type Identifier struct {
+	Version     uint16
+	PaymentHash [32]byte
+	TokenID     [32]byte
+}
+
+const identifierLen = 2 + 32 + 32
+
+// Bytes encodes id as version (big-endian) ‖ payment hash ‖ token ID.
+func (id Identifier) Bytes() []byte {
+	out := binary.BigEndian.AppendUint16(make([]byte, 0, identifierLen), id.Version)
+	out = append(out, id.PaymentHash[:]...)
+	return append(out, id.TokenID[:]...)
+}
+
+// DecodeIdentifier parses a version 0 L402 identifier.
+func DecodeIdentifier(b []byte) (*Identifier, error) {
+	if len(b) < 2 {
+		return nil, errors.New("L402 identifier is too short")
+	}
+	id := &Identifier{Version: binary.BigEndian.Uint16(b)}
+	if id.Version != 0 {
+		return nil, fmt.Errorf("unknown L402 identifier version %d", id.Version)
+	}
+	if len(b) != identifierLen {
+		return nil, fmt.Errorf("L402 identifier is %d bytes, want %d", len(b), identifierLen)
+	}
+	copy(id.PaymentHash[:], b[2:34])
+	copy(id.TokenID[:], b[34:])
+	return id, nil
+}
@@ -0,0 +1,258 @@
+// Package macaroon mints, serializes and verifies macaroons in the v2 binary
+// format used by L402, with first-party caveats only.
+package macaroon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// V2 field types.
+const (
+	fieldEOS        = 0
+	fieldLocation   = 1
+	fieldIdentifier = 2
+	fieldVID        = 4
+	fieldSignature  = 6
+)
+
+const version2 = 2
+
+// Macaroon is a bearer credential whose signature chains an HMAC over its
+// identifier and each caveat, so caveats can be added but never removed.
+type Macaroon struct {
+	Location string
+	ID       []byte
+	Caveats  []Caveat
+	Sig      [32]byte
+}
+
+// Caveat is a condition attached to a macaroon. First-party caveats have an
+// empty VerificationID; third-party ones are decoded but never satisfied.
+type Caveat struct {
+	Location       string
+	ID             []byte
+	VerificationID []byte
+}
+
+// FirstParty reports whether c is checked by the macaroon's issuer.
+func (c Caveat) FirstParty() bool { return len(c.VerificationID) == 0 }
+
+// New mints a macaroon with identifier id under rootKey.
+func New(rootKey, id []byte, location string) *Macaroon {
+	m := &Macaroon{Location: location, ID: append([]byte(nil), id...)}
+	copy(m.Sig[:], keyedHash(deriveKey(rootKey), id))
+	return m
+}
+
+// AddFirstPartyCaveat attenuates m with condition.
+func (m *Macaroon) AddFirstPartyCaveat(condition string) {
+	m.Caveats = append(m.Caveats, Caveat{ID: []byte(condition)})
+	copy(m.Sig[:], keyedHash(m.Sig[:], []byte(condition)))
+}
+
+// Conditions returns the first-party caveats of m in order.
+func (m *Macaroon) Conditions() []string {
+	var out []string
+	for _, c := range m.Caveats {
+		if c.FirstParty() {
+			out = append(out, string(c.ID))
+		}
+	}
+	return out
+}
+
+// Verify checks that m was minted under rootKey and that check accepts
+// every caveat. Third-party caveats are rejected.
+func (m *Macaroon) Verify(rootKey []byte, check func(condition string) error) error {
+	sig := keyedHash(deriveKey(rootKey), m.ID)
+	for _, c := range m.Caveats {
+		if !c.FirstParty() {
+			return errors.New("macaroon has a third-party caveat")
+		}
+		sig = keyedHash(sig, c.ID)
+	}
+	if !hmac.Equal(sig, m.Sig[:]) {
+		return errors.New("macaroon signature is invalid")
+	}
+	for _, c := range m.Caveats {
+		if err := check(string(c.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deriveKey stretches a root key the way libmacaroons does, so macaroons
+// minted here verify elsewhere.
+func deriveKey(rootKey []byte) []byte {
+	return keyedHash([]byte("macaroons-key-generator"), rootKey)
+}
+
+func keyedHash(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// MarshalBinary encodes m in the v2 binary format.
+func (m *Macaroon) MarshalBinary() ([]byte, error) {
+	out := []byte{version2}
+	if m.Location != "" {
+		out = appendField(out, fieldLocation, []byte(m.Location))
+	}
+	out = appendField(out, fieldIdentifier, m.ID)
+	out = append(out, fieldEOS)
+	for _, c := range m.Caveats {
+		if c.Location != "" {
+			out = appendField(out, fieldLocation, []byte(c.Location))
+		}
+		out = appendField(out, fieldIdentifier, c.ID)
+		if len(c.VerificationID) > 0 {
+			out = appendField(out, fieldVID, c.VerificationID)
+		}
+		out = append(out, fieldEOS)
+	}
+	out = append(out, fieldEOS)
+	return appendField(out, fieldSignature, m.Sig[:]), nil
+}
+
+func appendField(out []byte, typ byte, data []byte) []byte {
+	out = append(out, typ)
+	out = binary.AppendUvarint(out, uint64(len(data)))
+	return append(out, data...)
+}
+
+// UnmarshalBinary decodes a v2 binary macaroon.
+func (m *Macaroon) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != version2 {
+		return errors.New("not a v2 binary macaroon")
+	}
+	d := decoder{data: data[1:]}
+	*m = Macaroon{}
+
+	if d.next(fieldLocation) {
+		m.Location = string(d.field())
+	}
+	if !d.next(fieldIdentifier) {
+		return d.fail("identifier")
+	}
+	m.ID = d.field()
+	if !d.next(fieldEOS) {
+		return d.fail("end of header")
+	}
+	for !d.next(fieldEOS) {
+		var c Caveat
+		if d.next(fieldLocation) {
+			c.Location = string(d.field())
+		}
+		if !d.next(fieldIdentifier) {
+			return d.fail("caveat identifier")
+		}
+		c.ID = d.field()
+		if d.next(fieldVID) {
+			c.VerificationID = d.field()
+		}
+		if !d.next(fieldEOS) {
+			return d.fail("end of caveat")
+		}
+		m.Caveats = append(m.Caveats, c)
+	}
+	if !d.next(fieldSignature) {
+		return d.fail("signature")
+	}
+	sig := d.field()
+	if d.err != nil {
+		return d.err
+	}
+	if len(sig) != len(m.Sig) {
+		return fmt.Errorf("macaroon signature is %d bytes, want %d", len(sig), len(m.Sig))
+	}
+	copy(m.Sig[:], sig)
+	if len(d.data) != 0 {
+		return errors.New("trailing data after macaroon")
+	}
+	return nil
+}
+
+// decoder walks the fields of a v2 macaroon. next consumes the type byte
+// only when it matches, and field then reads the length-prefixed value.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(typ byte) bool {
+	if d.err != nil || len(d.data) == 0 || d.data[0] != typ {
+		return false
+	}
+	d.data = d.data[1:]
+	return true
+}
+
+func (d *decoder) field() []byte {
+	if d.err != nil {
+		return nil
+	}
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 || n > uint64(len(d.data)-size) {
+		d.err = errors.New("truncated macaroon field")
+		return nil
+	}
+	v := d.data[size : size+int(n)]
+	d.data = d.data[size+int(n):]
+	return append([]byte(nil), v...)
+}
+
+func (d *decoder) fail(want string) error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) == 0 {
+		return fmt.Errorf("truncated macaroon: missing %s", want)
+	}
+	return fmt.Errorf("malformed macaroon: field type %d where %s was expected", d.data[0], want)
+}
+
+// Encode returns m as base64url without padding, the form L402 challenges
+// carry.
+func (m *Macaroon) Encode() string {
+	data, _ := m.MarshalBinary()
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a binary macaroon given as base64 (standard or URL
+// alphabet, padded or not) or hex.
+func Decode(s string) (*Macaroon, error) {
+	s = strings.TrimSpace(s)
+	data, err := decodeText(s)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Macaroon)
+	if err := m.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func decodeText(s string) ([]byte, error) {
+	if len(s)%2 == 0 && strings.HasPrefix(s, "02") {
+		if data, err := hex.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("macaroon is neither base64 nor hex")
+	}
+	return data, nil
+}
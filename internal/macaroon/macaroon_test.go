@@ -0,0 +1,167 @@
+package macaroon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("this is our super secret key; only we should know it")
+
+func TestSignature(t *testing.T) {
+	// From the libmacaroons README
+	m := New(testKey, []byte("we used our secret key"), "http://mybank/")
+	if got := hex.EncodeToString(m.Sig[:]); got != "e3d9e02908526c4c0039ae15114115d97fdd68bf2ba379b342aaf0f617d0552f" {
+		t.Errorf("signature = %s", got)
+	}
+	m.AddFirstPartyCaveat("account = 3735928559")
+	if got := hex.EncodeToString(m.Sig[:]); got != "1efe4763f290dbce0c1d08477367e11f4eee456a64933cf662d79772dbb82128" {
+		t.Errorf("signature with caveat = %s", got)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	m := New(testKey, []byte("id"), "agentpay")
+	m.AddFirstPartyCaveat(Services("api"))
+	m.AddFirstPartyCaveat(MaxCalls(3))
+
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "02" + "0108" + hex.EncodeToString([]byte("agentpay")) + "0202" + hex.EncodeToString([]byte("id")) + "00" +
+		"020e" + hex.EncodeToString([]byte("services=api:0")) + "00" +
+		"020b" + hex.EncodeToString([]byte("max_calls=3")) + "00" +
+		"00" + "0620" + hex.EncodeToString(m.Sig[:])
+	if got := hex.EncodeToString(data); got != want {
+		t.Fatalf("binary = %s\nwant     %s", got, want)
+	}
+
+	for _, s := range []string{m.Encode(), base64.StdEncoding.EncodeToString(data), hex.EncodeToString(data)} {
+		got, err := Decode(s)
+		if err != nil {
+			t.Fatalf("Decode(%s): %v", s, err)
+		}
+		if got.Location != "agentpay" || !bytes.Equal(got.ID, m.ID) || got.Sig != m.Sig ||
+			strings.Join(got.Conditions(), ";") != "services=api:0;max_calls=3" {
+			t.Errorf("Decode(%s) = %+v", s, got)
+		}
+		if err := got.Verify(testKey, func(string) error { return nil }); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	m := New(testKey, []byte("id"), "")
+	data, _ := m.MarshalBinary()
+	tests := map[string][]byte{
+		"empty":     nil,
+		"v1":        append([]byte{1}, data[1:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(append([]byte(nil), data...), 0),
+		"no id":     {2, 0, 0, 6, 0},
+	}
+	for name, b := range tests {
+		if _, err := Decode(hex.EncodeToString(b)); err == nil {
+			t.Errorf("%s: decoded", name)
+		}
+	}
+	if _, err := Decode("not a macaroon!"); err == nil {
+		t.Error("decoded garbage")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	mint := func() *Macaroon {
+		m := New(testKey, []byte("id"), "")
+		m.AddFirstPartyCaveat(Services("api", "images"))
+		m.AddFirstPartyCaveat(Capabilities("api", "chat", "embed"))
+		m.AddFirstPartyCaveat(ValidUntil("api", now.Add(time.Hour)))
+		m.AddFirstPartyCaveat(PathPrefix("/v1"))
+		return m
+	}
+	ok := Request{Service: "api", Capability: "chat", Path: "/v1/chat", Now: now}
+
+	if err := mint().Verify(testKey, ok.Check); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := mint().Verify([]byte("other key"), ok.Check); err == nil {
+		t.Error("verified under the wrong key")
+	}
+
+	// Dropping a caveat breaks the signature chain
+	stripped := mint()
+	stripped.Caveats = stripped.Caveats[:len(stripped.Caveats)-1]
+	if err := stripped.Verify(testKey, ok.Check); err == nil {
+		t.Error("verified with a caveat removed")
+	}
+
+	// Attenuating a valid macaroon keeps it valid, and enforced
+	narrowed := mint()
+	narrowed.AddFirstPartyCaveat(PathPrefix("/v1/embed"))
+	if err := narrowed.Verify(testKey, ok.Check); err == nil {
+		t.Error("narrowed path was not enforced")
+	}
+
+	rejected := map[string]Request{
+		"service":    {Service: "video", Path: "/v1/chat", Now: now},
+		"capability": {Service: "api", Capability: "admin", Path: "/v1/chat", Now: now},
+		"expired":    {Service: "api", Path: "/v1/chat", Now: now.Add(time.Hour)},
+		"path":       {Service: "api", Path: "/v10/chat", Now: now},
+	}
+	for name, r := range rejected {
+		if err := mint().Verify(testKey, r.Check); err == nil {
+			t.Errorf("%s: verified", name)
+		}
+	}
+
+	// Capabilities and expiry of another service do not apply
+	images := Request{Service: "images", Capability: "upload", Path: "/v1/x", Now: now.Add(2 * time.Hour)}
+	if err := mint().Verify(testKey, images.Check); err != nil {
+		t.Errorf("images: %v", err)
+	}
+
+	unknown := New(testKey, []byte("id"), "")
+	unknown.AddFirstPartyCaveat("ip=10.0.0.1")
+	if err := unknown.Verify(testKey, ok.Check); err == nil {
+		t.Error("verified an unknown caveat")
+	}
+}
+
+func TestMaxCalls(t *testing.T) {
+	m := New(testKey, []byte("id"), "")
+	if _, ok := m.MaxCalls(); ok {
+		t.Error("unlimited macaroon has a limit")
+	}
+	m.AddFirstPartyCaveat(MaxCalls(10))
+	m.AddFirstPartyCaveat(MaxCalls(3))
+	m.AddFirstPartyCaveat(MaxCalls(5))
+	if n, ok := m.MaxCalls(); !ok || n != 3 {
+		t.Errorf("MaxCalls = %d, %v; want 3", n, ok)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	var id Identifier
+	id.PaymentHash[0], id.TokenID[31] = 0xaa, 0xbb
+	b := id.Bytes()
+	if len(b) != 66 || b[0] != 0 || b[1] != 0 || b[2] != 0xaa || b[65] != 0xbb {
+		t.Fatalf("Bytes = %x", b)
+	}
+	got, err := DecodeIdentifier(b)
+	if err != nil || *got != id {
+		t.Errorf("DecodeIdentifier = %+v, %v", got, err)
+	}
+	if _, err := DecodeIdentifier(b[:65]); err == nil {
+		t.Error("decoded a short identifier")
+	}
+	b[1] = 1
+	if _, err := DecodeIdentifier(b); err == nil {
+		t.Error("decoded an unknown version")
+	}
+}
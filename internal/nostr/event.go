@@ -0,0 +1,177 @@
+// Package nostr implements the parts of the Nostr protocol AgentPay needs to
+// talk to wallets over relays: signed events (NIP-01) and encrypted direct
+// payloads (NIP-04 and NIP-44).
+package nostr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+)
+
+// Event is a NIP-01 event.
+type Event struct {
+	ID        string     `json:"id"`
+	PubKey    string     `json:"pubkey"`
+	CreatedAt int64      `json:"created_at"`
+	Kind      int        `json:"kind"`
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+	Sig       string     `json:"sig"`
+}
+
+// Tag returns the first value of the first tag named name, or "".
+func (e *Event) Tag(name string) string {
+	for _, t := range e.Tags {
+		if len(t) >= 2 && t[0] == name {
+			return t[1]
+		}
+	}
+	return ""
+}
+
+// serialize returns the canonical [0, pubkey, created_at, kind, tags,
+// content] array the event ID is the hash of.
+func (e *Event) serialize() []byte {
+	var b strings.Builder
+	b.WriteString(`[0,"`)
+	b.WriteString(e.PubKey)
+	b.WriteString(`",`)
+	b.WriteString(strconv.FormatInt(e.CreatedAt, 10))
+	b.WriteString(",")
+	b.WriteString(strconv.Itoa(e.Kind))
+	b.WriteString(",[")
+	for i, tag := range e.Tags {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("[")
+		for j, v := range tag {
+			if j > 0 {
+				b.WriteString(",")
+			}
+			writeString(&b, v)
+		}
+		b.WriteString("]")
+	}
+	b.WriteString("],")
+	writeString(&b, e.Content)
+	b.WriteString("]")
+	return []byte(b.String())
+}
+
+// writeString writes s as a JSON string with NIP-01's minimal escaping.
+func writeString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// computeID returns the hex SHA-256 of the serialized event.
+func (e *Event) computeID() string {
+	sum := sha256.Sum256(e.serialize())
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign sets PubKey, ID and Sig for the event with priv.
+func (e *Event) Sign(priv *big.Int) error {
+	if e.Tags == nil {
+		e.Tags = [][]string{}
+	}
+	e.PubKey = hex.EncodeToString(secp256k1.XOnlyPublicKey(priv))
+	e.ID = e.computeID()
+	id, _ := hex.DecodeString(e.ID)
+
+	aux := make([]byte, 32)
+	if _, err := rand.Read(aux); err != nil {
+		return err
+	}
+	sig, err := secp256k1.SignSchnorr(priv, id, aux)
+	if err != nil {
+		return err
+	}
+	e.Sig = hex.EncodeToString(sig)
+	return nil
+}
+
+// Verify checks the event's ID and signature.
+func (e *Event) Verify() error {
+	if !utf8.ValidString(e.Content) {
+		return errors.New("nostr: content is not valid UTF-8")
+	}
+	if e.computeID() != e.ID {
+		return errors.New("nostr: event id does not match its content")
+	}
+	id, _ := hex.DecodeString(e.ID)
+	pub, err := hex.DecodeString(e.PubKey)
+	if err != nil {
+		return errors.New("nostr: invalid pubkey")
+	}
+	sig, err := hex.DecodeString(e.Sig)
+	if err != nil || !secp256k1.VerifySchnorr(pub, id, sig) {
+		return errors.New("nostr: invalid signature")
+	}
+	return nil
+}
+
+// ParsePrivateKey decodes a hex secret key.
+func ParsePrivateKey(s string) (*big.Int, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		return nil, errors.New("nostr: secret key must be 32 bytes of hex")
+	}
+	k := new(big.Int).SetBytes(b)
+	if k.Sign() == 0 || k.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("nostr: secret key out of range")
+	}
+	return k, nil
+}
+
+// PublicKey returns the hex x-only public key for priv.
+func PublicKey(priv *big.Int) string {
+	return hex.EncodeToString(secp256k1.XOnlyPublicKey(priv))
+}
+
+// sharedX returns the ECDH x coordinate between priv and a hex x-only pubkey.
+func sharedX(priv *big.Int, pubHex string) ([]byte, error) {
+	b, err := hex.DecodeString(pubHex)
+	if err != nil {
+		return nil, errors.New("nostr: invalid pubkey")
+	}
+	pub, err := secp256k1.ParseXOnly(b)
+	if err != nil {
+		return nil, fmt.Errorf("nostr: %w", err)
+	}
+	return secp256k1.ECDH(priv, pub)
+}
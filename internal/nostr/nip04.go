@@ -0,0 +1,75 @@
+package nostr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// EncryptNIP04 encrypts plaintext for pubHex with AES-256-CBC under the raw
+// ECDH shared x coordinate, formatted as "<ciphertext>?iv=<iv>".
+func EncryptNIP04(priv *big.Int, pubHex, plaintext string) (string, error) {
+	key, err := sharedX(priv, pubHex)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	data := append([]byte(plaintext), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	return base64.StdEncoding.EncodeToString(data) + "?iv=" + base64.StdEncoding.EncodeToString(iv), nil
+}
+
+// DecryptNIP04 reverses EncryptNIP04 for a message from pubHex.
+func DecryptNIP04(priv *big.Int, pubHex, content string) (string, error) {
+	ctB64, ivB64, ok := strings.Cut(content, "?iv=")
+	if !ok {
+		return "", errors.New("nip04: missing iv")
+	}
+	data, err := base64.StdEncoding.DecodeString(ctB64)
+	if err != nil {
+		return "", errors.New("nip04: invalid ciphertext")
+	}
+	iv, err := base64.StdEncoding.DecodeString(ivB64)
+	if err != nil || len(iv) != aes.BlockSize {
+		return "", errors.New("nip04: invalid iv")
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return "", errors.New("nip04: invalid ciphertext length")
+	}
+
+	key, err := sharedX(priv, pubHex)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(data) {
+		return "", errors.New("nip04: invalid padding")
+	}
+	for _, b := range data[len(data)-pad:] {
+		if int(b) != pad {
+			return "", errors.New("nip04: invalid padding")
+		}
+	}
+	return string(data[:len(data)-pad]), nil
+}
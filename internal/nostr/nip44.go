@@ -0,0 +1,167 @@
+package nostr
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+// ConversationKey derives the NIP-44 v2 key shared by priv and pubHex.
+func ConversationKey(priv *big.Int, pubHex string) ([]byte, error) {
+	shared, err := sharedX(priv, pubHex)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Extract(sha256.New, shared, []byte("nip44-v2"))
+}
+
+// EncryptNIP44 encrypts plaintext under a conversation key (NIP-44 v2).
+func EncryptNIP44(conversationKey []byte, plaintext string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return encryptNIP44(conversationKey, plaintext, nonce)
+}
+
+func encryptNIP44(conversationKey []byte, plaintext string, nonce []byte) (string, error) {
+	if len(plaintext) < 1 || len(plaintext) > 65535 {
+		return "", errors.New("nip44: plaintext must be 1 to 65535 bytes")
+	}
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	padded := make([]byte, 2+paddedLen(len(plaintext)))
+	binary.BigEndian.PutUint16(padded, uint16(len(plaintext)))
+	copy(padded[2:], plaintext)
+	chacha20(chachaKey, chachaNonce, padded)
+
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(nonce)
+	mac.Write(padded)
+
+	payload := []byte{2}
+	payload = append(payload, nonce...)
+	payload = append(payload, padded...)
+	payload = mac.Sum(payload)
+	return base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// DecryptNIP44 decrypts a NIP-44 v2 payload.
+func DecryptNIP44(conversationKey []byte, payload string) (string, error) {
+	if payload == "" || payload[0] == '#' {
+		return "", errors.New("nip44: unsupported encryption version")
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errors.New("nip44: invalid base64")
+	}
+	if len(data) < 99 || len(data) > 65603 {
+		return "", errors.New("nip44: invalid payload length")
+	}
+	if data[0] != 2 {
+		return "", errors.New("nip44: unsupported encryption version")
+	}
+	nonce, ciphertext, tag := data[1:33], data[33:len(data)-32], data[len(data)-32:]
+
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(nonce)
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil), tag) {
+		return "", errors.New("nip44: invalid MAC")
+	}
+
+	padded := append([]byte(nil), ciphertext...)
+	chacha20(chachaKey, chachaNonce, padded)
+	n := int(binary.BigEndian.Uint16(padded))
+	if n < 1 || len(padded) != 2+paddedLen(n) {
+		return "", errors.New("nip44: invalid padding")
+	}
+	return string(padded[2 : 2+n]), nil
+}
+
+func messageKeys(conversationKey, nonce []byte) (chachaKey, chachaNonce, hmacKey []byte, err error) {
+	if len(conversationKey) != 32 || len(nonce) != 32 {
+		return nil, nil, nil, errors.New("nip44: invalid key or nonce length")
+	}
+	keys, err := hkdf.Expand(sha256.New, conversationKey, string(nonce), 76)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return keys[:32], keys[32:44], keys[44:], nil
+}
+
+// paddedLen rounds a plaintext length up to NIP-44's padding scheme: 32
+// bytes minimum, then chunks of 1/8 of the next power of two.
+func paddedLen(n int) int {
+	if n <= 32 {
+		return 32
+	}
+	nextPower := 1 << bits.Len(uint(n-1))
+	chunk := 32
+	if nextPower > 256 {
+		chunk = nextPower / 8
+	}
+	return chunk * ((n-1)/chunk + 1)
+}
+
+// chacha20 XORs data in place with the RFC 8439 ChaCha20 keystream, starting
+// at block counter 0.
+func chacha20(key, nonce, data []byte) {
+	var state [16]uint32
+	state[0], state[1], state[2], state[3] = 0x61707865, 0x3320646e, 0x79622d32, 0x6b206574
+	for i := 0; i < 8; i++ {
+		state[4+i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+	for i := 0; i < 3; i++ {
+		state[13+i] = binary.LittleEndian.Uint32(nonce[4*i:])
+	}
+
+	var block [64]byte
+	for offset := 0; offset < len(data); offset += 64 {
+		chachaBlock(&state, &block)
+		state[12]++
+		for i := 0; i < 64 && offset+i < len(data); i++ {
+			data[offset+i] ^= block[i]
+		}
+	}
+}
+
+func chachaBlock(state *[16]uint32, out *[64]byte) {
+	x := *state
+	quarter := func(a, b, c, d int) {
+		x[a] += x[b]
+		x[d] = bits.RotateLeft32(x[d]^x[a], 16)
+		x[c] += x[d]
+		x[b] = bits.RotateLeft32(x[b]^x[c], 12)
+		x[a] += x[b]
+		x[d] = bits.RotateLeft32(x[d]^x[a], 8)
+		x[c] += x[d]
+		x[b] = bits.RotateLeft32(x[b]^x[c], 7)
+	}
+	for i := 0; i < 10; i++ {
+		quarter(0, 4, 8, 12)
+		quarter(1, 5, 9, 13)
+		quarter(2, 6, 10, 14)
+		quarter(3, 7, 11, 15)
+		quarter(0, 5, 10, 15)
+		quarter(1, 6, 11, 12)
+		quarter(2, 7, 8, 13)
+		quarter(3, 4, 9, 14)
+	}
+	for i := range x {
+		binary.LittleEndian.PutUint32(out[4*i:], x[i]+state[i])
+	}
+}
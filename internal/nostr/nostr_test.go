@@ -0,0 +1,104 @@
+package nostr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEventSignVerify(t *testing.T) {
+	priv := big.NewInt(3)
+	e := &Event{CreatedAt: 1700000000, Kind: 23194, Tags: [][]string{{"p", "abc"}}, Content: "line\n\"quoted\""}
+	if err := e.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	if e.PubKey != "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9" {
+		t.Errorf("pubkey = %s", e.PubKey)
+	}
+	want := `[0,"` + e.PubKey + `",1700000000,23194,[["p","abc"]],"line\n\"quoted\""]`
+	if got := string(e.serialize()); got != want {
+		t.Errorf("serialized = %s\nwant %s", got, want)
+	}
+	if err := e.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Content = "tampered"
+	if err := e.Verify(); err == nil {
+		t.Error("expected a tampered event to fail verification")
+	}
+}
+
+func TestNIP44Vector(t *testing.T) {
+	// From the NIP-44 v2 test vectors
+	sec1, sec2 := big.NewInt(1), big.NewInt(2)
+	conv, err := ConversationKey(sec1, PublicKey(sec2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(conv) != "c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d" {
+		t.Fatalf("conversation key = %x", conv)
+	}
+	other, _ := ConversationKey(sec2, PublicKey(sec1))
+	if !bytes.Equal(conv, other) {
+		t.Error("conversation key is not symmetric")
+	}
+
+	nonce := make([]byte, 32)
+	nonce[31] = 1
+	payload, err := encryptNIP44(conv, "a", nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9YypIObAtDKfYEAjD35uVkHyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9Xga8no+kF5Vsb"
+	if payload != want {
+		t.Errorf("payload = %s", payload)
+	}
+	if plain, err := DecryptNIP44(conv, payload); err != nil || plain != "a" {
+		t.Errorf("decrypt = %q, %v", plain, err)
+	}
+}
+
+func TestNIP44RoundTrip(t *testing.T) {
+	conv, _ := ConversationKey(big.NewInt(7), PublicKey(big.NewInt(11)))
+	long := strings.Repeat("x", 1000)
+	payload, err := EncryptNIP44(conv, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := DecryptNIP44(conv, payload); err != nil || plain != long {
+		t.Fatalf("round trip failed: %v", err)
+	}
+
+	// Flip a ciphertext bit; the MAC must catch it
+	raw := []byte(payload)
+	raw[60] ^= 1
+	if _, err := DecryptNIP44(conv, string(raw)); err == nil {
+		t.Error("expected a MAC failure")
+	}
+}
+
+func TestPaddedLen(t *testing.T) {
+	for n, want := range map[int]int{1: 32, 32: 32, 33: 64, 37: 64, 65: 96, 100: 128, 257: 320, 1000: 1024, 65535: 65536} {
+		if got := paddedLen(n); got != want {
+			t.Errorf("paddedLen(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestNIP04RoundTrip(t *testing.T) {
+	alice, bob := big.NewInt(5), big.NewInt(9)
+	content, err := EncryptNIP04(alice, PublicKey(bob), `{"method":"get_balance"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "?iv=") {
+		t.Errorf("content = %s", content)
+	}
+	plain, err := DecryptNIP04(bob, PublicKey(alice), content)
+	if err != nil || plain != `{"method":"get_balance"}` {
+		t.Errorf("decrypt = %q, %v", plain, err)
+	}
+}
@@ -0,0 +1,150 @@
+package secp256k1
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+var halfN = new(big.Int).Rsh(N, 1)
+
+// Signature is an ECDSA signature with the recovery ID Ethereum needs to
+// recover the signer's public key.
+type Signature struct {
+	R, S *big.Int
+	V    byte // recovery ID, 0 or 1
+}
+
+// Sign signs a 32-byte hash with priv using a deterministic RFC 6979 nonce.
+// S is normalized to the lower half of the order, as Ethereum requires.
+func Sign(priv *big.Int, hash []byte) (*Signature, error) {
+	if priv.Sign() <= 0 || priv.Cmp(N) >= 0 {
+		return nil, errors.New("secp256k1: invalid private key")
+	}
+	z := hashToInt(hash)
+
+	nonces := rfc6979(priv, hash)
+	for {
+		k := nonces()
+		R := ScalarBaseMult(k)
+		r := new(big.Int).Mod(R.X, N)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k⁻¹(z + r·priv) mod N
+		s := new(big.Int).Mul(r, priv)
+		s.Add(s, z)
+		s.Mul(s, new(big.Int).ModInverse(k, N))
+		s.Mod(s, N)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		v := byte(R.Y.Bit(0))
+		if R.X.Cmp(N) >= 0 {
+			v |= 2
+		}
+		if s.Cmp(halfN) > 0 {
+			s.Sub(N, s)
+			v ^= 1
+		}
+		return &Signature{R: r, S: s, V: v}, nil
+	}
+}
+
+// RecoverPublicKey returns the public key that produced sig over hash.
+func RecoverPublicKey(hash []byte, sig *Signature) (*Point, error) {
+	if sig.R.Sign() <= 0 || sig.R.Cmp(N) >= 0 || sig.S.Sign() <= 0 || sig.S.Cmp(N) >= 0 || sig.V > 3 {
+		return nil, errors.New("secp256k1: invalid signature")
+	}
+
+	x := new(big.Int).Set(sig.R)
+	if sig.V&2 != 0 {
+		x.Add(x, N)
+	}
+	if x.Cmp(P) >= 0 {
+		return nil, errors.New("secp256k1: invalid signature")
+	}
+	y, ok := liftX(x, sig.V&1 == 1)
+	if !ok {
+		return nil, errors.New("secp256k1: invalid signature")
+	}
+	R := &Point{X: x, Y: y}
+
+	// Q = r⁻¹(s·R - z·G)
+	rInv := new(big.Int).ModInverse(sig.R, N)
+	z := hashToInt(hash)
+	sR := ScalarMult(R, sig.S)
+	zG := ScalarBaseMult(z).Neg()
+	Q := ScalarMult(Add(sR, zG), rInv)
+	if Q.IsInfinity() {
+		return nil, errors.New("secp256k1: invalid signature")
+	}
+	return Q, nil
+}
+
+// Verify reports whether sig is a valid signature of hash by pub.
+func Verify(pub *Point, hash []byte, sig *Signature) bool {
+	if sig.R.Sign() <= 0 || sig.R.Cmp(N) >= 0 || sig.S.Sign() <= 0 || sig.S.Cmp(N) >= 0 {
+		return false
+	}
+	w := new(big.Int).ModInverse(sig.S, N)
+	u1 := new(big.Int).Mul(hashToInt(hash), w)
+	u2 := new(big.Int).Mul(sig.R, w)
+	X := Add(ScalarBaseMult(u1.Mod(u1, N)), ScalarMult(pub, u2.Mod(u2, N)))
+	if X.IsInfinity() {
+		return false
+	}
+	return new(big.Int).Mod(X.X, N).Cmp(sig.R) == 0
+}
+
+func hashToInt(hash []byte) *big.Int {
+	if len(hash) > 32 {
+		hash = hash[:32]
+	}
+	return new(big.Int).SetBytes(hash)
+}
+
+// rfc6979 returns a generator of deterministic nonces for priv and hash
+// (RFC 6979 section 3.2 with HMAC-SHA256).
+func rfc6979(priv *big.Int, hash []byte) func() *big.Int {
+	x := make([]byte, 32)
+	priv.FillBytes(x)
+	h := make([]byte, 32)
+	new(big.Int).Mod(hashToInt(hash), N).FillBytes(h)
+
+	v := make([]byte, 32)
+	k := make([]byte, 32)
+	for i := range v {
+		v[i] = 0x01
+	}
+	mac := func(key []byte, parts ...[]byte) []byte {
+		m := hmac.New(sha256.New, key)
+		for _, p := range parts {
+			m.Write(p)
+		}
+		return m.Sum(nil)
+	}
+
+	k = mac(k, v, []byte{0x00}, x, h)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, x, h)
+	v = mac(k, v)
+
+	return func() *big.Int {
+		for {
+			v = mac(k, v)
+			candidate := new(big.Int).SetBytes(v)
+			if candidate.Sign() > 0 && candidate.Cmp(N) < 0 {
+				// Prepare the next candidate in case this one is rejected
+				k = mac(k, v, []byte{0x00})
+				v = mac(k, v)
+				return candidate
+			}
+			k = mac(k, v, []byte{0x00})
+			v = mac(k, v)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package secp256k1
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// BIP-340 Schnorr signatures over x-only public keys, as used by Nostr.
+
+// taggedHash is SHA256(SHA256(tag) ‖ SHA256(tag) ‖ data...).
+func taggedHash(tag string, data ...[]byte) []byte {
+	t := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(t[:])
+	h.Write(t[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func bytes32(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+// XOnlyPublicKey returns the 32-byte x coordinate of priv's public key.
+func XOnlyPublicKey(priv *big.Int) []byte {
+	return bytes32(ScalarBaseMult(priv).X)
+}
+
+// SignSchnorr signs a 32-byte message with priv. aux is 32 bytes of fresh
+// randomness mixed into the nonce; it may be all zeros, at some cost to
+// side-channel resistance.
+func SignSchnorr(priv *big.Int, msg, aux []byte) ([]byte, error) {
+	if priv.Sign() <= 0 || priv.Cmp(N) >= 0 {
+		return nil, errors.New("secp256k1: invalid private key")
+	}
+	if len(msg) != 32 || len(aux) != 32 {
+		return nil, errors.New("secp256k1: message and aux must be 32 bytes")
+	}
+
+	P := ScalarBaseMult(priv)
+	d := new(big.Int).Set(priv)
+	if P.Y.Bit(0) == 1 {
+		d.Sub(N, d)
+	}
+	px := bytes32(P.X)
+
+	t := bytes32(d)
+	for i, b := range taggedHash("BIP0340/aux", aux) {
+		t[i] ^= b
+	}
+	k := new(big.Int).SetBytes(taggedHash("BIP0340/nonce", t, px, msg))
+	k.Mod(k, N)
+	if k.Sign() == 0 {
+		return nil, errors.New("secp256k1: nonce is zero")
+	}
+	R := ScalarBaseMult(k)
+	if R.Y.Bit(0) == 1 {
+		k.Sub(N, k)
+	}
+	rx := bytes32(R.X)
+
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", rx, px, msg))
+	e.Mod(e, N)
+	s := new(big.Int).Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, N)
+
+	return append(rx, bytes32(s)...), nil
+}
+
+// VerifySchnorr reports whether sig is a valid BIP-340 signature of msg by
+// the x-only public key pub.
+func VerifySchnorr(pub, msg, sig []byte) bool {
+	if len(pub) != 32 || len(sig) != 64 {
+		return false
+	}
+	px := new(big.Int).SetBytes(pub)
+	if px.Cmp(P) >= 0 {
+		return false
+	}
+	py, ok := liftX(px, false)
+	if !ok {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Cmp(P) >= 0 || s.Cmp(N) >= 0 {
+		return false
+	}
+
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", sig[:32], pub, msg))
+	e.Mod(e, N)
+	// R = s·G - e·P
+	R := Add(ScalarBaseMult(s), ScalarMult(&Point{X: px, Y: py}, e).Neg())
+	return !R.IsInfinity() && R.Y.Bit(0) == 0 && R.X.Cmp(r) == 0
+}
+
+// ECDH returns the x coordinate of priv·pub, the shared secret used by Nostr's
+// NIP-04 and NIP-44 encryption.
+func ECDH(priv *big.Int, pub *Point) ([]byte, error) {
+	if priv.Sign() <= 0 || priv.Cmp(N) >= 0 {
+		return nil, errors.New("secp256k1: invalid private key")
+	}
+	shared := ScalarMult(pub, priv)
+	if shared.IsInfinity() {
+		return nil, ErrInvalidPoint
+	}
+	return bytes32(shared.X), nil
+}
+
+// ParseXOnly lifts a 32-byte x-only public key to the point with even y.
+func ParseXOnly(b []byte) (*Point, error) {
+	if len(b) != 32 {
+		return nil, ErrInvalidPoint
+	}
+	x := new(big.Int).SetBytes(b)
+	if x.Cmp(P) >= 0 {
+		return nil, ErrInvalidPoint
+	}
+	y, ok := liftX(x, false)
+	if !ok {
+		return nil, ErrInvalidPoint
+	}
+	return &Point{X: x, Y: y}, nil
+}
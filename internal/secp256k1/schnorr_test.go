@@ -0,0 +1,76 @@
+package secp256k1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestSchnorrBIP340Vectors(t *testing.T) {
+	tests := []struct {
+		priv, pub, aux, msg, sig string
+	}{
+		{
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+		},
+		{
+			"B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF",
+			"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			"6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+		},
+	}
+	for i, tt := range tests {
+		priv, _ := new(big.Int).SetString(tt.priv, 16)
+		aux, _ := hex.DecodeString(tt.aux)
+		msg, _ := hex.DecodeString(tt.msg)
+
+		if got := strings.ToUpper(hex.EncodeToString(XOnlyPublicKey(priv))); got != tt.pub {
+			t.Errorf("vector %d: public key = %s", i, got)
+		}
+		sig, err := SignSchnorr(priv, msg, aux)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.ToUpper(hex.EncodeToString(sig)); got != tt.sig {
+			t.Errorf("vector %d: signature = %s", i, got)
+		}
+
+		pub, _ := hex.DecodeString(tt.pub)
+		if !VerifySchnorr(pub, msg, sig) {
+			t.Errorf("vector %d: signature does not verify", i)
+		}
+		sig[63] ^= 1
+		if VerifySchnorr(pub, msg, sig) {
+			t.Errorf("vector %d: tampered signature verifies", i)
+		}
+	}
+}
+
+func TestECDHIsSymmetric(t *testing.T) {
+	a, b := big.NewInt(1234567), big.NewInt(7654321)
+	ab, err := ECDH(a, ScalarBaseMult(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ba, _ := ECDH(b, ScalarBaseMult(a))
+	if !bytes.Equal(ab, ba) {
+		t.Error("shared secrets differ")
+	}
+
+	// x-only keys lose the y parity but ECDH only uses the x coordinate
+	bx, err := ParseXOnly(XOnlyPublicKey(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared, _ := ECDH(a, bx); !bytes.Equal(shared, ab) {
+		t.Error("x-only ECDH differs")
+	}
+}
@@ -0,0 +1,200 @@
+// Package secp256k1 implements the group operations of the secp256k1 curve
+// used by Bitcoin, Ethereum and Cashu. It uses math/big and is not constant
+// time; it is meant for client-side signing and blinding, not for servers
+// holding long-lived keys under timing attack.
+package secp256k1
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	// P is the field prime.
+	P, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	// N is the order of the base point.
+	N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+	gx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+	gy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+
+	seven   = big.NewInt(7)
+	sqrtExp = new(big.Int).Rsh(new(big.Int).Add(P, big.NewInt(1)), 2) // (P+1)/4
+)
+
+// ErrInvalidPoint is returned when bytes do not encode a point on the curve.
+var ErrInvalidPoint = errors.New("secp256k1: invalid point")
+
+// Point is an affine curve point. The zero value is the point at infinity.
+type Point struct {
+	X, Y *big.Int
+}
+
+// G returns the base point.
+func G() *Point {
+	return &Point{X: new(big.Int).Set(gx), Y: new(big.Int).Set(gy)}
+}
+
+// IsInfinity reports whether p is the point at infinity.
+func (p *Point) IsInfinity() bool {
+	return p == nil || p.X == nil
+}
+
+// Equal reports whether p and q are the same point.
+func (p *Point) Equal(q *Point) bool {
+	if p.IsInfinity() || q.IsInfinity() {
+		return p.IsInfinity() == q.IsInfinity()
+	}
+	return p.X.Cmp(q.X) == 0 && p.Y.Cmp(q.Y) == 0
+}
+
+// IsOnCurve reports whether p satisfies y² = x³ + 7.
+func (p *Point) IsOnCurve() bool {
+	if p.IsInfinity() {
+		return false
+	}
+	if p.X.Sign() < 0 || p.X.Cmp(P) >= 0 || p.Y.Sign() < 0 || p.Y.Cmp(P) >= 0 {
+		return false
+	}
+	y2 := new(big.Int).Mul(p.Y, p.Y)
+	y2.Mod(y2, P)
+	return y2.Cmp(curveRHS(p.X)) == 0
+}
+
+// curveRHS returns x³ + 7 mod P.
+func curveRHS(x *big.Int) *big.Int {
+	r := new(big.Int).Mul(x, x)
+	r.Mul(r, x)
+	r.Add(r, seven)
+	return r.Mod(r, P)
+}
+
+// Neg returns -p.
+func (p *Point) Neg() *Point {
+	if p.IsInfinity() {
+		return &Point{}
+	}
+	y := new(big.Int).Sub(P, p.Y)
+	return &Point{X: new(big.Int).Set(p.X), Y: y.Mod(y, P)}
+}
+
+// Add returns p + q.
+func Add(p, q *Point) *Point {
+	switch {
+	case p.IsInfinity():
+		return q.copy()
+	case q.IsInfinity():
+		return p.copy()
+	}
+
+	var lambda *big.Int
+	if p.X.Cmp(q.X) == 0 {
+		if p.Y.Cmp(q.Y) != 0 || p.Y.Sign() == 0 {
+			return &Point{} // p == -q
+		}
+		// Doubling: λ = 3x² / 2y
+		num := new(big.Int).Mul(p.X, p.X)
+		num.Mul(num, big.NewInt(3))
+		den := new(big.Int).Lsh(p.Y, 1)
+		lambda = num.Mul(num, den.ModInverse(den.Mod(den, P), P))
+	} else {
+		// λ = (y2 - y1) / (x2 - x1)
+		num := new(big.Int).Sub(q.Y, p.Y)
+		den := new(big.Int).Sub(q.X, p.X)
+		den.Mod(den, P)
+		lambda = num.Mul(num, den.ModInverse(den, P))
+	}
+	lambda.Mod(lambda, P)
+
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, p.X)
+	x.Sub(x, q.X)
+	x.Mod(x, P)
+
+	y := new(big.Int).Sub(p.X, x)
+	y.Mul(y, lambda)
+	y.Sub(y, p.Y)
+	y.Mod(y, P)
+	return &Point{X: x, Y: y}
+}
+
+// ScalarMult returns k·p.
+func ScalarMult(p *Point, k *big.Int) *Point {
+	k = new(big.Int).Mod(k, N)
+	result := &Point{}
+	addend := p.copy()
+	for i := 0; i < k.BitLen(); i++ {
+		if k.Bit(i) == 1 {
+			result = Add(result, addend)
+		}
+		addend = Add(addend, addend)
+	}
+	return result
+}
+
+// ScalarBaseMult returns k·G.
+func ScalarBaseMult(k *big.Int) *Point {
+	return ScalarMult(G(), k)
+}
+
+// Compressed returns the 33-byte SEC1 compressed encoding of p.
+func (p *Point) Compressed() []byte {
+	out := make([]byte, 33)
+	out[0] = 0x02 | byte(p.Y.Bit(0))
+	p.X.FillBytes(out[1:])
+	return out
+}
+
+// Uncompressed returns the 65-byte SEC1 uncompressed encoding of p.
+func (p *Point) Uncompressed() []byte {
+	out := make([]byte, 65)
+	out[0] = 0x04
+	p.X.FillBytes(out[1:33])
+	p.Y.FillBytes(out[33:])
+	return out
+}
+
+// ParsePoint decodes a SEC1 compressed or uncompressed point.
+func ParsePoint(b []byte) (*Point, error) {
+	switch {
+	case len(b) == 33 && (b[0] == 0x02 || b[0] == 0x03):
+		x := new(big.Int).SetBytes(b[1:])
+		if x.Cmp(P) >= 0 {
+			return nil, ErrInvalidPoint
+		}
+		y, ok := liftX(x, b[0] == 0x03)
+		if !ok {
+			return nil, ErrInvalidPoint
+		}
+		return &Point{X: x, Y: y}, nil
+	case len(b) == 65 && b[0] == 0x04:
+		p := &Point{X: new(big.Int).SetBytes(b[1:33]), Y: new(big.Int).SetBytes(b[33:])}
+		if !p.IsOnCurve() {
+			return nil, ErrInvalidPoint
+		}
+		return p, nil
+	default:
+		return nil, ErrInvalidPoint
+	}
+}
+
+// liftX returns the y coordinate for x with the requested parity, if x is on
+// the curve.
+func liftX(x *big.Int, odd bool) (*big.Int, bool) {
+	rhs := curveRHS(x)
+	y := new(big.Int).Exp(rhs, sqrtExp, P)
+	if new(big.Int).Mod(new(big.Int).Mul(y, y), P).Cmp(rhs) != 0 {
+		return nil, false
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(P, y)
+	}
+	return y, true
+}
+
+func (p *Point) copy() *Point {
+	if p.IsInfinity() {
+		return &Point{}
+	}
+	return &Point{X: new(big.Int).Set(p.X), Y: new(big.Int).Set(p.Y)}
+}
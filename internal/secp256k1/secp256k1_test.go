@@ -0,0 +1,116 @@
+package secp256k1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestScalarBaseMult(t *testing.T) {
+	tests := []struct {
+		k    int64
+		want string
+	}{
+		{1, "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{2, "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"},
+		{3, "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(ScalarBaseMult(big.NewInt(tt.k)).Compressed())
+		if got != tt.want {
+			t.Errorf("%d·G = %s, want %s", tt.k, got, tt.want)
+		}
+	}
+}
+
+func TestGroupLaws(t *testing.T) {
+	if !ScalarBaseMult(N).IsInfinity() {
+		t.Error("N·G should be the point at infinity")
+	}
+
+	a, b := big.NewInt(123456789), big.NewInt(987654321)
+	sum := Add(ScalarBaseMult(a), ScalarBaseMult(b))
+	if !sum.Equal(ScalarBaseMult(new(big.Int).Add(a, b))) {
+		t.Error("a·G + b·G != (a+b)·G")
+	}
+	if !Add(sum, sum.Neg()).IsInfinity() {
+		t.Error("P + (-P) should be the point at infinity")
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	p := ScalarBaseMult(big.NewInt(0xdeadbeef))
+	for _, enc := range [][]byte{p.Compressed(), p.Uncompressed()} {
+		got, err := ParsePoint(enc)
+		if err != nil {
+			t.Fatalf("parse %x: %v", enc, err)
+		}
+		if !got.Equal(p) {
+			t.Errorf("round trip of %x changed the point", enc)
+		}
+	}
+
+	bad := p.Uncompressed()
+	bad[64] ^= 1
+	if _, err := ParsePoint(bad); err == nil {
+		t.Error("expected an error for a point off the curve")
+	}
+	if _, err := ParsePoint([]byte{0x02, 0x01}); err == nil {
+		t.Error("expected an error for a short encoding")
+	}
+}
+
+func TestSignRecover(t *testing.T) {
+	priv, _ := new(big.Int).SetString("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", 16)
+	pub := ScalarBaseMult(priv)
+	hash := make([]byte, 32)
+	for i := range hash {
+		hash[i] = byte(i)
+	}
+
+	sig, err := Sign(priv, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.S.Cmp(halfN) > 0 {
+		t.Error("signature S should be normalized to the lower half")
+	}
+	if !Verify(pub, hash, sig) {
+		t.Error("signature does not verify")
+	}
+	got, err := RecoverPublicKey(hash, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(pub) {
+		t.Error("recovered a different public key")
+	}
+
+	again, _ := Sign(priv, hash)
+	if again.R.Cmp(sig.R) != 0 || again.S.Cmp(sig.S) != 0 {
+		t.Error("RFC 6979 signatures should be deterministic")
+	}
+
+	hash[0] ^= 1
+	if Verify(pub, hash, sig) {
+		t.Error("signature verified for a different hash")
+	}
+}
+
+func TestRFC6979Vector(t *testing.T) {
+	// Widely used secp256k1 vector: key 1, message "Satoshi Nakamoto"
+	sum := sha256.Sum256([]byte("Satoshi Nakamoto"))
+	sig, err := Sign(big.NewInt(1), sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantR := "934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8"
+	wantS := "2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"
+	if got := hex.EncodeToString(sig.R.FillBytes(make([]byte, 32))); got != wantR {
+		t.Errorf("r = %s, want %s", got, wantR)
+	}
+	if got := hex.EncodeToString(sig.S.FillBytes(make([]byte, 32))); got != wantS {
+		t.Errorf("s = %s, want %s", got, wantS)
+	}
+}
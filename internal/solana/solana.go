@@ -0,0 +1,122 @@
+// Package solana builds and signs the Solana transactions AgentPay needs to
+// pay without a hosted wallet: SPL token transfers to associated token
+// accounts, encoded as version 0 messages.
+package solana
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/joelklabo/agentpay/internal/base58"
+)
+
+// PublicKey is a 32-byte ed25519 public key or program address.
+type PublicKey [32]byte
+
+// Well-known program IDs.
+var (
+	TokenProgramID                  = MustPublicKey("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
+	Token2022ProgramID              = MustPublicKey("TokenzQdBNbLqP5VEhdkAS6EPFLC1PHnBqCXEpPxuEb")
+	AssociatedTokenAccountProgramID = MustPublicKey("ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL")
+	ComputeBudgetProgramID          = MustPublicKey("ComputeBudget111111111111111111111111111111")
+	MemoProgramID                   = MustPublicKey("MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr")
+)
+
+// ParsePublicKey decodes a base58 public key.
+func ParsePublicKey(s string) (PublicKey, error) {
+	var pk PublicKey
+	b, err := base58.Decode(s)
+	if err != nil {
+		return pk, fmt.Errorf("solana: invalid public key %q: %w", s, err)
+	}
+	if len(b) != 32 {
+		return pk, fmt.Errorf("solana: public key %q is %d bytes, want 32", s, len(b))
+	}
+	copy(pk[:], b)
+	return pk, nil
+}
+
+// MustPublicKey is ParsePublicKey for constants; it panics on error.
+func MustPublicKey(s string) PublicKey {
+	pk, err := ParsePublicKey(s)
+	if err != nil {
+		panic(err)
+	}
+	return pk
+}
+
+// String returns the base58 encoding.
+func (pk PublicKey) String() string { return base58.Encode(pk[:]) }
+
+// FindProgramAddress returns the first off-curve address derived from seeds
+// and program, searching bump seeds from 255 down, and the bump it used.
+func FindProgramAddress(seeds [][]byte, program PublicKey) (PublicKey, byte, error) {
+	for bump := 255; bump >= 0; bump-- {
+		pk := createProgramAddress(append(seeds[:len(seeds):len(seeds)], []byte{byte(bump)}), program)
+		if !IsOnCurve(pk) {
+			return pk, byte(bump), nil
+		}
+	}
+	return PublicKey{}, 0, errors.New("solana: no viable program address bump")
+}
+
+// createProgramAddress hashes seeds into a candidate program address. The
+// caller must check that it is off the curve.
+func createProgramAddress(seeds [][]byte, program PublicKey) PublicKey {
+	h := sha256.New()
+	for _, s := range seeds {
+		h.Write(s)
+	}
+	h.Write(program[:])
+	h.Write([]byte("ProgramDerivedAddress"))
+
+	var pk PublicKey
+	copy(pk[:], h.Sum(nil))
+	return pk
+}
+
+// AssociatedTokenAddress returns owner's associated token account for mint
+// under tokenProgram.
+func AssociatedTokenAddress(owner, mint, tokenProgram PublicKey) (PublicKey, error) {
+	pk, _, err := FindProgramAddress([][]byte{owner[:], tokenProgram[:], mint[:]}, AssociatedTokenAccountProgramID)
+	return pk, err
+}
+
+// Curve constants for edwards25519: p = 2²⁵⁵ - 19 and d = -121665/121666.
+var (
+	fieldP = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	edD    = func() *big.Int {
+		d := new(big.Int).ModInverse(big.NewInt(121666), fieldP)
+		d.Mul(d, big.NewInt(-121665))
+		return d.Mod(d, fieldP)
+	}()
+)
+
+// IsOnCurve reports whether pk decompresses to an edwards25519 point, i.e.
+// whether x² = (y² - 1) / (d·y² + 1) has a square root. Program addresses
+// must not be on the curve so that no private key can sign for them.
+func IsOnCurve(pk PublicKey) bool {
+	le := pk
+	le[31] &= 0x7f // the top bit is the sign of x
+	for i, j := 0, len(le)-1; i < j; i, j = i+1, j-1 {
+		le[i], le[j] = le[j], le[i]
+	}
+	y := new(big.Int).SetBytes(le[:])
+
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, fieldP)
+	u := new(big.Int).Sub(y2, big.NewInt(1))
+	v := new(big.Int).Mul(edD, y2)
+	v.Add(v, big.NewInt(1))
+	v.Mod(v, fieldP)
+
+	x2 := new(big.Int).ModInverse(v, fieldP)
+	x2.Mul(x2, u)
+	x2.Mod(x2, fieldP)
+	if x2.Sign() == 0 {
+		return true
+	}
+	return new(big.Int).ModSqrt(x2, fieldP) != nil
+}
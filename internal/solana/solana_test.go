@@ -0,0 +1,57 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestCreateProgramAddress(t *testing.T) {
+	// Vectors from the solana-web3.js test suite
+	program := MustPublicKey("BPFLoader1111111111111111111111111111111111")
+	seedKey := MustPublicKey("SeedPubey1111111111111111111111111111111111")
+	tests := []struct {
+		seeds [][]byte
+		want  string
+	}{
+		{[][]byte{{}, {1}}, "3gF2KMe9KiC6FNVBmfg9i267aMPvK37FewCip4eGBFcT"},
+		{[][]byte{[]byte("☉")}, "7ytmC1nT1xY4RfxCV2ZgyA7UakC93do5ZdyhdF3EtPj7"},
+		{[][]byte{[]byte("Talking"), []byte("Squirrels")}, "HwRVBufQ4haG5XSgpspwKtNd3PC9GM9m1196uJW36vds"},
+		{[][]byte{seedKey[:]}, "GUs5qLUfsEHkcMB9T38vjr18ypEhRuNWiePW2LoK4E3K"},
+	}
+	for _, tt := range tests {
+		got := createProgramAddress(tt.seeds, program)
+		if got.String() != tt.want {
+			t.Errorf("createProgramAddress(%q) = %s, want %s", tt.seeds, got, tt.want)
+		}
+		if IsOnCurve(got) {
+			t.Errorf("%s should be off the curve", got)
+		}
+	}
+}
+
+func TestFindProgramAddress(t *testing.T) {
+	program := MustPublicKey("BPFLoader1111111111111111111111111111111111")
+	pda, bump, err := FindProgramAddress([][]byte{{}}, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := createProgramAddress([][]byte{{}, {bump}}, program); pda != want {
+		t.Errorf("PDA %s does not match bump %d", pda, bump)
+	}
+	for b := 255; b > int(bump); b-- {
+		if !IsOnCurve(createProgramAddress([][]byte{{}, {byte(b)}}, program)) {
+			t.Errorf("bump %d was skipped but is off the curve", b)
+		}
+	}
+}
+
+func TestIsOnCurve(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		pub, _, _ := ed25519.GenerateKey(nil)
+		var pk PublicKey
+		copy(pk[:], pub)
+		if !IsOnCurve(pk) {
+			t.Fatalf("ed25519 key %s reported off the curve", pk)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// AccountMeta is an account an instruction reads or writes.
+type AccountMeta struct {
+	PublicKey  PublicKey
+	IsSigner   bool
+	IsWritable bool
+}
+
+// Instruction is a call into an on-chain program.
+type Instruction struct {
+	ProgramID PublicKey
+	Accounts  []AccountMeta
+	Data      []byte
+}
+
+// SetComputeUnitLimit caps the compute units the transaction may consume.
+func SetComputeUnitLimit(units uint32) Instruction {
+	data := make([]byte, 5)
+	data[0] = 2
+	binary.LittleEndian.PutUint32(data[1:], units)
+	return Instruction{ProgramID: ComputeBudgetProgramID, Data: data}
+}
+
+// SetComputeUnitPrice sets the priority fee in micro-lamports per compute unit.
+func SetComputeUnitPrice(microLamports uint64) Instruction {
+	data := make([]byte, 9)
+	data[0] = 3
+	binary.LittleEndian.PutUint64(data[1:], microLamports)
+	return Instruction{ProgramID: ComputeBudgetProgramID, Data: data}
+}
+
+// TransferChecked moves amount base units of mint from source to destination
+// token accounts, authorized by owner.
+func TransferChecked(tokenProgram, source, mint, destination, owner PublicKey, amount uint64, decimals uint8) Instruction {
+	data := make([]byte, 10)
+	data[0] = 12
+	binary.LittleEndian.PutUint64(data[1:], amount)
+	data[9] = decimals
+	return Instruction{
+		ProgramID: tokenProgram,
+		Accounts: []AccountMeta{
+			{PublicKey: source, IsWritable: true},
+			{PublicKey: mint},
+			{PublicKey: destination, IsWritable: true},
+			{PublicKey: owner, IsSigner: true},
+		},
+		Data: data,
+	}
+}
+
+// Memo records text on-chain with the SPL Memo program.
+func Memo(text string) Instruction {
+	return Instruction{ProgramID: MemoProgramID, Data: []byte(text)}
+}
+
+// CompiledInstruction is an instruction whose accounts are indexes into the
+// message's account keys.
+type CompiledInstruction struct {
+	ProgramIDIndex uint8
+	Accounts       []uint8
+	Data           []byte
+}
+
+// Message is a version 0 transaction message without address lookup tables.
+type Message struct {
+	NumRequiredSignatures       uint8
+	NumReadonlySignedAccounts   uint8
+	NumReadonlyUnsignedAccounts uint8
+	AccountKeys                 []PublicKey
+	RecentBlockhash             [32]byte
+	Instructions                []CompiledInstruction
+}
+
+// NewMessage compiles instructions into a message paid for by feePayer.
+// Accounts are ordered as the runtime requires: writable signers (fee payer
+// first), read-only signers, writable non-signers, read-only non-signers.
+func NewMessage(feePayer PublicKey, instructions []Instruction, blockhash [32]byte) (*Message, error) {
+	type entry struct {
+		meta  AccountMeta
+		order int
+	}
+	index := map[PublicKey]*entry{}
+	var entries []*entry
+	add := func(m AccountMeta) {
+		if e, ok := index[m.PublicKey]; ok {
+			e.meta.IsSigner = e.meta.IsSigner || m.IsSigner
+			e.meta.IsWritable = e.meta.IsWritable || m.IsWritable
+			return
+		}
+		e := &entry{meta: m, order: len(entries)}
+		index[m.PublicKey] = e
+		entries = append(entries, e)
+	}
+
+	add(AccountMeta{PublicKey: feePayer, IsSigner: true, IsWritable: true})
+	for _, ix := range instructions {
+		for _, a := range ix.Accounts {
+			add(a)
+		}
+		add(AccountMeta{PublicKey: ix.ProgramID})
+	}
+	if len(entries) > 256 {
+		return nil, errors.New("solana: too many accounts")
+	}
+
+	class := func(m AccountMeta) int {
+		switch {
+		case m.IsSigner && m.IsWritable:
+			return 0
+		case m.IsSigner:
+			return 1
+		case m.IsWritable:
+			return 2
+		default:
+			return 3
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		ci, cj := class(entries[i].meta), class(entries[j].meta)
+		if ci != cj {
+			return ci < cj
+		}
+		return entries[i].order < entries[j].order
+	})
+
+	msg := &Message{RecentBlockhash: blockhash}
+	position := map[PublicKey]uint8{}
+	for i, e := range entries {
+		position[e.meta.PublicKey] = uint8(i)
+		msg.AccountKeys = append(msg.AccountKeys, e.meta.PublicKey)
+		switch class(e.meta) {
+		case 0:
+			msg.NumRequiredSignatures++
+		case 1:
+			msg.NumRequiredSignatures++
+			msg.NumReadonlySignedAccounts++
+		case 3:
+			msg.NumReadonlyUnsignedAccounts++
+		}
+	}
+
+	for _, ix := range instructions {
+		ci := CompiledInstruction{ProgramIDIndex: position[ix.ProgramID], Data: ix.Data}
+		for _, a := range ix.Accounts {
+			ci.Accounts = append(ci.Accounts, position[a.PublicKey])
+		}
+		msg.Instructions = append(msg.Instructions, ci)
+	}
+	return msg, nil
+}
+
+// Serialize encodes the message in the version 0 wire format.
+func (m *Message) Serialize() []byte {
+	b := []byte{0x80, m.NumRequiredSignatures, m.NumReadonlySignedAccounts, m.NumReadonlyUnsignedAccounts}
+	b = appendCompactU16(b, len(m.AccountKeys))
+	for _, k := range m.AccountKeys {
+		b = append(b, k[:]...)
+	}
+	b = append(b, m.RecentBlockhash[:]...)
+	b = appendCompactU16(b, len(m.Instructions))
+	for _, ix := range m.Instructions {
+		b = append(b, ix.ProgramIDIndex)
+		b = appendCompactU16(b, len(ix.Accounts))
+		b = append(b, ix.Accounts...)
+		b = appendCompactU16(b, len(ix.Data))
+		b = append(b, ix.Data...)
+	}
+	return appendCompactU16(b, 0) // no address table lookups
+}
+
+// Transaction is a message with one signature slot per required signer.
+type Transaction struct {
+	Signatures [][64]byte
+	Message    *Message
+}
+
+// NewTransaction wraps msg with empty signature slots.
+func NewTransaction(msg *Message) *Transaction {
+	return &Transaction{Signatures: make([][64]byte, msg.NumRequiredSignatures), Message: msg}
+}
+
+// Sign fills the signature slot of each key's signer. Other slots are left
+// empty for co-signers such as a facilitator paying the fee.
+func (tx *Transaction) Sign(keys ...ed25519.PrivateKey) error {
+	data := tx.Message.Serialize()
+	for _, key := range keys {
+		var pub PublicKey
+		copy(pub[:], key.Public().(ed25519.PublicKey))
+		slot := -1
+		for i := 0; i < int(tx.Message.NumRequiredSignatures); i++ {
+			if tx.Message.AccountKeys[i] == pub {
+				slot = i
+			}
+		}
+		if slot < 0 {
+			return fmt.Errorf("solana: %s is not a signer of this transaction", pub)
+		}
+		copy(tx.Signatures[slot][:], ed25519.Sign(key, data))
+	}
+	return nil
+}
+
+// Serialize encodes the signed transaction for submission.
+func (tx *Transaction) Serialize() []byte {
+	b := appendCompactU16(nil, len(tx.Signatures))
+	for _, s := range tx.Signatures {
+		b = append(b, s[:]...)
+	}
+	return append(b, tx.Message.Serialize()...)
+}
+
+// ParseTransaction decodes a serialized version 0 transaction without address
+// table lookups, as produced by Serialize.
+func ParseTransaction(data []byte) (*Transaction, error) {
+	r := &reader{b: data}
+	n := r.compactU16()
+	tx := &Transaction{}
+	for i := 0; i < n && r.err == nil; i++ {
+		var s [64]byte
+		copy(s[:], r.bytes(64))
+		tx.Signatures = append(tx.Signatures, s)
+	}
+
+	if r.byte() != 0x80 {
+		return nil, errors.New("solana: not a version 0 message")
+	}
+	m := &Message{
+		NumRequiredSignatures:       r.byte(),
+		NumReadonlySignedAccounts:   r.byte(),
+		NumReadonlyUnsignedAccounts: r.byte(),
+	}
+	keys := r.compactU16()
+	for i := 0; i < keys && r.err == nil; i++ {
+		var k PublicKey
+		copy(k[:], r.bytes(32))
+		m.AccountKeys = append(m.AccountKeys, k)
+	}
+	copy(m.RecentBlockhash[:], r.bytes(32))
+	ixs := r.compactU16()
+	for i := 0; i < ixs && r.err == nil; i++ {
+		ix := CompiledInstruction{ProgramIDIndex: r.byte()}
+		ix.Accounts = append([]uint8(nil), r.bytes(r.compactU16())...)
+		ix.Data = append([]byte(nil), r.bytes(r.compactU16())...)
+		m.Instructions = append(m.Instructions, ix)
+	}
+	if lookups := r.compactU16(); lookups != 0 && r.err == nil {
+		return nil, errors.New("solana: address table lookups are not supported")
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.b) != 0 {
+		return nil, errors.New("solana: trailing bytes after transaction")
+	}
+	if len(tx.Signatures) != int(m.NumRequiredSignatures) || int(m.NumRequiredSignatures) > len(m.AccountKeys) {
+		return nil, errors.New("solana: signature count does not match message header")
+	}
+	for _, ix := range m.Instructions {
+		if int(ix.ProgramIDIndex) >= len(m.AccountKeys) {
+			return nil, errors.New("solana: instruction program index out of range")
+		}
+		for _, a := range ix.Accounts {
+			if int(a) >= len(m.AccountKeys) {
+				return nil, errors.New("solana: instruction account index out of range")
+			}
+		}
+	}
+	tx.Message = m
+	return tx, nil
+}
+
+// appendCompactU16 appends n in Solana's shortvec encoding: 7 bits per byte,
+// least significant first, high bit set on all but the last byte.
+func appendCompactU16(b []byte, n int) []byte {
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.b) {
+		r.err = errors.New("solana: transaction truncated")
+		return nil
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *reader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) compactU16() int {
+	n := 0
+	for i := 0; i < 3; i++ {
+		c := r.byte()
+		n |= int(c&0x7f) << (7 * i)
+		if c&0x80 == 0 {
+			return n
+		}
+	}
+	if r.err == nil {
+		r.err = errors.New("solana: invalid compact-u16")
+	}
+	return 0
+}
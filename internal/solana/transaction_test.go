@@ -0,0 +1,108 @@
+package solana
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestCompactU16(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x80, 0x01}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{0x4000, []byte{0x80, 0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		got := appendCompactU16(nil, tt.n)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("appendCompactU16(%d) = %x, want %x", tt.n, got, tt.want)
+		}
+		if r := (&reader{b: got}); r.compactU16() != tt.n || r.err != nil {
+			t.Errorf("round trip of %d failed", tt.n)
+		}
+	}
+}
+
+func TestTransferTransaction(t *testing.T) {
+	feePayerPub, feePayerKey, _ := ed25519.GenerateKey(nil)
+	ownerPub, ownerKey, _ := ed25519.GenerateKey(nil)
+	var feePayer, owner PublicKey
+	copy(feePayer[:], feePayerPub)
+	copy(owner[:], ownerPub)
+	mint := MustPublicKey("EPjFWdd5AufqSSqeM2qN1xxoxmTbX6CmaVgDaZdgF3jT")
+	dest := MustPublicKey("SeedPubey1111111111111111111111111111111111")
+
+	source, err := AssociatedTokenAddress(owner, mint, TokenProgramID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destATA, err := AssociatedTokenAddress(dest, mint, TokenProgramID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := NewMessage(feePayer, []Instruction{
+		SetComputeUnitLimit(20000),
+		SetComputeUnitPrice(1),
+		TransferChecked(TokenProgramID, source, mint, destATA, owner, 10000, 6),
+	}, [32]byte{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.NumRequiredSignatures != 2 || msg.NumReadonlySignedAccounts != 1 || msg.NumReadonlyUnsignedAccounts != 3 {
+		t.Errorf("header = %d/%d/%d, want 2/1/3",
+			msg.NumRequiredSignatures, msg.NumReadonlySignedAccounts, msg.NumReadonlyUnsignedAccounts)
+	}
+	if msg.AccountKeys[0] != feePayer || msg.AccountKeys[1] != owner {
+		t.Error("signers are not ordered fee payer first")
+	}
+
+	tx := NewTransaction(msg)
+	if err := tx.Sign(ownerKey); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Signatures[0] != [64]byte{} {
+		t.Error("fee payer slot should stay empty for the co-signer")
+	}
+
+	parsed, err := ParseTransaction(tx.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := parsed.Message.Serialize()
+	if !bytes.Equal(data, msg.Serialize()) {
+		t.Fatal("message changed in round trip")
+	}
+	if !ed25519.Verify(ownerPub, data, parsed.Signatures[1][:]) {
+		t.Error("owner signature does not verify")
+	}
+
+	// The facilitator completes the transaction
+	if err := parsed.Sign(feePayerKey); err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(feePayerPub, data, parsed.Signatures[0][:]) {
+		t.Error("fee payer signature does not verify")
+	}
+
+	transfer := parsed.Message.Instructions[2]
+	if got := parsed.Message.AccountKeys[transfer.ProgramIDIndex]; got != TokenProgramID {
+		t.Errorf("transfer program = %s", got)
+	}
+	if got := parsed.Message.AccountKeys[transfer.Accounts[2]]; got != destATA {
+		t.Errorf("transfer destination = %s, want %s", got, destATA)
+	}
+
+	if _, err := ParseTransaction(tx.Serialize()[:100]); err == nil {
+		t.Error("expected an error for a truncated transaction")
+	}
+	stranger := ed25519.NewKeyFromSeed(make([]byte, 32))
+	if err := tx.Sign(stranger); err == nil {
+		t.Error("expected an error signing with a non-signer key")
+	}
+}
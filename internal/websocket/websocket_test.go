@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEcho(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		// Send a ping first; the client must answer it transparently
+		c.writeFrame(opPing, []byte("hi"))
+		for {
+			msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			c.WriteText(msg)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, msg := range [][]byte{[]byte(`["REQ","sub",{}]`), bytes.Repeat([]byte("a"), 70000)} {
+		if err := c.WriteText(msg); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Errorf("echo of %d bytes came back as %d bytes", len(msg), len(got))
+		}
+	}
+}
+
+func TestDialRejectsPlainHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a relay"))
+	}))
+	defer srv.Close()
+
+	if _, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")); err == nil {
+		t.Error("expected a handshake error")
+	}
+}
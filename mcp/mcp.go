@@ -0,0 +1,327 @@
+// Package mcp is a Model Context Protocol server over stdio: newline-delimited
+// JSON-RPC 2.0 messages on a reader and writer. It implements the lifecycle
+// handshake and the tools capability, which is all AgentPay exposes.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ProtocolVersion is the MCP revision the server speaks when the client asks
+// for one it doesn't know.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the revisions whose tools API the server implements.
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Tool is a function the model can call.
+type Tool struct {
+	Name        string
+	Description string
+	// InputSchema is the JSON Schema of the arguments object.
+	InputSchema json.RawMessage
+	// Handler runs the tool. Its arguments are the raw arguments object.
+	// A returned error is reported to the model as a failed tool call,
+	// not as a protocol error.
+	Handler func(ctx context.Context, args json.RawMessage) (*Result, error)
+}
+
+// Result is the outcome of a tool call.
+type Result struct {
+	// Text is shown to the model, one content block per entry.
+	Text []string
+	// Structured is also returned as structuredContent, when set. It must
+	// encode as a JSON object.
+	Structured any
+}
+
+// Server serves tools over one connection.
+type Server struct {
+	name    string
+	version string
+	tools   map[string]*Tool
+
+	mu       sync.Mutex // guards writes and inFlight
+	out      io.Writer
+	inFlight map[string]context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a server that introduces itself as name and version.
+func NewServer(name, version string) *Server {
+	return &Server{
+		name:     name,
+		version:  version,
+		tools:    make(map[string]*Tool),
+		inFlight: make(map[string]context.CancelFunc),
+	}
+}
+
+// AddTool registers t, replacing any tool of the same name.
+func (s *Server) AddTool(t *Tool) {
+	s.tools[t.Name] = t
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// Serve reads requests from in and writes responses to out until in is
+// exhausted or ctx is cancelled. Tool calls run concurrently; Serve waits
+// for those in flight before returning.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.out = out
+	defer s.wg.Wait()
+
+	lines := make(chan []byte)
+	errc := make(chan error, 1)
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				errc <- err
+				close(lines)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				return <-errc
+			}
+			s.handle(ctx, line)
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, line []byte) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		s.reply(json.RawMessage("null"), nil, &rpcError{CodeParseError, "parse error: " + err.Error()})
+		return
+	}
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		if msg.ID != nil && msg.Method == "" {
+			return // a response to a request we never send
+		}
+		s.reply(idOrNull(msg.ID), nil, &rpcError{CodeInvalidRequest, "invalid request"})
+		return
+	}
+
+	// Notifications get no response
+	if msg.ID == nil {
+		if msg.Method == "notifications/cancelled" {
+			var p struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			json.Unmarshal(msg.Params, &p)
+			s.mu.Lock()
+			cancel := s.inFlight[string(p.RequestID)]
+			s.mu.Unlock()
+			if cancel != nil {
+				cancel()
+			}
+		}
+		return
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		version := p.ProtocolVersion
+		if !supportedVersions[version] {
+			version = ProtocolVersion
+		}
+		s.reply(msg.ID, map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": s.name, "version": s.version},
+		}, nil)
+	case "ping":
+		s.reply(msg.ID, struct{}{}, nil)
+	case "tools/list":
+		s.reply(msg.ID, map[string]any{"tools": s.listTools()}, nil)
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			s.reply(msg.ID, nil, &rpcError{CodeInvalidParams, "invalid params: " + err.Error()})
+			return
+		}
+		tool := s.tools[p.Name]
+		if tool == nil {
+			s.reply(msg.ID, nil, &rpcError{CodeInvalidParams, "unknown tool: " + p.Name})
+			return
+		}
+		if len(p.Arguments) == 0 || string(p.Arguments) == "null" {
+			p.Arguments = json.RawMessage("{}")
+		}
+
+		callCtx, cancel := context.WithCancel(ctx)
+		key := string(msg.ID)
+		s.mu.Lock()
+		s.inFlight[key] = cancel
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			result := s.call(callCtx, tool, p.Arguments)
+			s.mu.Lock()
+			delete(s.inFlight, key)
+			s.mu.Unlock()
+			// A cancelled request gets no response
+			if callCtx.Err() != nil && ctx.Err() == nil {
+				cancel()
+				return
+			}
+			cancel()
+			s.reply(msg.ID, result, nil)
+		}()
+	default:
+		s.reply(msg.ID, nil, &rpcError{CodeMethodNotFound, "method not found: " + msg.Method})
+	}
+}
+
+// call runs tool and converts its outcome to a CallToolResult.
+func (s *Server) call(ctx context.Context, tool *Tool, args json.RawMessage) map[string]any {
+	res, err := safeCall(ctx, tool, args)
+	if err != nil {
+		return map[string]any{
+			"content": []map[string]string{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+
+	content := []map[string]string{}
+	for _, t := range res.Text {
+		content = append(content, map[string]string{"type": "text", "text": t})
+	}
+	out := map[string]any{"content": content, "isError": false}
+	if res.Structured != nil {
+		out["structuredContent"] = res.Structured
+	}
+	return out
+}
+
+// safeCall runs tool, turning a panic into an error so one bad call doesn't
+// take the server down.
+func safeCall(ctx context.Context, tool *Tool, args json.RawMessage) (res *Result, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%s: internal error: %v", tool.Name, p)
+		}
+	}()
+	res, err = tool.Handler(ctx, args)
+	if err == nil && res == nil {
+		err = errors.New(tool.Name + ": no result")
+	}
+	return res, err
+}
+
+func (s *Server) listTools() []map[string]any {
+	names := make([]string, 0, len(s.tools))
+	for name := range s.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		t := s.tools[name]
+		schema := t.InputSchema
+		if schema == nil {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		tools = append(tools, map[string]any{
+			"name":        t.Name,
+			"description": t.Description,
+			"inputSchema": schema,
+		})
+	}
+	return tools
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rerr *rpcError) {
+	resp := response{JSONRPC: "2.0", ID: id, Result: result}
+	if rerr != nil {
+		resp.Error = rerr
+		resp.Result = nil
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(response{JSONRPC: "2.0", ID: id, Error: &rpcError{CodeInternalError, err.Error()}})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
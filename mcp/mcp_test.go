@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// session sends requests, one per line, and returns the responses by id.
+func session(t *testing.T, s *Server, requests ...string) map[string]map[string]any {
+	t.Helper()
+	var out strings.Builder
+	if err := s.Serve(context.Background(), strings.NewReader(strings.Join(requests, "\n")+"\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	responses := make(map[string]map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var resp map[string]any
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("bad response %q: %v", line, err)
+		}
+		id, _ := json.Marshal(resp["id"])
+		responses[string(id)] = resp
+	}
+	return responses
+}
+
+func testServer() *Server {
+	s := NewServer("test", "1.0")
+	s.AddTool(&Tool{
+		Name:        "echo",
+		Description: "Echo the message",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"message":{"type":"string"}}}`),
+		Handler: func(ctx context.Context, args json.RawMessage) (*Result, error) {
+			var a struct{ Message string }
+			json.Unmarshal(args, &a)
+			if a.Message == "" {
+				return nil, errors.New("message is required")
+			}
+			return &Result{Text: []string{a.Message}, Structured: map[string]string{"message": a.Message}}, nil
+		},
+	})
+	return s
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	got := session(t, testServer(),
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"c","version":"1"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":"p","method":"ping"}`,
+	)
+	if len(got) != 3 {
+		t.Fatalf("responses = %v", got)
+	}
+	init := got["1"]["result"].(map[string]any)
+	if init["protocolVersion"] != "2025-03-26" || init["serverInfo"].(map[string]any)["name"] != "test" {
+		t.Errorf("initialize = %v", init)
+	}
+	if init["capabilities"].(map[string]any)["tools"] == nil {
+		t.Error("no tools capability")
+	}
+	tools := got["2"]["result"].(map[string]any)["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["name"] != "echo" || tools[0].(map[string]any)["inputSchema"] == nil {
+		t.Errorf("tools = %v", tools)
+	}
+	if got[`"p"`]["result"] == nil {
+		t.Errorf("ping = %v", got[`"p"`])
+	}
+
+	got = session(t, testServer(), `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)
+	if v := got["1"]["result"].(map[string]any)["protocolVersion"]; v != ProtocolVersion {
+		t.Errorf("negotiated %v for an unknown version", v)
+	}
+}
+
+func TestServer_ToolsCall(t *testing.T) {
+	got := session(t, testServer(),
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"nope"}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"resources/list"}`,
+		`not json`,
+	)
+
+	ok := got["1"]["result"].(map[string]any)
+	content := ok["content"].([]any)[0].(map[string]any)
+	if content["type"] != "text" || content["text"] != "hi" || ok["isError"] != false {
+		t.Errorf("call = %v", ok)
+	}
+	if ok["structuredContent"].(map[string]any)["message"] != "hi" {
+		t.Errorf("structured = %v", ok["structuredContent"])
+	}
+
+	// Tool failures are results the model sees, not protocol errors
+	failed := got["2"]["result"].(map[string]any)
+	if failed["isError"] != true || !strings.Contains(failed["content"].([]any)[0].(map[string]any)["text"].(string), "required") {
+		t.Errorf("failed call = %v", failed)
+	}
+
+	for id, code := range map[string]float64{"3": CodeInvalidParams, "4": CodeMethodNotFound, "null": CodeParseError} {
+		if e, _ := got[id]["error"].(map[string]any); e == nil || e["code"] != code {
+			t.Errorf("id %s: response %v, want error %v", id, got[id], code)
+		}
+	}
+}
+
+func TestServer_Cancel(t *testing.T) {
+	s := NewServer("test", "1.0")
+	started := make(chan struct{})
+	s.AddTool(&Tool{Name: "wait", Handler: func(ctx context.Context, args json.RawMessage) (*Result, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}})
+
+	in, w := pipe()
+	var out strings.Builder
+	done := make(chan error)
+	go func() { done <- s.Serve(context.Background(), in, &out) }()
+	w <- `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"wait"}}`
+	<-started
+	w <- `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`
+	close(w)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("cancelled request got a response: %s", out.String())
+	}
+}
+
+// pipe returns a reader that yields each line sent on the channel.
+func pipe() (*lineReader, chan<- string) {
+	c := make(chan string)
+	return &lineReader{c: c}, c
+}
+
+type lineReader struct {
+	c   chan string
+	buf []byte
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		line, ok := <-r.c
+		if !ok {
+			return 0, io.EOF
+		}
+		r.buf = []byte(line + "\n")
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,125 @@
+package paywall
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/macaroon"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// l402Challenge creates an invoice for price and returns the
+// WWW-Authenticate challenge that pays it.
+func (p *Paywall) l402Challenge(r *http.Request, pattern string, price Price) (string, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	invoice, err := p.cfg.Lightning.CreateInvoice(ctx, price.Msat, price.Description)
+	if err != nil {
+		return "", err
+	}
+	var id macaroon.Identifier
+	hash, err := hex.DecodeString(invoice.PaymentHash)
+	if err != nil || len(hash) != len(id.PaymentHash) {
+		return "", fmt.Errorf("backend returned an invalid payment hash %q", invoice.PaymentHash)
+	}
+	copy(id.PaymentHash[:], hash)
+	rand.Read(id.TokenID[:])
+
+	mac := p.mintMacaroon(id, pattern, price)
+	return fmt.Sprintf(`L402 macaroon="%s", invoice="%s"`, mac.Encode(), invoice.Bolt11), nil
+}
+
+// mintMacaroon binds a payment hash to the paywall's service, the route's
+// capability, the token TTL and the route's call allowance. The allowance
+// is always stated, even for a single call, so the macaroon says what it
+// buys.
+func (p *Paywall) mintMacaroon(id macaroon.Identifier, pattern string, price Price) *macaroon.Macaroon {
+	service := p.cfg.Service
+	mac := macaroon.New(p.cfg.RootKey, id.Bytes(), "agentpay")
+	mac.AddFirstPartyCaveat(macaroon.Services(service))
+	mac.AddFirstPartyCaveat(macaroon.Capabilities(service, price.capability(pattern)))
+	mac.AddFirstPartyCaveat(macaroon.ValidUntil(service, p.now().Add(p.cfg.TokenTTL)))
+	mac.AddFirstPartyCaveat(macaroon.MaxCalls(price.maxCalls()))
+	return mac
+}
+
+func hasL402Proof(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	return strings.HasPrefix(auth, "L402 ") || strings.HasPrefix(auth, "LSAT ")
+}
+
+// redeemL402 checks the "L402 <macaroon>:<preimage>" credential of r: the
+// macaroon must be ours and its caveats must hold for this request, the
+// preimage must hash to its payment hash, and the payment must have calls
+// left.
+func (p *Paywall) redeemL402(r *http.Request, pattern string, price Price) (*Payment, error) {
+	credential := strings.TrimSpace(r.Header.Get("Authorization")[5:])
+	token, preimageHex, ok := strings.Cut(credential, ":")
+	if !ok {
+		return nil, errors.New("malformed L402 credential")
+	}
+	mac, err := macaroon.Decode(token)
+	if err != nil {
+		return nil, errors.New("unknown L402 macaroon")
+	}
+	id, err := macaroon.DecodeIdentifier(mac.ID)
+	if err != nil {
+		return nil, errors.New("unknown L402 macaroon")
+	}
+	req := macaroon.Request{
+		Service:    p.cfg.Service,
+		Capability: price.capability(pattern),
+		Path:       r.URL.Path,
+		Now:        p.now(),
+	}
+	if err := mac.Verify(p.cfg.RootKey, req.Check); err != nil {
+		return nil, fmt.Errorf("L402 macaroon rejected: %w", err)
+	}
+
+	preimage, err := hex.DecodeString(preimageHex)
+	if err != nil || len(preimage) != 32 {
+		return nil, errors.New("malformed L402 preimage")
+	}
+	if sum := sha256.Sum256(preimage); !hmac.Equal(sum[:], id.PaymentHash[:]) {
+		return nil, errors.New("L402 preimage does not match the invoice")
+	}
+
+	// Anyone holding the macaroon can append caveats without the root
+	// key, so a max_calls caveat may only lower the route's allowance.
+	// Every macaroon we mint expires within TokenTTL, so its uses need
+	// not be kept longer.
+	limit := price.maxCalls()
+	if n, ok := mac.MaxCalls(); ok && n < limit {
+		limit = n
+	}
+	key := "l402:" + hex.EncodeToString(id.PaymentHash[:])
+	fresh, err := p.cfg.Replay.Redeem(key, limit, p.now().Add(p.cfg.TokenTTL))
+	if err != nil {
+		return nil, fmt.Errorf("record payment: %w", err)
+	}
+	if !fresh {
+		return nil, errors.New("L402 payment has no calls left")
+	}
+
+	// Only the first call of a multi-call payment is a new payment
+	if limit > 1 {
+		if first, err := p.cfg.Replay.Redeem(key+":paid", 1, p.now().Add(p.cfg.TokenTTL)); err != nil || !first {
+			return nil, nil
+		}
+	}
+	return &Payment{
+		Protocol:    router.ProtocolL402,
+		Rail:        "lightning",
+		Amount:      router.NewAmount(price.Msat, router.Msat),
+		PaymentHash: hex.EncodeToString(id.PaymentHash[:]),
+		Preimage:    preimageHex,
+	}, nil
+}
@@ -0,0 +1,318 @@
+// Package paywall is net/http middleware for selling API routes to agents.
+// Unpaid requests to a priced route get a 402 with an x402 challenge, an L402
+// challenge backed by a real Lightning invoice, or both. A request carrying
+// valid proof of payment is passed to the wrapped handler, once per payment
+// unless the route sells several calls per L402 payment.
+package paywall
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// Price is what one request to a route costs on each rail. A zero price
+// leaves that rail out of the challenge.
+type Price struct {
+	// USDC is the x402 price.
+	USDC router.Amount
+	// Msat is the L402 price.
+	Msat int64
+	// Description is shown to the payer in challenges and invoices.
+	Description string
+	// Capability is what an L402 macaroon for this route grants. Routes
+	// with the same capability accept each other's macaroons. Defaults to
+	// the route's pattern.
+	Capability string
+	// MaxCalls is how many requests one L402 payment buys. Defaults to 1.
+	MaxCalls int
+}
+
+func (p Price) capability(pattern string) string {
+	if p.Capability != "" {
+		return p.Capability
+	}
+	return pattern
+}
+
+func (p Price) maxCalls() int {
+	if p.MaxCalls > 1 {
+		return p.MaxCalls
+	}
+	return 1
+}
+
+// Config configures a Paywall. At least one of X402 and Lightning must be set.
+type Config struct {
+	// X402 offers x402 challenges. Nil disables them.
+	X402 *X402Config
+	// Lightning creates the invoices of L402 challenges. Nil disables them.
+	Lightning providers.Invoicer
+	// RootKey signs L402 macaroons. When empty a random key is used, so
+	// unpaid challenges do not survive a restart.
+	RootKey []byte
+	// Service names the seller in L402 macaroon caveats. Defaults to
+	// "agentpay".
+	Service string
+	// TokenTTL is how long an L402 macaroon can be paid and redeemed.
+	// Defaults to one hour.
+	TokenTTL time.Duration
+	// Replay records redeemed payments so each buys only the requests it
+	// paid for. Defaults to a MemoryReplayStore.
+	Replay ReplayStore
+	// Logger receives errors minting challenges. Defaults to log.Default().
+	Logger *log.Logger
+	// OnPayment, if set, is called once for each payment accepted, before
+	// the paid request is served.
+	OnPayment func(Payment)
+}
+
+// Payment is a payment the paywall accepted.
+type Payment struct {
+	Protocol router.Protocol
+	Rail     string        // "lightning" or the x402 network
+	Amount   router.Amount // USDC for x402, msat for L402
+	Pattern  string        // the priced route
+	Resource string        // the URL requested
+	// Description is the route's price description.
+	Description string
+	// Payer is the x402 payer's address; L402 payers are anonymous.
+	Payer string
+	// TxID is the x402 settlement transaction.
+	TxID string
+	// PaymentHash and Preimage are the L402 invoice's hash and its proof.
+	PaymentHash string
+	Preimage    string
+}
+
+// Paywall charges for requests to the routes it has prices for.
+type Paywall struct {
+	cfg Config
+
+	mu     sync.RWMutex
+	mux    *http.ServeMux
+	prices map[string]Price // by pattern
+
+	now func() time.Time
+}
+
+// New creates a Paywall with no priced routes.
+func New(cfg Config) (*Paywall, error) {
+	if cfg.X402 == nil && cfg.Lightning == nil {
+		return nil, errors.New("paywall: configure x402, Lightning or both")
+	}
+	if cfg.X402 != nil {
+		if err := cfg.X402.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.RootKey) == 0 {
+		cfg.RootKey = make([]byte, 32)
+		rand.Read(cfg.RootKey)
+	}
+	if cfg.Service == "" {
+		cfg.Service = "agentpay"
+	}
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = time.Hour
+	}
+	if cfg.Replay == nil {
+		cfg.Replay = NewMemoryReplayStore()
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	return &Paywall{
+		cfg:    cfg,
+		mux:    http.NewServeMux(),
+		prices: make(map[string]Price),
+		now:    time.Now,
+	}, nil
+}
+
+// pricedRoute marks a pattern registered with SetPrice.
+type pricedRoute struct{}
+
+func (pricedRoute) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+// SetPrice charges price for requests matching pattern, an http.ServeMux
+// pattern such as "GET /v1/chat" or "/images/". Like ServeMux it panics if
+// pattern is invalid or already priced.
+func (p *Paywall) SetPrice(pattern string, price Price) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mux.Handle(pattern, pricedRoute{})
+	p.prices[pattern] = price
+}
+
+// priceFor returns the price of r and the pattern it matched, or false if r
+// is free.
+func (p *Paywall) priceFor(r *http.Request) (Price, string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	h, pattern := p.mux.Handler(r)
+	if _, ok := h.(pricedRoute); !ok {
+		return Price{}, "", false
+	}
+	price, ok := p.prices[pattern]
+	return price, pattern, ok
+}
+
+// Handler wraps next so that priced requests must be paid for. Free routes
+// pass straight through, and GET router.WellKnownPricingPath returns the
+// paywall's PricingDocument.
+func (p *Paywall) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == router.WellKnownPricingPath && r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(p.Pricing())
+			return
+		}
+		price, pattern, ok := p.priceFor(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var payment *Payment
+		var err error
+		switch {
+		case p.cfg.Lightning != nil && price.Msat > 0 && hasL402Proof(r):
+			payment, err = p.redeemL402(r, pattern, price)
+		case p.cfg.X402 != nil && !price.USDC.IsZero() && x402Proof(r) != "":
+			var settlement *X402Settlement
+			if settlement, err = p.redeemX402(r, price); err == nil {
+				setX402Response(w, settlement)
+				payment = &Payment{
+					Protocol: router.ProtocolX402,
+					Rail:     p.cfg.X402.Network,
+					Amount:   price.USDC,
+					Payer:    settlement.Payer,
+					TxID:     settlement.Transaction,
+				}
+			}
+		default:
+			err = errors.New("payment required")
+		}
+		if err != nil {
+			p.challenge(w, r, pattern, price, err)
+			return
+		}
+		if payment != nil && p.cfg.OnPayment != nil {
+			payment.Pattern = pattern
+			payment.Resource = resourceURL(r)
+			payment.Description = price.Description
+			p.cfg.OnPayment(*payment)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Pricing describes every priced route and the rails it can be paid on.
+func (p *Paywall) Pricing() *router.PricingDocument {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	patterns := make([]string, 0, len(p.prices))
+	for pattern := range p.prices {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	doc := &router.PricingDocument{Version: 1, Routes: []router.PricedRoute{}}
+	for _, pattern := range patterns {
+		price := p.prices[pattern]
+		method, path := splitPattern(pattern)
+		route := router.PricedRoute{Method: method, Path: path, Description: price.Description}
+		if p.cfg.X402 != nil && !price.USDC.IsZero() {
+			route.Prices = append(route.Prices, router.RoutePrice{
+				Protocol: string(router.ProtocolX402),
+				Rail:     p.cfg.X402.Network,
+				Amount:   price.USDC,
+				Asset:    p.cfg.X402.Asset,
+				PayTo:    p.cfg.X402.PayTo,
+			})
+		}
+		if p.cfg.Lightning != nil && price.Msat > 0 {
+			rp := router.RoutePrice{
+				Protocol: string(router.ProtocolL402),
+				Rail:     "lightning",
+				Amount:   router.NewAmount(price.Msat, router.Msat),
+			}
+			if price.MaxCalls > 1 {
+				rp.MaxCalls = price.MaxCalls
+			}
+			route.Prices = append(route.Prices, rp)
+		}
+		if len(route.Prices) > 0 {
+			doc.Routes = append(doc.Routes, route)
+		}
+	}
+	return doc
+}
+
+// splitPattern splits a ServeMux pattern into its method and path. A host
+// in the pattern is dropped.
+func splitPattern(pattern string) (method, path string) {
+	if m, rest, ok := strings.Cut(pattern, " "); ok {
+		method, pattern = m, strings.TrimLeft(rest, " \t")
+	}
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		path = pattern[i:]
+	}
+	return method, path
+}
+
+// challenge answers 402 with every challenge the route can be paid by.
+func (p *Paywall) challenge(w http.ResponseWriter, r *http.Request, pattern string, price Price, reason error) {
+	body := map[string]any{"error": reason.Error()}
+
+	offered := false
+	if p.cfg.X402 != nil && !price.USDC.IsZero() {
+		accept := p.cfg.X402.accept(r, price)
+		header, err := x402Challenge(accept, reason)
+		if err != nil {
+			p.cfg.Logger.Printf("paywall: x402 challenge: %v", err)
+		} else {
+			w.Header().Set("Payment-Required", header)
+			body["x402Version"] = 1
+			body["accepts"] = []*router.X402Accept{accept}
+			offered = true
+		}
+	}
+	if p.cfg.Lightning != nil && price.Msat > 0 {
+		header, err := p.l402Challenge(r, pattern, price)
+		if err != nil {
+			p.cfg.Logger.Printf("paywall: L402 challenge: %v", err)
+		} else {
+			w.Header().Set("WWW-Authenticate", header)
+			offered = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !offered {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "payment is temporarily unavailable"})
+		return
+	}
+	w.WriteHeader(http.StatusPaymentRequired)
+	json.NewEncoder(w).Encode(body)
+}
+
+// resourceURL reconstructs the absolute URL of r for x402's resource field.
+func resourceURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
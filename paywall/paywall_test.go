@@ -0,0 +1,494 @@
+package paywall
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/macaroon"
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// stubLightning is both the seller's Invoicer and the buyer's backend: it
+// pays invoices it created by revealing their preimages.
+type stubLightning struct {
+	mu        sync.Mutex
+	preimages map[string]string // bolt11 -> preimage hex
+}
+
+func newStubLightning() *stubLightning {
+	return &stubLightning{preimages: make(map[string]string)}
+}
+
+func (s *stubLightning) CreateInvoice(ctx context.Context, amountMsat int64, description string) (*providers.LightningInvoice, error) {
+	preimage := make([]byte, 32)
+	rand.Read(preimage)
+	hash := sha256.Sum256(preimage)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bolt11 := fmt.Sprintf("lnbc%dn1ptest%d", amountMsat/100, len(s.preimages))
+	s.preimages[bolt11] = hex.EncodeToString(preimage)
+	return &providers.LightningInvoice{Bolt11: bolt11, PaymentHash: hex.EncodeToString(hash[:])}, nil
+}
+
+func (s *stubLightning) PayInvoice(ctx context.Context, bolt11 string) (*providers.LightningPayment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	preimage, ok := s.preimages[bolt11]
+	if !ok {
+		return nil, fmt.Errorf("unknown invoice %s", bolt11)
+	}
+	return &providers.LightningPayment{Preimage: preimage, Fee: router.NewAmount(0, router.Msat)}, nil
+}
+
+func (s *stubLightning) Balance(ctx context.Context) (router.Amount, error) {
+	return router.NewAmount(1e9, router.Msat), nil
+}
+
+// stubFacilitator settles every payment it is handed.
+type stubFacilitator struct {
+	mu      sync.Mutex
+	settled []string
+}
+
+func (f *stubFacilitator) Settle(ctx context.Context, payment string, accept *router.X402Accept) (*X402Settlement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settled = append(f.settled, payment)
+	return &X402Settlement{Transaction: "0xfeed", Network: accept.Network}, nil
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, `{"answer":42}`)
+})
+
+func newTestPaywall(t *testing.T, cfg Config) (*Paywall, *httptest.Server) {
+	t.Helper()
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetPrice("GET /paid", Price{USDC: router.NewAmount(10000, router.USDC), Msat: 21000, Description: "answer"})
+	p.SetPrice("GET /other", Price{Msat: 1000})
+	srv := httptest.NewServer(p.Handler(okHandler))
+	t.Cleanup(srv.Close)
+	return p, srv
+}
+
+func TestPaywall_L402(t *testing.T) {
+	ln := newStubLightning()
+	_, srv := newTestPaywall(t, Config{Lightning: ln})
+
+	r := router.New(router.Config{MaxPerRequestUSD: 1, MaxSessionUSD: 1})
+	r.RegisterProvider(providers.NewL402ProviderWithBackend(ln))
+	body, receipt, err := r.Fetch(context.Background(), "GET", srv.URL+"/paid", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"answer":42}` || receipt == nil || receipt.Protocol != "L402" {
+		t.Fatalf("body %s, receipt %+v", body, receipt)
+	}
+
+	// Free routes pass through
+	resp, err := http.Get(srv.URL + "/free")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("free route: HTTP %d", resp.StatusCode)
+	}
+}
+
+// l402Credential fetches a challenge for path and pays it.
+func l402Credential(t *testing.T, ln *stubLightning, url string) (token, preimage string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPaymentRequired {
+		t.Fatalf("HTTP %d, want 402", resp.StatusCode)
+	}
+	options, err := router.DetectProtocol(resp, nil)
+	if err != nil || len(options) != 1 {
+		t.Fatalf("options %v, %v", options, err)
+	}
+	payment, err := ln.PayInvoice(context.Background(), options[0].L402Invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return options[0].L402Hash, payment.Preimage
+}
+
+func TestPaywall_L402Proofs(t *testing.T) {
+	ln := newStubLightning()
+	_, srv := newTestPaywall(t, Config{Lightning: ln})
+
+	get := func(path, credential string) int {
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		req.Header.Set("Authorization", credential)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	token, preimage := l402Credential(t, ln, srv.URL+"/paid")
+	wrong := strings.Repeat("00", 32)
+	if code := get("/paid", "L402 "+token+":"+wrong); code != 402 {
+		t.Errorf("wrong preimage: HTTP %d", code)
+	}
+	if code := get("/other", "L402 "+token+":"+preimage); code != 402 {
+		t.Errorf("token for another route: HTTP %d", code)
+	}
+	if code := get("/paid", "L402 x"+token[1:]+":"+preimage); code != 402 {
+		t.Errorf("tampered token: HTTP %d", code)
+	}
+	if code := get("/paid", "LSAT "+token+":"+preimage); code != 200 {
+		t.Errorf("valid proof: HTTP %d", code)
+	}
+	if code := get("/paid", "L402 "+token+":"+preimage); code != 402 {
+		t.Errorf("replayed proof: HTTP %d", code)
+	}
+}
+
+func TestPaywall_L402TokenExpires(t *testing.T) {
+	ln := newStubLightning()
+	p, srv := newTestPaywall(t, Config{Lightning: ln, TokenTTL: time.Minute})
+
+	token, preimage := l402Credential(t, ln, srv.URL+"/paid")
+	p.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	req, _ := http.NewRequest("GET", srv.URL+"/paid", nil)
+	req.Header.Set("Authorization", "L402 "+token+":"+preimage)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 402 {
+		t.Errorf("expired token: HTTP %d", resp.StatusCode)
+	}
+}
+
+func TestPaywall_L402Macaroon(t *testing.T) {
+	ln := newStubLightning()
+	p, err := New(Config{Lightning: ln, Service: "answers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetPrice("GET /bulk/", Price{Msat: 1000, Capability: "bulk", MaxCalls: 2})
+	p.SetPrice("GET /batch/", Price{Msat: 1000, Capability: "bulk", MaxCalls: 2})
+	srv := httptest.NewServer(p.Handler(okHandler))
+	defer srv.Close()
+
+	get := func(path, token, preimage string) int {
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		req.Header.Set("Authorization", "L402 "+token+":"+preimage)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	token, preimage := l402Credential(t, ln, srv.URL+"/bulk/a")
+	mac, err := macaroon.Decode(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"services=answers:0", "answers_capabilities=bulk", "answers_valid_until=", "max_calls=2"}
+	conds := mac.Conditions()
+	if len(conds) != len(want) {
+		t.Fatalf("caveats %q", conds)
+	}
+	for i := range want {
+		if !strings.HasPrefix(conds[i], want[i]) {
+			t.Errorf("caveat %d = %q, want %s...", i, conds[i], want[i])
+		}
+	}
+
+	// A buyer can narrow the macaroon before handing it on
+	mac.AddFirstPartyCaveat(macaroon.PathPrefix("/bulk/a"))
+	narrowed := mac.Encode()
+	if code := get("/bulk/b", narrowed, preimage); code != 402 {
+		t.Errorf("narrowed macaroon on another path: HTTP %d", code)
+	}
+
+	// Routes sharing a capability share the payment's two calls
+	if code := get("/bulk/a", narrowed, preimage); code != 200 {
+		t.Errorf("first call: HTTP %d", code)
+	}
+	if code := get("/batch/x", token, preimage); code != 200 {
+		t.Errorf("second call on a route with the same capability: HTTP %d", code)
+	}
+	if code := get("/bulk/a", token, preimage); code != 402 {
+		t.Errorf("third call: HTTP %d", code)
+	}
+}
+
+func TestPaywall_L402AttenuationCannotRaiseCalls(t *testing.T) {
+	ln := newStubLightning()
+	_, srv := newTestPaywall(t, Config{Lightning: ln})
+
+	token, preimage := l402Credential(t, ln, srv.URL+"/other")
+	mac, err := macaroon.Decode(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := mac.MaxCalls(); !ok || n != 1 {
+		t.Fatalf("minted max_calls = %d, %v; want 1", n, ok)
+	}
+	// The holder can append caveats without the root key
+	mac.AddFirstPartyCaveat(macaroon.MaxCalls(5))
+	widened := mac.Encode()
+
+	for i, want := range []int{200, 402} {
+		req, _ := http.NewRequest("GET", srv.URL+"/other", nil)
+		req.Header.Set("Authorization", "L402 "+widened+":"+preimage)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("call %d: HTTP %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestMemoryReplayStore_Limit(t *testing.T) {
+	s := NewMemoryReplayStore()
+	later := time.Now().Add(time.Hour)
+	for i := range 3 {
+		if ok, _ := s.Redeem("a", 3, later); !ok {
+			t.Fatalf("use %d refused", i+1)
+		}
+	}
+	if ok, _ := s.Redeem("a", 3, later); ok {
+		t.Error("fourth use allowed")
+	}
+}
+
+const testPayTo = "0x5049CaCF18346ee22EBA390B9B6309cb3f03abFB"
+
+func testX402Config(f Facilitator) *X402Config {
+	return &X402Config{
+		Network:     "eip155:8453",
+		Asset:       "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+		PayTo:       testPayTo,
+		Facilitator: f,
+	}
+}
+
+func TestPaywall_X402(t *testing.T) {
+	facilitator := &stubFacilitator{}
+	_, srv := newTestPaywall(t, Config{X402: testX402Config(facilitator)})
+
+	payer, err := providers.NewEVMKeyProvider(big.NewInt(0xC0FFEE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router.New(router.Config{MaxPerRequestUSD: 1, MaxSessionUSD: 1})
+	r.RegisterProvider(payer)
+	body, receipt, err := r.Fetch(context.Background(), "GET", srv.URL+"/paid", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"answer":42}` || receipt.Cost != router.NewAmount(10000, router.USD) {
+		t.Fatalf("body %s, receipt %+v", body, receipt)
+	}
+	if len(facilitator.settled) != 1 {
+		t.Fatalf("settled %d payments", len(facilitator.settled))
+	}
+
+	// The same authorization cannot buy a second request
+	req, _ := http.NewRequest("GET", srv.URL+"/paid", nil)
+	req.Header.Set("X-Payment", facilitator.settled[0])
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 402 || len(facilitator.settled) != 1 {
+		t.Errorf("replay: HTTP %d, settled %d", resp.StatusCode, len(facilitator.settled))
+	}
+}
+
+func TestPaywall_X402Underpaid(t *testing.T) {
+	facilitator := &stubFacilitator{}
+	_, srv := newTestPaywall(t, Config{X402: testX402Config(facilitator)})
+
+	// Sign for less than the route's price
+	payer, _ := providers.NewEVMKeyProvider(big.NewInt(0xC0FFEE))
+	accept := testX402Config(nil).accept(httptest.NewRequest("GET", "/paid", nil), Price{USDC: router.NewAmount(9999, router.USDC)})
+	_, payment, err := payer.Pay(context.Background(), &router.PaymentRequirement{Protocol: router.ProtocolX402, X402Accept: accept})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/paid", nil)
+	req.Header.Set("Payment", payment)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 402 || len(facilitator.settled) != 0 {
+		t.Errorf("underpaid: HTTP %d, settled %d", resp.StatusCode, len(facilitator.settled))
+	}
+	if resp.Header.Get("Payment-Required") == "" {
+		t.Error("expected a fresh challenge")
+	}
+}
+
+func TestPaywall_BothRails(t *testing.T) {
+	_, srv := newTestPaywall(t, Config{X402: testX402Config(&stubFacilitator{}), Lightning: newStubLightning()})
+	resp, err := http.Get(srv.URL + "/paid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	options, err := router.DetectProtocol(resp, nil)
+	if err != nil || len(options) != 2 {
+		t.Fatalf("options %v, %v", options, err)
+	}
+	if a := options[0].X402Accept; a == nil || a.MaxAmountRequired != "10000" || a.Resource != srv.URL+"/paid" {
+		t.Errorf("x402 option = %+v", options[0].X402Accept)
+	}
+	if !strings.HasPrefix(options[1].L402Invoice, "lnbc210n1") {
+		t.Errorf("L402 option = %+v", options[1])
+	}
+}
+
+func TestPaywall_Pricing(t *testing.T) {
+	_, srv := newTestPaywall(t, Config{X402: testX402Config(&stubFacilitator{}), Lightning: newStubLightning()})
+	resp, err := http.Get(srv.URL + router.WellKnownPricingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc router.PricingDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != 1 || len(doc.Routes) != 2 {
+		t.Fatalf("document %+v", doc)
+	}
+	other, paid := doc.Routes[0], doc.Routes[1]
+	if other.Method != "GET" || other.Path != "/other" || len(other.Prices) != 1 || other.Prices[0].Protocol != "L402" {
+		t.Errorf("/other = %+v", other)
+	}
+	if paid.Path != "/paid" || len(paid.Prices) != 2 {
+		t.Fatalf("/paid = %+v", paid)
+	}
+	x402, l402 := paid.Prices[0], paid.Prices[1]
+	if x402.Rail != "eip155:8453" || x402.Amount != router.NewAmount(10000, router.USDC) || x402.PayTo != testPayTo {
+		t.Errorf("x402 price = %+v", x402)
+	}
+	if l402.Rail != "lightning" || l402.Amount != router.NewAmount(21000, router.Msat) {
+		t.Errorf("L402 price = %+v", l402)
+	}
+}
+
+func TestPaywall_OnPayment(t *testing.T) {
+	ln := newStubLightning()
+	var mu sync.Mutex
+	var payments []Payment
+	p, srv := newTestPaywall(t, Config{Lightning: ln, OnPayment: func(pay Payment) {
+		mu.Lock()
+		defer mu.Unlock()
+		payments = append(payments, pay)
+	}})
+	p.SetPrice("GET /bulk", Price{Msat: 5000, MaxCalls: 3})
+
+	for _, path := range []string{"/paid", "/bulk"} {
+		token, preimage := l402Credential(t, ln, srv.URL+path)
+		for range 2 {
+			req, _ := http.NewRequest("GET", srv.URL+path, nil)
+			req.Header.Set("Authorization", "L402 "+token+":"+preimage)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+	}
+
+	// The replayed /paid call is refused; the second /bulk call is paid for
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payments) != 2 {
+		t.Fatalf("payments %+v", payments)
+	}
+	first := payments[0]
+	if first.Protocol != router.ProtocolL402 || first.Pattern != "GET /paid" || first.Resource != srv.URL+"/paid" ||
+		first.Amount != router.NewAmount(21000, router.Msat) || first.Description != "answer" || len(first.Preimage) != 64 {
+		t.Errorf("payment %+v", first)
+	}
+	if payments[1].Pattern != "GET /bulk" {
+		t.Errorf("payment %+v", payments[1])
+	}
+}
+
+func TestFacilitatorClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/settle" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"success":true,"transaction":"0xabc","network":"eip155:8453","payer":"0x1"}`)
+	}))
+	defer srv.Close()
+
+	c := &FacilitatorClient{URL: srv.URL + "/"}
+	s, err := c.Settle(context.Background(), base64.StdEncoding.EncodeToString([]byte(`{}`)), &router.X402Accept{})
+	if err != nil || s.Transaction != "0xabc" {
+		t.Errorf("settlement %+v, %v", s, err)
+	}
+}
+
+func TestFileReplayStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay")
+	s, err := OpenFileReplayStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if ok, _ := s.Redeem("a", 1, later); !ok {
+		t.Fatal("first redemption refused")
+	}
+	if ok, _ := s.Redeem("a", 1, later); ok {
+		t.Fatal("second redemption allowed")
+	}
+	s.Redeem("gone", 1, time.Now().Add(-time.Minute))
+	s.Close()
+
+	s, err = OpenFileReplayStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if ok, _ := s.Redeem("a", 1, later); ok {
+		t.Error("redemption forgotten across restart")
+	}
+	if ok, _ := s.Redeem("gone", 1, later); !ok {
+		t.Error("expired redemption kept")
+	}
+}
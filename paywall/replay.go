@@ -0,0 +1,167 @@
+package paywall
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayStore records redeemed payments. A key only needs to be kept until
+// its expiry, after which the payment could not be redeemed anyway.
+type ReplayStore interface {
+	// Redeem records one use of key, kept until expires, and reports
+	// whether key had been used fewer than limit times before.
+	Redeem(key string, limit int, expires time.Time) (bool, error)
+}
+
+// MemoryReplayStore is an in-process ReplayStore. Redeemed payments are
+// forgotten on restart, so it suits a single process with short token TTLs.
+type MemoryReplayStore struct {
+	mu        sync.Mutex
+	spent     map[string]*redemption
+	lastSweep time.Time
+}
+
+type redemption struct {
+	uses    int
+	expires time.Time
+}
+
+// NewMemoryReplayStore creates an empty MemoryReplayStore.
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{spent: make(map[string]*redemption)}
+}
+
+// Redeem implements ReplayStore.
+func (s *MemoryReplayStore) Redeem(key string, limit int, expires time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.redeem(key, limit, expires, time.Now()), nil
+}
+
+func (s *MemoryReplayStore) redeem(key string, limit int, expires, now time.Time) bool {
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, r := range s.spent {
+			if now.After(r.expires) {
+				delete(s.spent, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	r, ok := s.spent[key]
+	if !ok || now.After(r.expires) {
+		r = &redemption{}
+		s.spent[key] = r
+	}
+	if r.uses >= limit {
+		return false
+	}
+	r.uses++
+	if expires.After(r.expires) {
+		r.expires = expires
+	}
+	return true
+}
+
+// unredeem takes back the last use of key after it failed to persist.
+func (s *MemoryReplayStore) unredeem(key string) {
+	if r, ok := s.spent[key]; ok {
+		if r.uses--; r.uses <= 0 {
+			delete(s.spent, key)
+		}
+	}
+}
+
+// FileReplayStore is a ReplayStore that survives restarts. Each use is
+// appended to a file as "<expiry> <key>" and synced before the request is
+// let through.
+type FileReplayStore struct {
+	mu   sync.Mutex
+	mem  *MemoryReplayStore
+	file *os.File
+}
+
+// OpenFileReplayStore loads the redemptions recorded at path, dropping
+// expired ones, and appends new ones to it.
+func OpenFileReplayStore(path string) (*FileReplayStore, error) {
+	mem := NewMemoryReplayStore()
+	now := time.Now()
+	var live []string
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			expStr, key, ok := strings.Cut(scanner.Text(), " ")
+			exp, err := strconv.ParseInt(expStr, 10, 64)
+			if !ok || err != nil {
+				continue // a torn final line from a crash
+			}
+			if expires := time.Unix(exp, 0); now.Before(expires) {
+				mem.redeem(key, math.MaxInt, expires, now)
+				live = append(live, scanner.Text())
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read replay store: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read replay store: %w", err)
+	}
+
+	// Rewrite without the expired entries
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create replay store dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	var data []byte
+	for _, line := range live {
+		data = append(data, line+"\n"...)
+	}
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return nil, fmt.Errorf("write replay store: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("write replay store: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open replay store: %w", err)
+	}
+	return &FileReplayStore{mem: mem, file: file}, nil
+}
+
+// Redeem implements ReplayStore.
+func (s *FileReplayStore) Redeem(key string, limit int, expires time.Time) (bool, error) {
+	if strings.ContainsAny(key, "\n\r") {
+		return false, fmt.Errorf("invalid replay key %q", key)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	if !s.mem.redeem(key, limit, expires, time.Now()) {
+		return false, nil
+	}
+	if _, err := fmt.Fprintf(s.file, "%d %s\n", expires.Unix(), key); err != nil {
+		s.mem.unredeem(key)
+		return false, err
+	}
+	if err := s.file.Sync(); err != nil {
+		s.mem.unredeem(key)
+		return false, err
+	}
+	return true, nil
+}
+
+// Close closes the underlying file.
+func (s *FileReplayStore) Close() error {
+	return s.file.Close()
+}
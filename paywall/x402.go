@@ -0,0 +1,215 @@
+package paywall
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/providers"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// X402Config describes where x402 payments go and who settles them.
+type X402Config struct {
+	// Network is the CAIP-2 chain, e.g. "eip155:8453" for Base.
+	Network string
+	// Asset is the USDC contract address on Network.
+	Asset string
+	// PayTo is the address that receives payments.
+	PayTo string
+	// Name and Version are the token's EIP-712 domain. They default to
+	// USDC's "USD Coin" and "2".
+	Name    string
+	Version string
+	// MaxTimeout is how long a payer's authorization needs to stay valid.
+	// Defaults to one minute.
+	MaxTimeout time.Duration
+	// Facilitator settles verified payments on chain.
+	Facilitator Facilitator
+}
+
+func (c *X402Config) validate() error {
+	switch {
+	case !strings.HasPrefix(c.Network, "eip155:"):
+		return fmt.Errorf("paywall: x402 network %q is not an EVM chain", c.Network)
+	case c.Asset == "" || c.PayTo == "":
+		return errors.New("paywall: x402 needs an asset and a payTo address")
+	case c.Facilitator == nil:
+		return errors.New("paywall: x402 needs a facilitator to settle payments")
+	}
+	return nil
+}
+
+// accept returns the x402 payment option for r at price.
+func (c *X402Config) accept(r *http.Request, price Price) *router.X402Accept {
+	name, version := c.Name, c.Version
+	if name == "" {
+		name = "USD Coin"
+	}
+	if version == "" {
+		version = "2"
+	}
+	extra, _ := json.Marshal(map[string]string{"name": name, "version": version})
+	timeout := c.MaxTimeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	return &router.X402Accept{
+		Scheme:            "exact",
+		Network:           c.Network,
+		MaxAmountRequired: strconv.FormatInt(price.USDC.Units, 10),
+		Resource:          resourceURL(r),
+		Description:       price.Description,
+		PayTo:             c.PayTo,
+		MaxTimeoutSeconds: int(timeout.Seconds()),
+		Asset:             c.Asset,
+		Extra:             extra,
+	}
+}
+
+// x402Challenge encodes the Payment-Required header offering accept.
+func x402Challenge(accept *router.X402Accept, reason error) (string, error) {
+	data, err := json.Marshal(map[string]any{
+		"x402Version": 1,
+		"error":       reason.Error(),
+		"accepts":     []*router.X402Accept{accept},
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// x402Proof returns the payment header of r. Clients send it as
+// Payment-Signature (v2), X-Payment (v1) or Payment.
+func x402Proof(r *http.Request) string {
+	for _, name := range []string{"Payment-Signature", "X-Payment", "Payment"} {
+		if v := r.Header.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// redeemX402 verifies the payment r carries against the route's price,
+// marks its nonce spent and has the facilitator settle it.
+func (p *Paywall) redeemX402(r *http.Request, price Price) (*X402Settlement, error) {
+	header := x402Proof(r)
+	payment, err := providers.DecodeX402Payment(header)
+	if err != nil {
+		return nil, err
+	}
+	accept := p.cfg.X402.accept(r, price)
+	if err := payment.Verify(accept, p.now()); err != nil {
+		return nil, err
+	}
+
+	validBefore, _ := strconv.ParseInt(payment.ValidBefore, 10, 64)
+	key := "x402:" + payment.Network + ":" + strings.ToLower(payment.From) + ":" + strings.ToLower(payment.Nonce)
+	fresh, err := p.cfg.Replay.Redeem(key, 1, time.Unix(validBefore, 0))
+	if err != nil {
+		return nil, fmt.Errorf("record payment: %w", err)
+	}
+	if !fresh {
+		return nil, errors.New("x402 payment has already been redeemed")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(accept.MaxTimeoutSeconds)*time.Second)
+	defer cancel()
+	settlement, err := p.cfg.X402.Facilitator.Settle(ctx, header, accept)
+	if err != nil {
+		return nil, fmt.Errorf("settle payment: %w", err)
+	}
+	return settlement, nil
+}
+
+// setX402Response reports the settlement to the payer.
+func setX402Response(w http.ResponseWriter, s *X402Settlement) {
+	data, err := json.Marshal(map[string]any{
+		"success":     true,
+		"transaction": s.Transaction,
+		"network":     s.Network,
+		"payer":       s.Payer,
+	})
+	if err == nil {
+		w.Header().Set("X-Payment-Response", base64.StdEncoding.EncodeToString(data))
+	}
+}
+
+// X402Settlement is a payment a facilitator settled.
+type X402Settlement struct {
+	Transaction string
+	Network     string
+	Payer       string
+}
+
+// Facilitator settles x402 payments: it submits the signed authorization to
+// the chain and reports the transaction.
+type Facilitator interface {
+	Settle(ctx context.Context, payment string, accept *router.X402Accept) (*X402Settlement, error)
+}
+
+// FacilitatorClient settles through an x402 facilitator service's /settle
+// endpoint.
+type FacilitatorClient struct {
+	// URL is the facilitator's base URL, e.g. "https://x402.org/facilitator".
+	URL string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Settle implements Facilitator.
+func (c *FacilitatorClient) Settle(ctx context.Context, payment string, accept *router.X402Accept) (*X402Settlement, error) {
+	raw, err := base64.StdEncoding.DecodeString(payment)
+	if err != nil {
+		return nil, fmt.Errorf("x402 payment is not base64: %w", err)
+	}
+	body, err := json.Marshal(map[string]any{
+		"x402Version":         1,
+		"paymentHeader":       payment,
+		"paymentPayload":      json.RawMessage(raw),
+		"paymentRequirements": accept,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal settle request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(c.URL, "/")+"/settle", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build settle request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("settle request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var result struct {
+		Success     bool   `json:"success"`
+		ErrorReason string `json:"errorReason"`
+		Transaction string `json:"transaction"`
+		Network     string `json:"network"`
+		Payer       string `json:"payer"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("facilitator HTTP %d: %s", resp.StatusCode, respBody)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("facilitator declined the payment: %s", result.ErrorReason)
+	}
+	return &X402Settlement{Transaction: result.Transaction, Network: result.Network, Payer: result.Payer}, nil
+}
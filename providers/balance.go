@@ -0,0 +1,175 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+// fetchAgentWalletUSDC returns the USDC held by an AgentWallet account, keyed
+// by CAIP-2 network (see balanceNetwork). Balances the document doesn't place
+// on a network are left out. X402Provider and SolanaProvider share the same
+// wallet and therefore the same balances endpoint.
+func fetchAgentWalletUSDC(ctx context.Context, client *http.Client, apiBase, username, token string) (map[string]router.Amount, error) {
+	url := fmt.Sprintf("%s/api/wallets/%s/balances", apiBase, username)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build balance request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("balance request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("balance HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("parse balances: %w", err)
+	}
+
+	totals := map[string]router.Amount{}
+	collectUSDC(doc, "", totals)
+	return totals, nil
+}
+
+// agentWalletUSDC returns the USDC an AgentWallet account holds on network.
+// It is an error if the wallet reports no USDC balance there, so the router
+// treats the balance as unknown rather than counting funds on another chain.
+func agentWalletUSDC(ctx context.Context, client *http.Client, apiBase, username, token, network string) (router.Amount, error) {
+	key := balanceNetwork(network)
+	if key == "" {
+		return router.Amount{}, fmt.Errorf("unsupported network %q", network)
+	}
+	totals, err := fetchAgentWalletUSDC(ctx, client, apiBase, username, token)
+	if err != nil {
+		return router.Amount{}, err
+	}
+	amt, ok := totals[key]
+	if !ok {
+		return router.Amount{}, fmt.Errorf("AgentWallet reports no USDC balance on %s", network)
+	}
+	return usdcToUSD(amt), nil
+}
+
+// collectUSDC walks an AgentWallet balances document and sums every USDC
+// entry into totals by network. Each entry names its asset and chain and
+// carries either a raw integer amount or a decimal one, so the walk keys off
+// those fields rather than a fixed schema. An entry's own chain or network
+// field wins over a CAIP-2 key it is nested under.
+func collectUSDC(v interface{}, network string, totals map[string]router.Amount) {
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			collectUSDC(item, network, totals)
+		}
+	case map[string]interface{}:
+		if n := entryNetwork(val); n != "" {
+			network = n
+		}
+		if amt, ok := usdcEntry(val); ok {
+			if sum, ok := totals[network]; ok {
+				totals[network] = sum.Add(amt)
+			} else if network != "" {
+				totals[network] = amt
+			}
+			return
+		}
+		for k, child := range val {
+			n := network
+			if strings.Contains(k, ":") {
+				n = balanceNetwork(k)
+			}
+			collectUSDC(child, n, totals)
+		}
+	}
+}
+
+// entryNetwork returns the network named by an entry's chain or network field.
+func entryNetwork(m map[string]interface{}) string {
+	for _, key := range []string{"network", "chain"} {
+		if s, ok := m[key].(string); ok {
+			if n := balanceNetwork(s); n != "" {
+				return n
+			}
+		}
+	}
+	return ""
+}
+
+// evmChainNetworks maps AgentWallet's EVM chain names to CAIP-2 networks.
+var evmChainNetworks = map[string]string{
+	"base":         "eip155:8453",
+	"base-mainnet": "eip155:8453",
+	"base-sepolia": "eip155:84532",
+	"ethereum":     "eip155:1",
+	"polygon":      "eip155:137",
+	"arbitrum":     "eip155:42161",
+	"optimism":     "eip155:10",
+}
+
+// balanceNetwork normalizes a CAIP-2 network or AgentWallet chain name to the
+// key balances are totalled under: the CAIP-2 network for EVM chains and
+// "solana:<cluster>" for Solana. It returns "" for a name that doesn't say
+// which network it is, such as "evm".
+func balanceNetwork(s string) string {
+	var cluster string
+	switch lower := strings.ToLower(s); {
+	case strings.HasPrefix(lower, "eip155:"):
+		return lower
+	case strings.HasPrefix(lower, "solana:"):
+		// Genesis hashes are case-sensitive
+		cluster = solanaCluster(s)
+	case lower == "solana":
+		cluster = "mainnet"
+	case strings.HasPrefix(lower, "solana-"):
+		cluster = solanaCluster(strings.TrimPrefix(lower, "solana-"))
+	default:
+		return evmChainNetworks[lower]
+	}
+	if cluster == "" {
+		return ""
+	}
+	return "solana:" + cluster
+}
+
+// usdcEntry parses a single balance entry if it is USDC.
+func usdcEntry(m map[string]interface{}) (router.Amount, bool) {
+	symbol := ""
+	for _, key := range []string{"asset", "symbol", "token"} {
+		if s, ok := m[key].(string); ok {
+			symbol = s
+			break
+		}
+	}
+	if !strings.EqualFold(symbol, "usdc") {
+		return router.Amount{}, false
+	}
+	for _, key := range []string{"rawAmount", "rawValue", "raw"} {
+		if s, ok := m[key].(string); ok {
+			if amt, err := router.ParseUnits(s, router.USDC); err == nil {
+				return amt, true
+			}
+		}
+	}
+	for _, key := range []string{"amount", "balance", "value"} {
+		if s, ok := m[key].(string); ok {
+			if amt, err := router.ParseDecimal(s, router.USDC); err == nil {
+				return amt, true
+			}
+		}
+	}
+	return router.Amount{}, false
+}
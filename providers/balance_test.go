@@ -0,0 +1,77 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+func TestX402Provider_Balance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/wallets/testuser/balances" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{
+			"evm": {"address": "0xabc", "balances": [
+				{"chain": "base", "asset": "USDC", "rawValue": "0"},
+				{"chain": "base", "asset": "ETH", "rawValue": "1000000000000000"},
+				{"chain": "ethereum", "asset": "USDC", "amount": "7"},
+				{"asset": "USDC", "amount": "3"}
+			]},
+			"solana": {"address": "SoLaNa", "balances": [
+				{"chain": "solana-devnet", "asset": "usdc", "amount": "2.5"}
+			]}
+		}`))
+	}))
+	defer srv.Close()
+
+	p := NewX402Provider(srv.URL, "testuser", "token")
+
+	base := router.X402Accept{Network: "eip155:8453", MaxAmountRequired: "1000"}
+	sol := router.X402Accept{Network: "solana:devnet", MaxAmountRequired: "1000"}
+
+	got, err := p.Balance(context.Background(), &router.PaymentRequirement{X402Accept: &base})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.IsZero() {
+		t.Errorf("Base USDC = %s, want 0", got)
+	}
+
+	got, err = p.Balance(context.Background(), &router.PaymentRequirement{X402Accept: &sol})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != router.FromUSD(2.5) {
+		t.Errorf("Solana USDC = %s, want 2.5 USD", got)
+	}
+
+	// USDC on another chain, or on no named chain, is not a Base Sepolia balance
+	sepolia := router.X402Accept{Network: "eip155:84532", MaxAmountRequired: "1000"}
+	if got, err := p.Balance(context.Background(), &router.PaymentRequirement{X402Accept: &sepolia}); err == nil {
+		t.Errorf("Base Sepolia USDC = %s, want an unknown balance", got)
+	}
+}
+
+func TestL402Provider_Balance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "admin" {
+			t.Errorf("missing admin key")
+		}
+		w.Write([]byte(`{"name":"agent","balance":5000000}`)) // 5000 sats
+	}))
+	defer srv.Close()
+
+	p := NewL402Provider(srv.URL, "admin")
+	got, err := p.Balance(context.Background(), &router.PaymentRequirement{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 5000 sats at $100K/BTC = $5
+	if got != router.FromUSD(5) {
+		t.Errorf("balance = %s, want 5 USD", got)
+	}
+}
@@ -0,0 +1,696 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/cashu"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// InvoicePayer pays Lightning invoices. L402Provider implements it.
+type InvoicePayer interface {
+	PayInvoice(ctx context.Context, bolt11 string) (paymentHash string, err error)
+}
+
+// CashuProvider pays NUT-24 (X-Cashu) challenges with ecash from a local
+// wallet file. Proofs come from a single mint; when the wallet runs short it
+// mints more by paying the mint's Lightning invoice through Payer.
+type CashuProvider struct {
+	mintURL    string
+	walletPath string
+	client     *http.Client
+	// Payer funds minting. Nil disables automatic top-ups.
+	Payer InvoicePayer
+	// BTCPriceUSD is the whole-dollar price of 1 BTC used for cost estimation.
+	BTCPriceUSD int64
+	// QuotePollInterval is how often a mint quote is checked while waiting
+	// for the Lightning payment to land.
+	QuotePollInterval time.Duration
+
+	mu sync.Mutex // serializes wallet file access
+}
+
+// NewCashuProvider creates a Cashu provider for mintURL that keeps its proofs
+// in walletPath.
+func NewCashuProvider(mintURL, walletPath string) *CashuProvider {
+	return &CashuProvider{
+		mintURL:           strings.TrimRight(mintURL, "/"),
+		walletPath:        walletPath,
+		client:            &http.Client{Timeout: 30 * time.Second},
+		BTCPriceUSD:       100000,
+		QuotePollInterval: time.Second,
+	}
+}
+
+func (p *CashuProvider) Protocol() router.Protocol {
+	return router.ProtocolCashu
+}
+
+// Capabilities reports that the provider only settles Cashu requests.
+func (p *CashuProvider) Capabilities() router.Capabilities {
+	return router.Capabilities{Networks: []string{"cashu"}}
+}
+
+// MintURL returns the mint the wallet holds proofs from.
+func (p *CashuProvider) MintURL() string {
+	return p.mintURL
+}
+
+func (p *CashuProvider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	cr, err := p.request(req)
+	if err != nil {
+		return router.Amount{}, "", err
+	}
+	usd, err := cashuUSD(cr.Amount, cr.Unit, p.BTCPriceUSD)
+	if err != nil {
+		return router.Amount{}, "", err
+	}
+	desc := fmt.Sprintf("%d %s ecash ($%.4f)", cr.Amount, cr.Unit, usd.Float64())
+	return usd, desc, nil
+}
+
+func (p *CashuProvider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
+	cr, err := p.request(req)
+	if err != nil {
+		return "", "", err
+	}
+	token, err := p.Send(ctx, cr.Amount, cr.Unit)
+	if err != nil {
+		return "", "", err
+	}
+	return "X-Cashu", token, nil
+}
+
+// Balance returns the wallet's proofs for the request's unit valued in USD.
+// With a Payer the wallet can top itself up, so the Payer's own balance is
+// added when it can report one.
+func (p *CashuProvider) Balance(ctx context.Context, req *router.PaymentRequirement) (router.Amount, error) {
+	cr, err := p.request(req)
+	if err != nil {
+		return router.Amount{}, err
+	}
+	units, err := p.WalletBalance(cr.Unit)
+	if err != nil {
+		return router.Amount{}, err
+	}
+	usd, err := cashuUSD(units, cr.Unit, p.BTCPriceUSD)
+	if err != nil || p.Payer == nil {
+		return usd, err
+	}
+
+	bp, ok := p.Payer.(router.BalanceProvider)
+	if !ok {
+		return router.Amount{}, fmt.Errorf("balance unknown: wallet tops up from a Lightning wallet without a balance")
+	}
+	funding, err := bp.Balance(ctx, req)
+	if err != nil {
+		return router.Amount{}, fmt.Errorf("funding wallet balance: %w", err)
+	}
+	return usd.Add(funding), nil
+}
+
+// request extracts the Cashu payment request and checks that our mint is
+// one the server accepts.
+func (p *CashuProvider) request(req *router.PaymentRequirement) (*router.CashuRequest, error) {
+	cr, ok := req.Details.(*router.CashuRequest)
+	if !ok {
+		return nil, fmt.Errorf("no Cashu payment request")
+	}
+	for _, m := range cr.Mints {
+		if strings.TrimRight(m, "/") == p.mintURL {
+			return cr, nil
+		}
+	}
+	return nil, fmt.Errorf("server does not accept ecash from %s (accepts %s)", p.mintURL, strings.Join(cr.Mints, ", "))
+}
+
+// cashuUSD values an amount in a Cashu unit. The "usd" unit counts cents.
+func cashuUSD(amount int64, unit string, btcPriceUSD int64) (router.Amount, error) {
+	switch strings.ToLower(unit) {
+	case "sat":
+		return router.BTCToUSD(router.NewAmount(amount, router.Sat), btcPriceUSD), nil
+	case "msat":
+		return router.BTCToUSD(router.NewAmount(amount, router.Msat), btcPriceUSD), nil
+	case "usd":
+		return router.NewAmount(amount*10000, router.USD), nil
+	default:
+		return router.Amount{}, fmt.Errorf("unsupported Cashu unit %q", unit)
+	}
+}
+
+// WalletBalance returns the total of unspent proofs for unit.
+func (p *CashuProvider) WalletBalance(unit string) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w, err := p.load()
+	if err != nil {
+		return 0, err
+	}
+	return int64(cashu.Sum(w.proofs(p.mintURL, unit))), nil
+}
+
+// Send takes amount from the wallet and returns it as a serialized V4 token.
+// Proofs are swapped at the mint when no subset adds up to the exact amount,
+// and the wallet is topped up over Lightning if it runs short. The sent
+// proofs are removed from the wallet before the token is returned.
+func (p *CashuProvider) Send(ctx context.Context, amount int64, unit string) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("invalid Cashu amount %d", amount)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keysets, err := p.keysets(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	w, err := p.load()
+	if err != nil {
+		return "", err
+	}
+	p.resumePending(ctx, w)
+	have := w.proofs(p.mintURL, unit)
+
+	inputs, exact := selectProofs(have, uint64(amount), keysets)
+	if inputs == nil {
+		if p.Payer == nil {
+			return "", fmt.Errorf("%w: %d %s in Cashu wallet, need %d", router.ErrInsufficientBalance, cashu.Sum(have), unit, amount)
+		}
+		// Mint the shortfall plus enough to cover swap fees on every proof
+		// the next selection might spend, including the new ones.
+		var shortfall uint64
+		if sum := cashu.Sum(have); sum < uint64(amount) {
+			shortfall = uint64(amount) - sum
+		}
+		newProofs := uint64(len(cashu.SplitAmount(shortfall)) + 1)
+		topUp := shortfall + inputFee(have, keysets) + (maxFeePPK(keysets)*newProofs+999)/1000
+		if err := p.mintLocked(ctx, w, topUp, unit); err != nil {
+			return "", fmt.Errorf("top up Cashu wallet: %w", err)
+		}
+		have = w.proofs(p.mintURL, unit)
+		if inputs, exact = selectProofs(have, uint64(amount), keysets); inputs == nil {
+			return "", fmt.Errorf("%w: Cashu wallet still short after minting", router.ErrInsufficientBalance)
+		}
+	}
+
+	send := inputs
+	var change []cashu.Proof
+	if !exact {
+		send, change, err = p.swap(ctx, inputs, uint64(amount), unit, keysets)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	w.remove(inputs)
+	w.add(p.mintURL, unit, change)
+	if err := p.save(w); err != nil {
+		return "", err
+	}
+
+	tok := &cashu.Token{Mint: p.mintURL, Unit: unit, Proofs: send}
+	return tok.Encode()
+}
+
+// Mint buys amount of ecash from the mint by paying its Lightning invoice.
+func (p *CashuProvider) Mint(ctx context.Context, amount int64, unit string) error {
+	if amount <= 0 {
+		return fmt.Errorf("invalid Cashu amount %d", amount)
+	}
+	if p.Payer == nil {
+		return fmt.Errorf("no Lightning wallet configured to pay the mint")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w, err := p.load()
+	if err != nil {
+		return err
+	}
+	p.resumePending(ctx, w)
+	return p.mintLocked(ctx, w, uint64(amount), unit)
+}
+
+// mintLocked runs the NUT-04 bolt11 flow and saves the new proofs into w.
+// The quote and its blinded outputs are saved before the invoice is paid, so
+// a payment that outlives this call can still be claimed by resumePending.
+func (p *CashuProvider) mintLocked(ctx context.Context, w *cashuWallet, amount uint64, unit string) error {
+	var quote struct {
+		Quote   string `json:"quote"`
+		Request string `json:"request"`
+		State   string `json:"state"`
+		Expiry  int64  `json:"expiry"`
+	}
+	err := p.mintRequest(ctx, "POST", "/v1/mint/quote/bolt11",
+		map[string]any{"amount": amount, "unit": unit}, &quote)
+	if err != nil {
+		return fmt.Errorf("mint quote: %w", err)
+	}
+
+	ks, err := p.activeKeyset(ctx, unit)
+	if err != nil {
+		return err
+	}
+	outputs, err := newOutputs(cashu.SplitAmount(amount), ks.ID)
+	if err != nil {
+		return err
+	}
+	pm := pendingMint{Mint: p.mintURL, Unit: unit, Quote: quote.Quote, Expiry: quote.Expiry, Outputs: outputs}
+	if pm.Expiry == 0 {
+		pm.Expiry = time.Now().Add(pendingMintTTL).Unix()
+	}
+	w.Pending = append(w.Pending, pm)
+	if err := p.save(w); err != nil {
+		return err
+	}
+
+	if _, err := p.Payer.PayInvoice(ctx, quote.Request); err != nil {
+		return fmt.Errorf("pay mint invoice: %w", err)
+	}
+	if err := p.waitPaid(ctx, quote.Quote); err != nil {
+		return err
+	}
+	return p.claim(ctx, w, pm, ks)
+}
+
+// pendingMintTTL is how long an unpaid quote is kept when the mint gives no
+// expiry.
+const pendingMintTTL = 24 * time.Hour
+
+// claim mints the proofs for a paid quote and saves them in place of its
+// pending entry.
+func (p *CashuProvider) claim(ctx context.Context, w *cashuWallet, pm pendingMint, ks *cashuKeyset) error {
+	var resp struct {
+		Signatures []cashu.BlindSignature `json:"signatures"`
+	}
+	err := p.mintRequest(ctx, "POST", "/v1/mint/bolt11",
+		map[string]any{"quote": pm.Quote, "outputs": messages(pm.Outputs)}, &resp)
+	if err != nil {
+		return fmt.Errorf("mint tokens: %w", err)
+	}
+	proofs, err := unblind(pm.Outputs, resp.Signatures, ks)
+	if err != nil {
+		return err
+	}
+
+	w.add(pm.Mint, pm.Unit, proofs)
+	w.dropPending(pm.Quote)
+	return p.save(w)
+}
+
+// resumePending claims quotes that were paid after an earlier mint attempt
+// gave up. Quotes the mint has issued or let expire unpaid are dropped; any
+// other entry is kept for the next load.
+func (p *CashuProvider) resumePending(ctx context.Context, w *cashuWallet) {
+	changed := false
+	for _, pm := range append([]pendingMint(nil), w.Pending...) {
+		if pm.Mint != p.mintURL || len(pm.Outputs) == 0 {
+			continue
+		}
+		state, err := p.quoteState(ctx, pm.Quote)
+		switch {
+		case err != nil:
+		case state == "PAID":
+			if ks, err := p.keysetKeys(ctx, pm.Outputs[0].Message.ID, nil); err == nil {
+				p.claim(ctx, w, pm, ks)
+			}
+		case state == "ISSUED", state == "UNPAID" && time.Now().Unix() > pm.Expiry:
+			w.dropPending(pm.Quote)
+			changed = true
+		}
+	}
+	if changed {
+		p.save(w)
+	}
+}
+
+// quoteState asks the mint for a mint quote's state.
+func (p *CashuProvider) quoteState(ctx context.Context, quoteID string) (string, error) {
+	var q struct {
+		State string `json:"state"`
+		Paid  bool   `json:"paid"` // pre-state mints
+	}
+	if err := p.mintRequest(ctx, "GET", "/v1/mint/quote/bolt11/"+quoteID, nil, &q); err != nil {
+		return "", fmt.Errorf("check mint quote: %w", err)
+	}
+	if q.State == "" && q.Paid {
+		return "PAID", nil
+	}
+	return q.State, nil
+}
+
+// waitPaid polls a mint quote until the mint has seen the Lightning payment.
+func (p *CashuProvider) waitPaid(ctx context.Context, quoteID string) error {
+	for attempt := 0; attempt < 30; attempt++ {
+		state, err := p.quoteState(ctx, quoteID)
+		if err != nil {
+			return err
+		}
+		if state == "PAID" || state == "ISSUED" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.QuotePollInterval):
+		}
+	}
+	return fmt.Errorf("mint quote %s not paid", quoteID)
+}
+
+// swap exchanges inputs for proofs worth exactly amount plus change (NUT-03).
+func (p *CashuProvider) swap(ctx context.Context, inputs []cashu.Proof, amount uint64, unit string, keysets map[string]cashuKeyset) (send, change []cashu.Proof, err error) {
+	ks, err := p.activeKeyset(ctx, unit)
+	if err != nil {
+		return nil, nil, err
+	}
+	fee := inputFee(inputs, keysets)
+	total := cashu.Sum(inputs)
+	if total < amount+fee {
+		return nil, nil, fmt.Errorf("%w: swap needs %d %s plus %d fee", router.ErrInsufficientBalance, amount, unit, fee)
+	}
+
+	sendAmounts := cashu.SplitAmount(amount)
+	outputs, err := newOutputs(append(sendAmounts, cashu.SplitAmount(total-amount-fee)...), ks.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resp struct {
+		Signatures []cashu.BlindSignature `json:"signatures"`
+	}
+	err = p.mintRequest(ctx, "POST", "/v1/swap",
+		map[string]any{"inputs": inputs, "outputs": messages(outputs)}, &resp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("swap proofs: %w", err)
+	}
+	proofs, err := unblind(outputs, resp.Signatures, ks)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proofs[:len(sendAmounts)], proofs[len(sendAmounts):], nil
+}
+
+// selectProofs picks proofs to pay amount. It returns exact=true when the
+// picked proofs add up to amount and can be sent as they are, otherwise a
+// set worth at least amount plus the swap fee. It returns nil if the wallet
+// cannot cover the payment.
+func selectProofs(have []cashu.Proof, amount uint64, keysets map[string]cashuKeyset) (picked []cashu.Proof, exact bool) {
+	sorted := append([]cashu.Proof(nil), have...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Amount > sorted[j].Amount })
+
+	// Largest-first finds an exact match whenever the wallet holds the
+	// power-of-two denominations of amount.
+	remaining := amount
+	for _, pr := range sorted {
+		if pr.Amount <= remaining {
+			picked = append(picked, pr)
+			remaining -= pr.Amount
+		}
+	}
+	if remaining == 0 {
+		return picked, true
+	}
+
+	// Otherwise take smallest-first until the total covers amount and fees,
+	// keeping the swap's input count low only as a secondary concern.
+	picked = nil
+	for i := len(sorted) - 1; i >= 0; i-- {
+		picked = append(picked, sorted[i])
+		if cashu.Sum(picked) >= amount+inputFee(picked, keysets) {
+			return picked, false
+		}
+	}
+	return nil, false
+}
+
+// inputFee is the NUT-02 fee for spending proofs: the keysets' per-proof
+// fees in parts per thousand, rounded up.
+func inputFee(proofs []cashu.Proof, keysets map[string]cashuKeyset) uint64 {
+	var ppk uint64
+	for _, pr := range proofs {
+		ppk += keysets[pr.ID].InputFeePPK
+	}
+	return (ppk + 999) / 1000
+}
+
+func maxFeePPK(keysets map[string]cashuKeyset) uint64 {
+	var max uint64
+	for _, ks := range keysets {
+		if ks.InputFeePPK > max {
+			max = ks.InputFeePPK
+		}
+	}
+	return max
+}
+
+func newOutputs(amounts []uint64, keysetID string) ([]*cashu.Output, error) {
+	outputs := make([]*cashu.Output, len(amounts))
+	for i, a := range amounts {
+		o, err := cashu.NewOutput(a, keysetID)
+		if err != nil {
+			return nil, fmt.Errorf("blind output: %w", err)
+		}
+		outputs[i] = o
+	}
+	return outputs, nil
+}
+
+func messages(outputs []*cashu.Output) []cashu.BlindedMessage {
+	msgs := make([]cashu.BlindedMessage, len(outputs))
+	for i, o := range outputs {
+		msgs[i] = o.Message
+	}
+	return msgs
+}
+
+func unblind(outputs []*cashu.Output, sigs []cashu.BlindSignature, ks *cashuKeyset) ([]cashu.Proof, error) {
+	if len(sigs) != len(outputs) {
+		return nil, fmt.Errorf("mint returned %d signatures for %d outputs", len(sigs), len(outputs))
+	}
+	proofs := make([]cashu.Proof, len(sigs))
+	for i, sig := range sigs {
+		key, ok := ks.Keys[fmt.Sprint(sig.Amount)]
+		if !ok {
+			return nil, fmt.Errorf("mint keyset %s has no key for amount %d", ks.ID, sig.Amount)
+		}
+		proof, err := outputs[i].Unblind(sig, key)
+		if err != nil {
+			return nil, err
+		}
+		proofs[i] = proof
+	}
+	return proofs, nil
+}
+
+// cashuKeyset is a mint keyset (NUT-01/NUT-02). Keys is only filled by
+// keysetKeys.
+type cashuKeyset struct {
+	ID          string            `json:"id"`
+	Unit        string            `json:"unit"`
+	Active      bool              `json:"active"`
+	InputFeePPK uint64            `json:"input_fee_ppk"`
+	Keys        map[string]string `json:"keys,omitempty"`
+}
+
+// keysets lists the mint's keysets by ID.
+func (p *CashuProvider) keysets(ctx context.Context) (map[string]cashuKeyset, error) {
+	var resp struct {
+		Keysets []cashuKeyset `json:"keysets"`
+	}
+	if err := p.mintRequest(ctx, "GET", "/v1/keysets", nil, &resp); err != nil {
+		return nil, fmt.Errorf("mint keysets: %w", err)
+	}
+	out := make(map[string]cashuKeyset, len(resp.Keysets))
+	for _, ks := range resp.Keysets {
+		out[ks.ID] = ks
+	}
+	return out, nil
+}
+
+// activeKeyset returns the mint's active keyset for unit with its public keys.
+func (p *CashuProvider) activeKeyset(ctx context.Context, unit string) (*cashuKeyset, error) {
+	keysets, err := p.keysets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var id string
+	for _, ks := range keysets {
+		// Prefer hex keyset IDs; legacy base64 IDs cannot be put in V4 tokens
+		if ks.Active && ks.Unit == unit && strings.HasPrefix(ks.ID, "00") {
+			id = ks.ID
+			break
+		}
+	}
+	if id == "" {
+		return nil, fmt.Errorf("mint %s has no active keyset for unit %q", p.mintURL, unit)
+	}
+	return p.keysetKeys(ctx, id, keysets)
+}
+
+// keysetKeys fetches the public keys of keyset id. keysets, if given, supplies
+// its input fee.
+func (p *CashuProvider) keysetKeys(ctx context.Context, id string, keysets map[string]cashuKeyset) (*cashuKeyset, error) {
+	var resp struct {
+		Keysets []cashuKeyset `json:"keysets"`
+	}
+	if err := p.mintRequest(ctx, "GET", "/v1/keys/"+id, nil, &resp); err != nil {
+		return nil, fmt.Errorf("mint keys: %w", err)
+	}
+	for _, ks := range resp.Keysets {
+		if ks.ID == id {
+			ks.InputFeePPK = keysets[id].InputFeePPK
+			return &ks, nil
+		}
+	}
+	return nil, fmt.Errorf("mint did not return keys for keyset %s", id)
+}
+
+// mintRequest calls the mint's REST API and decodes the JSON response into out.
+func (p *CashuProvider) mintRequest(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.mintURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("mint request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		return statusError("mint %s %s: HTTP %d: %s", resp.StatusCode, respBody, method, path)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("parse mint response: %w", err)
+	}
+	return nil
+}
+
+// cashuWallet is the on-disk wallet: unspent proofs tagged with their mint
+// and unit.
+type cashuWallet struct {
+	Proofs  []walletProof `json:"proofs"`
+	Pending []pendingMint `json:"pending,omitempty"`
+}
+
+// pendingMint is a mint quote whose invoice may have been paid, with the
+// blinded outputs that claim it.
+type pendingMint struct {
+	Mint    string          `json:"mint"`
+	Unit    string          `json:"unit"`
+	Quote   string          `json:"quote"`
+	Expiry  int64           `json:"expiry"`
+	Outputs []*cashu.Output `json:"outputs"`
+}
+
+type walletProof struct {
+	Mint string `json:"mint"`
+	Unit string `json:"unit"`
+	cashu.Proof
+}
+
+func (w *cashuWallet) proofs(mint, unit string) []cashu.Proof {
+	var out []cashu.Proof
+	for _, wp := range w.Proofs {
+		if wp.Mint == mint && wp.Unit == unit {
+			out = append(out, wp.Proof)
+		}
+	}
+	return out
+}
+
+func (w *cashuWallet) add(mint, unit string, proofs []cashu.Proof) {
+	for _, pr := range proofs {
+		w.Proofs = append(w.Proofs, walletProof{Mint: mint, Unit: unit, Proof: pr})
+	}
+}
+
+func (w *cashuWallet) remove(proofs []cashu.Proof) {
+	spent := make(map[string]bool, len(proofs))
+	for _, pr := range proofs {
+		spent[pr.Secret] = true
+	}
+	kept := w.Proofs[:0]
+	for _, wp := range w.Proofs {
+		if !spent[wp.Secret] {
+			kept = append(kept, wp)
+		}
+	}
+	w.Proofs = kept
+}
+
+func (w *cashuWallet) dropPending(quote string) {
+	kept := w.Pending[:0]
+	for _, pm := range w.Pending {
+		if pm.Quote != quote {
+			kept = append(kept, pm)
+		}
+	}
+	w.Pending = kept
+}
+
+// load reads the wallet file. A missing file is an empty wallet.
+func (p *CashuProvider) load() (*cashuWallet, error) {
+	data, err := os.ReadFile(p.walletPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &cashuWallet{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read Cashu wallet: %w", err)
+	}
+	var w cashuWallet
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("parse Cashu wallet %s: %w", p.walletPath, err)
+	}
+	return &w, nil
+}
+
+// save writes the wallet atomically so a crash never leaves half a file of
+// bearer tokens.
+func (p *CashuProvider) save(w *cashuWallet) error {
+	if err := os.MkdirAll(filepath.Dir(p.walletPath), 0700); err != nil {
+		return fmt.Errorf("create wallet dir: %w", err)
+	}
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal Cashu wallet: %w", err)
+	}
+	tmp := p.walletPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write Cashu wallet: %w", err)
+	}
+	if err := os.Rename(tmp, p.walletPath); err != nil {
+		return fmt.Errorf("write Cashu wallet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package providers
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/joelklabo/agentpay/internal/cashu"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+	"github.com/joelklabo/agentpay/router"
+)
+
+const testKeysetID = "00ffd48b8f5ecf80"
+
+// testMint is a stand-in Cashu mint implementing the NUT-01..04 endpoints
+// the provider uses.
+type testMint struct {
+	*httptest.Server
+	feePPK uint64
+
+	mu     sync.Mutex
+	keys   map[uint64]*big.Int
+	quotes map[string]*testQuote
+	spent  map[string]bool
+	swaps  int
+}
+
+type testQuote struct {
+	amount  uint64
+	invoice string
+	paid    bool
+	issued  bool
+}
+
+func newTestMint(t *testing.T) *testMint {
+	t.Helper()
+	m := &testMint{
+		keys:   map[uint64]*big.Int{},
+		quotes: map[string]*testQuote{},
+		spent:  map[string]bool{},
+	}
+	for i := uint64(0); i < 16; i++ {
+		m.keys[1<<i] = big.NewInt(int64(1000 + i))
+	}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.handle))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *testMint) handle(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fail := func(msg string) {
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"detail": msg})
+	}
+
+	switch {
+	case r.URL.Path == "/v1/keysets":
+		json.NewEncoder(w).Encode(map[string]any{"keysets": []map[string]any{
+			{"id": testKeysetID, "unit": "sat", "active": true, "input_fee_ppk": m.feePPK},
+		}})
+
+	case r.URL.Path == "/v1/keys/"+testKeysetID:
+		keys := map[string]string{}
+		for amt, k := range m.keys {
+			keys[fmt.Sprint(amt)] = hex.EncodeToString(secp256k1.ScalarBaseMult(k).Compressed())
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keysets": []map[string]any{
+			{"id": testKeysetID, "unit": "sat", "keys": keys},
+		}})
+
+	case r.URL.Path == "/v1/mint/quote/bolt11" && r.Method == "POST":
+		var req struct {
+			Amount uint64 `json:"amount"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		id := fmt.Sprintf("q%d", len(m.quotes)+1)
+		m.quotes[id] = &testQuote{amount: req.Amount, invoice: fmt.Sprintf("lnbc%dn1p%s", req.Amount*10, id)}
+		json.NewEncoder(w).Encode(map[string]any{"quote": id, "request": m.quotes[id].invoice, "state": "UNPAID"})
+
+	case strings.HasPrefix(r.URL.Path, "/v1/mint/quote/bolt11/"):
+		q := m.quotes[strings.TrimPrefix(r.URL.Path, "/v1/mint/quote/bolt11/")]
+		if q == nil {
+			fail("unknown quote")
+			return
+		}
+		state := "UNPAID"
+		switch {
+		case q.issued:
+			state = "ISSUED"
+		case q.paid:
+			state = "PAID"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"state": state})
+
+	case r.URL.Path == "/v1/mint/bolt11":
+		var req struct {
+			Quote   string                 `json:"quote"`
+			Outputs []cashu.BlindedMessage `json:"outputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		q := m.quotes[req.Quote]
+		if q == nil || !q.paid || q.issued {
+			fail("quote not paid")
+			return
+		}
+		if sumOutputs(req.Outputs) != q.amount {
+			fail("outputs do not match quote")
+			return
+		}
+		q.issued = true
+		m.sign(w, req.Outputs)
+
+	case r.URL.Path == "/v1/swap":
+		m.swaps++
+		var req struct {
+			Inputs  []cashu.Proof          `json:"inputs"`
+			Outputs []cashu.BlindedMessage `json:"outputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if err := m.spend(req.Inputs); err != nil {
+			fail(err.Error())
+			return
+		}
+		fee := (m.feePPK*uint64(len(req.Inputs)) + 999) / 1000
+		if cashu.Sum(req.Inputs)-fee != sumOutputs(req.Outputs) {
+			fail("inputs and outputs are unbalanced")
+			return
+		}
+		m.sign(w, req.Outputs)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *testMint) sign(w http.ResponseWriter, outputs []cashu.BlindedMessage) {
+	sigs := make([]cashu.BlindSignature, len(outputs))
+	for i, o := range outputs {
+		c, _ := cashu.SignBlinded(o.B, m.keys[o.Amount])
+		sigs[i] = cashu.BlindSignature{Amount: o.Amount, ID: o.ID, C: c}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"signatures": sigs})
+}
+
+// spend verifies and marks proofs spent. Callers hold m.mu.
+func (m *testMint) spend(proofs []cashu.Proof) error {
+	for _, p := range proofs {
+		if m.spent[p.Secret] {
+			return errors.New("proof already spent")
+		}
+		if !cashu.Verify(p, m.keys[p.Amount]) {
+			return errors.New("invalid proof")
+		}
+	}
+	for _, p := range proofs {
+		m.spent[p.Secret] = true
+	}
+	return nil
+}
+
+// redeem is what a paid API would do with a received token.
+func (m *testMint) redeem(token string) (uint64, error) {
+	tok, err := cashu.DecodeToken(token)
+	if err != nil {
+		return 0, err
+	}
+	if tok.Mint != m.URL {
+		return 0, fmt.Errorf("token from %s", tok.Mint)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.spend(tok.Proofs); err != nil {
+		return 0, err
+	}
+	return cashu.Sum(tok.Proofs), nil
+}
+
+func sumOutputs(outputs []cashu.BlindedMessage) uint64 {
+	var total uint64
+	for _, o := range outputs {
+		total += o.Amount
+	}
+	return total
+}
+
+// mintPayer pays the test mint's invoices.
+type mintPayer struct {
+	mint     *testMint
+	invoices []string
+}
+
+func (p *mintPayer) PayInvoice(ctx context.Context, bolt11 string) (string, error) {
+	p.mint.mu.Lock()
+	defer p.mint.mu.Unlock()
+	for _, q := range p.mint.quotes {
+		if q.invoice == bolt11 {
+			q.paid = true
+			p.invoices = append(p.invoices, bolt11)
+			return "hash", nil
+		}
+	}
+	return "", errors.New("unknown invoice")
+}
+
+func cashuRequirement(t *testing.T, amount int64, mints ...string) *router.PaymentRequirement {
+	t.Helper()
+	cr := &router.CashuRequest{Amount: amount, Unit: "sat", Mints: mints}
+	encoded, err := cr.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &router.PaymentRequirement{Protocol: router.ProtocolCashu, Raw: encoded, Details: cr}
+}
+
+func TestCashuProvider_MintsAndPays(t *testing.T) {
+	mint := newTestMint(t)
+	payer := &mintPayer{mint: mint}
+	p := NewCashuProvider(mint.URL, filepath.Join(t.TempDir(), "cashu.json"))
+	p.Payer = payer
+
+	if err := p.Mint(context.Background(), 20, "sat"); err != nil {
+		t.Fatalf("mint: %v", err)
+	}
+	if bal, _ := p.WalletBalance("sat"); bal != 20 {
+		t.Fatalf("expected 20 sat after minting, got %d", bal)
+	}
+
+	// 20 = 16+4, so 13 needs a swap into 8+4+1 plus 4+2+1 change
+	header, token, err := p.Pay(context.Background(), cashuRequirement(t, 13, mint.URL))
+	if err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	if header != "X-Cashu" || !strings.HasPrefix(token, "cashuB") {
+		t.Errorf("expected an X-Cashu V4 token, got %s: %s", header, token)
+	}
+	if got, err := mint.redeem(token); err != nil || got != 13 {
+		t.Errorf("redeem: got %d, %v; want 13", got, err)
+	}
+	if mint.swaps != 1 {
+		t.Errorf("expected 1 swap, got %d", mint.swaps)
+	}
+
+	// The change covers 7 exactly, so no swap is needed
+	_, token, err = p.Pay(context.Background(), cashuRequirement(t, 7, mint.URL))
+	if err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	if got, err := mint.redeem(token); err != nil || got != 7 {
+		t.Errorf("redeem: got %d, %v; want 7", got, err)
+	}
+	if mint.swaps != 1 {
+		t.Errorf("exact payment should not swap, got %d swaps", mint.swaps)
+	}
+	if bal, _ := p.WalletBalance("sat"); bal != 0 {
+		t.Errorf("expected an empty wallet, got %d", bal)
+	}
+
+	// An empty wallet tops itself up over Lightning
+	_, token, err = p.Pay(context.Background(), cashuRequirement(t, 5, mint.URL))
+	if err != nil {
+		t.Fatalf("pay with top-up: %v", err)
+	}
+	if got, err := mint.redeem(token); err != nil || got != 5 {
+		t.Errorf("redeem: got %d, %v; want 5", got, err)
+	}
+	if len(payer.invoices) != 2 {
+		t.Errorf("expected a second mint invoice for the top-up, got %d", len(payer.invoices))
+	}
+}
+
+func TestCashuProvider_Fees(t *testing.T) {
+	mint := newTestMint(t)
+	mint.feePPK = 100 // 0.1 sat per input, rounded up per swap
+	p := NewCashuProvider(mint.URL, filepath.Join(t.TempDir(), "cashu.json"))
+	p.Payer = &mintPayer{mint: mint}
+
+	_, token, err := p.Pay(context.Background(), cashuRequirement(t, 10, mint.URL))
+	if err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	if got, err := mint.redeem(token); err != nil || got != 10 {
+		t.Errorf("redeem: got %d, %v; want 10", got, err)
+	}
+}
+
+func TestCashuProvider_InsufficientWithoutPayer(t *testing.T) {
+	mint := newTestMint(t)
+	p := NewCashuProvider(mint.URL, filepath.Join(t.TempDir(), "cashu.json"))
+
+	_, _, err := p.Pay(context.Background(), cashuRequirement(t, 5, mint.URL))
+	if !errors.Is(err, router.ErrInsufficientBalance) {
+		t.Errorf("expected ErrInsufficientBalance, got %v", err)
+	}
+}
+
+func TestCashuProvider_RejectsOtherMints(t *testing.T) {
+	p := NewCashuProvider("https://mint.ours.example", filepath.Join(t.TempDir(), "cashu.json"))
+
+	if _, _, err := p.EstimateCost(cashuRequirement(t, 5, "https://mint.theirs.example")); err == nil {
+		t.Error("expected an error for a mint the server does not accept")
+	}
+
+	usd, desc, err := p.EstimateCost(cashuRequirement(t, 100, "https://mint.ours.example/"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usd.Cmp(router.FromUSD(0.1)) != 0 {
+		t.Errorf("100 sat at $100K/BTC should cost $0.10, got %s", usd)
+	}
+	if !strings.Contains(desc, "100 sat") {
+		t.Errorf("unexpected description %q", desc)
+	}
+}
+
+func TestCashuProvider_RouterFetch(t *testing.T) {
+	mint := newTestMint(t)
+	cr := &router.CashuRequest{Amount: 21, Unit: "sat", Mints: []string{mint.URL}}
+	challenge, _ := cr.Encode()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get("X-Cashu"); token != "" {
+			if got, err := mint.redeem(token); err != nil || got < 21 {
+				http.Error(w, "bad token", 400)
+				return
+			}
+			w.Write([]byte(`{"data":"paid content"}`))
+			return
+		}
+		w.Header().Set("X-Cashu", challenge)
+		w.WriteHeader(402)
+	}))
+	defer api.Close()
+
+	p := NewCashuProvider(mint.URL, filepath.Join(t.TempDir(), "cashu.json"))
+	p.Payer = &mintPayer{mint: mint}
+
+	r := router.New(router.Config{MaxPerRequestUSD: 1.0})
+	r.RegisterDetector(router.CashuDetector{})
+	r.RegisterProvider(p)
+
+	body, receipt, err := r.Fetch(context.Background(), "GET", api.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if string(body) != `{"data":"paid content"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+	if receipt.Protocol != "cashu" || receipt.Rail != "cashu" {
+		t.Errorf("expected a cashu receipt, got %s on %s", receipt.Protocol, receipt.Rail)
+	}
+}
+
+// crashingPayer pays the invoice but reports failure, as when the process
+// dies or times out while the payment is in flight.
+type crashingPayer struct {
+	mintPayer
+	walletPath string
+	saved      []byte
+}
+
+func (p *crashingPayer) PayInvoice(ctx context.Context, bolt11 string) (string, error) {
+	p.saved, _ = os.ReadFile(p.walletPath)
+	p.mintPayer.PayInvoice(ctx, bolt11)
+	return "", context.DeadlineExceeded
+}
+
+func TestCashuProvider_ResumesPaidQuote(t *testing.T) {
+	mint := newTestMint(t)
+	path := filepath.Join(t.TempDir(), "cashu.json")
+	p := NewCashuProvider(mint.URL, path)
+	payer := &crashingPayer{mintPayer: mintPayer{mint: mint}, walletPath: path}
+	p.Payer = payer
+
+	if err := p.Mint(context.Background(), 20, "sat"); err == nil {
+		t.Fatal("expected the payment error")
+	}
+	if !strings.Contains(string(payer.saved), `"quote": "q1"`) {
+		t.Fatalf("quote was not saved before paying: %s", payer.saved)
+	}
+
+	// A fresh provider claims the paid quote on its next load
+	p = NewCashuProvider(mint.URL, path)
+	p.Payer = &mintPayer{mint: mint}
+	if err := p.Mint(context.Background(), 4, "sat"); err != nil {
+		t.Fatalf("mint: %v", err)
+	}
+	if bal, _ := p.WalletBalance("sat"); bal != 24 {
+		t.Errorf("expected 24 sat after resuming, got %d", bal)
+	}
+	w, err := p.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Pending) != 0 {
+		t.Errorf("expected no pending quotes, got %d", len(w.Pending))
+	}
+}
@@ -0,0 +1,248 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+// CLNBackend pays invoices from a Core Lightning node through the CLNRest
+// plugin, authenticated with a rune.
+type CLNBackend struct {
+	url    string
+	rune   string
+	client *http.Client
+
+	// MaxFeePercent caps the routing fee as a percentage of the amount.
+	// Zero leaves CLN's default (0.5%).
+	MaxFeePercent float64
+	// RetryFor bounds how long CLN keeps retrying routes.
+	RetryFor time.Duration
+}
+
+// NewCLNBackend creates a backend for the CLNRest endpoint at url (e.g.
+// "https://localhost:3010"). tlsCertPEM is the plugin's ca.pem; when empty
+// the system roots are used.
+func NewCLNBackend(url, accessRune string, tlsCertPEM []byte) (*CLNBackend, error) {
+	client, err := pinnedTLSClient(tlsCertPEM)
+	if err != nil {
+		return nil, fmt.Errorf("CLN TLS certificate: %w", err)
+	}
+	return &CLNBackend{
+		url:      strings.TrimRight(url, "/"),
+		rune:     accessRune,
+		client:   client,
+		RetryFor: 60 * time.Second,
+	}, nil
+}
+
+// LoadCLNBackend is NewCLNBackend with the CA certificate read from tlsCertPath.
+func LoadCLNBackend(url, accessRune, tlsCertPath string) (*CLNBackend, error) {
+	var cert []byte
+	if tlsCertPath != "" {
+		var err error
+		if cert, err = os.ReadFile(tlsCertPath); err != nil {
+			return nil, fmt.Errorf("read TLS certificate: %w", err)
+		}
+	}
+	return NewCLNBackend(url, accessRune, cert)
+}
+
+// clnMsat decodes CLN msat amounts, which are integers in current releases
+// and "1000msat" strings in older ones.
+type clnMsat int64
+
+func (m *clnMsat) UnmarshalJSON(b []byte) error {
+	s := strings.TrimSuffix(strings.Trim(string(b), `"`), "msat")
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid msat amount %s", b)
+	}
+	*m = clnMsat(n)
+	return nil
+}
+
+// clnErrorsWorthRetrying are pay error codes another wallet might not hit:
+// no route (205), route too expensive (206) and gave up retrying (210).
+var clnErrorsWorthRetrying = map[int]bool{205: true, 206: true, 210: true}
+
+// call invokes a CLN RPC method through CLNRest and decodes the result.
+func (b *CLNBackend) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal %s request: %w", method, err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.url+"/v1/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build %s request: %w", method, err)
+	}
+	httpReq.Header.Set("Rune", b.rune)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		return requestError("CLN %s request failed: %w", err, method)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		// RPC failures come back as HTTP 500 with the JSON-RPC error
+		var rpcErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &rpcErr) == nil && rpcErr.Message != "" {
+			err := fmt.Errorf("CLN %s: %s (code %d)", method, rpcErr.Message, rpcErr.Code)
+			if clnErrorsWorthRetrying[rpcErr.Code] {
+				err = router.Retryable(err)
+			}
+			return err
+		}
+		if method == "pay" {
+			return submitError("CLN pay HTTP %d: %s", resp.StatusCode, respBody)
+		}
+		return statusError("CLN "+method+" HTTP %d: %s", resp.StatusCode, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("parse %s response: %w", method, err)
+	}
+	return nil
+}
+
+// PayInvoice implements LightningBackend with CLN's pay command, which
+// blocks until the payment completes or fails.
+func (b *CLNBackend) PayInvoice(ctx context.Context, bolt11 string) (*LightningPayment, error) {
+	params := map[string]interface{}{"bolt11": bolt11}
+	if b.MaxFeePercent > 0 {
+		params["maxfeepercent"] = b.MaxFeePercent
+	}
+	if b.RetryFor > 0 {
+		params["retry_for"] = int(b.RetryFor.Seconds())
+		// Leave CLN time to report the failure after it stops retrying
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.RetryFor+10*time.Second)
+		defer cancel()
+	}
+
+	var result struct {
+		PaymentHash     string  `json:"payment_hash"`
+		PaymentPreimage string  `json:"payment_preimage"`
+		AmountMsat      clnMsat `json:"amount_msat"`
+		AmountSentMsat  clnMsat `json:"amount_sent_msat"`
+		Status          string  `json:"status"`
+	}
+	if err := b.call(ctx, "pay", params, &result); err != nil {
+		return nil, err
+	}
+	if result.Status != "complete" {
+		// A pending payment may still settle; paying again elsewhere could pay twice
+		return nil, fmt.Errorf("CLN payment %s is %s", result.PaymentHash, result.Status)
+	}
+
+	return &LightningPayment{
+		PaymentHash: result.PaymentHash,
+		Preimage:    result.PaymentPreimage,
+		Fee:         router.NewAmount(int64(result.AmountSentMsat-result.AmountMsat), router.Msat),
+	}, nil
+}
+
+// PayOffer implements OfferPayer: fetchinvoice asks the issuer for an
+// invoice over onion messages, and pay settles it like a BOLT11 invoice.
+func (b *CLNBackend) PayOffer(ctx context.Context, offer string, amountMsat int64) (*LightningPayment, error) {
+	params := map[string]interface{}{"offer": offer}
+	if amountMsat > 0 {
+		params["amount_msat"] = amountMsat
+	}
+	var result struct {
+		Invoice string `json:"invoice"`
+		Changes struct {
+			AmountMsat *clnMsat `json:"amount_msat"`
+		} `json:"changes"`
+	}
+	if err := b.call(ctx, "fetchinvoice", params, &result); err != nil {
+		// Nothing has been paid yet, so another wallet can safely try
+		return nil, router.Retryable(err)
+	}
+	if result.Changes.AmountMsat != nil {
+		return nil, fmt.Errorf("offer issuer asked for %d msat instead of the quoted amount", int64(*result.Changes.AmountMsat))
+	}
+	return b.PayInvoice(ctx, result.Invoice)
+}
+
+// CreateInvoice implements Invoicer. CLN requires a unique label per
+// invoice, so each gets a random one.
+func (b *CLNBackend) CreateInvoice(ctx context.Context, amountMsat int64, description string) (*LightningInvoice, error) {
+	label := make([]byte, 16)
+	rand.Read(label)
+	var result struct {
+		Bolt11      string `json:"bolt11"`
+		PaymentHash string `json:"payment_hash"`
+	}
+	err := b.call(ctx, "invoice", map[string]interface{}{
+		"amount_msat": amountMsat,
+		"label":       "agentpay-" + hex.EncodeToString(label),
+		"description": description,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &LightningInvoice{Bolt11: result.Bolt11, PaymentHash: result.PaymentHash}, nil
+}
+
+// Balance implements LightningBackend with the outbound liquidity of the
+// node's active channels.
+func (b *CLNBackend) Balance(ctx context.Context) (router.Amount, error) {
+	var result struct {
+		Channels []struct {
+			State         string  `json:"state"`
+			PeerConnected bool    `json:"peer_connected"`
+			SpendableMsat clnMsat `json:"spendable_msat"`
+		} `json:"channels"`
+	}
+	if err := b.call(ctx, "listpeerchannels", map[string]interface{}{}, &result); err != nil {
+		return router.Amount{}, err
+	}
+
+	var total int64
+	for _, ch := range result.Channels {
+		if ch.State == "CHANNELD_NORMAL" && ch.PeerConnected {
+			total += int64(ch.SpendableMsat)
+		}
+	}
+	return router.NewAmount(total, router.Msat), nil
+}
+
+// OnchainBalance returns the node's confirmed on-chain funds in msat.
+func (b *CLNBackend) OnchainBalance(ctx context.Context) (router.Amount, error) {
+	var result struct {
+		Outputs []struct {
+			AmountMsat clnMsat `json:"amount_msat"`
+			Status     string  `json:"status"`
+			Reserved   bool    `json:"reserved"`
+		} `json:"outputs"`
+	}
+	if err := b.call(ctx, "listfunds", map[string]interface{}{}, &result); err != nil {
+		return router.Amount{}, err
+	}
+
+	var total int64
+	for _, out := range result.Outputs {
+		if out.Status == "confirmed" && !out.Reserved {
+			total += int64(out.AmountMsat)
+		}
+	}
+	return router.NewAmount(total, router.Msat), nil
+}
@@ -0,0 +1,235 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+const testRune = "tU-RLjMiDpY2U0o3W1oFowar36RFGpWloPbW9-RuZdo9MyZpZD0wMjRiOWExZmE4ZTAwNmYxZTM5MzdmNjVmNjZjNDA4ZTZkYThlMWNhNzI4ZWE0MzIyMmE3MzgxZGYxY2M0NDk2MDUmbWV0aG9kPWxpc3RwZWVycw=="
+
+// newCLNStandIn serves CLNRest over TLS. pay handles /v1/pay; fetchinvoice
+// answers testOffer with a fixed invoice and the balance routes return fixed
+// funds.
+func newCLNStandIn(t *testing.T, pay http.HandlerFunc) *CLNBackend {
+	t.Helper()
+	mux := http.NewServeMux()
+	if pay != nil {
+		mux.HandleFunc("/v1/pay", pay)
+	}
+	mux.HandleFunc("/v1/invoice", func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			AmountMsat  int64  `json:"amount_msat"`
+			Label       string `json:"label"`
+			Description string `json:"description"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		if params.AmountMsat != 21000 || !strings.HasPrefix(params.Label, "agentpay-") || params.Description != "answer" {
+			t.Errorf("invoice params = %+v", params)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"payment_hash":"` + testPayHash + `","expires_at":1700003600,"bolt11":"lnbc210n1ptest"}`))
+	})
+	mux.HandleFunc("/v1/fetchinvoice", func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Offer      string `json:"offer"`
+			AmountMsat int64  `json:"amount_msat"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		switch {
+		case params.Offer != testOffer:
+			http.Error(w, `{"code":1005,"message":"Timeout waiting for response"}`, http.StatusInternalServerError)
+		case params.AmountMsat == 21000:
+			w.Write([]byte(`{"invoice":"lni1qtest","changes":{}}`))
+		default:
+			w.Write([]byte(`{"invoice":"lni1qtest","changes":{"amount_msat":25000}}`))
+		}
+	})
+	mux.HandleFunc("/v1/listpeerchannels", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"channels":[
+			{"state":"CHANNELD_NORMAL","peer_connected":true,"spendable_msat":3000000},
+			{"state":"CHANNELD_NORMAL","peer_connected":true,"spendable_msat":"2000000msat"},
+			{"state":"CHANNELD_NORMAL","peer_connected":false,"spendable_msat":9000000},
+			{"state":"CHANNELD_AWAITING_LOCKIN","peer_connected":true,"spendable_msat":9000000}
+		]}`))
+	})
+	mux.HandleFunc("/v1/listfunds", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"outputs":[
+			{"amount_msat":100000000,"status":"confirmed"},
+			{"amount_msat":50000000,"status":"unconfirmed"},
+			{"amount_msat":70000000,"status":"confirmed","reserved":true}
+		],"channels":[]}`))
+	})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("%s %s: CLNRest methods are POST", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Rune") != testRune {
+			http.Error(w, `{"code":1501,"message":"Not authorized: Not derived from master"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	b, err := NewCLNBackend(srv.URL, testRune, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCLNBackend_Pay(t *testing.T) {
+	b := newCLNStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Bolt11        string  `json:"bolt11"`
+			MaxFeePercent float64 `json:"maxfeepercent"`
+			RetryFor      int     `json:"retry_for"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		if params.Bolt11 != "lnbc20u1ptest" || params.MaxFeePercent != 0.25 || params.RetryFor != 30 {
+			t.Errorf("params = %+v", params)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"destination":"02abc","payment_hash":"` + testPayHash + `","created_at":1700000000.0,"parts":1,
+			"amount_msat":2000000,"amount_sent_msat":2001200,"payment_preimage":"` + testPreimage + `","status":"complete"}`))
+	})
+	b.MaxFeePercent = 0.25
+	b.RetryFor = 30 * time.Second
+
+	p := NewL402ProviderWithBackend(b)
+	s, err := p.Settle(context.Background(), &router.PaymentRequirement{
+		Protocol:    router.ProtocolL402,
+		L402Invoice: "lnbc20u1ptest",
+		L402Hash:    "mac",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.HeaderValue != "L402 mac:"+testPreimage || s.TxID != testPayHash {
+		t.Errorf("settlement = %+v", s)
+	}
+	if s.Fee == nil || *s.Fee != router.NewAmount(1200, router.Msat) {
+		t.Errorf("fee = %v, want 1200 msat", s.Fee)
+	}
+}
+
+func TestCLNBackend_PayErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		retryable bool
+	}{
+		{"no route", 500, `{"code":205,"message":"Could not find a route"}`, true},
+		{"expired", 500, `{"code":207,"message":"Invoice expired"}`, false},
+		{"pending", 201, `{"payment_hash":"` + testPayHash + `","amount_msat":1000,"amount_sent_msat":1000,"status":"pending"}`, false},
+		// A gateway error may come after the HTLC left, so it is final
+		{"proxy error", 502, `bad gateway`, false},
+		{"rate limited", 429, `slow down`, true},
+	}
+	for _, tt := range tests {
+		b := newCLNStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		})
+		_, err := b.PayInvoice(context.Background(), "lnbc10n1ptest")
+		if err == nil {
+			t.Fatalf("%s: expected an error", tt.name)
+		}
+		if router.IsRetryable(err) != tt.retryable {
+			t.Errorf("%s: retryable = %v, want %v (%v)", tt.name, router.IsRetryable(err), tt.retryable, err)
+		}
+	}
+}
+
+func TestCLNBackend_Balances(t *testing.T) {
+	b := newCLNStandIn(t, nil)
+
+	outbound, err := b.Balance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only connected CHANNELD_NORMAL channels count: 3000 + 2000 sats
+	if outbound != router.NewAmount(5000000, router.Msat) {
+		t.Errorf("outbound = %s, want 5000000 msat", outbound)
+	}
+
+	onchain, err := b.OnchainBalance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onchain != router.NewAmount(100000000, router.Msat) {
+		t.Errorf("on-chain = %s, want 100000000 msat", onchain)
+	}
+
+	wrong, _ := NewCLNBackend(b.url, "bogus", nil)
+	wrong.client = b.client
+	if _, err := wrong.Balance(context.Background()); err == nil {
+		t.Error("expected an error for a bad rune")
+	}
+}
+
+// testOffer is the minimal offer from the BOLT12 test vectors; it leaves the
+// amount to the payer.
+const testOffer = "lno1pgx9getnwss8vetrw3hhyuckyypwa3eyt44h6txtxquqh7lz5djge4afgfjn7k4rgrkuag0jsd5xvxg"
+
+func TestCLNBackend_PayOffer(t *testing.T) {
+	var paid []string
+	b := newCLNStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Bolt11 string `json:"bolt11"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		paid = append(paid, params.Bolt11)
+		w.Write([]byte(`{"payment_hash":"` + testPayHash + `","amount_msat":21000,"amount_sent_msat":21010,` +
+			`"payment_preimage":"` + testPreimage + `","status":"complete"}`))
+	})
+
+	p := NewL402ProviderWithBackend(b)
+	req := &router.PaymentRequirement{
+		Protocol: router.ProtocolL402,
+		L402Hash: "mac",
+		Details:  &router.BOLT12Offer{Offer: testOffer, ID: "0123456789abcdef0123", AmountMsat: 21000},
+	}
+	if _, desc, err := p.EstimateCost(req); err != nil || !strings.HasPrefix(desc, "21 sats to BOLT12 offer 0123456789abcdef ") {
+		t.Errorf("estimate = %q, %v", desc, err)
+	}
+	s, err := p.Settle(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.HeaderValue != "L402 mac:"+testPreimage || s.OfferID != "0123456789abcdef0123" {
+		t.Errorf("settlement = %+v", s)
+	}
+	if len(paid) != 1 || paid[0] != "lni1qtest" {
+		t.Errorf("paid %v", paid)
+	}
+
+	// The issuer quoting a different amount is refused before anything is paid
+	if _, err := b.PayOffer(context.Background(), testOffer, 20000); err == nil || len(paid) != 1 {
+		t.Errorf("err = %v, paid %d invoices", err, len(paid))
+	}
+	if _, err := b.PayOffer(context.Background(), "lno1other", 21000); !router.IsRetryable(err) {
+		t.Errorf("fetchinvoice failure should be retryable: %v", err)
+	}
+}
+
+func TestCLNBackend_CreateInvoice(t *testing.T) {
+	b := newCLNStandIn(t, nil)
+	inv, err := b.CreateInvoice(context.Background(), 21000, "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.PaymentHash != testPayHash || inv.Bolt11 != "lnbc210n1ptest" {
+		t.Errorf("invoice = %+v", inv)
+	}
+}
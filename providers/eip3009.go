@@ -0,0 +1,388 @@
+package providers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/keccak"
+	"github.com/joelklabo/agentpay/internal/keystore"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// transferAuthorization is an EIP-3009 TransferWithAuthorization for the x402
+// exact scheme on EVM chains. CDP signs its typedData remotely; EVMKeyProvider
+// signs its digest locally.
+type transferAuthorization struct {
+	accept *router.X402Accept
+
+	// EIP-712 domain of the token contract
+	name     string
+	version  string
+	chainID  int64
+	contract string
+
+	from        string
+	to          string
+	value       string
+	validAfter  string
+	validBefore string
+	nonce       string // 0x-prefixed 32 bytes
+}
+
+// newTransferAuthorization authorizes moving accept.MaxAmountRequired from
+// from to accept.PayTo, valid for the next ten minutes.
+func newTransferAuthorization(from string, accept *router.X402Accept) *transferAuthorization {
+	a := authorizationDomain(accept)
+	a.from = from
+	a.to = accept.PayTo
+	a.value = accept.MaxAmountRequired
+	a.validAfter = "0"
+	a.validBefore = strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10)
+	a.nonce = generateNonce()
+	return a
+}
+
+// usdcDomains are the EIP-712 domain name and version of the canonical USDC
+// contract on each network.
+var usdcDomains = map[string][2]string{
+	"eip155:8453":  {"USD Coin", "2"},
+	"eip155:84532": {"USDC", "2"},
+}
+
+// authorizationDomain returns an authorization for accept with only the
+// token's EIP-712 domain filled in. Canonical USDC always gets its own
+// domain; only other tokens take it from the server's extra.
+func authorizationDomain(accept *router.X402Accept) *transferAuthorization {
+	var extra struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if len(accept.Extra) > 0 {
+		json.Unmarshal(accept.Extra, &extra)
+	}
+	if extra.Name == "" {
+		extra.Name = "USD Coin"
+	}
+	if extra.Version == "" {
+		extra.Version = "2"
+	}
+	if domain, ok := usdcDomains[accept.Network]; ok && strings.EqualFold(accept.Asset, router.USDCContract(accept.Network)) {
+		extra.Name, extra.Version = domain[0], domain[1]
+	}
+
+	// Extract chain ID from network (e.g., "eip155:84532" -> 84532)
+	chainID := int64(84532) // default Base Sepolia
+	if parts := strings.SplitN(accept.Network, ":", 2); len(parts) == 2 {
+		if id, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			chainID = id
+		}
+	}
+
+	return &transferAuthorization{
+		accept:   accept,
+		name:     extra.Name,
+		version:  extra.Version,
+		chainID:  chainID,
+		contract: accept.Asset,
+	}
+}
+
+// typedData returns the EIP-712 typed data in the eth_signTypedData_v4 shape.
+func (a *transferAuthorization) typedData() map[string]interface{} {
+	return map[string]interface{}{
+		"domain": map[string]interface{}{
+			"name":              a.name,
+			"version":           a.version,
+			"chainId":           a.chainID,
+			"verifyingContract": a.contract,
+		},
+		"types": map[string]interface{}{
+			"EIP712Domain": []map[string]string{
+				{"name": "name", "type": "string"},
+				{"name": "version", "type": "string"},
+				{"name": "chainId", "type": "uint256"},
+				{"name": "verifyingContract", "type": "address"},
+			},
+			"TransferWithAuthorization": []map[string]string{
+				{"name": "from", "type": "address"},
+				{"name": "to", "type": "address"},
+				{"name": "value", "type": "uint256"},
+				{"name": "validAfter", "type": "uint256"},
+				{"name": "validBefore", "type": "uint256"},
+				{"name": "nonce", "type": "bytes32"},
+			},
+		},
+		"primaryType": "TransferWithAuthorization",
+		"message": map[string]interface{}{
+			"from":        a.from,
+			"to":          a.to,
+			"value":       a.value,
+			"validAfter":  a.validAfter,
+			"validBefore": a.validBefore,
+			"nonce":       a.nonce,
+		},
+	}
+}
+
+var (
+	eip712DomainTypeHash = keccak.Sum256([]byte(
+		"EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	transferWithAuthorizationTypeHash = keccak.Sum256([]byte(
+		"TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"))
+)
+
+// digest returns the EIP-712 hash the payer signs:
+// keccak256(0x1901 ‖ domainSeparator ‖ hashStruct(message)).
+func (a *transferAuthorization) digest() ([]byte, error) {
+	contract, err := abiAddress(a.contract)
+	if err != nil {
+		return nil, fmt.Errorf("asset: %w", err)
+	}
+	from, err := abiAddress(a.from)
+	if err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	to, err := abiAddress(a.to)
+	if err != nil {
+		return nil, fmt.Errorf("payTo: %w", err)
+	}
+	value, err := abiUint(a.value)
+	if err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+	validAfter, err := abiUint(a.validAfter)
+	if err != nil {
+		return nil, err
+	}
+	validBefore, err := abiUint(a.validBefore)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(strings.TrimPrefix(a.nonce, "0x"))
+	if err != nil || len(nonce) != 32 {
+		return nil, fmt.Errorf("invalid nonce %q", a.nonce)
+	}
+
+	name, version := keccak.Sum256([]byte(a.name)), keccak.Sum256([]byte(a.version))
+	domain := keccak.Sum256(eip712DomainTypeHash[:], name[:], version[:],
+		abiUint64(uint64(a.chainID)), contract)
+	message := keccak.Sum256(transferWithAuthorizationTypeHash[:],
+		from, to, value, validAfter, validBefore, nonce)
+
+	sum := keccak.Sum256([]byte{0x19, 0x01}, domain[:], message[:])
+	return sum[:], nil
+}
+
+// payment builds the base64 x402 payment payload carrying signature.
+func (a *transferAuthorization) payment(signature string) (string, string, error) {
+	payment := map[string]interface{}{
+		"x402Version": 1,
+		"scheme":      a.accept.Scheme,
+		"network":     a.accept.Network,
+		"payload": map[string]interface{}{
+			"signature":   signature,
+			"from":        a.from,
+			"to":          a.to,
+			"value":       a.value,
+			"validAfter":  a.validAfter,
+			"validBefore": a.validBefore,
+			"nonce":       a.nonce,
+		},
+	}
+
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal payment: %w", err)
+	}
+	return "Payment", base64.StdEncoding.EncodeToString(paymentBytes), nil
+}
+
+// abiAddress left-pads a 0x address to a 32-byte ABI word.
+func abiAddress(addr string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(addr, "0x"))
+	if err != nil || len(b) != 20 {
+		return nil, fmt.Errorf("invalid address %q", addr)
+	}
+	word := make([]byte, 32)
+	copy(word[12:], b)
+	return word, nil
+}
+
+// abiUint encodes a decimal uint256 as a 32-byte ABI word.
+func abiUint(s string) ([]byte, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid uint256 %q", s)
+	}
+	return n.FillBytes(make([]byte, 32)), nil
+}
+
+func abiUint64(n uint64) []byte {
+	return new(big.Int).SetUint64(n).FillBytes(make([]byte, 32))
+}
+
+// X402Payment is a decoded x402 exact-scheme payment on an EVM chain: an
+// EIP-3009 TransferWithAuthorization and the payer's signature over it.
+type X402Payment struct {
+	X402Version int
+	Scheme      string
+	Network     string
+	Signature   string // 0x-prefixed 65-byte r ‖ s ‖ v
+	From        string
+	To          string
+	Value       string // USDC minor units
+	ValidAfter  string // unix seconds
+	ValidBefore string // unix seconds
+	Nonce       string // 0x-prefixed 32 bytes
+	// Accepted is the requirement the payment answers, which x402 v2
+	// payloads repeat; nil for v1 payloads.
+	Accepted *router.X402Accept
+}
+
+// DecodeX402Payment decodes the base64 payment header a payer retries with.
+// It accepts the flat payload this package sends and the reference
+// implementation's payload, which nests the fields under "authorization".
+func DecodeX402Payment(header string) (*X402Payment, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header))
+	if err != nil {
+		return nil, fmt.Errorf("x402 payment is not base64: %w", err)
+	}
+	type authorization struct {
+		From        string `json:"from"`
+		To          string `json:"to"`
+		Value       string `json:"value"`
+		ValidAfter  string `json:"validAfter"`
+		ValidBefore string `json:"validBefore"`
+		Nonce       string `json:"nonce"`
+	}
+	var msg struct {
+		X402Version int                `json:"x402Version"`
+		Scheme      string             `json:"scheme"`
+		Network     string             `json:"network"`
+		Accepted    *router.X402Accept `json:"accepted"`
+		Payload     struct {
+			Signature     string         `json:"signature"`
+			Authorization *authorization `json:"authorization"`
+			authorization
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("parse x402 payment: %w", err)
+	}
+	auth := msg.Payload.authorization
+	if msg.Payload.Authorization != nil {
+		auth = *msg.Payload.Authorization
+	}
+	if msg.Payload.Signature == "" || auth.From == "" {
+		return nil, errors.New("x402 payment has no EIP-3009 authorization")
+	}
+	if msg.Accepted != nil {
+		// v2 names the scheme and network only in the requirement
+		if msg.Scheme == "" {
+			msg.Scheme = msg.Accepted.Scheme
+		}
+		if msg.Network == "" {
+			msg.Network = msg.Accepted.Network
+		}
+	}
+	return &X402Payment{
+		X402Version: msg.X402Version,
+		Scheme:      msg.Scheme,
+		Network:     msg.Network,
+		Accepted:    msg.Accepted,
+		Signature:   msg.Payload.Signature,
+		From:        auth.From,
+		To:          auth.To,
+		Value:       auth.Value,
+		ValidAfter:  auth.ValidAfter,
+		ValidBefore: auth.ValidBefore,
+		Nonce:       auth.Nonce,
+	}, nil
+}
+
+// Verify checks that p pays accept: the scheme, network and payee match, the
+// value covers the amount, the authorization is valid at now, and the
+// signature over its EIP-712 digest recovers to From. It does not check that
+// the nonce is unused or that From holds the funds; settlement does.
+func (p *X402Payment) Verify(accept *router.X402Accept, now time.Time) error {
+	if p.Scheme != accept.Scheme || p.Network != accept.Network {
+		return fmt.Errorf("payment is for %s on %s, want %s on %s", p.Scheme, p.Network, accept.Scheme, accept.Network)
+	}
+	if !strings.EqualFold(p.To, accept.PayTo) {
+		return fmt.Errorf("payment is to %s, want %s", p.To, accept.PayTo)
+	}
+	value, err := router.ParseUnits(p.Value, router.USDC)
+	if err != nil {
+		return err
+	}
+	required, err := router.ParseUnits(accept.MaxAmountRequired, router.USDC)
+	if err != nil {
+		return err
+	}
+	if value.Cmp(required) < 0 {
+		return fmt.Errorf("payment of %s is less than %s", value, required)
+	}
+	after, err1 := strconv.ParseInt(p.ValidAfter, 10, 64)
+	before, err2 := strconv.ParseInt(p.ValidBefore, 10, 64)
+	if err1 != nil || err2 != nil {
+		return errors.New("payment has an invalid validity window")
+	}
+	if now.Unix() <= after || now.Unix() >= before {
+		return errors.New("payment authorization is not valid now")
+	}
+
+	signer, err := p.Signer(accept)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(signer, p.From) {
+		return fmt.Errorf("payment is signed by %s, not %s", signer, p.From)
+	}
+	return nil
+}
+
+// authorization rebuilds the signed authorization, with the token's EIP-712
+// domain taken from accept.
+func (p *X402Payment) authorization(accept *router.X402Accept) *transferAuthorization {
+	a := authorizationDomain(accept)
+	a.from, a.to, a.value = p.From, p.To, p.Value
+	a.validAfter, a.validBefore, a.nonce = p.ValidAfter, p.ValidBefore, p.Nonce
+	return a
+}
+
+// TypedData returns the EIP-712 typed data p's signature covers, with the
+// token's domain taken from accept's network, asset and extra.
+func (p *X402Payment) TypedData(accept *router.X402Accept) map[string]interface{} {
+	return p.authorization(accept).typedData()
+}
+
+// Signer recovers the address whose signature p carries, with the token's
+// EIP-712 domain taken from accept. It is From if the signature is genuine.
+func (p *X402Payment) Signer(accept *router.X402Accept) (string, error) {
+	digest, err := p.authorization(accept).digest()
+	if err != nil {
+		return "", err
+	}
+	sigBytes, err := hex.DecodeString(strings.TrimPrefix(p.Signature, "0x"))
+	if err != nil || len(sigBytes) != 65 || sigBytes[64] < 27 {
+		return "", errors.New("payment has a malformed signature")
+	}
+	sig := &secp256k1.Signature{
+		R: new(big.Int).SetBytes(sigBytes[:32]),
+		S: new(big.Int).SetBytes(sigBytes[32:64]),
+		V: sigBytes[64] - 27,
+	}
+	pub, err := secp256k1.RecoverPublicKey(digest, sig)
+	if err != nil {
+		return "", fmt.Errorf("payment signature: %w", err)
+	}
+	return keystore.Address(pub), nil
+}
@@ -0,0 +1,45 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+// statusError reports a failed backend response. Server errors and rate
+// limiting are marked retryable so the router fails over to another provider;
+// anything else (bad request, insufficient funds) is final.
+func statusError(format string, code int, body []byte, args ...interface{}) error {
+	args = append(args, code, string(body))
+	err := fmt.Errorf(format, args...)
+	if code >= 500 || code == http.StatusTooManyRequests {
+		return router.Retryable(err)
+	}
+	return err
+}
+
+// submitError is statusError for requests that send a payment. A server
+// error may come after the payment left, so only rate limiting is retryable.
+func submitError(format string, code int, body []byte, args ...interface{}) error {
+	if code == http.StatusTooManyRequests {
+		return statusError(format, code, body, args...)
+	}
+	args = append(args, code, string(body))
+	return fmt.Errorf(format, args...)
+}
+
+// requestError reports a request that got no response. Only a failure to
+// connect is retryable, since the backend never saw the request; a timeout or
+// dropped connection may have left a payment in flight, so it is final.
+func requestError(format string, err error, args ...interface{}) error {
+	args = append(args, err)
+	err = fmt.Errorf(format, args...)
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return router.Retryable(err)
+	}
+	return err
+}
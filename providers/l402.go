@@ -2,31 +2,38 @@ package providers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
+	"time"
 
+	"github.com/joelklabo/agentpay/internal/bolt11"
 	"github.com/joelklabo/agentpay/router"
 )
 
-// L402Provider handles L402 (Lightning) payments via LNbits.
+// L402Provider handles L402 (Lightning) payments through a LightningBackend:
+// an LNbits wallet, a node or an NWC wallet. Challenges may carry a BOLT11
+// invoice, a Lightning address or LNURL, or a BOLT12 offer when the backend
+// is an OfferPayer.
 type L402Provider struct {
-	lnbitsURL string
-	adminKey  string
-	client    *http.Client
-	// SatPriceUSD is the current price of 1 sat in USD (for cost estimation).
-	SatPriceUSD float64
+	backend LightningBackend
+	// BTCPriceUSD is the whole-dollar price of 1 BTC used for cost estimation.
+	BTCPriceUSD int64
+	// HTTPClient fetches invoices from Lightning addresses and LNURL-pay
+	// services. Nil means http.DefaultClient.
+	HTTPClient *http.Client
 }
 
 // NewL402Provider creates a new L402 payment provider backed by LNbits.
 func NewL402Provider(lnbitsURL, adminKey string) *L402Provider {
+	return NewL402ProviderWithBackend(NewLNbitsBackend(lnbitsURL, adminKey))
+}
+
+// NewL402ProviderWithBackend creates an L402 provider that pays through backend.
+func NewL402ProviderWithBackend(backend LightningBackend) *L402Provider {
 	return &L402Provider{
-		lnbitsURL:   strings.TrimRight(lnbitsURL, "/"),
-		adminKey:    adminKey,
-		client:      &http.Client{},
-		SatPriceUSD: 0.00001, // ~$100K/BTC default
+		backend:     backend,
+		BTCPriceUSD: 100000, // ~$100K/BTC default
 	}
 }
 
@@ -34,133 +41,224 @@ func (p *L402Provider) Protocol() router.Protocol {
 	return router.ProtocolL402
 }
 
-func (p *L402Provider) EstimateCost(req *router.PaymentRequirement) (float64, string, error) {
+// Capabilities reports that the provider pays Lightning invoices only.
+func (p *L402Provider) Capabilities() router.Capabilities {
+	return router.Capabilities{Networks: []string{"lightning"}}
+}
+
+func (p *L402Provider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	if lnurl, ok := req.Details.(*router.LNURLPay); ok && req.L402Invoice == "" {
+		msat := lnurl.AmountMsat
+		if msat <= 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			params, err := p.ResolveLNURL(ctx, lnurl.Target)
+			if err != nil {
+				return router.Amount{}, "", err
+			}
+			if msat, err = lnurlAmount(lnurl, params); err != nil {
+				return router.Amount{}, "", err
+			}
+		}
+		usd := router.BTCToUSD(router.NewAmount(msat, router.Msat), p.BTCPriceUSD)
+		desc := fmt.Sprintf("%d sats to %s ($%.4f)", msat/1000, lnurl.Target, usd.Float64())
+		return usd, desc, nil
+	}
+	if offer, ok := req.Details.(*router.BOLT12Offer); ok && req.L402Invoice == "" {
+		if _, err := p.offerPayer(); err != nil {
+			return router.Amount{}, "", err
+		}
+		msat, err := offerAmount(offer)
+		if err != nil {
+			return router.Amount{}, "", err
+		}
+		usd := router.BTCToUSD(router.NewAmount(msat, router.Msat), p.BTCPriceUSD)
+		desc := fmt.Sprintf("%d sats to BOLT12 offer %.16s ($%.4f)", msat/1000, offer.ID, usd.Float64())
+		return usd, desc, nil
+	}
 	if req.L402Invoice == "" {
-		return 0, "", fmt.Errorf("no Lightning invoice")
+		return router.Amount{}, "", fmt.Errorf("no Lightning invoice")
 	}
 
-	// Decode invoice amount from BOLT11
-	sats, err := decodeBolt11Amount(req.L402Invoice)
+	amount, err := invoiceAmount(req.L402Invoice)
 	if err != nil {
-		return 0, "", fmt.Errorf("decode invoice: %w", err)
+		return router.Amount{}, "", fmt.Errorf("decode invoice: %w", err)
 	}
 
-	usd := float64(sats) * p.SatPriceUSD
-	desc := fmt.Sprintf("%d sats ($%.4f)", sats, usd)
+	usd := router.BTCToUSD(amount, p.BTCPriceUSD)
+	desc := fmt.Sprintf("%s ($%.4f)", formatMsat(amount.Units), usd.Float64())
 	return usd, desc, nil
 }
 
 func (p *L402Provider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
-	if req.L402Invoice == "" {
-		return "", "", fmt.Errorf("no Lightning invoice to pay")
+	s, err := p.Settle(ctx, req)
+	if err != nil {
+		return "", "", err
 	}
+	return s.HeaderName, s.HeaderValue, nil
+}
 
-	// Pay the invoice via LNbits
-	payURL := fmt.Sprintf("%s/api/v1/payments", p.lnbitsURL)
-	payloadData := struct {
-		Out    bool   `json:"out"`
-		Bolt11 string `json:"bolt11"`
-	}{Out: true, Bolt11: req.L402Invoice}
-	payloadBytes, err := json.Marshal(payloadData)
-	if err != nil {
-		return "", "", fmt.Errorf("marshal pay request: %w", err)
+// Settle pays the invoice and returns the L402 proof along with the payment
+// hash, preimage and routing fee.
+func (p *L402Provider) Settle(ctx context.Context, req *router.PaymentRequirement) (*router.Settlement, error) {
+	var payment *LightningPayment
+	var offerID string
+	var err error
+	if lnurl, ok := req.Details.(*router.LNURLPay); ok && req.L402Invoice == "" {
+		params, err := p.ResolveLNURL(ctx, lnurl.Target)
+		if err != nil {
+			return nil, err
+		}
+		msat, err := lnurlAmount(lnurl, params)
+		if err != nil {
+			return nil, err
+		}
+		if payment, err = p.PayLNURL(ctx, params, msat); err != nil {
+			return nil, err
+		}
+	} else if offer, ok := req.Details.(*router.BOLT12Offer); ok && req.L402Invoice == "" {
+		payer, err := p.offerPayer()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := offerAmount(offer); err != nil {
+			return nil, err
+		}
+		// Offers that fix an amount are paid that amount
+		var msat int64
+		if offer.OfferMsat == 0 {
+			msat = offer.AmountMsat
+		}
+		if payment, err = payer.PayOffer(ctx, offer.Offer, msat); err != nil {
+			return nil, err
+		}
+		offerID = offer.ID
+	} else {
+		if req.L402Invoice == "" {
+			return nil, fmt.Errorf("no Lightning invoice to pay")
+		}
+		if payment, err = p.backend.PayInvoice(ctx, req.L402Invoice); err != nil {
+			return nil, err
+		}
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", payURL, strings.NewReader(string(payloadBytes)))
-	if err != nil {
-		return "", "", fmt.Errorf("build pay request: %w", err)
+	// L402 proves payment with the preimage. The payment went out, so a
+	// missing one is final: paying again elsewhere would pay twice.
+	if payment.Preimage == "" {
+		return nil, fmt.Errorf("payment %s sent but the wallet did not report its preimage", payment.PaymentHash)
 	}
-	httpReq.Header.Set("X-Api-Key", p.adminKey)
-	httpReq.Header.Set("Content-Type", "application/json")
+	fee := payment.Fee
+	return &router.Settlement{
+		HeaderName:  "Authorization",
+		HeaderValue: fmt.Sprintf("L402 %s:%s", req.L402Hash, payment.Preimage),
+		TxID:        payment.PaymentHash,
+		Preimage:    payment.Preimage,
+		OfferID:     offerID,
+		Fee:         &fee,
+	}, nil
+}
+
+// ResolveLNURL fetches the payRequest behind a Lightning address or
+// LNURL-pay link.
+func (p *L402Provider) ResolveLNURL(ctx context.Context, target string) (*LNURLPayParams, error) {
+	return ResolveLNURLPay(ctx, p.httpClient(), target)
+}
 
-	resp, err := p.client.Do(httpReq)
+// PayLNURL pays amountMsat to a resolved LNURL-pay service: it requests an
+// invoice for the exact amount, checks it against the payRequest and pays it
+// from the backend.
+func (p *L402Provider) PayLNURL(ctx context.Context, params *LNURLPayParams, amountMsat int64) (*LightningPayment, error) {
+	invoice, err := params.RequestInvoice(ctx, p.httpClient(), amountMsat)
 	if err != nil {
-		return "", "", fmt.Errorf("pay request failed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
+	return p.backend.PayInvoice(ctx, invoice)
+}
 
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
-		return "", "", fmt.Errorf("LNbits pay HTTP %d: %s", resp.StatusCode, string(respBody))
+// lnurlAmount returns the msat to send for an LNURL-pay option. When the
+// challenge names no amount, the service's payRequest must fix one.
+func lnurlAmount(lnurl *router.LNURLPay, params *LNURLPayParams) (int64, error) {
+	if lnurl.AmountMsat > 0 {
+		return lnurl.AmountMsat, nil
+	}
+	if params.MinSendable != params.MaxSendable {
+		return 0, fmt.Errorf("%s accepts %d-%d msat and the challenge names no amount",
+			lnurl.Target, params.MinSendable, params.MaxSendable)
 	}
+	return params.MinSendable, nil
+}
 
-	var result struct {
-		PaymentHash string `json:"payment_hash"`
-		Preimage    string `json:"checking_id"`
+// offerAmount returns the msat to send for a BOLT12 offer option. The
+// challenge may name the amount of an offer that leaves it to the payer, but
+// not contradict an offer's own amount.
+func offerAmount(offer *router.BOLT12Offer) (int64, error) {
+	if offer.Currency != "" {
+		return 0, fmt.Errorf("BOLT12 offer is priced in %s; only msat offers are supported", offer.Currency)
 	}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", "", fmt.Errorf("parse pay response: %w", err)
+	if offer.OfferMsat > 0 && offer.AmountMsat > 0 && offer.AmountMsat != offer.OfferMsat {
+		return 0, fmt.Errorf("challenge asks for %d msat but the BOLT12 offer is for %d msat", offer.AmountMsat, offer.OfferMsat)
 	}
-
-	// Return the preimage/payment_hash as the proof
-	// L402 uses the preimage in an Authorization header
-	proofValue := fmt.Sprintf("L402 %s:%s", "", result.PaymentHash)
-	if req.L402Hash != "" {
-		proofValue = fmt.Sprintf("L402 %s:%s", req.L402Hash, result.PaymentHash)
+	if offer.Msat() <= 0 {
+		return 0, fmt.Errorf("BOLT12 offer %.16s names no amount and neither does the challenge", offer.ID)
 	}
-
-	return "Authorization", proofValue, nil
+	return offer.Msat(), nil
 }
 
-// decodeBolt11Amount extracts the amount in sats from a BOLT11 invoice string.
-// BOLT11 format: lnbc<amount><multiplier>1...
-func decodeBolt11Amount(invoice string) (int64, error) {
-	invoice = strings.ToLower(invoice)
-	var prefix string
-	for _, p := range []string{"lnbcrt", "lntbs", "lntb", "lnbc"} {
-		if strings.HasPrefix(invoice, p) {
-			prefix = p
-			break
-		}
+func (p *L402Provider) offerPayer() (OfferPayer, error) {
+	payer, ok := p.backend.(OfferPayer)
+	if !ok {
+		return nil, fmt.Errorf("Lightning backend %T cannot pay BOLT12 offers", p.backend)
 	}
-	if prefix == "" {
-		return 0, fmt.Errorf("not a valid BOLT11 invoice")
+	return payer, nil
+}
+
+func (p *L402Provider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
 	}
+	return http.DefaultClient
+}
 
-	rest := invoice[len(prefix):]
+// Backend returns the Lightning backend payments go through.
+func (p *L402Provider) Backend() LightningBackend {
+	return p.backend
+}
 
-	// Find the separator '1' that precedes the data part
-	sepIdx := strings.LastIndex(rest, "1")
-	if sepIdx < 1 {
-		return 0, fmt.Errorf("no amount in invoice")
+// PayInvoice pays a BOLT11 invoice from the backend and returns its payment
+// hash.
+func (p *L402Provider) PayInvoice(ctx context.Context, bolt11 string) (string, error) {
+	payment, err := p.backend.PayInvoice(ctx, bolt11)
+	if err != nil {
+		return "", err
 	}
-	amountStr := rest[:sepIdx]
+	return payment.PaymentHash, nil
+}
 
-	if len(amountStr) == 0 {
-		return 0, fmt.Errorf("no amount in invoice")
+// Balance returns the backend's spendable balance valued in USD at BTCPriceUSD.
+func (p *L402Provider) Balance(ctx context.Context, req *router.PaymentRequirement) (router.Amount, error) {
+	msat, err := p.backend.Balance(ctx)
+	if err != nil {
+		return router.Amount{}, err
 	}
+	return router.BTCToUSD(msat, p.BTCPriceUSD), nil
+}
 
-	// The last character is the multiplier
-	multiplier := amountStr[len(amountStr)-1]
-	numStr := amountStr[:len(amountStr)-1]
+// invoiceAmount returns the exact amount of a BOLT11 invoice.
+func invoiceAmount(invoice string) (router.Amount, error) {
+	msat, err := bolt11.AmountMsat(invoice)
+	if err != nil {
+		return router.Amount{}, err
+	}
+	if msat == 0 {
+		return router.Amount{}, errors.New("no amount in invoice")
+	}
+	return router.NewAmount(msat, router.Msat), nil
+}
 
-	var num int64
-	for _, c := range numStr {
-		if c < '0' || c > '9' {
-			return 0, fmt.Errorf("invalid amount character: %c", c)
-		}
-		num = num*10 + int64(c-'0')
-	}
-
-	// Multipliers: m=milli(0.001), u=micro(0.000001), n=nano(0.000000001), p=pico(0.000000000001)
-	// 1 BTC = 100,000,000 sats
-	switch multiplier {
-	case 'm':
-		return num * 100000, nil // milli-BTC to sats
-	case 'u':
-		return num * 100, nil // micro-BTC to sats
-	case 'n':
-		// nano-BTC: 1 nano = 0.1 sat, need to handle sub-sat
-		return num / 10, nil
-	case 'p':
-		// pico-BTC: 1 pico = 0.0001 sat
-		return num / 10000, nil
-	default:
-		// No multiplier — amount is in BTC
-		if multiplier >= '0' && multiplier <= '9' {
-			num = num*10 + int64(multiplier-'0')
-			return num * 100000000, nil // BTC to sats
-		}
-		return 0, fmt.Errorf("unknown multiplier: %c", multiplier)
+// formatMsat writes msat in sats, falling back to msat for sub-sat amounts.
+func formatMsat(msat int64) string {
+	if msat%1000 != 0 {
+		return fmt.Sprintf("%d msat", msat)
 	}
+	return fmt.Sprintf("%d sats", msat/1000)
 }
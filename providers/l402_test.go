@@ -1,8 +1,18 @@
 package providers
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
 
-func TestDecodeBolt11Amount(t *testing.T) {
+	"github.com/joelklabo/agentpay/router"
+)
+
+func TestInvoiceAmount(t *testing.T) {
 	tests := []struct {
 		name    string
 		invoice string
@@ -12,37 +22,52 @@ func TestDecodeBolt11Amount(t *testing.T) {
 		{
 			name:    "100 micro-BTC (10000 sats)",
 			invoice: "lnbc100u1pjexample",
-			want:    10000,
+			want:    10000000,
 		},
 		{
 			name:    "10 micro-BTC (1000 sats)",
 			invoice: "lnbc10u1pjexample",
-			want:    1000,
+			want:    1000000,
 		},
 		{
 			name:    "1 milli-BTC (100000 sats)",
 			invoice: "lnbc1m1pjexample",
-			want:    100000,
+			want:    100000000,
 		},
 		{
 			name:    "50 micro-BTC (5000 sats)",
 			invoice: "lnbc50u1pjexample",
-			want:    5000,
+			want:    5000000,
 		},
 		{
 			name:    "250 nano-BTC (25 sats)",
 			invoice: "lnbc250n1pjexample",
-			want:    25,
+			want:    25000,
 		},
 		{
 			name:    "testnet invoice",
 			invoice: "lntb100u1pjexample",
-			want:    10000,
+			want:    10000000,
 		},
 		{
 			name:    "regtest invoice",
 			invoice: "lnbcrt100u1pjexample",
-			want:    10000,
+			want:    10000000,
+		},
+		{
+			name:    "5 nano-BTC (500 msat)",
+			invoice: "lnbc5n1pjexample",
+			want:    500,
+		},
+		{
+			name:    "overflowing amount",
+			invoice: "lnbc100000000000m1pjexample",
+			wantErr: true,
+		},
+		{
+			name:    "no amount",
+			invoice: "lnbc1pjexample",
+			wantErr: true,
 		},
 		{
 			name:    "invalid prefix",
@@ -53,18 +78,162 @@ func TestDecodeBolt11Amount(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := decodeBolt11Amount(tt.invoice)
+			got, err := invoiceAmount(tt.invoice)
 			if tt.wantErr {
 				if err == nil {
-					t.Errorf("expected error, got %d", got)
+					t.Errorf("expected error, got %s", got)
 				}
 				return
 			}
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
-			if got != tt.want {
-				t.Errorf("got %d, want %d", got, tt.want)
+			if got != router.NewAmount(tt.want, router.Msat) {
+				t.Errorf("got %s, want %d msat", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestL402Provider_EstimateCostSubSat(t *testing.T) {
+	p := NewL402Provider("http://lnbits.invalid", "admin")
+	usd, desc, err := p.EstimateCost(&router.PaymentRequirement{Protocol: router.ProtocolL402, L402Invoice: "lnbc5n1pjexample"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 500 msat at $100K/BTC is $0.0005
+	if usd != router.FromUSD(0.0005) || !strings.HasPrefix(desc, "500 msat ") {
+		t.Errorf("cost = %s, %q", usd, desc)
+	}
+}
+func TestL402Provider_LNbitsPreimage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "admin" {
+			t.Errorf("missing admin key")
+		}
+		switch r.URL.Path {
+		case "/api/v1/payments":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"payment_hash":"` + testPayHash + `","checking_id":"` + testPayHash + `"}`))
+		case "/api/v1/payments/" + testPayHash:
+			w.Write([]byte(`{"paid":true,"preimage":"` + testPreimage + `","details":{"fee":-2000}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewL402Provider(srv.URL, "admin")
+	s, err := p.Settle(context.Background(), &router.PaymentRequirement{
+		Protocol:    router.ProtocolL402,
+		L402Invoice: "lnbc10n1ptest",
+		L402Hash:    "mac",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.HeaderValue != "L402 mac:"+testPreimage {
+		t.Errorf("proof = %q, want the preimage", s.HeaderValue)
+	}
+	if s.Fee == nil || *s.Fee != router.NewAmount(2000, router.Msat) {
+		t.Errorf("fee = %v, want 2000 msat", s.Fee)
+	}
+}
+
+func TestL402Provider_BOLT12OfferChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend LightningBackend
+		offer   router.BOLT12Offer
+		want    string
+	}{
+		{"backend without offers", &recordingBackend{}, router.BOLT12Offer{Offer: testOffer, AmountMsat: 1000}, "cannot pay BOLT12"},
+		{"no amount", &PhoenixdBackend{}, router.BOLT12Offer{Offer: testOffer}, "names no amount"},
+		{"fiat", &PhoenixdBackend{}, router.BOLT12Offer{Offer: testOffer, Currency: "USD", AmountMsat: 1000}, "priced in USD"},
+		{"contradicts offer", &PhoenixdBackend{}, router.BOLT12Offer{Offer: testOffer, OfferMsat: 5000, AmountMsat: 1000}, "offer is for 5000"},
+	}
+	for _, tt := range tests {
+		p := NewL402ProviderWithBackend(tt.backend)
+		_, _, err := p.EstimateCost(&router.PaymentRequirement{Protocol: router.ProtocolL402, Details: &tt.offer})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLNbitsBackend_CreateInvoice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Out    bool   `json:"out"`
+			Amount int64  `json:"amount"`
+			Memo   string `json:"memo"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		if r.URL.Path != "/api/v1/payments" || params.Out || params.Amount != 21 || params.Memo != "answer" {
+			t.Errorf("%s %+v", r.URL.Path, params)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"payment_hash":"` + testPayHash + `","payment_request":"lnbc210n1ptest"}`))
+	}))
+	defer srv.Close()
+
+	inv, err := NewLNbitsBackend(srv.URL, "key").CreateInvoice(context.Background(), 21000, "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.PaymentHash != testPayHash || inv.Bolt11 != "lnbc210n1ptest" {
+		t.Errorf("invoice = %+v", inv)
+	}
+}
+
+func TestL402Provider_LNbitsPendingPreimage(t *testing.T) {
+	defer func(d time.Duration) { lnbitsLookupInterval = d }(lnbitsLookupInterval)
+	lnbitsLookupInterval = time.Millisecond
+
+	for _, tt := range []struct {
+		name    string
+		pending int // lookups before the preimage shows up
+	}{
+		{"reported later", 2},
+		{"never reported", 1000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v1/payments":
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`{"payment_hash":"` + testPayHash + `"}`))
+				case "/api/v1/payments/" + testPayHash:
+					if lookups++; lookups <= tt.pending {
+						w.Write([]byte(`{"paid":false,"preimage":""}`))
+						return
+					}
+					w.Write([]byte(`{"paid":true,"preimage":"` + testPreimage + `"}`))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			p := NewL402Provider(srv.URL, "admin")
+			s, err := p.Settle(context.Background(), &router.PaymentRequirement{
+				Protocol:    router.ProtocolL402,
+				L402Invoice: "lnbc10n1ptest",
+				L402Hash:    "mac",
+			})
+			if tt.pending < lnbitsLookupAttempts {
+				if err != nil || s.HeaderValue != "L402 mac:"+testPreimage {
+					t.Errorf("settle = %+v, %v", s, err)
+				}
+				return
+			}
+			// The hash is no proof, and the invoice is already paid
+			if err == nil {
+				t.Fatalf("settled without a preimage: %+v", s)
+			}
+			if router.IsRetryable(err) || !strings.Contains(err.Error(), testPayHash) {
+				t.Errorf("err = %v, want a final error naming the payment hash", err)
 			}
 		})
 	}
@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+// LightningBackend is a Lightning wallet or node that L402Provider pays
+// invoices through.
+type LightningBackend interface {
+	// PayInvoice pays a BOLT11 invoice and blocks until it settles or fails.
+	PayInvoice(ctx context.Context, bolt11 string) (*LightningPayment, error)
+	// Balance returns the spendable balance in msat.
+	Balance(ctx context.Context) (router.Amount, error)
+}
+
+// OfferPayer is implemented by backends that can pay BOLT12 offers. PayOffer
+// fetches an invoice from the offer's issuer and pays it; amountMsat sets the
+// amount for offers that leave it to the payer and is 0 otherwise.
+type OfferPayer interface {
+	PayOffer(ctx context.Context, offer string, amountMsat int64) (*LightningPayment, error)
+}
+
+// Invoicer is implemented by backends that can receive. The paywall sells
+// with the invoices it creates.
+type Invoicer interface {
+	// CreateInvoice creates a BOLT11 invoice for amountMsat.
+	CreateInvoice(ctx context.Context, amountMsat int64, description string) (*LightningInvoice, error)
+}
+
+// LightningInvoice is an invoice a backend created to be paid.
+type LightningInvoice struct {
+	Bolt11      string
+	PaymentHash string // hex
+}
+
+// LightningPayment is a settled Lightning payment.
+type LightningPayment struct {
+	PaymentHash string // hex
+	Preimage    string // hex; empty if the backend does not report it
+	Fee         router.Amount
+}
+
+// pinnedTLSClient returns an HTTP client that trusts only certPEM, the
+// self-signed certificate Lightning nodes serve their REST APIs with. An
+// empty certPEM falls back to the system roots.
+func pinnedTLSClient(certPEM []byte) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(certPEM) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(certPEM) {
+			return nil, errors.New("no PEM certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, nil
+}
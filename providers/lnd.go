@@ -0,0 +1,257 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+// LNDBackend pays invoices from an LND node over its REST API, authenticated
+// with a hex macaroon and pinned to the node's TLS certificate.
+type LNDBackend struct {
+	url         string
+	macaroonHex string
+	client      *http.Client
+
+	// FeeLimitSat caps the routing fee per payment. Zero means 1% of the
+	// invoice amount, but at least 10 sats.
+	FeeLimitSat int64
+	// Timeout bounds how long LND keeps trying routes.
+	Timeout time.Duration
+}
+
+// NewLNDBackend creates a backend for the LND REST endpoint at url (e.g.
+// "https://localhost:8080"). tlsCertPEM is the node's tls.cert; when empty
+// the system roots are used, as for a node behind a proxy with a public
+// certificate.
+func NewLNDBackend(url, macaroonHex string, tlsCertPEM []byte) (*LNDBackend, error) {
+	client, err := pinnedTLSClient(tlsCertPEM)
+	if err != nil {
+		return nil, fmt.Errorf("LND TLS certificate: %w", err)
+	}
+	return &LNDBackend{
+		url:         strings.TrimRight(url, "/"),
+		macaroonHex: macaroonHex,
+		client:      client,
+		Timeout:     60 * time.Second,
+	}, nil
+}
+
+// LoadLNDBackend reads the macaroon and TLS certificate from lnd's files.
+// macaroonPath may also hold the macaroon as hex.
+func LoadLNDBackend(url, macaroonPath, tlsCertPath string) (*LNDBackend, error) {
+	mac, err := os.ReadFile(macaroonPath)
+	if err != nil {
+		return nil, fmt.Errorf("read macaroon: %w", err)
+	}
+	macHex := strings.TrimSpace(string(mac))
+	if !isHex(macHex) {
+		macHex = fmt.Sprintf("%x", mac)
+	}
+
+	var cert []byte
+	if tlsCertPath != "" {
+		if cert, err = os.ReadFile(tlsCertPath); err != nil {
+			return nil, fmt.Errorf("read TLS certificate: %w", err)
+		}
+	}
+	return NewLNDBackend(url, macHex, cert)
+}
+
+func isHex(s string) bool {
+	if s == "" || len(s)%2 != 0 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// lndPayment is the Payment message streamed by /v2/router/send. LND's REST
+// gateway encodes 64-bit integers as strings.
+type lndPayment struct {
+	PaymentHash     string `json:"payment_hash"`
+	PaymentPreimage string `json:"payment_preimage"`
+	FeeMsat         int64  `json:"fee_msat,string"`
+	Status          string `json:"status"`
+	FailureReason   string `json:"failure_reason"`
+}
+
+// lndFailuresWorthRetrying are failure reasons another wallet might not hit.
+var lndFailuresWorthRetrying = map[string]bool{
+	"FAILURE_REASON_TIMEOUT":              true,
+	"FAILURE_REASON_NO_ROUTE":             true,
+	"FAILURE_REASON_INSUFFICIENT_BALANCE": true,
+}
+
+// PayInvoice implements LightningBackend. It sends the payment with
+// /v2/router/send and reads the status stream until LND reports the final
+// outcome.
+func (b *LNDBackend) PayInvoice(ctx context.Context, bolt11 string) (*LightningPayment, error) {
+	feeLimit := b.FeeLimitSat
+	if feeLimit <= 0 {
+		feeLimit = 10
+		if amount, err := invoiceAmount(bolt11); err == nil && amount.Units/100_000 > feeLimit {
+			feeLimit = amount.Units / 100_000
+		}
+	}
+	timeout := b.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"payment_request": bolt11,
+		"fee_limit_sat":   fmt.Sprint(feeLimit),
+		"timeout_seconds": int(timeout.Seconds()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal send request: %w", err)
+	}
+
+	// Leave LND time to report the failure after its own timeout expires
+	ctx, cancel := context.WithTimeout(ctx, timeout+10*time.Second)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.url+"/v2/router/send", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build send request: %w", err)
+	}
+	httpReq.Header.Set("Grpc-Metadata-macaroon", b.macaroonHex)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		return nil, requestError("LND send request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, submitError("LND send HTTP %d: %s", resp.StatusCode, respBody)
+	}
+
+	// Each line is {"result": Payment} or {"error": Status}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var update struct {
+			Result *lndPayment `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(line, &update); err != nil {
+			return nil, fmt.Errorf("parse LND payment update: %w", err)
+		}
+		if update.Error != nil {
+			return nil, fmt.Errorf("LND send: %s", update.Error.Message)
+		}
+		if update.Result == nil {
+			continue
+		}
+
+		switch p := update.Result; p.Status {
+		case "SUCCEEDED":
+			return &LightningPayment{
+				PaymentHash: p.PaymentHash,
+				Preimage:    p.PaymentPreimage,
+				Fee:         router.NewAmount(p.FeeMsat, router.Msat),
+			}, nil
+		case "FAILED":
+			err := fmt.Errorf("LND payment failed: %s", p.FailureReason)
+			if lndFailuresWorthRetrying[p.FailureReason] {
+				err = router.Retryable(err)
+			}
+			return nil, err
+		}
+	}
+	// Anything short of a final status, including our deadline passing
+	// while the payment is IN_FLIGHT, may still settle
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read LND payment updates: %w", err)
+	}
+	return nil, errors.New("LND closed the payment stream before the payment settled")
+}
+
+// CreateInvoice implements Invoicer with /v1/invoices.
+func (b *LNDBackend) CreateInvoice(ctx context.Context, amountMsat int64, description string) (*LightningInvoice, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"value_msat": fmt.Sprint(amountMsat),
+		"memo":       description,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal invoice request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.url+"/v1/invoices", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build invoice request: %w", err)
+	}
+	httpReq.Header.Set("Grpc-Metadata-macaroon", b.macaroonHex)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("LND invoice request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, statusError("LND invoice HTTP %d: %s", resp.StatusCode, respBody)
+	}
+
+	// bytes fields such as r_hash come back base64-encoded
+	var result struct {
+		RHash          []byte `json:"r_hash"`
+		PaymentRequest string `json:"payment_request"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("parse invoice response: %w", err)
+	}
+	return &LightningInvoice{Bolt11: result.PaymentRequest, PaymentHash: hex.EncodeToString(result.RHash)}, nil
+}
+
+// Balance implements LightningBackend with the node's local channel balance.
+func (b *LNDBackend) Balance(ctx context.Context) (router.Amount, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", b.url+"/v1/balance/channels", nil)
+	if err != nil {
+		return router.Amount{}, fmt.Errorf("build balance request: %w", err)
+	}
+	httpReq.Header.Set("Grpc-Metadata-macaroon", b.macaroonHex)
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		return router.Amount{}, fmt.Errorf("LND balance request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return router.Amount{}, statusError("LND balance HTTP %d: %s", resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		LocalBalance struct {
+			Msat int64 `json:"msat,string"`
+		} `json:"local_balance"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return router.Amount{}, fmt.Errorf("parse balance response: %w", err)
+	}
+	return router.NewAmount(result.LocalBalance.Msat, router.Msat), nil
+}
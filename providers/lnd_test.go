@@ -0,0 +1,224 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+const (
+	testLNDMacaroon = "0201036c6e6402f801"
+	testPreimage    = "0f4d2b5a9a5b1e8c3f7d6e2a1b0c9d8e7f6a5b4c3d2e1f00112233445566778899"
+	testPayHash     = "b1a6f6a2a3c6e6f2bd4e5f0a6c3b2a19f8e7d6c5b4a392817161514131211100"
+)
+
+// newLNDStandIn serves the LND REST routes over TLS and returns a backend
+// trusting its certificate.
+func newLNDStandIn(t *testing.T, send http.HandlerFunc) (*LNDBackend, *httptest.Server) {
+	t.Helper()
+	mux := http.NewServeMux()
+	if send != nil {
+		mux.HandleFunc("/v2/router/send", send)
+	}
+	mux.HandleFunc("POST /v1/invoices", func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			ValueMsat string `json:"value_msat"`
+			Memo      string `json:"memo"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		hash, _ := hex.DecodeString(testPayHash)
+		fmt.Fprintf(w, `{"r_hash":"%s","payment_request":"lnbc%sp1ptest","add_index":"7"}`,
+			base64.StdEncoding.EncodeToString(hash), params.ValueMsat+"0")
+	})
+	mux.HandleFunc("/v1/balance/channels", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Grpc-Metadata-macaroon") != testLNDMacaroon {
+			http.Error(w, `{"message":"verification failed"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"balance":"5000","local_balance":{"sat":"5000","msat":"5000000"}}`))
+	})
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	b, err := NewLNDBackend(srv.URL, testLNDMacaroon, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, srv
+}
+
+func TestLNDBackend_PayStreamsUntilSettled(t *testing.T) {
+	b, _ := newLNDStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Grpc-Metadata-macaroon") != testLNDMacaroon {
+			t.Errorf("missing macaroon header")
+		}
+		var body struct {
+			PaymentRequest string `json:"payment_request"`
+			FeeLimitSat    string `json:"fee_limit_sat"`
+			TimeoutSeconds int    `json:"timeout_seconds"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.PaymentRequest != "lnbc20u1ptest" {
+			t.Errorf("payment_request = %q", body.PaymentRequest)
+		}
+		// 2000 sats: 1% is 20 sats
+		if body.FeeLimitSat != "20" || body.TimeoutSeconds != 60 {
+			t.Errorf("fee_limit_sat = %s, timeout_seconds = %d", body.FeeLimitSat, body.TimeoutSeconds)
+		}
+
+		w.Write([]byte(`{"result":{"payment_hash":"` + testPayHash + `","status":"IN_FLIGHT","fee_msat":"0"}}` + "\n"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(`{"result":{"payment_hash":"` + testPayHash + `","payment_preimage":"` + testPreimage +
+			`","status":"SUCCEEDED","fee_msat":"1500"}}` + "\n"))
+	})
+
+	p := NewL402ProviderWithBackend(b)
+	s, err := p.Settle(context.Background(), &router.PaymentRequirement{
+		Protocol:    router.ProtocolL402,
+		L402Invoice: "lnbc20u1ptest",
+		L402Hash:    "AgEEbHNhdAJCAAA",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "L402 AgEEbHNhdAJCAAA:" + testPreimage; s.HeaderValue != want {
+		t.Errorf("proof = %q, want %q", s.HeaderValue, want)
+	}
+	if s.TxID != testPayHash || s.Preimage != testPreimage {
+		t.Errorf("settlement = %+v", s)
+	}
+	if s.Fee == nil || *s.Fee != router.NewAmount(1500, router.Msat) {
+		t.Errorf("fee = %v, want 1500 msat", s.Fee)
+	}
+}
+
+func TestLNDBackend_FeeLimitOverride(t *testing.T) {
+	b, _ := newLNDStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			FeeLimitSat string `json:"fee_limit_sat"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.FeeLimitSat != "3" {
+			t.Errorf("fee_limit_sat = %s, want 3", body.FeeLimitSat)
+		}
+		w.Write([]byte(`{"result":{"payment_hash":"` + testPayHash + `","payment_preimage":"` + testPreimage + `","status":"SUCCEEDED"}}`))
+	})
+	b.FeeLimitSat = 3
+
+	if _, err := b.PayInvoice(context.Background(), "lnbc20u1ptest"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLNDBackend_SendHTTPError(t *testing.T) {
+	// LND can answer 500 after the HTLC left, so only rate limiting is retried
+	for status, retryable := range map[int]bool{500: false, 503: false, 429: true} {
+		b, _ := newLNDStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+		_, err := b.PayInvoice(context.Background(), "lnbc10n1ptest")
+		if err == nil || router.IsRetryable(err) != retryable {
+			t.Errorf("HTTP %d: err = %v, want retryable %v", status, err, retryable)
+		}
+	}
+}
+
+func TestLNDBackend_PaymentFailed(t *testing.T) {
+	tests := []struct {
+		reason    string
+		retryable bool
+	}{
+		{"FAILURE_REASON_NO_ROUTE", true},
+		{"FAILURE_REASON_INCORRECT_PAYMENT_DETAILS", false},
+	}
+	for _, tt := range tests {
+		b, _ := newLNDStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"result":{"status":"IN_FLIGHT"}}` + "\n"))
+			w.Write([]byte(`{"result":{"status":"FAILED","failure_reason":"` + tt.reason + `"}}` + "\n"))
+		})
+		_, err := b.PayInvoice(context.Background(), "lnbc10n1ptest")
+		if err == nil {
+			t.Fatalf("%s: expected an error", tt.reason)
+		}
+		if router.IsRetryable(err) != tt.retryable {
+			t.Errorf("%s: retryable = %v, want %v", tt.reason, router.IsRetryable(err), tt.retryable)
+		}
+	}
+}
+
+func TestLNDBackend_StreamError(t *testing.T) {
+	b, _ := newLNDStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":{"code":2,"message":"invoice is already paid"}}`))
+	})
+	if _, err := b.PayInvoice(context.Background(), "lnbc10n1ptest"); err == nil {
+		t.Error("expected the stream error to surface")
+	}
+
+	b, _ = newLNDStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":{"status":"IN_FLIGHT"}}`))
+	})
+	if _, err := b.PayInvoice(context.Background(), "lnbc10n1ptest"); err == nil {
+		t.Error("expected an error when the stream ends in flight")
+	}
+}
+
+func TestLNDBackend_TimeoutInFlightIsFinal(t *testing.T) {
+	b, _ := newLNDStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":{"status":"IN_FLIGHT"}}` + "\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := b.PayInvoice(ctx, "lnbc10n1ptest")
+	if err == nil || router.IsRetryable(err) {
+		t.Errorf("err = %v, want a final error: the payment may still settle", err)
+	}
+
+	// A node that can't be reached never saw the payment
+	b, srv := newLNDStandIn(t, nil)
+	srv.Close()
+	if _, err := b.PayInvoice(context.Background(), "lnbc10n1ptest"); !router.IsRetryable(err) {
+		t.Errorf("err = %v, want a retryable error", err)
+	}
+}
+
+func TestLNDBackend_Balance(t *testing.T) {
+	b, srv := newLNDStandIn(t, nil)
+	p := NewL402ProviderWithBackend(b)
+	got, err := p.Balance(context.Background(), &router.PaymentRequirement{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5000 sats at $100K/BTC = $5
+	if got != router.FromUSD(5) {
+		t.Errorf("balance = %s, want 5 USD", got)
+	}
+
+	// Without the node's certificate the TLS handshake must fail
+	untrusted, _ := NewLNDBackend(srv.URL, testLNDMacaroon, nil)
+	if _, err := untrusted.Balance(context.Background()); err == nil {
+		t.Error("expected a TLS error without the node certificate")
+	}
+}
+
+func TestLNDBackend_CreateInvoice(t *testing.T) {
+	b, _ := newLNDStandIn(t, nil)
+	inv, err := b.CreateInvoice(context.Background(), 21000, "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.PaymentHash != testPayHash || inv.Bolt11 != "lnbc210000p1ptest" {
+		t.Errorf("invoice = %+v", inv)
+	}
+}
@@ -0,0 +1,208 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/bech32"
+	"github.com/joelklabo/agentpay/internal/bolt11"
+)
+
+// LNURLPayParams is the payRequest an LNURL-pay service (LUD-06) or
+// Lightning address (LUD-16) answers with.
+type LNURLPayParams struct {
+	Target      string // the address or LNURL it was resolved from
+	Callback    string
+	MinSendable int64 // msat
+	MaxSendable int64 // msat
+	// Metadata is the raw metadata string; invoices commit to its SHA-256.
+	Metadata    string
+	Description string // the text/plain metadata entry
+}
+
+// LNURLPayURL returns the HTTPS URL a Lightning address or LNURL resolves to.
+func LNURLPayURL(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if len(target) > 10 && strings.EqualFold(target[:10], "lightning:") {
+		target = target[10:]
+	}
+	lower := strings.ToLower(target)
+
+	switch {
+	case strings.HasPrefix(lower, "lnurl1"):
+		hrp, data, err := bech32.Decode(target)
+		if err != nil {
+			return "", fmt.Errorf("invalid LNURL: %w", err)
+		}
+		if hrp != "lnurl" {
+			return "", fmt.Errorf("invalid LNURL prefix %q", hrp)
+		}
+		raw, err := bech32.ConvertBits(data, 5, 8, false)
+		if err != nil {
+			return "", fmt.Errorf("invalid LNURL: %w", err)
+		}
+		return string(raw), nil
+
+	case strings.HasPrefix(lower, "lnurlp://"):
+		// LUD-17: the scheme stands in for https (http on onion services)
+		rest := target[len("lnurlp://"):]
+		host, _, _ := strings.Cut(rest, "/")
+		if strings.HasSuffix(strings.ToLower(host), ".onion") {
+			return "http://" + rest, nil
+		}
+		return "https://" + rest, nil
+
+	case strings.Contains(target, "@"):
+		// LUD-16: user@domain -> https://domain/.well-known/lnurlp/user
+		user, domain, _ := strings.Cut(target, "@")
+		user = strings.ToLower(user)
+		if user == "" || domain == "" || strings.ContainsAny(user, "/?#") || strings.ContainsAny(domain, "/?#@") {
+			return "", fmt.Errorf("invalid Lightning address %q", target)
+		}
+		scheme := "https"
+		if strings.HasSuffix(strings.ToLower(domain), ".onion") {
+			scheme = "http"
+		}
+		return fmt.Sprintf("%s://%s/.well-known/lnurlp/%s", scheme, domain, user), nil
+	}
+	return "", fmt.Errorf("%q is not a Lightning address or LNURL", target)
+}
+
+// lnurlGet fetches an LNURL endpoint and decodes its JSON, surfacing the
+// {"status":"ERROR","reason":...} responses services fail with.
+func lnurlGet(ctx context.Context, client *http.Client, endpoint string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("build LNURL request: %w", err)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("LNURL request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var status struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if json.Unmarshal(body, &status) == nil && strings.EqualFold(status.Status, "ERROR") {
+		return fmt.Errorf("LNURL service error: %s", status.Reason)
+	}
+	if resp.StatusCode != 200 {
+		return statusError("LNURL HTTP %d: %s", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("parse LNURL response: %w", err)
+	}
+	return nil
+}
+
+// ResolveLNURLPay fetches and validates the payRequest behind a Lightning
+// address or LNURL-pay link. A nil client means http.DefaultClient.
+func ResolveLNURLPay(ctx context.Context, client *http.Client, target string) (*LNURLPayParams, error) {
+	endpoint, err := LNURLPayURL(target)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "https" && !(u.Scheme == "http" && strings.HasSuffix(u.Hostname(), ".onion"))) {
+		return nil, fmt.Errorf("LNURL %s must use https", endpoint)
+	}
+
+	var resp struct {
+		Tag         string `json:"tag"`
+		Callback    string `json:"callback"`
+		MinSendable int64  `json:"minSendable"`
+		MaxSendable int64  `json:"maxSendable"`
+		Metadata    string `json:"metadata"`
+	}
+	if err := lnurlGet(ctx, client, endpoint, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Tag != "payRequest" {
+		return nil, fmt.Errorf("%s is not an LNURL-pay service (tag %q)", target, resp.Tag)
+	}
+	if resp.Callback == "" {
+		return nil, errors.New("LNURL payRequest has no callback")
+	}
+	if resp.MinSendable <= 0 || resp.MaxSendable < resp.MinSendable {
+		return nil, fmt.Errorf("LNURL payRequest has an invalid range %d-%d msat", resp.MinSendable, resp.MaxSendable)
+	}
+
+	// Metadata is a JSON array of [mime type, content] pairs with exactly one text/plain
+	var entries [][]interface{}
+	if err := json.Unmarshal([]byte(resp.Metadata), &entries); err != nil {
+		return nil, fmt.Errorf("LNURL payRequest has invalid metadata: %w", err)
+	}
+	var description string
+	var plain int
+	for _, e := range entries {
+		if len(e) >= 2 && e[0] == "text/plain" {
+			description, _ = e[1].(string)
+			plain++
+		}
+	}
+	if plain != 1 {
+		return nil, errors.New("LNURL payRequest metadata must have one text/plain entry")
+	}
+
+	return &LNURLPayParams{
+		Target:      target,
+		Callback:    resp.Callback,
+		MinSendable: resp.MinSendable,
+		MaxSendable: resp.MaxSendable,
+		Metadata:    resp.Metadata,
+		Description: description,
+	}, nil
+}
+
+// RequestInvoice asks the service for an invoice of amountMsat and checks
+// that it is for that amount and commits to the payRequest's metadata.
+func (p *LNURLPayParams) RequestInvoice(ctx context.Context, client *http.Client, amountMsat int64) (string, error) {
+	if amountMsat < p.MinSendable || amountMsat > p.MaxSendable {
+		return "", fmt.Errorf("%d msat is outside %s's range of %d-%d msat", amountMsat, p.Target, p.MinSendable, p.MaxSendable)
+	}
+	callback, err := url.Parse(p.Callback)
+	if err != nil {
+		return "", fmt.Errorf("invalid LNURL callback: %w", err)
+	}
+	q := callback.Query()
+	q.Set("amount", fmt.Sprint(amountMsat))
+	callback.RawQuery = q.Encode()
+
+	var resp struct {
+		PR string `json:"pr"`
+	}
+	if err := lnurlGet(ctx, client, callback.String(), &resp); err != nil {
+		return "", err
+	}
+
+	inv, err := bolt11.Decode(resp.PR)
+	if err != nil {
+		return "", fmt.Errorf("LNURL service returned an invalid invoice: %w", err)
+	}
+	if inv.AmountMsat != amountMsat {
+		return "", fmt.Errorf("LNURL invoice is for %d msat, requested %d", inv.AmountMsat, amountMsat)
+	}
+	metadataHash := sha256.Sum256([]byte(p.Metadata))
+	if inv.DescriptionHash != hex.EncodeToString(metadataHash[:]) {
+		return "", errors.New("LNURL invoice description_hash does not match the payRequest metadata")
+	}
+	if time.Now().After(inv.ExpiresAt()) {
+		return "", errors.New("LNURL invoice has already expired")
+	}
+	return resp.PR, nil
+}
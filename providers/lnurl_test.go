@@ -0,0 +1,214 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/bech32"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// recordingBackend is a LightningBackend that records the invoices it pays.
+type recordingBackend struct {
+	paid []string
+}
+
+func (b *recordingBackend) PayInvoice(ctx context.Context, bolt11 string) (*LightningPayment, error) {
+	b.paid = append(b.paid, bolt11)
+	return &LightningPayment{PaymentHash: testPayHash, Preimage: testPreimage, Fee: router.NewAmount(0, router.Msat)}, nil
+}
+
+func (b *recordingBackend) Balance(ctx context.Context) (router.Amount, error) {
+	return router.NewAmount(0, router.Msat), nil
+}
+
+// signInvoice builds a signed BOLT11 invoice for amountMsat committing to
+// descriptionHash.
+func signInvoice(t *testing.T, amountMsat int64, descriptionHash [32]byte) string {
+	t.Helper()
+	hrp := "lnbc" + strconv.FormatInt(amountMsat*10, 10) + "p"
+
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().Unix())<<29)
+	data, _ := bech32.ConvertBits(ts[:5], 8, 5, false) // top 35 bits
+	data = data[:7]
+
+	tag := func(typ byte, value []byte) {
+		groups, _ := bech32.ConvertBits(value, 8, 5, true)
+		data = append(data, typ, byte(len(groups)>>5), byte(len(groups)&31))
+		data = append(data, groups...)
+	}
+	payHash := sha256.Sum256([]byte(testPreimage))
+	tag(1, payHash[:])
+	tag(23, descriptionHash[:])
+
+	signed, _ := bech32.ConvertBits(data, 5, 8, true)
+	hash := sha256.Sum256(append([]byte(hrp), signed...))
+	sig, err := secp256k1.Sign(big.NewInt(42), hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, 65)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:64])
+	raw[64] = sig.V
+	sigGroups, _ := bech32.ConvertBits(raw, 8, 5, true)
+	return bech32.Encode(hrp, append(data, sigGroups...))
+}
+
+const testLNURLMetadata = `[["text/plain","Pay agent"],["text/identifier","agent@example.com"]]`
+
+// newLNURLStandIn serves a Lightning address over TLS. invoice builds the
+// callback's invoice for the requested amount.
+func newLNURLStandIn(t *testing.T, min, max int64, invoice func(msat int64) string) (*httptest.Server, string) {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/lnurlp/agent":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"tag":         "payRequest",
+				"callback":    srv.URL + "/lnurlp/agent/callback?k1=abc",
+				"minSendable": min,
+				"maxSendable": max,
+				"metadata":    testLNURLMetadata,
+			})
+		case "/lnurlp/agent/callback":
+			if r.URL.Query().Get("k1") != "abc" {
+				t.Errorf("callback lost its query: %s", r.URL.RawQuery)
+			}
+			msat, _ := strconv.ParseInt(r.URL.Query().Get("amount"), 10, 64)
+			json.NewEncoder(w).Encode(map[string]interface{}{"pr": invoice(msat), "routes": []string{}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, "agent@" + srv.Listener.Addr().String()
+}
+
+func TestL402Provider_LightningAddress(t *testing.T) {
+	metadataHash := sha256.Sum256([]byte(testLNURLMetadata))
+	var requested []int64
+	srv, address := newLNURLStandIn(t, 1000, 100000000, func(msat int64) string {
+		requested = append(requested, msat)
+		return signInvoice(t, msat, metadataHash)
+	})
+
+	backend := &recordingBackend{}
+	p := NewL402ProviderWithBackend(backend)
+	p.HTTPClient = srv.Client()
+
+	req := &router.PaymentRequirement{
+		Protocol: router.ProtocolL402,
+		L402Hash: "mac",
+		Details:  &router.LNURLPay{Target: address, AmountMsat: 21000},
+	}
+	cost, desc, err := p.EstimateCost(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != router.BTCToUSD(router.NewAmount(21000, router.Msat), p.BTCPriceUSD) || !strings.Contains(desc, "21 sats to agent@") {
+		t.Errorf("estimate = %s %q", cost, desc)
+	}
+
+	s, err := p.Settle(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.HeaderValue != "L402 mac:"+testPreimage || len(backend.paid) != 1 {
+		t.Errorf("settlement = %+v, paid %d invoices", s, len(backend.paid))
+	}
+	if len(requested) != 1 || requested[0] != 21000 {
+		t.Errorf("requested amounts = %v", requested)
+	}
+}
+
+func TestL402Provider_LNURLFixedAmount(t *testing.T) {
+	srv, address := newLNURLStandIn(t, 50000, 50000, func(int64) string { return "" })
+	p := NewL402ProviderWithBackend(&recordingBackend{})
+	p.HTTPClient = srv.Client()
+
+	_, desc, err := p.EstimateCost(&router.PaymentRequirement{
+		Protocol: router.ProtocolL402,
+		Details:  &router.LNURLPay{Target: address},
+	})
+	if err != nil || !strings.HasPrefix(desc, "50 sats") {
+		t.Errorf("estimate = %q, %v", desc, err)
+	}
+}
+
+func TestPayLNURL_RejectsBadInvoices(t *testing.T) {
+	metadataHash := sha256.Sum256([]byte(testLNURLMetadata))
+	tests := []struct {
+		name    string
+		amount  int64
+		invoice func(msat int64) string
+		want    string
+	}{
+		{"below min", 500, func(msat int64) string { return signInvoice(t, msat, metadataHash) }, "outside"},
+		{"wrong amount", 21000, func(msat int64) string { return signInvoice(t, msat+1000, metadataHash) }, "requested"},
+		{"wrong description hash", 21000, func(msat int64) string { return signInvoice(t, msat, sha256.Sum256([]byte("other"))) }, "description_hash"},
+		{"not an invoice", 21000, func(int64) string { return "lnbc210n1pfake" }, "invalid invoice"},
+	}
+	for _, tt := range tests {
+		srv, address := newLNURLStandIn(t, 1000, 1000000, tt.invoice)
+		backend := &recordingBackend{}
+		p := NewL402ProviderWithBackend(backend)
+		p.HTTPClient = srv.Client()
+
+		params, err := p.ResolveLNURL(context.Background(), address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p.PayLNURL(context.Background(), params, tt.amount)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+		if len(backend.paid) != 0 {
+			t.Errorf("%s: paid a rejected invoice", tt.name)
+		}
+	}
+}
+
+func TestLNURLPayURL(t *testing.T) {
+	for target, want := range map[string]string{
+		// LUD-01 example
+		"LNURL1DP68GURN8GHJ7UM9WFMXJCM99E3K7MF0V9CXJ0M385EKVCENXC6R2C35XVUKXEFCV5MKVV34X5EKZD3EV56NYD3HXQURZEPEXEJXXEPNXSCRVWFNV9NXZCN9XQ6XYEFHVGCXXCMYXYMNSERXFQ5FNS": "https://service.com/api?q=3fc3645b439ce8e7f2553a69e5267081d96dcd340693afabe04be7b0ccd178df",
+		"lightning:Agent@Example.com":     "https://Example.com/.well-known/lnurlp/agent",
+		"lnurlp://example.com/pay/agent":  "https://example.com/pay/agent",
+		"agent@abcdefghijklmnop.onion":    "http://abcdefghijklmnop.onion/.well-known/lnurlp/agent",
+		"lnurlp://abcdefghijklmnop.onion": "http://abcdefghijklmnop.onion",
+	} {
+		got, err := LNURLPayURL(target)
+		if err != nil || got != want {
+			t.Errorf("LNURLPayURL(%.30s) = %q, %v; want %q", target, got, err, want)
+		}
+	}
+	if _, err := LNURLPayURL("lnbc100u1ptest"); err == nil {
+		t.Error("expected an invoice to be rejected")
+	}
+}
+
+func TestResolveLNURLPay_ServiceError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"ERROR","reason":"unknown user"}`)
+	}))
+	defer srv.Close()
+
+	_, err := ResolveLNURLPay(context.Background(), srv.Client(), "nobody@"+srv.Listener.Addr().String())
+	if err == nil || !strings.Contains(err.Error(), "unknown user") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,402 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/nostr"
+	"github.com/joelklabo/agentpay/internal/websocket"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// NIP-47 event kinds.
+const (
+	nwcKindInfo     = 13194
+	nwcKindRequest  = 23194
+	nwcKindResponse = 23195
+)
+
+// NWC encryption schemes, as named in the wallet's info event.
+const (
+	NWCEncryptionNIP44 = "nip44_v2"
+	NWCEncryptionNIP04 = "nip04"
+)
+
+// NWCBackend pays invoices through a Nostr Wallet Connect (NIP-47) wallet
+// service: requests are signed with the connection's app secret, encrypted
+// to the wallet and exchanged over a relay.
+type NWCBackend struct {
+	walletPubKey string
+	relays       []string
+	secret       *big.Int
+	lud16        string
+
+	// Encryption forces NWCEncryptionNIP44 or NWCEncryptionNIP04. Empty
+	// picks NIP-44 when the wallet's info event advertises it.
+	Encryption string
+	// Timeout bounds how long to wait for the wallet's response.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	detected string // encryption read from the info event
+}
+
+// NewNWCBackend parses a nostr+walletconnect://<wallet pubkey>?relay=...&secret=...
+// connection string.
+func NewNWCBackend(uri string) (*NWCBackend, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("parse NWC URI: %w", err)
+	}
+	if u.Scheme != "nostr+walletconnect" && u.Scheme != "nostrwalletconnect" {
+		return nil, fmt.Errorf("NWC URI must start with nostr+walletconnect://, got %q", u.Scheme+"://")
+	}
+	wallet := u.Host
+	if wallet == "" {
+		wallet = u.Opaque
+	}
+	if b, err := hex.DecodeString(wallet); err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("NWC URI has an invalid wallet pubkey %q", wallet)
+	}
+
+	q := u.Query()
+	relays := q["relay"]
+	if len(relays) == 0 {
+		return nil, errors.New("NWC URI has no relay")
+	}
+	secret, err := nostr.ParsePrivateKey(q.Get("secret"))
+	if err != nil {
+		return nil, fmt.Errorf("NWC URI secret: %w", err)
+	}
+	return &NWCBackend{
+		walletPubKey: strings.ToLower(wallet),
+		relays:       relays,
+		secret:       secret,
+		lud16:        q.Get("lud16"),
+		Timeout:      60 * time.Second,
+	}, nil
+}
+
+// WalletPubKey returns the wallet service's hex pubkey.
+func (b *NWCBackend) WalletPubKey() string { return b.walletPubKey }
+
+// LightningAddress returns the lud16 address from the connection string, if any.
+func (b *NWCBackend) LightningAddress() string { return b.lud16 }
+
+// nwcError is the error object of a NIP-47 response.
+type nwcError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// nwcErrorsWorthRetrying are error codes another wallet might not hit.
+var nwcErrorsWorthRetrying = map[string]bool{
+	"RATE_LIMITED":         true,
+	"INSUFFICIENT_BALANCE": true,
+	"QUOTA_EXCEEDED":       true,
+	"PAYMENT_FAILED":       true,
+}
+
+// PayInvoice implements LightningBackend with the pay_invoice method.
+func (b *NWCBackend) PayInvoice(ctx context.Context, bolt11 string) (*LightningPayment, error) {
+	var result struct {
+		Preimage string `json:"preimage"`
+		FeesPaid int64  `json:"fees_paid"` // msat
+	}
+	if err := b.call(ctx, "pay_invoice", map[string]interface{}{"invoice": bolt11}, &result); err != nil {
+		return nil, err
+	}
+	preimage, err := hex.DecodeString(result.Preimage)
+	if err != nil || len(preimage) != 32 {
+		return nil, fmt.Errorf("NWC wallet returned an invalid preimage %q", result.Preimage)
+	}
+	hash := sha256.Sum256(preimage)
+	return &LightningPayment{
+		PaymentHash: hex.EncodeToString(hash[:]),
+		Preimage:    result.Preimage,
+		Fee:         router.NewAmount(result.FeesPaid, router.Msat),
+	}, nil
+}
+
+// Balance implements LightningBackend with the get_balance method.
+func (b *NWCBackend) Balance(ctx context.Context) (router.Amount, error) {
+	var result struct {
+		Balance int64 `json:"balance"` // msat
+	}
+	if err := b.call(ctx, "get_balance", map[string]interface{}{}, &result); err != nil {
+		return router.Amount{}, err
+	}
+	return router.NewAmount(result.Balance, router.Msat), nil
+}
+
+// CreateInvoice implements Invoicer with make_invoice.
+func (b *NWCBackend) CreateInvoice(ctx context.Context, amountMsat int64, description string) (*LightningInvoice, error) {
+	var result struct {
+		Invoice     string `json:"invoice"`
+		PaymentHash string `json:"payment_hash"`
+	}
+	err := b.call(ctx, "make_invoice", map[string]interface{}{
+		"amount":      amountMsat,
+		"description": description,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &LightningInvoice{Bolt11: result.Invoice, PaymentHash: result.PaymentHash}, nil
+}
+
+// call sends an encrypted NIP-47 request and decodes the wallet's result.
+func (b *NWCBackend) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	conn, err := b.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock reads once ctx ends
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	encryption, err := b.encryption(conn)
+	if err != nil {
+		return b.contextError(ctx, err)
+	}
+
+	plaintext, err := json.Marshal(map[string]interface{}{"method": method, "params": params})
+	if err != nil {
+		return fmt.Errorf("marshal %s request: %w", method, err)
+	}
+	req := &nostr.Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      nwcKindRequest,
+		Tags:      [][]string{{"p", b.walletPubKey}},
+	}
+	if encryption == NWCEncryptionNIP44 {
+		req.Tags = append(req.Tags, []string{"encryption", NWCEncryptionNIP44})
+	}
+	if req.Content, err = b.encrypt(encryption, string(plaintext)); err != nil {
+		return fmt.Errorf("encrypt %s request: %w", method, err)
+	}
+	if err := req.Sign(b.secret); err != nil {
+		return fmt.Errorf("sign %s request: %w", method, err)
+	}
+
+	// Subscribe to the response before publishing so it cannot be missed
+	filter := map[string]interface{}{
+		"kinds":   []int{nwcKindResponse},
+		"authors": []string{b.walletPubKey},
+		"#e":      []string{req.ID},
+	}
+	if err := writeRelay(conn, "REQ", "nwc-"+req.ID[:16], filter); err != nil {
+		return fmt.Errorf("NWC relay: %w", err)
+	}
+	if err := writeRelay(conn, "EVENT", req); err != nil {
+		return fmt.Errorf("NWC relay: %w", err)
+	}
+
+	timeout := b.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	timer := time.AfterFunc(timeout, func() { conn.Close() })
+	defer timer.Stop()
+
+	for {
+		msg, err := readRelay(conn)
+		if err != nil {
+			err = b.contextError(ctx, fmt.Errorf("NWC relay: %w", err))
+			if method == "pay_invoice" {
+				// The wallet may still settle; paying again elsewhere could pay twice
+				return fmt.Errorf("no NWC response to pay_invoice within %s: %v", timeout, err)
+			}
+			return err
+		}
+
+		switch msg.typ {
+		case "OK":
+			var accepted bool
+			if len(msg.args) >= 3 && json.Unmarshal(msg.args[2], &accepted) == nil && !accepted {
+				var reason string
+				if len(msg.args) >= 4 {
+					json.Unmarshal(msg.args[3], &reason)
+				}
+				return router.Retryable(fmt.Errorf("NWC relay rejected the request: %s", reason))
+			}
+		case "EVENT":
+			if len(msg.args) < 3 {
+				continue
+			}
+			var ev nostr.Event
+			if json.Unmarshal(msg.args[2], &ev) != nil || ev.Kind != nwcKindResponse ||
+				ev.PubKey != b.walletPubKey || ev.Tag("e") != req.ID || ev.Verify() != nil {
+				continue
+			}
+			return b.decodeResponse(method, &ev, result)
+		}
+	}
+}
+
+func (b *NWCBackend) decodeResponse(method string, ev *nostr.Event, result interface{}) error {
+	scheme := NWCEncryptionNIP44
+	if strings.Contains(ev.Content, "?iv=") {
+		scheme = NWCEncryptionNIP04
+	}
+	plaintext, err := b.decrypt(scheme, ev.Content)
+	if err != nil {
+		return fmt.Errorf("decrypt NWC response: %w", err)
+	}
+
+	var resp struct {
+		ResultType string          `json:"result_type"`
+		Error      *nwcError       `json:"error"`
+		Result     json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(plaintext), &resp); err != nil {
+		return fmt.Errorf("parse NWC response: %w", err)
+	}
+	if resp.Error != nil {
+		err := fmt.Errorf("NWC %s: %s (%s)", method, resp.Error.Message, resp.Error.Code)
+		if nwcErrorsWorthRetrying[resp.Error.Code] {
+			err = router.Retryable(err)
+		}
+		return err
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("parse NWC %s result: %w", method, err)
+	}
+	return nil
+}
+
+// dial connects to the first reachable relay.
+func (b *NWCBackend) dial(ctx context.Context) (*websocket.Conn, error) {
+	var lastErr error
+	for _, relay := range b.relays {
+		conn, err := websocket.Dial(ctx, relay)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("connect to NWC relay: %w", lastErr)
+}
+
+// encryption returns the scheme to use, reading the wallet's info event the
+// first time when none is configured.
+func (b *NWCBackend) encryption(conn *websocket.Conn) (string, error) {
+	if b.Encryption != "" {
+		return b.Encryption, nil
+	}
+	b.mu.Lock()
+	detected := b.detected
+	b.mu.Unlock()
+	if detected != "" {
+		return detected, nil
+	}
+
+	filter := map[string]interface{}{
+		"kinds":   []int{nwcKindInfo},
+		"authors": []string{b.walletPubKey},
+		"limit":   1,
+	}
+	if err := writeRelay(conn, "REQ", "nwc-info", filter); err != nil {
+		return "", fmt.Errorf("NWC relay: %w", err)
+	}
+
+	// Wallets that predate the encryption tag only speak NIP-04
+	detected = NWCEncryptionNIP04
+	for {
+		msg, err := readRelay(conn)
+		if err != nil {
+			return "", fmt.Errorf("NWC relay: %w", err)
+		}
+		if msg.typ == "EOSE" {
+			break
+		}
+		if msg.typ != "EVENT" || len(msg.args) < 3 {
+			continue
+		}
+		var ev nostr.Event
+		if json.Unmarshal(msg.args[2], &ev) != nil || ev.PubKey != b.walletPubKey || ev.Verify() != nil {
+			continue
+		}
+		for _, scheme := range strings.Fields(ev.Tag("encryption")) {
+			if scheme == NWCEncryptionNIP44 {
+				detected = NWCEncryptionNIP44
+			}
+		}
+	}
+	writeRelay(conn, "CLOSE", "nwc-info")
+
+	b.mu.Lock()
+	b.detected = detected
+	b.mu.Unlock()
+	return detected, nil
+}
+
+func (b *NWCBackend) encrypt(scheme, plaintext string) (string, error) {
+	if scheme == NWCEncryptionNIP04 {
+		return nostr.EncryptNIP04(b.secret, b.walletPubKey, plaintext)
+	}
+	key, err := nostr.ConversationKey(b.secret, b.walletPubKey)
+	if err != nil {
+		return "", err
+	}
+	return nostr.EncryptNIP44(key, plaintext)
+}
+
+func (b *NWCBackend) decrypt(scheme, content string) (string, error) {
+	if scheme == NWCEncryptionNIP04 {
+		return nostr.DecryptNIP04(b.secret, b.walletPubKey, content)
+	}
+	key, err := nostr.ConversationKey(b.secret, b.walletPubKey)
+	if err != nil {
+		return "", err
+	}
+	return nostr.DecryptNIP44(key, content)
+}
+
+// contextError prefers ctx's error once it has ended, since closing the
+// connection to unblock a read surfaces as an unrelated I/O error.
+func (b *NWCBackend) contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// relayMessage is a relay-to-client message such as ["EVENT", sub, event].
+type relayMessage struct {
+	typ  string
+	args []json.RawMessage
+}
+
+func writeRelay(conn *websocket.Conn, typ string, args ...interface{}) error {
+	msg, err := json.Marshal(append([]interface{}{typ}, args...))
+	if err != nil {
+		return err
+	}
+	return conn.WriteText(msg)
+}
+
+func readRelay(conn *websocket.Conn) (*relayMessage, error) {
+	data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var args []json.RawMessage
+	if err := json.Unmarshal(data, &args); err != nil || len(args) == 0 {
+		return nil, fmt.Errorf("malformed relay message %.100s", data)
+	}
+	msg := &relayMessage{args: args}
+	json.Unmarshal(args[0], &msg.typ)
+	return msg, nil
+}
@@ -0,0 +1,298 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/nostr"
+	"github.com/joelklabo/agentpay/internal/websocket"
+	"github.com/joelklabo/agentpay/router"
+)
+
+const (
+	testNWCSecret   = "0000000000000000000000000000000000000000000000000000000000000007"
+	testNWCPreimage = "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"
+)
+
+var testWalletKey = big.NewInt(11)
+
+// nwcStandIn is a relay with a NIP-47 wallet service attached: requests
+// addressed to the wallet are decrypted, answered by handle and published
+// back to the subscriptions that match them.
+type nwcStandIn struct {
+	t          *testing.T
+	encryption string // advertised in the info event; "" publishes none
+	handle     func(method string, params json.RawMessage) (result interface{}, errCode string)
+
+	mu       sync.Mutex
+	requests []*nostr.Event
+}
+
+func (s *nwcStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	subs := map[string]map[string]json.RawMessage{}
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg []json.RawMessage
+		json.Unmarshal(data, &msg)
+		var typ string
+		json.Unmarshal(msg[0], &typ)
+
+		switch typ {
+		case "REQ":
+			var id string
+			var filter map[string]json.RawMessage
+			json.Unmarshal(msg[1], &id)
+			json.Unmarshal(msg[2], &filter)
+			subs[id] = filter
+			if strings.Contains(string(filter["kinds"]), "13194") && s.encryption != "" {
+				info := &nostr.Event{CreatedAt: 1700000000, Kind: nwcKindInfo,
+					Tags: [][]string{{"encryption", s.encryption}}, Content: "pay_invoice get_balance"}
+				info.Sign(testWalletKey)
+				s.send(conn, "EVENT", id, info)
+			}
+			s.send(conn, "EOSE", id)
+		case "CLOSE":
+			var id string
+			json.Unmarshal(msg[1], &id)
+			delete(subs, id)
+		case "EVENT":
+			var req nostr.Event
+			json.Unmarshal(msg[1], &req)
+			if err := req.Verify(); err != nil {
+				s.send(conn, "OK", req.ID, false, "invalid: "+err.Error())
+				continue
+			}
+			s.send(conn, "OK", req.ID, true, "")
+			s.mu.Lock()
+			s.requests = append(s.requests, &req)
+			s.mu.Unlock()
+
+			resp := s.respond(&req)
+			if resp == nil {
+				continue
+			}
+			for id, filter := range subs {
+				if strings.Contains(string(filter["#e"]), req.ID) {
+					s.send(conn, "EVENT", id, resp)
+				}
+			}
+		}
+	}
+}
+
+func (s *nwcStandIn) respond(req *nostr.Event) *nostr.Event {
+	nip04 := strings.Contains(req.Content, "?iv=")
+	var plaintext string
+	var err error
+	if nip04 {
+		plaintext, err = nostr.DecryptNIP04(testWalletKey, req.PubKey, req.Content)
+	} else {
+		key, _ := nostr.ConversationKey(testWalletKey, req.PubKey)
+		plaintext, err = nostr.DecryptNIP44(key, req.Content)
+	}
+	if err != nil {
+		s.t.Errorf("wallet could not decrypt the request: %v", err)
+		return nil
+	}
+	var call struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	json.Unmarshal([]byte(plaintext), &call)
+
+	result, code := s.handle(call.Method, call.Params)
+	if result == nil && code == "" {
+		return nil // never answer
+	}
+	body := map[string]interface{}{"result_type": call.Method, "result": result}
+	if code != "" {
+		body = map[string]interface{}{"result_type": call.Method, "error": map[string]string{"code": code, "message": "wallet says no"}}
+	}
+	raw, _ := json.Marshal(body)
+
+	resp := &nostr.Event{CreatedAt: req.CreatedAt, Kind: nwcKindResponse,
+		Tags: [][]string{{"p", req.PubKey}, {"e", req.ID}}}
+	if nip04 {
+		resp.Content, _ = nostr.EncryptNIP04(testWalletKey, req.PubKey, string(raw))
+	} else {
+		key, _ := nostr.ConversationKey(testWalletKey, req.PubKey)
+		resp.Content, _ = nostr.EncryptNIP44(key, string(raw))
+	}
+	resp.Sign(testWalletKey)
+	return resp
+}
+
+func (s *nwcStandIn) firstRequest() *nostr.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[0]
+}
+
+func (s *nwcStandIn) send(conn *websocket.Conn, args ...interface{}) {
+	data, _ := json.Marshal(args)
+	conn.WriteText(data)
+}
+
+// newNWCStandIn starts the stand-in and returns a backend connected to it.
+func newNWCStandIn(t *testing.T, s *nwcStandIn) *NWCBackend {
+	t.Helper()
+	s.t = t
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	relay := "ws" + strings.TrimPrefix(srv.URL, "http")
+	uri := fmt.Sprintf("nostr+walletconnect://%s?relay=%s&secret=%s&lud16=agent@example.com",
+		nostr.PublicKey(testWalletKey), relay, testNWCSecret)
+	b, err := NewNWCBackend(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNWCBackend_PayNIP44(t *testing.T) {
+	s := &nwcStandIn{encryption: "nip44_v2 nip04", handle: func(method string, params json.RawMessage) (interface{}, string) {
+		if method != "pay_invoice" || !strings.Contains(string(params), `"invoice":"lnbc20u1ptest"`) {
+			return nil, "NOT_IMPLEMENTED"
+		}
+		return map[string]interface{}{"preimage": testNWCPreimage, "fees_paid": 1500}, ""
+	}}
+	b := newNWCStandIn(t, s)
+
+	p := NewL402ProviderWithBackend(b)
+	settlement, err := p.Settle(context.Background(), &router.PaymentRequirement{
+		Protocol:    router.ProtocolL402,
+		L402Invoice: "lnbc20u1ptest",
+		L402Hash:    "mac",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	preimage, _ := hex.DecodeString(testNWCPreimage)
+	hash := sha256.Sum256(preimage)
+	if settlement.HeaderValue != "L402 mac:"+testNWCPreimage || settlement.TxID != hex.EncodeToString(hash[:]) {
+		t.Errorf("settlement = %+v", settlement)
+	}
+	if settlement.Fee == nil || *settlement.Fee != router.NewAmount(1500, router.Msat) {
+		t.Errorf("fee = %v, want 1500 msat", settlement.Fee)
+	}
+
+	req := s.firstRequest()
+	if req.Tag("p") != b.WalletPubKey() || req.Tag("encryption") != NWCEncryptionNIP44 {
+		t.Errorf("request tags = %v", req.Tags)
+	}
+	if req.PubKey != nostr.PublicKey(big.NewInt(7)) {
+		t.Errorf("request signed by %s, want the app secret's pubkey", req.PubKey)
+	}
+	if b.LightningAddress() != "agent@example.com" {
+		t.Errorf("lud16 = %q", b.LightningAddress())
+	}
+}
+
+func TestNWCBackend_BalanceNIP04(t *testing.T) {
+	// No info event: the wallet is assumed to speak only NIP-04
+	s := &nwcStandIn{handle: func(method string, params json.RawMessage) (interface{}, string) {
+		return map[string]interface{}{"balance": 21000000}, ""
+	}}
+	b := newNWCStandIn(t, s)
+
+	bal, err := b.Balance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal != router.NewAmount(21000000, router.Msat) {
+		t.Errorf("balance = %s", bal)
+	}
+	if req := s.firstRequest(); !strings.Contains(req.Content, "?iv=") || req.Tag("encryption") != "" {
+		t.Errorf("expected a NIP-04 request, got tags %v", req.Tags)
+	}
+}
+
+func TestNWCBackend_CreateInvoice(t *testing.T) {
+	s := &nwcStandIn{handle: func(method string, params json.RawMessage) (interface{}, string) {
+		var p struct {
+			Amount      int64  `json:"amount"`
+			Description string `json:"description"`
+		}
+		json.Unmarshal(params, &p)
+		if method != "make_invoice" || p.Amount != 21000 || p.Description != "answer" {
+			return nil, "NOT_IMPLEMENTED"
+		}
+		return map[string]interface{}{"type": "incoming", "invoice": "lnbc210n1ptest", "payment_hash": testPayHash, "amount": 21000}, ""
+	}}
+	b := newNWCStandIn(t, s)
+
+	inv, err := b.CreateInvoice(context.Background(), 21000, "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.PaymentHash != testPayHash || inv.Bolt11 != "lnbc210n1ptest" {
+		t.Errorf("invoice = %+v", inv)
+	}
+}
+
+func TestNWCBackend_Errors(t *testing.T) {
+	for code, retryable := range map[string]bool{"INSUFFICIENT_BALANCE": true, "RESTRICTED": false} {
+		s := &nwcStandIn{encryption: "nip44_v2", handle: func(string, json.RawMessage) (interface{}, string) {
+			return nil, code
+		}}
+		b := newNWCStandIn(t, s)
+		_, err := b.PayInvoice(context.Background(), "lnbc10n1ptest")
+		if err == nil || !strings.Contains(err.Error(), code) {
+			t.Fatalf("%s: err = %v", code, err)
+		}
+		if router.IsRetryable(err) != retryable {
+			t.Errorf("%s: retryable = %v, want %v", code, router.IsRetryable(err), retryable)
+		}
+	}
+}
+
+func TestNWCBackend_Timeout(t *testing.T) {
+	s := &nwcStandIn{encryption: "nip44_v2", handle: func(string, json.RawMessage) (interface{}, string) {
+		return nil, ""
+	}}
+	b := newNWCStandIn(t, s)
+	b.Timeout = 200 * time.Millisecond
+
+	_, err := b.PayInvoice(context.Background(), "lnbc10n1ptest")
+	if err == nil {
+		t.Fatal("expected a timeout")
+	}
+	// The payment may still be in flight, so it must not fail over
+	if router.IsRetryable(err) {
+		t.Errorf("an unanswered pay_invoice should not be retryable: %v", err)
+	}
+}
+
+func TestNewNWCBackend_Invalid(t *testing.T) {
+	wallet := nostr.PublicKey(testWalletKey)
+	for _, uri := range []string{
+		"https://example.com",
+		"nostr+walletconnect://abcd?relay=wss://relay.example&secret=" + testNWCSecret,
+		"nostr+walletconnect://" + wallet + "?secret=" + testNWCSecret,
+		"nostr+walletconnect://" + wallet + "?relay=wss://relay.example&secret=zz",
+	} {
+		if _, err := NewNWCBackend(uri); err == nil {
+			t.Errorf("%s: expected an error", uri)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+// PhoenixdBackend pays invoices from a phoenixd node over its HTTP API,
+// authenticated with the http-password from phoenix.conf.
+type PhoenixdBackend struct {
+	url      string
+	password string
+	client   *http.Client
+}
+
+// NewPhoenixdBackend creates a backend for the phoenixd API at url (e.g.
+// "http://localhost:9740").
+func NewPhoenixdBackend(url, password string) *PhoenixdBackend {
+	return &PhoenixdBackend{
+		url:      strings.TrimRight(url, "/"),
+		password: password,
+		client:   &http.Client{},
+	}
+}
+
+// do sends an authenticated request and returns the response body.
+func (b *PhoenixdBackend) do(ctx context.Context, method, path string, form url.Values) ([]byte, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, b.url+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("build phoenixd request: %w", err)
+	}
+	// phoenixd ignores the username
+	httpReq.SetBasicAuth("", b.password)
+	if form != nil {
+		httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		return nil, requestError("phoenixd %s request failed: %w", err, path)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		if strings.HasPrefix(path, "/pay") {
+			return nil, submitError("phoenixd "+path+" HTTP %d: %s", resp.StatusCode, respBody)
+		}
+		return nil, statusError("phoenixd "+path+" HTTP %d: %s", resp.StatusCode, respBody)
+	}
+	return respBody, nil
+}
+
+// PayInvoice implements LightningBackend with /payinvoice, which blocks
+// until the payment settles or fails.
+func (b *PhoenixdBackend) PayInvoice(ctx context.Context, bolt11 string) (*LightningPayment, error) {
+	body, err := b.do(ctx, "POST", "/payinvoice", url.Values{"invoice": {bolt11}})
+	if err != nil {
+		return nil, err
+	}
+	return phoenixdPayment(body)
+}
+
+// PayOffer implements OfferPayer with /payoffer, which fetches the offer's
+// invoice and pays it. phoenixd takes whole sats.
+func (b *PhoenixdBackend) PayOffer(ctx context.Context, offer string, amountMsat int64) (*LightningPayment, error) {
+	form := url.Values{"offer": {offer}}
+	if amountMsat > 0 {
+		if amountMsat%1000 != 0 {
+			return nil, fmt.Errorf("phoenixd cannot pay %d msat: it pays whole sats", amountMsat)
+		}
+		form.Set("amountSat", fmt.Sprint(amountMsat/1000))
+	}
+	body, err := b.do(ctx, "POST", "/payoffer", form)
+	if err != nil {
+		return nil, err
+	}
+	return phoenixdPayment(body)
+}
+
+// CreateInvoice implements Invoicer with /createinvoice. phoenixd invoices
+// are in whole sats.
+func (b *PhoenixdBackend) CreateInvoice(ctx context.Context, amountMsat int64, description string) (*LightningInvoice, error) {
+	if amountMsat <= 0 || amountMsat%1000 != 0 {
+		return nil, fmt.Errorf("phoenixd cannot invoice %d msat: it invoices whole sats", amountMsat)
+	}
+	body, err := b.do(ctx, "POST", "/createinvoice", url.Values{
+		"amountSat":   {fmt.Sprint(amountMsat / 1000)},
+		"description": {description},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		PaymentHash string `json:"paymentHash"`
+		Serialized  string `json:"serialized"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse createinvoice response: %w", err)
+	}
+	return &LightningInvoice{Bolt11: result.Serialized, PaymentHash: result.PaymentHash}, nil
+}
+
+// phoenixdPayment decodes the response /payinvoice and /payoffer share.
+func phoenixdPayment(body []byte) (*LightningPayment, error) {
+	var result struct {
+		PaymentHash     string `json:"paymentHash"`
+		PaymentPreimage string `json:"paymentPreimage"`
+		RoutingFeeSat   int64  `json:"routingFeeSat"`
+		Reason          string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse phoenixd payment response: %w", err)
+	}
+	if result.PaymentPreimage == "" {
+		// A failed payment is final, so another wallet can safely try
+		reason := result.Reason
+		if reason == "" {
+			reason = string(body)
+		}
+		return nil, router.Retryable(fmt.Errorf("phoenixd payment failed: %s", reason))
+	}
+
+	return &LightningPayment{
+		PaymentHash: result.PaymentHash,
+		Preimage:    result.PaymentPreimage,
+		Fee:         router.NewAmount(result.RoutingFeeSat*1000, router.Msat),
+	}, nil
+}
+
+// Balance implements LightningBackend with /getbalance.
+func (b *PhoenixdBackend) Balance(ctx context.Context) (router.Amount, error) {
+	body, err := b.do(ctx, "GET", "/getbalance", nil)
+	if err != nil {
+		return router.Amount{}, err
+	}
+	var result struct {
+		BalanceSat int64 `json:"balanceSat"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return router.Amount{}, fmt.Errorf("parse balance response: %w", err)
+	}
+	return router.NewAmount(result.BalanceSat*1000, router.Msat), nil
+}
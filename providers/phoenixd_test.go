@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joelklabo/agentpay/router"
+)
+
+// newPhoenixdStandIn serves phoenixd's API. pay answers /payinvoice and
+// /payoffer.
+func newPhoenixdStandIn(t *testing.T, pay http.HandlerFunc) *PhoenixdBackend {
+	t.Helper()
+	mux := http.NewServeMux()
+	if pay != nil {
+		mux.HandleFunc("POST /payinvoice", pay)
+		mux.HandleFunc("POST /payoffer", pay)
+	}
+	mux.HandleFunc("POST /createinvoice", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("amountSat") != "21" || r.FormValue("description") != "answer" {
+			t.Errorf("form = %v", r.Form)
+		}
+		w.Write([]byte(`{"amountSat":21,"paymentHash":"` + testPayHash + `","serialized":"lnbc210n1ptest"}`))
+	})
+	mux.HandleFunc("GET /getbalance", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"balanceSat":42000,"feeCreditSat":120}`))
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, ok := r.BasicAuth(); !ok || pass != "s3cret" {
+			http.Error(w, "Invalid authentication (use basic auth with the http password set in phoenix.conf)", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return NewPhoenixdBackend(srv.URL, "s3cret")
+}
+
+func TestPhoenixdBackend_Pay(t *testing.T) {
+	b := newPhoenixdStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("invoice"); got != "lnbc20u1ptest" {
+			t.Errorf("invoice = %q", got)
+		}
+		w.Write([]byte(`{"recipientAmountSat":2000,"routingFeeSat":4,"paymentId":"4c5f6a1e-2f4c-4b8e-9d1f-3c2b1a0f9e8d",` +
+			`"paymentHash":"` + testPayHash + `","paymentPreimage":"` + testPreimage + `"}`))
+	})
+
+	p := NewL402ProviderWithBackend(b)
+	s, err := p.Settle(context.Background(), &router.PaymentRequirement{
+		Protocol:    router.ProtocolL402,
+		L402Invoice: "lnbc20u1ptest",
+		L402Hash:    "mac",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.HeaderValue != "L402 mac:"+testPreimage || s.TxID != testPayHash || s.Preimage != testPreimage {
+		t.Errorf("settlement = %+v", s)
+	}
+	if s.Fee == nil || *s.Fee != router.NewAmount(4000, router.Msat) {
+		t.Errorf("fee = %v, want 4000 msat", s.Fee)
+	}
+}
+
+func TestPhoenixdBackend_PayFailed(t *testing.T) {
+	b := newPhoenixdStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"paymentId":"4c5f6a1e","paymentHash":"` + testPayHash + `","reason":"route not found"}`))
+	})
+	_, err := b.PayInvoice(context.Background(), "lnbc10n1ptest")
+	if err == nil || !router.IsRetryable(err) {
+		t.Errorf("err = %v, want a retryable failure", err)
+	}
+}
+
+func TestPhoenixdBackend_PayOffer(t *testing.T) {
+	b := newPhoenixdStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("offer") != testOffer || r.FormValue("amountSat") != "21" {
+			t.Errorf("form = %v", r.Form)
+		}
+		w.Write([]byte(`{"recipientAmountSat":21,"routingFeeSat":1,"paymentId":"4c5f6a1e",` +
+			`"paymentHash":"` + testPayHash + `","paymentPreimage":"` + testPreimage + `"}`))
+	})
+
+	payment, err := b.PayOffer(context.Background(), testOffer, 21000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Preimage != testPreimage || payment.Fee != router.NewAmount(1000, router.Msat) {
+		t.Errorf("payment = %+v", payment)
+	}
+	if _, err := b.PayOffer(context.Background(), testOffer, 21500); err == nil {
+		t.Error("expected a sub-sat amount to be rejected")
+	}
+}
+
+func TestPhoenixdBackend_CreateInvoice(t *testing.T) {
+	b := newPhoenixdStandIn(t, nil)
+	inv, err := b.CreateInvoice(context.Background(), 21000, "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.PaymentHash != testPayHash || inv.Bolt11 != "lnbc210n1ptest" {
+		t.Errorf("invoice = %+v", inv)
+	}
+	if _, err := b.CreateInvoice(context.Background(), 21500, "answer"); err == nil {
+		t.Error("expected a sub-sat amount to be rejected")
+	}
+}
+
+func TestPhoenixdBackend_Balance(t *testing.T) {
+	b := newPhoenixdStandIn(t, nil)
+	bal, err := b.Balance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal != router.NewAmount(42000000, router.Msat) {
+		t.Errorf("balance = %s", bal)
+	}
+
+	wrong := NewPhoenixdBackend(b.url, "bogus")
+	if _, err := wrong.Balance(context.Background()); err == nil {
+		t.Error("expected an error for a bad password")
+	}
+}
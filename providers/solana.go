@@ -7,11 +7,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/joelklabo/agentpay/router"
 )
 
 // SolanaProvider handles direct Solana SPL token payments via AgentWallet.
 // This covers cases where a service accepts direct Solana payments rather
-// than using the x402 protocol.
+// than using the x402 protocol: it settles Solana Pay transfer requests with
+// USDC. AgentWallet's transfer action does not attach reference keys to the
+// transaction, so the proof carries the reference alongside the signature
+// for the server to match the payment.
 type SolanaProvider struct {
 	apiBase  string
 	username string
@@ -33,13 +38,17 @@ func NewSolanaProvider(apiBase, username, token, network string) *SolanaProvider
 
 // TransferUSDC sends USDC on Solana to a recipient address.
 func (p *SolanaProvider) TransferUSDC(ctx context.Context, to string, amountMicroUSDC string) (string, error) {
+	return p.transferUSDC(ctx, p.network, to, amountMicroUSDC)
+}
+
+func (p *SolanaProvider) transferUSDC(ctx context.Context, network, to, amountMicroUSDC string) (string, error) {
 	url := fmt.Sprintf("%s/api/wallets/%s/actions/transfer-solana", p.apiBase, p.username)
 
 	payload := map[string]string{
 		"to":      to,
 		"amount":  amountMicroUSDC,
 		"asset":   "usdc",
-		"network": p.network,
+		"network": network,
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -55,13 +64,13 @@ func (p *SolanaProvider) TransferUSDC(ctx context.Context, to string, amountMicr
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("transfer request: %w", err)
+		return "", requestError("transfer request: %w", err)
 	}
 	defer resp.Body.Close()
 	respBody, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("transfer HTTP %d: %s", resp.StatusCode, string(respBody))
+		return "", submitError("transfer HTTP %d: %s", resp.StatusCode, respBody)
 	}
 
 	var result struct {
@@ -99,6 +108,16 @@ func (p *SolanaProvider) GetBalance(ctx context.Context) (map[string]interface{}
 	return balances, nil
 }
 
+// Balance returns the wallet's USDC balance on the cluster req pays on,
+// valued in USD. It implements router.BalanceProvider.
+func (p *SolanaProvider) Balance(ctx context.Context, req *router.PaymentRequirement) (router.Amount, error) {
+	_, _, cluster, err := solanaPayUSDC(req)
+	if err != nil {
+		return router.Amount{}, err
+	}
+	return agentWalletUSDC(ctx, p.client, p.apiBase, p.username, p.token, "solana:"+cluster)
+}
+
 // RequestDevnetSOL requests free devnet SOL from the AgentWallet faucet.
 func (p *SolanaProvider) RequestDevnetSOL(ctx context.Context) (string, error) {
 	url := fmt.Sprintf("%s/api/wallets/%s/actions/faucet-sol", p.apiBase, p.username)
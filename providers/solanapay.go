@@ -0,0 +1,215 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/joelklabo/agentpay/internal/base58"
+	"github.com/joelklabo/agentpay/internal/solana"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// USDC mint addresses on Solana. A Solana Pay request names the mint, which
+// also tells us the cluster to transfer on.
+var solanaUSDCMints = map[string]string{
+	"EPjFWdd5AufqSSqeM2qN1xxoxmTbX6CmaVgDaZdgF3jT": "mainnet",
+	"4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU": "devnet",
+}
+
+func (p *SolanaProvider) Protocol() router.Protocol {
+	return router.ProtocolSolanaPay
+}
+
+// Capabilities reports that the provider only settles on Solana.
+func (p *SolanaProvider) Capabilities() router.Capabilities {
+	return router.Capabilities{Networks: []string{"solana"}}
+}
+
+func (p *SolanaProvider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	spr, amount, _, err := solanaPayUSDC(req)
+	if err != nil {
+		return router.Amount{}, "", err
+	}
+	usd := usdcToUSD(amount)
+	return usd, fmt.Sprintf("%s USDC to %s", spr.Amount, shortAddress(spr.Recipient)), nil
+}
+
+func (p *SolanaProvider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
+	s, err := p.Settle(ctx, req)
+	if err != nil {
+		return "", "", err
+	}
+	return s.HeaderName, s.HeaderValue, nil
+}
+
+// Settle transfers the requested USDC and returns the transaction signature
+// and reference as an Authorization proof:
+//
+//	Authorization: SolanaPay signature="<sig>", reference="<ref>"
+func (p *SolanaProvider) Settle(ctx context.Context, req *router.PaymentRequirement) (*router.Settlement, error) {
+	spr, amount, network, err := solanaPayUSDC(req)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := p.transferUSDC(ctx, network, spr.Recipient, strconv.FormatInt(amount.Units, 10))
+	if err != nil {
+		return nil, err
+	}
+	if sig == "" {
+		return nil, fmt.Errorf("transfer returned no transaction signature")
+	}
+
+	return solanaPaySettlement(spr, sig), nil
+}
+
+// solanaPaySettlement is the Authorization proof for transaction sig.
+func solanaPaySettlement(spr *router.SolanaPayRequest, sig string) *router.Settlement {
+	proof := fmt.Sprintf("SolanaPay signature=%q", sig)
+	if ref := spr.Reference(); ref != "" {
+		proof += fmt.Sprintf(", reference=%q", ref)
+	}
+	return &router.Settlement{
+		HeaderName:  "Authorization",
+		HeaderValue: proof,
+		TxID:        sig,
+		Reference:   spr.Reference(),
+	}
+}
+
+// SolanaPayKeyProvider settles Solana Pay transfer requests with a local
+// keypair. It builds the transfer itself, so unlike AgentWallet's transfer
+// action the reference keys ride on the transfer instruction as read-only
+// accounts and the memo is recorded on-chain, as the spec requires.
+type SolanaPayKeyProvider struct {
+	svm *SVMKeyProvider
+
+	// Sender broadcasts the signed transfer.
+	Sender TransactionSender
+}
+
+// SolanaPay returns a Solana Pay provider that signs with p's keypair and
+// broadcasts through sender.
+func (p *SVMKeyProvider) SolanaPay(sender TransactionSender) *SolanaPayKeyProvider {
+	return &SolanaPayKeyProvider{svm: p, Sender: sender}
+}
+
+func (p *SolanaPayKeyProvider) Protocol() router.Protocol {
+	return router.ProtocolSolanaPay
+}
+
+// Capabilities reports that the provider only settles on Solana.
+func (p *SolanaPayKeyProvider) Capabilities() router.Capabilities {
+	return router.Capabilities{Networks: []string{"solana"}}
+}
+
+func (p *SolanaPayKeyProvider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	spr, amount, _, err := solanaPayUSDC(req)
+	if err != nil {
+		return router.Amount{}, "", err
+	}
+	desc := fmt.Sprintf("%s USDC to %s (local key)", spr.Amount, shortAddress(spr.Recipient))
+	return usdcToUSD(amount), desc, nil
+}
+
+func (p *SolanaPayKeyProvider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
+	s, err := p.Settle(ctx, req)
+	if err != nil {
+		return "", "", err
+	}
+	return s.HeaderName, s.HeaderValue, nil
+}
+
+// Settle signs and broadcasts a USDC TransferChecked to the recipient's
+// associated token account, preceded by the request's memo, and returns the
+// same proof as SolanaProvider.
+func (p *SolanaPayKeyProvider) Settle(ctx context.Context, req *router.PaymentRequirement) (*router.Settlement, error) {
+	spr, amount, cluster, err := solanaPayUSDC(req)
+	if err != nil {
+		return nil, err
+	}
+	mint, err := solana.ParsePublicKey(spr.SPLToken)
+	if err != nil {
+		return nil, fmt.Errorf("spl-token: %w", err)
+	}
+	recipient, err := solana.ParsePublicKey(spr.Recipient)
+	if err != nil {
+		return nil, fmt.Errorf("recipient: %w", err)
+	}
+	source, err := solana.AssociatedTokenAddress(p.svm.address, mint, solana.TokenProgramID)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := solana.AssociatedTokenAddress(recipient, mint, solana.TokenProgramID)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := solana.TransferChecked(solana.TokenProgramID, source, mint, destination, p.svm.address,
+		uint64(amount.Units), uint8(router.USDC.Decimals))
+	for _, ref := range spr.References {
+		key, err := solana.ParsePublicKey(ref)
+		if err != nil {
+			return nil, fmt.Errorf("reference: %w", err)
+		}
+		transfer.Accounts = append(transfer.Accounts, solana.AccountMeta{PublicKey: key})
+	}
+	instructions := []solana.Instruction{
+		solana.SetComputeUnitLimit(p.svm.ComputeUnitLimit),
+		solana.SetComputeUnitPrice(p.svm.ComputeUnitPrice),
+	}
+	if spr.Memo != "" {
+		instructions = append(instructions, solana.Memo(spr.Memo))
+	}
+	instructions = append(instructions, transfer)
+
+	blockhash, err := p.svm.Blockhash.LatestBlockhash(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := solana.NewMessage(p.svm.address, instructions, blockhash)
+	if err != nil {
+		return nil, err
+	}
+	tx := solana.NewTransaction(msg)
+	if err := tx.Sign(p.svm.key); err != nil {
+		return nil, err
+	}
+
+	sig, err := p.Sender.SendTransaction(ctx, cluster, tx.Serialize())
+	if err != nil {
+		return nil, err
+	}
+	if sig == "" {
+		sig = base58.Encode(tx.Signatures[0][:])
+	}
+	return solanaPaySettlement(spr, sig), nil
+}
+
+// solanaPayUSDC extracts a USDC transfer request, its amount and cluster.
+func solanaPayUSDC(req *router.PaymentRequirement) (*router.SolanaPayRequest, router.Amount, string, error) {
+	spr, ok := req.Details.(*router.SolanaPayRequest)
+	if !ok {
+		return nil, router.Amount{}, "", fmt.Errorf("no Solana Pay request")
+	}
+	network, ok := solanaUSDCMints[spr.SPLToken]
+	if !ok {
+		if spr.SPLToken == "" {
+			return nil, router.Amount{}, "", fmt.Errorf("native SOL transfers are not supported, only USDC")
+		}
+		return nil, router.Amount{}, "", fmt.Errorf("unsupported token %s, only USDC", spr.SPLToken)
+	}
+	amount, err := router.ParseDecimal(spr.Amount, router.USDC)
+	if err != nil {
+		return nil, router.Amount{}, "", fmt.Errorf("invalid amount %q: %w", spr.Amount, err)
+	}
+	return spr, amount, network, nil
+}
+
+func shortAddress(addr string) string {
+	if len(addr) <= 12 {
+		return addr
+	}
+	return addr[:4] + "…" + addr[len(addr)-4:]
+}
@@ -0,0 +1,197 @@
+package providers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/joelklabo/agentpay/internal/solana"
+	"github.com/joelklabo/agentpay/router"
+)
+
+const (
+	testSolRecipient = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	testSolReference = "82ZJ7nbGpixjeDCmEhUcmwXYfvurzAgGdtSMuHnUgyny"
+)
+
+// agentWalletTransfers stands in for AgentWallet's balances and
+// transfer-solana endpoints and records each transfer it receives.
+func agentWalletTransfers(t *testing.T, got *[]map[string]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/wallets/agent/balances" {
+			w.Write([]byte(`{"solana":{"balances":[{"chain":"solana","asset":"USDC","amount":"5"}]}}`))
+			return
+		}
+		if r.URL.Path != "/api/wallets/agent/actions/transfer-solana" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		*got = append(*got, payload)
+		w.Write([]byte(`{"actionId":"a1","status":"confirmed","txHash":"5xSig"}`))
+	}))
+}
+
+func TestSolanaProvider_SettleSolanaPay(t *testing.T) {
+	var transfers []map[string]string
+	srv := agentWalletTransfers(t, &transfers)
+	defer srv.Close()
+
+	p := NewSolanaProvider(srv.URL, "agent", "token", "mainnet")
+	spr, err := router.ParseSolanaPayURL("solana:" + testSolRecipient +
+		"?amount=0.25&spl-token=4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU&reference=" + testSolReference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &router.PaymentRequirement{Protocol: router.ProtocolSolanaPay, Details: spr}
+
+	usd, _, err := p.EstimateCost(req)
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	if usd != router.FromUSD(0.25) {
+		t.Errorf("expected $0.25, got %s", usd)
+	}
+
+	s, err := p.Settle(context.Background(), req)
+	if err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	if s.HeaderName != "Authorization" || s.HeaderValue != `SolanaPay signature="5xSig", reference="`+testSolReference+`"` {
+		t.Errorf("unexpected proof %s: %s", s.HeaderName, s.HeaderValue)
+	}
+	if s.TxID != "5xSig" || s.Reference != testSolReference {
+		t.Errorf("unexpected settlement %+v", s)
+	}
+
+	if len(transfers) != 1 {
+		t.Fatalf("expected 1 transfer, got %d", len(transfers))
+	}
+	want := map[string]string{"to": testSolRecipient, "amount": "250000", "asset": "usdc", "network": "devnet"}
+	for k, v := range want {
+		if transfers[0][k] != v {
+			t.Errorf("transfer %s = %q, want %q (devnet USDC mint selects devnet)", k, transfers[0][k], v)
+		}
+	}
+}
+
+func TestSolanaProvider_RejectsOtherTokens(t *testing.T) {
+	p := NewSolanaProvider("http://unused", "agent", "token", "mainnet")
+	for _, uri := range []string{
+		"solana:" + testSolRecipient + "?amount=1",                                                        // native SOL
+		"solana:" + testSolRecipient + "?amount=1&spl-token=Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB", // USDT
+	} {
+		spr, err := router.ParseSolanaPayURL(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := p.EstimateCost(&router.PaymentRequirement{Details: spr}); err == nil {
+			t.Errorf("expected an error for %s", uri)
+		}
+	}
+}
+
+func TestSolanaProvider_RouterFetch(t *testing.T) {
+	var transfers []map[string]string
+	wallet := agentWalletTransfers(t, &transfers)
+	defer wallet.Close()
+
+	uri := "solana:" + testSolRecipient + "?amount=0.01&spl-token=EPjFWdd5AufqSSqeM2qN1xxoxmTbX6CmaVgDaZdgF3jT&reference=" + testSolReference
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "SolanaPay ") {
+			if !strings.Contains(auth, testSolReference) {
+				http.Error(w, "wrong reference", 400)
+				return
+			}
+			w.Write([]byte(`ok`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(402)
+		w.Write([]byte(`{"solana_pay":"` + uri + `"}`))
+	}))
+	defer api.Close()
+
+	r := router.New(router.Config{MaxPerRequestUSD: 1.0})
+	r.RegisterDetector(router.SolanaPayDetector{})
+	r.RegisterProvider(NewSolanaProvider(wallet.URL, "agent", "token", "mainnet"))
+
+	body, receipt, err := r.Fetch(context.Background(), "GET", api.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if string(body) != "ok" {
+		t.Errorf("unexpected body %s", body)
+	}
+	if receipt.TxID != "5xSig" || receipt.Reference != testSolReference {
+		t.Errorf("receipt should carry the signature and reference, got %+v", receipt)
+	}
+}
+
+// capturedSender records the transaction it is asked to broadcast.
+type capturedSender struct {
+	network string
+	tx      []byte
+}
+
+func (s *capturedSender) SendTransaction(ctx context.Context, network string, tx []byte) (string, error) {
+	s.network, s.tx = network, tx
+	return "5xLocalSig", nil
+}
+
+func TestSolanaPayKeyProvider_ReferenceAndMemoOnChain(t *testing.T) {
+	svm := NewSVMKeyProvider(ed25519.NewKeyFromSeed(make([]byte, 32)))
+	svm.Blockhash = fixedBlockhash{7}
+	sender := &capturedSender{}
+	p := svm.SolanaPay(sender)
+
+	spr, err := router.ParseSolanaPayURL("solana:" + testSolRecipient +
+		"?amount=0.25&spl-token=4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU&reference=" + testSolReference + "&memo=order-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Settle(context.Background(), &router.PaymentRequirement{Protocol: router.ProtocolSolanaPay, Details: spr})
+	if err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	if s.HeaderValue != `SolanaPay signature="5xLocalSig", reference="`+testSolReference+`"` || s.Reference != testSolReference {
+		t.Errorf("unexpected settlement %+v", s)
+	}
+	if sender.network != "devnet" {
+		t.Errorf("expected a devnet broadcast, got %q", sender.network)
+	}
+
+	tx, err := solana.ParseTransaction(sender.tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := tx.Message
+	if len(msg.Instructions) != 4 {
+		t.Fatalf("expected compute budget, memo and transfer instructions, got %d", len(msg.Instructions))
+	}
+	memo := msg.Instructions[2]
+	if msg.AccountKeys[memo.ProgramIDIndex] != solana.MemoProgramID || string(memo.Data) != "order-42" {
+		t.Errorf("unexpected memo instruction %+v", memo)
+	}
+
+	transfer := msg.Instructions[3]
+	if msg.AccountKeys[transfer.ProgramIDIndex] != solana.TokenProgramID || len(transfer.Accounts) != 5 {
+		t.Fatalf("unexpected transfer instruction %+v", transfer)
+	}
+	ref := int(transfer.Accounts[4])
+	if msg.AccountKeys[ref] != solana.MustPublicKey(testSolReference) {
+		t.Errorf("transfer does not carry the reference key")
+	}
+	// Read-only non-signers come last in the account list
+	if ref < len(msg.AccountKeys)-int(msg.NumReadonlyUnsignedAccounts) {
+		t.Errorf("reference key is writable")
+	}
+	if tx.Signatures[0] == ([64]byte{}) {
+		t.Error("transaction is not signed")
+	}
+}
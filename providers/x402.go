@@ -3,12 +3,12 @@ package providers
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/joelklabo/agentpay/router"
 )
@@ -38,44 +38,150 @@ func (p *X402Provider) Protocol() router.Protocol {
 	return router.ProtocolX402
 }
 
-func (p *X402Provider) EstimateCost(req *router.PaymentRequirement) (float64, string, error) {
+// Capabilities reports the networks AgentWallet can sign x402 payments on.
+func (p *X402Provider) Capabilities() router.Capabilities {
+	return router.Capabilities{
+		Networks: []string{"eip155:", "solana:"},
+		Schemes:  []string{"exact"},
+	}
+}
+
+func (p *X402Provider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	accept, usdc, err := p.selectAccept(req)
+	if err != nil {
+		return router.Amount{}, "", err
+	}
+
+	desc := fmt.Sprintf("%s on %s", usdc, accept.Network)
+	return usdcToUSD(usdc), desc, nil
+}
+
+// selectAccept returns the accepts entry to pay: the one the router chose, or
+// else the cheapest on PreferredChain, falling back to the cheapest overall.
+func (p *X402Provider) selectAccept(req *router.PaymentRequirement) (*router.X402Accept, router.Amount, error) {
+	if req.X402Accept != nil {
+		amt, err := router.ParseUnits(req.X402Accept.MaxAmountRequired, router.USDC)
+		if err != nil {
+			return nil, router.Amount{}, err
+		}
+		return req.X402Accept, amt, nil
+	}
 	if req.X402Requirement == nil || len(req.X402Requirement.Accepts) == 0 {
-		return 0, "", fmt.Errorf("no x402 payment options")
+		return nil, router.Amount{}, fmt.Errorf("no x402 payment options")
+	}
+
+	if p.PreferredChain != "" && p.PreferredChain != "auto" {
+		var preferred []router.X402Accept
+		for _, opt := range req.X402Requirement.Accepts {
+			if chainFamily(opt.Network) == p.PreferredChain {
+				preferred = append(preferred, opt)
+			}
+		}
+		if accept, amt, err := cheapestAccept(preferred); err == nil {
+			return accept, amt, nil
+		}
 	}
+	return cheapestAccept(req.X402Requirement.Accepts)
+}
+
+// Balance returns the AgentWallet USDC balance on the network req would be
+// paid on, valued in USD.
+func (p *X402Provider) Balance(ctx context.Context, req *router.PaymentRequirement) (router.Amount, error) {
+	accept, _, err := p.selectAccept(req)
+	if err != nil {
+		return router.Amount{}, err
+	}
+	return agentWalletUSDC(ctx, p.client, p.apiBase, p.username, p.token, accept.Network)
+}
 
-	// Find the cheapest option
+// chainFamily maps a CAIP-2 network to AgentWallet's chain names.
+func chainFamily(network string) string {
+	switch {
+	case strings.HasPrefix(network, "eip155:"):
+		return "evm"
+	case strings.HasPrefix(network, "solana:"):
+		return "solana"
+	default:
+		return ""
+	}
+}
+
+// cheapestAccept returns the accepts entry with the lowest USDC amount.
+// Entries whose amount does not parse are skipped.
+func cheapestAccept(accepts []router.X402Accept) (*router.X402Accept, router.Amount, error) {
 	var cheapest *router.X402Accept
-	var cheapestUSD float64 = math.MaxFloat64
+	var cheapestAmt router.Amount
 
-	for i := range req.X402Requirement.Accepts {
-		opt := &req.X402Requirement.Accepts[i]
-		amount, err := strconv.ParseFloat(opt.MaxAmountRequired, 64)
+	for i := range accepts {
+		opt := &accepts[i]
+		amt, err := router.ParseUnits(opt.MaxAmountRequired, router.USDC)
 		if err != nil {
 			continue
 		}
-		// USDC has 6 decimals
-		usd := amount / 1e6
-		if usd < cheapestUSD {
-			cheapestUSD = usd
+		if cheapest == nil || amt.Cmp(cheapestAmt) < 0 {
 			cheapest = opt
+			cheapestAmt = amt
 		}
 	}
 
 	if cheapest == nil {
-		return 0, "", fmt.Errorf("no parseable payment amounts")
+		return nil, router.Amount{}, fmt.Errorf("no parseable payment amounts")
+	}
+	return cheapest, cheapestAmt, nil
+}
+
+// singleAcceptRequirement rewrites the raw x402 requirement to offer only
+// accept, keeping its other fields and its base64 or JSON encoding. A raw
+// value that doesn't parse is replaced with a version 1 requirement.
+func singleAcceptRequirement(raw string, accept *router.X402Accept) (string, error) {
+	fields := map[string]json.RawMessage{}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+	isBase64 := err == nil
+	if !isBase64 {
+		decoded = []byte(raw)
+	}
+	if json.Unmarshal(decoded, &fields) != nil || fields == nil {
+		fields = map[string]json.RawMessage{"x402Version": json.RawMessage("1")}
+		isBase64 = true
 	}
 
-	desc := fmt.Sprintf("$%.4f USDC on %s", cheapestUSD, cheapest.Network)
-	return cheapestUSD, desc, nil
+	accepts, err := json.Marshal([]*router.X402Accept{accept})
+	if err != nil {
+		return "", fmt.Errorf("marshal accept: %w", err)
+	}
+	fields["accepts"] = accepts
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("marshal requirement: %w", err)
+	}
+	if isBase64 {
+		return base64.StdEncoding.EncodeToString(out), nil
+	}
+	return string(out), nil
+}
+
+// usdcToUSD values a USDC amount at par. Both assets use 6 decimals.
+func usdcToUSD(a router.Amount) router.Amount {
+	return router.NewAmount(a.Units, router.USD)
 }
 
 func (p *X402Provider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
 	// Use AgentWallet x402/pay endpoint
 	signURL := fmt.Sprintf("%s/api/wallets/%s/actions/x402/pay", p.apiBase, p.username)
 
+	// Send only the option that was priced, so AgentWallet can't pick another
+	accept, _, err := p.selectAccept(req)
+	if err != nil {
+		return "", "", err
+	}
+	requirement, err := singleAcceptRequirement(req.Raw, accept)
+	if err != nil {
+		return "", "", err
+	}
+
 	payload := map[string]interface{}{
-		"requirement":    req.Raw,
-		"preferredChain": p.PreferredChain,
+		"requirement":    requirement,
+		"preferredChain": chainFamily(accept.Network),
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -91,13 +197,13 @@ func (p *X402Provider) Pay(ctx context.Context, req *router.PaymentRequirement)
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return "", "", fmt.Errorf("sign request failed: %w", err)
+		return "", "", requestError("sign request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	respBody, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != 200 {
-		return "", "", fmt.Errorf("sign request HTTP %d: %s", resp.StatusCode, string(respBody))
+		return "", "", statusError("sign request HTTP %d: %s", resp.StatusCode, respBody)
 	}
 
 	var result struct {
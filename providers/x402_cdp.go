@@ -11,11 +11,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"math/big"
 	"net/http"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
@@ -109,104 +107,66 @@ func (p *CDPProvider) RequestFaucet(ctx context.Context, network, token string)
 	return err
 }
 
-func (p *CDPProvider) EstimateCost(req *router.PaymentRequirement) (float64, string, error) {
-	if req.X402Requirement == nil || len(req.X402Requirement.Accepts) == 0 {
-		return 0, "", fmt.Errorf("no x402 payment options")
+// Capabilities reports that CDP wallets sign the x402 exact scheme on EVM chains.
+func (p *CDPProvider) Capabilities() router.Capabilities {
+	return router.Capabilities{
+		Networks: []string{"eip155:"},
+		Schemes:  []string{"exact"},
 	}
+}
 
-	var cheapest *router.X402Accept
-	var cheapestUSD float64 = math.MaxFloat64
+func (p *CDPProvider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	accept, usdc, err := selectUSDCAccept(req)
+	if err != nil {
+		return router.Amount{}, "", err
+	}
 
-	for i := range req.X402Requirement.Accepts {
-		opt := &req.X402Requirement.Accepts[i]
-		amount, err := strconv.ParseFloat(opt.MaxAmountRequired, 64)
-		if err != nil {
-			continue
+	desc := fmt.Sprintf("%s on %s (CDP)", usdc, accept.Network)
+	return usdcToUSD(usdc), desc, nil
+}
+
+// selectEVMAccept returns the EVM accepts entry to pay: the one the router
+// chose, or else the cheapest EVM option. CDP wallets cannot pay other chains.
+func selectEVMAccept(req *router.PaymentRequirement) (*router.X402Accept, router.Amount, error) {
+	if req.X402Accept != nil {
+		if !strings.HasPrefix(req.X402Accept.Network, "eip155:") {
+			return nil, router.Amount{}, fmt.Errorf("no EVM payment option found")
 		}
-		usd := amount / 1e6 // USDC has 6 decimals
-		if usd < cheapestUSD {
-			cheapestUSD = usd
-			cheapest = opt
+		amt, err := router.ParseUnits(req.X402Accept.MaxAmountRequired, router.USDC)
+		if err != nil {
+			return nil, router.Amount{}, err
 		}
+		return req.X402Accept, amt, nil
 	}
-
-	if cheapest == nil {
-		return 0, "", fmt.Errorf("no parseable payment amounts")
+	if req.X402Requirement == nil || len(req.X402Requirement.Accepts) == 0 {
+		return nil, router.Amount{}, fmt.Errorf("no x402 payment options")
 	}
 
-	desc := fmt.Sprintf("$%.4f USDC on %s (CDP)", cheapestUSD, cheapest.Network)
-	return cheapestUSD, desc, nil
+	var evm []router.X402Accept
+	for _, opt := range req.X402Requirement.Accepts {
+		if strings.HasPrefix(opt.Network, "eip155:") {
+			evm = append(evm, opt)
+		}
+	}
+	if len(evm) == 0 {
+		return nil, router.Amount{}, fmt.Errorf("no EVM payment option found")
+	}
+	return cheapestAccept(evm)
 }
 
 func (p *CDPProvider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
 	if p.address == "" {
 		return "", "", fmt.Errorf("CDP provider not initialized — call Init first")
 	}
-	if req.X402Requirement == nil || len(req.X402Requirement.Accepts) == 0 {
-		return "", "", fmt.Errorf("no x402 payment options")
-	}
 
-	// Pick the cheapest EVM option
-	var accept *router.X402Accept
-	for i := range req.X402Requirement.Accepts {
-		opt := &req.X402Requirement.Accepts[i]
-		if strings.HasPrefix(opt.Network, "eip155:") {
-			accept = opt
-			break
-		}
-	}
-	if accept == nil {
-		return "", "", fmt.Errorf("no EVM payment option found")
+	accept, _, err := selectUSDCAccept(req)
+	if err != nil {
+		return "", "", err
 	}
 
 	// Build EIP-712 TransferWithAuthorization typed data
-	nonce := generateNonce()
-	validAfter := "0"
-	validBefore := strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10)
-
-	// Extract chain ID from network (e.g., "eip155:84532" -> 84532)
-	chainID := int64(84532) // default Base Sepolia
-	if parts := strings.SplitN(accept.Network, ":", 2); len(parts) == 2 {
-		if id, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
-			chainID = id
-		}
-	}
-
-	typedData := map[string]interface{}{
-		"domain": map[string]interface{}{
-			"name":              "USD Coin",
-			"version":           "2",
-			"chainId":           chainID,
-			"verifyingContract": accept.Asset,
-		},
-		"types": map[string]interface{}{
-			"EIP712Domain": []map[string]string{
-				{"name": "name", "type": "string"},
-				{"name": "version", "type": "string"},
-				{"name": "chainId", "type": "uint256"},
-				{"name": "verifyingContract", "type": "address"},
-			},
-			"TransferWithAuthorization": []map[string]string{
-				{"name": "from", "type": "address"},
-				{"name": "to", "type": "address"},
-				{"name": "value", "type": "uint256"},
-				{"name": "validAfter", "type": "uint256"},
-				{"name": "validBefore", "type": "uint256"},
-				{"name": "nonce", "type": "bytes32"},
-			},
-		},
-		"primaryType": "TransferWithAuthorization",
-		"message": map[string]interface{}{
-			"from":        p.address,
-			"to":          accept.PayTo,
-			"value":       accept.MaxAmountRequired,
-			"validAfter":  validAfter,
-			"validBefore": validBefore,
-			"nonce":       nonce,
-		},
-	}
-
-	bodyBytes, err := json.Marshal(typedData)
+	auth := newTransferAuthorization(p.address, accept)
+	bodyBytes, err := json.Marshal(auth.typedData())
 	if err != nil {
 		return "", "", fmt.Errorf("marshal typed data: %w", err)
 	}
@@ -225,29 +185,69 @@ func (p *CDPProvider) Pay(ctx context.Context, req *router.PaymentRequirement) (
 		return "", "", fmt.Errorf("parse signature: %w", err)
 	}
 
-	// Build the x402 payment payload
-	payment := map[string]interface{}{
-		"x402Version": 1,
-		"scheme":      accept.Scheme,
-		"network":     accept.Network,
-		"payload": map[string]interface{}{
-			"signature":  sigResult.Signature,
-			"from":       p.address,
-			"to":         accept.PayTo,
-			"value":      accept.MaxAmountRequired,
-			"validAfter": validAfter,
-			"validBefore": validBefore,
-			"nonce":      nonce,
-		},
-	}
+	return auth.payment(sigResult.Signature)
+}
+
+// cdpNetworks maps CAIP-2 networks to CDP's network names.
+var cdpNetworks = map[string]string{
+	"eip155:8453":  "base",
+	"eip155:84532": "base-sepolia",
+	"eip155:1":     "ethereum",
+}
 
-	paymentBytes, err := json.Marshal(payment)
+// Balance returns the wallet's USDC balance on the network req would be paid
+// on, valued in USD.
+func (p *CDPProvider) Balance(ctx context.Context, req *router.PaymentRequirement) (router.Amount, error) {
+	if p.address == "" {
+		return router.Amount{}, fmt.Errorf("CDP provider not initialized — call Init first")
+	}
+	accept, _, err := selectUSDCAccept(req)
 	if err != nil {
-		return "", "", fmt.Errorf("marshal payment: %w", err)
+		return router.Amount{}, err
+	}
+	network, ok := cdpNetworks[accept.Network]
+	if !ok {
+		return router.Amount{}, fmt.Errorf("unsupported CDP network %q", accept.Network)
 	}
 
-	encoded := base64.StdEncoding.EncodeToString(paymentBytes)
-	return "Payment", encoded, nil
+	path := fmt.Sprintf("/platform/v2/evm/token-balances/%s/%s", network, p.address)
+	resp, err := p.cdpRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return router.Amount{}, fmt.Errorf("token balances: %w", err)
+	}
+
+	var result struct {
+		Balances []struct {
+			Amount struct {
+				Amount   string `json:"amount"`
+				Decimals int    `json:"decimals"`
+			} `json:"amount"`
+			Token struct {
+				Symbol          string `json:"symbol"`
+				ContractAddress string `json:"contractAddress"`
+			} `json:"token"`
+		} `json:"balances"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return router.Amount{}, fmt.Errorf("parse token balances: %w", err)
+	}
+
+	total := router.NewAmount(0, router.USDC)
+	for _, b := range result.Balances {
+		matches := strings.EqualFold(b.Token.ContractAddress, accept.Asset)
+		if accept.Asset == "" || !strings.HasPrefix(accept.Asset, "0x") {
+			matches = strings.EqualFold(b.Token.Symbol, "USDC")
+		}
+		if !matches || b.Amount.Decimals != router.USDC.Decimals {
+			continue
+		}
+		amt, err := router.ParseUnits(b.Amount.Amount, router.USDC)
+		if err != nil {
+			continue
+		}
+		total = total.Add(amt)
+	}
+	return usdcToUSD(total), nil
 }
 
 // cdpRequest makes an authenticated request to the CDP API.
@@ -292,7 +292,7 @@ func (p *CDPProvider) cdpRequest(ctx context.Context, method, path string, body
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("CDP API %s %s: HTTP %d: %s", method, path, resp.StatusCode, string(respBody))
+		return nil, statusError("CDP API %s %s: HTTP %d: %s", resp.StatusCode, respBody, method, path)
 	}
 
 	return respBody, nil
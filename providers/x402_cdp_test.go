@@ -25,7 +25,7 @@ func TestCDPProvider_EstimateCost(t *testing.T) {
 	tests := []struct {
 		name    string
 		req     *router.PaymentRequirement
-		wantUSD float64
+		wantUSD router.Amount
 		wantErr bool
 	}{
 		{
@@ -42,7 +42,7 @@ func TestCDPProvider_EstimateCost(t *testing.T) {
 					}},
 				},
 			},
-			wantUSD: 0.001,
+			wantUSD: router.FromUSD(0.001),
 		},
 		{
 			name: "picks cheapest EVM option",
@@ -50,12 +50,37 @@ func TestCDPProvider_EstimateCost(t *testing.T) {
 				Protocol: router.ProtocolX402,
 				X402Requirement: &router.X402Requirement{
 					Accepts: []router.X402Accept{
-						{Network: "eip155:84532", MaxAmountRequired: "50000", PayTo: "0xa"},
-						{Network: "eip155:84532", MaxAmountRequired: "1000", PayTo: "0xb"},
+						{Network: "eip155:84532", MaxAmountRequired: "50000", PayTo: "0xa", Asset: "0x036CbD53842c5426634e7929541eC2318f3dCF7e"},
+						{Network: "eip155:84532", MaxAmountRequired: "1000", PayTo: "0xb", Asset: "0x036CbD53842c5426634e7929541eC2318f3dCF7e"},
 					},
 				},
 			},
-			wantUSD: 0.001,
+			wantUSD: router.FromUSD(0.001),
+		},
+		{
+			name: "skips a cheaper non-USDC token",
+			req: &router.PaymentRequirement{
+				Protocol: router.ProtocolX402,
+				X402Requirement: &router.X402Requirement{
+					Accepts: []router.X402Accept{
+						{Network: "eip155:84532", MaxAmountRequired: "1", PayTo: "0xa", Asset: "0x00000000000000000000000000000000000000aa"},
+						{Network: "eip155:84532", MaxAmountRequired: "1000", PayTo: "0xb", Asset: "0x036CbD53842c5426634e7929541eC2318f3dCF7e"},
+					},
+				},
+			},
+			wantUSD: router.FromUSD(0.001),
+		},
+		{
+			name: "refuses other tokens",
+			req: &router.PaymentRequirement{
+				Protocol: router.ProtocolX402,
+				X402Requirement: &router.X402Requirement{
+					Accepts: []router.X402Accept{
+						{Network: "eip155:8453", MaxAmountRequired: "1000", PayTo: "0xa", Asset: "0x00000000000000000000000000000000000000aa"},
+					},
+				},
+			},
+			wantErr: true,
 		},
 		{
 			name:    "nil requirement",
@@ -85,7 +110,7 @@ func TestCDPProvider_EstimateCost(t *testing.T) {
 				t.Fatalf("unexpected error: %v", err)
 			}
 			if usd != tt.wantUSD {
-				t.Errorf("got $%.4f, want $%.4f", usd, tt.wantUSD)
+				t.Errorf("got %s, want %s", usd, tt.wantUSD)
 			}
 			if !strings.Contains(desc, "CDP") {
 				t.Errorf("description should mention CDP: %s", desc)
@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/joelklabo/agentpay/internal/keystore"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// EVMKeyProvider pays x402 exact-scheme challenges on EVM chains by signing
+// EIP-3009 TransferWithAuthorization locally with a secp256k1 key. No hosted
+// signer is involved: the key never leaves this process.
+type EVMKeyProvider struct {
+	key     *big.Int
+	address string
+}
+
+// NewEVMKeyProvider creates a provider that signs with priv.
+func NewEVMKeyProvider(priv *big.Int) (*EVMKeyProvider, error) {
+	if priv.Sign() <= 0 || priv.Cmp(secp256k1.N) >= 0 {
+		return nil, fmt.Errorf("invalid secp256k1 private key")
+	}
+	return &EVMKeyProvider{
+		key:     priv,
+		address: keystore.Address(secp256k1.ScalarBaseMult(priv)),
+	}, nil
+}
+
+// LoadEVMKeyProvider unlocks a Web3 Secret Storage (v3) keystore file.
+func LoadEVMKeyProvider(path, password string) (*EVMKeyProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keystore: %w", err)
+	}
+	key, err := keystore.Decrypt(data, password)
+	if err != nil {
+		return nil, err
+	}
+	return NewEVMKeyProvider(key.PrivateKey)
+}
+
+func (p *EVMKeyProvider) Protocol() router.Protocol {
+	return router.ProtocolX402
+}
+
+// Address returns the checksummed address that signs payments.
+func (p *EVMKeyProvider) Address() string {
+	return p.address
+}
+
+// Capabilities reports that a local key signs the x402 exact scheme on EVM chains.
+func (p *EVMKeyProvider) Capabilities() router.Capabilities {
+	return router.Capabilities{
+		Networks: []string{"eip155:"},
+		Schemes:  []string{"exact"},
+	}
+}
+
+func (p *EVMKeyProvider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	accept, usdc, err := selectUSDCAccept(req)
+	if err != nil {
+		return router.Amount{}, "", err
+	}
+
+	desc := fmt.Sprintf("%s on %s (local key)", usdc, accept.Network)
+	return usdcToUSD(usdc), desc, nil
+}
+
+func (p *EVMKeyProvider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
+	accept, _, err := selectUSDCAccept(req)
+	if err != nil {
+		return "", "", err
+	}
+
+	auth := newTransferAuthorization(p.address, accept)
+	digest, err := auth.digest()
+	if err != nil {
+		return "", "", fmt.Errorf("EIP-712 digest: %w", err)
+	}
+	sig, err := secp256k1.Sign(p.key, digest)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 65-byte r ‖ s ‖ v with Ethereum's v = 27 + recovery ID
+	raw := make([]byte, 65)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:64])
+	raw[64] = 27 + sig.V
+	return auth.payment("0x" + hex.EncodeToString(raw))
+}
+
+// selectUSDCAccept is selectEVMAccept for options paying in USDC. EVM
+// signers authorize whatever token the accept names, and amounts are priced
+// at USDC's six decimals, so any other token is refused rather than mispriced.
+func selectUSDCAccept(req *router.PaymentRequirement) (*router.X402Accept, router.Amount, error) {
+	// Pick the cheapest USDC option, not the cheapest of any token
+	if req.X402Accept == nil && req.X402Requirement != nil {
+		var usdc []router.X402Accept
+		for _, opt := range req.X402Requirement.Accepts {
+			if want := router.USDCContract(opt.Network); want != "" && strings.EqualFold(opt.Asset, want) {
+				usdc = append(usdc, opt)
+			}
+		}
+		if len(usdc) > 0 {
+			filtered := *req
+			filtered.X402Requirement = &router.X402Requirement{Accepts: usdc}
+			req = &filtered
+		}
+	}
+	accept, usdc, err := selectEVMAccept(req)
+	if err != nil {
+		return nil, router.Amount{}, err
+	}
+	want := router.USDCContract(accept.Network)
+	if want == "" {
+		return nil, router.Amount{}, fmt.Errorf("no known USDC contract on %s", accept.Network)
+	}
+	if !strings.EqualFold(accept.Asset, want) {
+		return nil, router.Amount{}, fmt.Errorf("asset %s is not USDC on %s", accept.Asset, accept.Network)
+	}
+	return accept, usdc, nil
+}
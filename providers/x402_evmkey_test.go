@@ -0,0 +1,260 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/keystore"
+	"github.com/joelklabo/agentpay/internal/secp256k1"
+	"github.com/joelklabo/agentpay/router"
+)
+
+func TestEIP3009TypeHashes(t *testing.T) {
+	// Constants from the USDC (FiatTokenV2) contract source
+	if got := hex.EncodeToString(transferWithAuthorizationTypeHash[:]); got != "7c7c6cdb67a18743f49ec6fa9b35f50d52ed05cbed4cc592e13b44501c1a2267" {
+		t.Errorf("TransferWithAuthorization type hash = %s", got)
+	}
+	if got := hex.EncodeToString(eip712DomainTypeHash[:]); got != "8b73c3c69bb8fe3d512ecc4cf759cc79239f7b179b0ffacaa9a75d522b39400f" {
+		t.Errorf("EIP712Domain type hash = %s", got)
+	}
+}
+
+func TestEVMKeyProvider_PayRecoversSigner(t *testing.T) {
+	priv, _ := new(big.Int).SetString("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", 16)
+	data, err := keystore.Encrypt(priv, "hunter2", keystore.LightScryptN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadEVMKeyProvider(path, "wrong"); err == nil {
+		t.Error("expected an error for the wrong password")
+	}
+	p, err := LoadEVMKeyProvider(path, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Address() != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("address = %s", p.Address())
+	}
+
+	accept := router.X402Accept{
+		Scheme:            "exact",
+		Network:           "eip155:8453",
+		MaxAmountRequired: "10000",
+		PayTo:             "0x209693Bc6afc0C5328bA36FaF03C514EF312287C",
+		Asset:             "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+		Extra:             json.RawMessage(`{"name":"USD Coin","version":"2"}`),
+	}
+	req := &router.PaymentRequirement{
+		Protocol:        router.ProtocolX402,
+		X402Requirement: &router.X402Requirement{Accepts: []router.X402Accept{accept}},
+	}
+
+	name, value, err := p.Pay(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Payment" {
+		t.Errorf("header = %q, want Payment", name)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payment struct {
+		X402Version int    `json:"x402Version"`
+		Network     string `json:"network"`
+		Payload     struct {
+			Signature   string `json:"signature"`
+			From        string `json:"from"`
+			To          string `json:"to"`
+			Value       string `json:"value"`
+			ValidAfter  string `json:"validAfter"`
+			ValidBefore string `json:"validBefore"`
+			Nonce       string `json:"nonce"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &payment); err != nil {
+		t.Fatal(err)
+	}
+	if payment.X402Version != 1 || payment.Network != "eip155:8453" || payment.Payload.Value != "10000" {
+		t.Errorf("unexpected payment: %s", raw)
+	}
+
+	// Rebuild the digest the facilitator would check and recover the signer
+	auth := &transferAuthorization{
+		accept:      &accept,
+		name:        "USD Coin",
+		version:     "2",
+		chainID:     8453,
+		contract:    accept.Asset,
+		from:        payment.Payload.From,
+		to:          payment.Payload.To,
+		value:       payment.Payload.Value,
+		validAfter:  payment.Payload.ValidAfter,
+		validBefore: payment.Payload.ValidBefore,
+		nonce:       payment.Payload.Nonce,
+	}
+	digest, err := auth.digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigBytes, err := hex.DecodeString(strings.TrimPrefix(payment.Payload.Signature, "0x"))
+	if err != nil || len(sigBytes) != 65 {
+		t.Fatalf("bad signature %q", payment.Payload.Signature)
+	}
+	if sigBytes[64] != 27 && sigBytes[64] != 28 {
+		t.Errorf("v = %d, want 27 or 28", sigBytes[64])
+	}
+	sig := &secp256k1.Signature{
+		R: new(big.Int).SetBytes(sigBytes[:32]),
+		S: new(big.Int).SetBytes(sigBytes[32:64]),
+		V: sigBytes[64] - 27,
+	}
+	pub, err := secp256k1.RecoverPublicKey(digest, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keystore.Address(pub); got != p.Address() || got != payment.Payload.From {
+		t.Errorf("recovered %s, want %s", got, p.Address())
+	}
+}
+
+func TestEVMKeyProvider_DomainFromExtra(t *testing.T) {
+	p, err := NewEVMKeyProvider(big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accept := &router.X402Accept{
+		Scheme:            "exact",
+		Network:           "eip155:84532",
+		MaxAmountRequired: "1000",
+		PayTo:             "0x209693Bc6afc0C5328bA36FaF03C514EF312287C",
+		Asset:             "0x036CbD53842c5426634e7929541eC2318f3dCF7e",
+		Extra:             json.RawMessage(`{"name":"USDC","version":"2"}`),
+	}
+	auth := newTransferAuthorization(p.Address(), accept)
+	if auth.name != "USDC" || auth.chainID != 84532 {
+		t.Errorf("domain = %q on chain %d", auth.name, auth.chainID)
+	}
+
+	// A different domain name must change what is signed
+	other := *auth
+	other.name = "USD Coin"
+	a, _ := auth.digest()
+	b, _ := other.digest()
+	if hex.EncodeToString(a) == hex.EncodeToString(b) {
+		t.Error("domain name did not affect the digest")
+	}
+}
+
+func TestAuthorizationDomain_PinsUSDC(t *testing.T) {
+	accept := &router.X402Accept{
+		Network: "eip155:8453",
+		Asset:   "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+		Extra:   json.RawMessage(`{"name":"Wrapped Ether","version":"1"}`),
+	}
+	if a := authorizationDomain(accept); a.name != "USD Coin" || a.version != "2" {
+		t.Errorf("USDC domain = %q %q, want the canonical one", a.name, a.version)
+	}
+
+	// Other tokens, which only a seller verifies, keep the server's domain
+	accept.Asset = "0x00000000000000000000000000000000000000aa"
+	if a := authorizationDomain(accept); a.name != "Wrapped Ether" || a.version != "1" {
+		t.Errorf("token domain = %q %q", a.name, a.version)
+	}
+}
+
+func TestEVMKeyProvider_PayNoEVMOption(t *testing.T) {
+	p, _ := NewEVMKeyProvider(big.NewInt(1))
+	req := &router.PaymentRequirement{
+		Protocol: router.ProtocolX402,
+		X402Requirement: &router.X402Requirement{
+			Accepts: []router.X402Accept{{Scheme: "exact", Network: "solana:mainnet", MaxAmountRequired: "1000"}},
+		},
+	}
+	if _, _, err := p.Pay(context.Background(), req); err == nil {
+		t.Error("expected an error without an EVM option")
+	}
+}
+
+func TestEVMKeyProvider_OnlyUSDC(t *testing.T) {
+	p, _ := NewEVMKeyProvider(big.NewInt(1))
+	for _, accept := range []router.X402Accept{
+		// A token with 18 decimals would be priced a trillion times too low
+		{Scheme: "exact", Network: "eip155:8453", MaxAmountRequired: "1000000", PayTo: "0xabc", Asset: "0x4200000000000000000000000000000000000006"},
+		{Scheme: "exact", Network: "eip155:1", MaxAmountRequired: "1000", PayTo: "0xabc", Asset: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"},
+	} {
+		req := &router.PaymentRequirement{Protocol: router.ProtocolX402, X402Accept: &accept}
+		if _, _, err := p.EstimateCost(req); err == nil {
+			t.Errorf("%s on %s: priced", accept.Asset, accept.Network)
+		}
+		if _, _, err := p.Pay(context.Background(), req); err == nil {
+			t.Errorf("%s on %s: signed", accept.Asset, accept.Network)
+		}
+	}
+
+	// Contract addresses compare without their checksum case
+	accept := router.X402Accept{Scheme: "exact", Network: "eip155:8453", MaxAmountRequired: "1000", PayTo: "0xabc", Asset: strings.ToLower(router.USDCContract("eip155:8453"))}
+	if _, _, err := p.EstimateCost(&router.PaymentRequirement{Protocol: router.ProtocolX402, X402Accept: &accept}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestX402Payment_Verify(t *testing.T) {
+	p, _ := NewEVMKeyProvider(big.NewInt(0xC0FFEE))
+	accept := &router.X402Accept{
+		Scheme:            "exact",
+		Network:           "eip155:8453",
+		MaxAmountRequired: "10000",
+		PayTo:             "0x5049CaCF18346ee22EBA390B9B6309cb3f03abFB",
+		Asset:             "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+	}
+	_, header, err := p.Pay(context.Background(), &router.PaymentRequirement{Protocol: router.ProtocolX402, X402Accept: accept})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-wrap in the reference implementation's nested payload
+	raw, _ := base64.StdEncoding.DecodeString(header)
+	var flat map[string]interface{}
+	json.Unmarshal(raw, &flat)
+	payload := flat["payload"].(map[string]interface{})
+	sig := payload["signature"]
+	delete(payload, "signature")
+	flat["payload"] = map[string]interface{}{"signature": sig, "authorization": payload}
+	nested, _ := json.Marshal(flat)
+
+	for _, h := range []string{header, base64.StdEncoding.EncodeToString(nested)} {
+		payment, err := DecodeX402Payment(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payment.From != p.Address() {
+			t.Errorf("from = %s", payment.From)
+		}
+		if err := payment.Verify(accept, time.Now()); err != nil {
+			t.Errorf("verify: %v", err)
+		}
+		if err := payment.Verify(accept, time.Now().Add(time.Hour)); err == nil {
+			t.Error("expected an expired authorization to fail")
+		}
+		payment.Value = "20000"
+		if err := payment.Verify(accept, time.Now()); err == nil || !strings.Contains(err.Error(), "signed by") {
+			t.Errorf("tampered value: %v", err)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joelklabo/agentpay/internal/solana"
+	"github.com/joelklabo/agentpay/router"
+)
+
+// BlockhashSource supplies a recent blockhash for the Solana cluster a CAIP-2
+// network names. Tests inject a fixed one to build transactions offline.
+type BlockhashSource interface {
+	LatestBlockhash(ctx context.Context, network string) ([32]byte, error)
+}
+
+// solanaRPCEndpoints are the public RPC nodes per cluster.
+var solanaRPCEndpoints = map[string]string{
+	"mainnet": "https://api.mainnet-beta.solana.com",
+	"devnet":  "https://api.devnet.solana.com",
+	"testnet": "https://api.testnet.solana.com",
+}
+
+// solanaCluster maps a CAIP-2 Solana network to its cluster name. Both the
+// genesis-hash form and the short names used in configs are accepted.
+func solanaCluster(network string) string {
+	ref := strings.TrimPrefix(network, "solana:")
+	switch {
+	case ref == "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp" || strings.HasPrefix(ref, "mainnet"):
+		return "mainnet"
+	case ref == "EtWTRABZaYq6iMfeYKouRu166VL8qxg7" || ref == "devnet":
+		return "devnet"
+	case ref == "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3z" || ref == "testnet":
+		return "testnet"
+	default:
+		return ""
+	}
+}
+
+// TransactionSender broadcasts a signed transaction on the Solana cluster a
+// CAIP-2 network names and returns its signature.
+type TransactionSender interface {
+	SendTransaction(ctx context.Context, network string, tx []byte) (string, error)
+}
+
+// RPCBlockhashSource fetches blockhashes with the getLatestBlockhash JSON-RPC
+// method and broadcasts transactions with sendTransaction.
+type RPCBlockhashSource struct {
+	// Endpoints overrides the RPC URL per cluster ("mainnet", "devnet", "testnet").
+	Endpoints map[string]string
+	Client    *http.Client
+}
+
+// LatestBlockhash implements BlockhashSource.
+func (s *RPCBlockhashSource) LatestBlockhash(ctx context.Context, network string) ([32]byte, error) {
+	var result struct {
+		Value struct {
+			Blockhash string `json:"blockhash"`
+		} `json:"value"`
+	}
+	params := []interface{}{map[string]string{"commitment": "finalized"}}
+	if err := s.call(ctx, network, "getLatestBlockhash", params, &result); err != nil {
+		return [32]byte{}, err
+	}
+	hash, err := solana.ParsePublicKey(result.Value.Blockhash)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("getLatestBlockhash: %w", err)
+	}
+	return hash, nil
+}
+
+// SendTransaction implements TransactionSender. The node simulates the
+// transaction first, so an error before it is broadcast is reported as such.
+func (s *RPCBlockhashSource) SendTransaction(ctx context.Context, network string, tx []byte) (string, error) {
+	var sig string
+	params := []interface{}{
+		base64.StdEncoding.EncodeToString(tx),
+		map[string]string{"encoding": "base64", "preflightCommitment": "confirmed"},
+	}
+	if err := s.call(ctx, network, "sendTransaction", params, &sig); err != nil {
+		return "", err
+	}
+	return sig, nil
+}
+
+// call makes a JSON-RPC request to the network's endpoint and decodes its
+// result into out.
+func (s *RPCBlockhashSource) call(ctx context.Context, network, method string, params []interface{}, out interface{}) error {
+	cluster := solanaCluster(network)
+	endpoint := s.Endpoints[cluster]
+	if endpoint == "" {
+		endpoint = solanaRPCEndpoints[cluster]
+	}
+	if endpoint == "" {
+		return fmt.Errorf("no Solana RPC endpoint for network %q", network)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return requestError("%s: %w", err, method)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		if method == "sendTransaction" {
+			return submitError("%s HTTP %d: %s", resp.StatusCode, respBody, method)
+		}
+		return statusError("%s HTTP %d: %s", resp.StatusCode, respBody, method)
+	}
+
+	var result struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("parse %s: %w", method, err)
+	}
+	if result.Error != nil {
+		return fmt.Errorf("%s: %s", method, result.Error.Message)
+	}
+	if err := json.Unmarshal(result.Result, out); err != nil {
+		return fmt.Errorf("parse %s: %w", method, err)
+	}
+	return nil
+}
+
+// SVMKeyProvider pays x402 exact-scheme challenges on Solana with a local
+// keypair. It builds an SPL TransferChecked transaction between associated
+// token accounts, signs it as the token owner and leaves the fee payer's
+// signature for the facilitator named in extra.feePayer.
+type SVMKeyProvider struct {
+	key     ed25519.PrivateKey
+	address solana.PublicKey
+
+	// Blockhash supplies recent blockhashes; defaults to the public RPC nodes.
+	Blockhash BlockhashSource
+	// ComputeUnitLimit and ComputeUnitPrice (micro-lamports per unit) set the
+	// compute budget. A TransferChecked uses about 6,000 units.
+	ComputeUnitLimit uint32
+	ComputeUnitPrice uint64
+}
+
+// NewSVMKeyProvider creates a provider that signs with key.
+func NewSVMKeyProvider(key ed25519.PrivateKey) *SVMKeyProvider {
+	p := &SVMKeyProvider{
+		key:              key,
+		Blockhash:        &RPCBlockhashSource{},
+		ComputeUnitLimit: 20000,
+		ComputeUnitPrice: 1,
+	}
+	copy(p.address[:], key.Public().(ed25519.PublicKey))
+	return p
+}
+
+// LoadSVMKeyProvider reads a Solana CLI keypair file: a JSON array of the
+// 64-byte secret key (seed followed by public key).
+func LoadSVMKeyProvider(path string) (*SVMKeyProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keypair: %w", err)
+	}
+	var raw []byte
+	var ints []int
+	if err := json.Unmarshal(data, &ints); err != nil {
+		return nil, fmt.Errorf("parse keypair %s: %w", path, err)
+	}
+	for _, n := range ints {
+		if n < 0 || n > 255 {
+			return nil, fmt.Errorf("parse keypair %s: byte out of range", path)
+		}
+		raw = append(raw, byte(n))
+	}
+	if len(raw) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("keypair %s has %d bytes, want %d", path, len(raw), ed25519.PrivateKeySize)
+	}
+
+	key := ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize])
+	if !bytes.Equal(key[ed25519.SeedSize:], raw[ed25519.SeedSize:]) {
+		return nil, fmt.Errorf("keypair %s: public key does not match secret", path)
+	}
+	return NewSVMKeyProvider(key), nil
+}
+
+func (p *SVMKeyProvider) Protocol() router.Protocol {
+	return router.ProtocolX402
+}
+
+// Address returns the base58 public key that owns the token accounts.
+func (p *SVMKeyProvider) Address() string {
+	return p.address.String()
+}
+
+// Capabilities reports that a local keypair signs the x402 exact scheme on Solana.
+func (p *SVMKeyProvider) Capabilities() router.Capabilities {
+	return router.Capabilities{
+		Networks: []string{"solana:"},
+		Schemes:  []string{"exact"},
+	}
+}
+
+func (p *SVMKeyProvider) EstimateCost(req *router.PaymentRequirement) (router.Amount, string, error) {
+	accept, usdc, err := selectSVMUSDCAccept(req)
+	if err != nil {
+		return router.Amount{}, "", err
+	}
+
+	desc := fmt.Sprintf("%s on %s (local key)", usdc, accept.Network)
+	return usdcToUSD(usdc), desc, nil
+}
+
+// selectSVMAccept returns the Solana accepts entry to pay: the one the router
+// chose, or else the cheapest Solana option.
+func selectSVMAccept(req *router.PaymentRequirement) (*router.X402Accept, router.Amount, error) {
+	if req.X402Accept != nil {
+		if !strings.HasPrefix(req.X402Accept.Network, "solana:") {
+			return nil, router.Amount{}, fmt.Errorf("no Solana payment option found")
+		}
+		amt, err := router.ParseUnits(req.X402Accept.MaxAmountRequired, router.USDC)
+		if err != nil {
+			return nil, router.Amount{}, err
+		}
+		return req.X402Accept, amt, nil
+	}
+	if req.X402Requirement == nil || len(req.X402Requirement.Accepts) == 0 {
+		return nil, router.Amount{}, fmt.Errorf("no x402 payment options")
+	}
+
+	var svm []router.X402Accept
+	for _, opt := range req.X402Requirement.Accepts {
+		if strings.HasPrefix(opt.Network, "solana:") {
+			svm = append(svm, opt)
+		}
+	}
+	if len(svm) == 0 {
+		return nil, router.Amount{}, fmt.Errorf("no Solana payment option found")
+	}
+	return cheapestAccept(svm)
+}
+
+// selectSVMUSDCAccept is selectSVMAccept for options paying in USDC on the
+// cluster the network names. The transfer is built for the SPL Token program
+// and USDC's six decimals, whatever the server's extra says.
+func selectSVMUSDCAccept(req *router.PaymentRequirement) (*router.X402Accept, router.Amount, error) {
+	accept, usdc, err := selectSVMAccept(req)
+	if err != nil {
+		return nil, router.Amount{}, err
+	}
+	cluster := solanaCluster(accept.Network)
+	if cluster == "" || solanaUSDCMints[accept.Asset] != cluster {
+		return nil, router.Amount{}, fmt.Errorf("asset %s is not USDC on %s", accept.Asset, accept.Network)
+	}
+	return accept, usdc, nil
+}
+
+func (p *SVMKeyProvider) Pay(ctx context.Context, req *router.PaymentRequirement) (string, string, error) {
+	accept, _, err := selectSVMUSDCAccept(req)
+	if err != nil {
+		return "", "", err
+	}
+
+	var extra struct {
+		FeePayer string `json:"feePayer"`
+	}
+	if len(accept.Extra) > 0 {
+		json.Unmarshal(accept.Extra, &extra)
+	}
+	// The facilitator sponsors the fee; without one we pay it ourselves
+	feePayer := p.address
+	if extra.FeePayer != "" {
+		if feePayer, err = solana.ParsePublicKey(extra.FeePayer); err != nil {
+			return "", "", fmt.Errorf("feePayer: %w", err)
+		}
+	}
+	decimals := uint8(router.USDC.Decimals)
+	tokenProgram := solana.TokenProgramID
+
+	mint, err := solana.ParsePublicKey(accept.Asset)
+	if err != nil {
+		return "", "", fmt.Errorf("asset: %w", err)
+	}
+	payTo, err := solana.ParsePublicKey(accept.PayTo)
+	if err != nil {
+		return "", "", fmt.Errorf("payTo: %w", err)
+	}
+	amount, err := strconv.ParseUint(accept.MaxAmountRequired, 10, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid amount %q: %w", accept.MaxAmountRequired, err)
+	}
+
+	source, err := solana.AssociatedTokenAddress(p.address, mint, tokenProgram)
+	if err != nil {
+		return "", "", err
+	}
+	destination, err := solana.AssociatedTokenAddress(payTo, mint, tokenProgram)
+	if err != nil {
+		return "", "", err
+	}
+
+	blockhash, err := p.Blockhash.LatestBlockhash(ctx, accept.Network)
+	if err != nil {
+		return "", "", err
+	}
+	msg, err := solana.NewMessage(feePayer, []solana.Instruction{
+		solana.SetComputeUnitLimit(p.ComputeUnitLimit),
+		solana.SetComputeUnitPrice(p.ComputeUnitPrice),
+		solana.TransferChecked(tokenProgram, source, mint, destination, p.address, amount, decimals),
+	}, blockhash)
+	if err != nil {
+		return "", "", err
+	}
+	tx := solana.NewTransaction(msg)
+	if err := tx.Sign(p.key); err != nil {
+		return "", "", err
+	}
+
+	payment := map[string]interface{}{
+		"x402Version": 1,
+		"scheme":      accept.Scheme,
+		"network":     accept.Network,
+		"payload": map[string]interface{}{
+			"transaction": base64.StdEncoding.EncodeToString(tx.Serialize()),
+		},
+	}
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal payment: %w", err)
+	}
+	return "Payment", base64.StdEncoding.EncodeToString(paymentBytes), nil
+}
@@ -0,0 +1,254 @@
+package providers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joelklabo/agentpay/internal/solana"
+	"github.com/joelklabo/agentpay/router"
+)
+
+type fixedBlockhash [32]byte
+
+func (b fixedBlockhash) LatestBlockhash(ctx context.Context, network string) ([32]byte, error) {
+	return b, nil
+}
+
+func writeKeypair(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	ints := make([]int, len(key))
+	for i, b := range key {
+		ints[i] = int(b)
+	}
+	data, _ := json.Marshal(ints)
+	path := filepath.Join(t.TempDir(), "id.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSVMKeyProvider_Pay(t *testing.T) {
+	ownerPub, ownerKey, _ := ed25519.GenerateKey(nil)
+	p, err := LoadSVMKeyProvider(writeKeypair(t, ownerKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var owner solana.PublicKey
+	copy(owner[:], ownerPub)
+	if p.Address() != owner.String() {
+		t.Errorf("address = %s, want %s", p.Address(), owner)
+	}
+	blockhash := fixedBlockhash{1, 2, 3}
+	p.Blockhash = blockhash
+
+	feePayer := "2wmVCSfPxGPjrnMMn7rchp4uaeoTqN39mXFC2zhPdri9"
+	accept := router.X402Accept{
+		Scheme:            "exact",
+		Network:           "solana:EtWTRABZaYq6iMfeYKouRu166VL8qxg7",
+		MaxAmountRequired: "10000",
+		PayTo:             testSolRecipient,
+		Asset:             "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU",
+		// The mint fixes the token program and decimals, not the server
+		Extra: json.RawMessage(`{"feePayer":"` + feePayer + `","decimals":0,"tokenProgram":"TokenzQdBNbLqP5VEhdkAS6EPFLC1PHnBqCXEpPxuEb"}`),
+	}
+	req := &router.PaymentRequirement{
+		Protocol: router.ProtocolX402,
+		X402Requirement: &router.X402Requirement{Accepts: []router.X402Accept{
+			{Scheme: "exact", Network: "eip155:8453", MaxAmountRequired: "5000", PayTo: "0xabc"},
+			accept,
+		}},
+	}
+
+	cost, _, err := p.EstimateCost(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost.Units != 10000 {
+		t.Errorf("cost = %s, want the Solana option", cost)
+	}
+
+	name, value, err := p.Pay(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Payment" {
+		t.Errorf("header = %q, want Payment", name)
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(value)
+	var payment struct {
+		X402Version int    `json:"x402Version"`
+		Scheme      string `json:"scheme"`
+		Network     string `json:"network"`
+		Payload     struct {
+			Transaction string `json:"transaction"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &payment); err != nil {
+		t.Fatal(err)
+	}
+	if payment.Network != accept.Network || payment.Scheme != "exact" {
+		t.Errorf("unexpected payment: %s", raw)
+	}
+	txBytes, err := base64.StdEncoding.DecodeString(payment.Payload.Transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := solana.ParseTransaction(txBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := tx.Message
+
+	if msg.AccountKeys[0].String() != feePayer {
+		t.Errorf("fee payer = %s, want %s", msg.AccountKeys[0], feePayer)
+	}
+	if tx.Signatures[0] != [64]byte{} {
+		t.Error("fee payer signature should be left for the facilitator")
+	}
+	if msg.AccountKeys[1] != owner || !ed25519.Verify(ownerPub, msg.Serialize(), tx.Signatures[1][:]) {
+		t.Error("transaction is not signed by the owner")
+	}
+	if msg.RecentBlockhash != blockhash {
+		t.Error("injected blockhash not used")
+	}
+
+	if len(msg.Instructions) != 3 {
+		t.Fatalf("got %d instructions, want compute limit, compute price, transfer", len(msg.Instructions))
+	}
+	for i, want := range []byte{2, 3} {
+		ix := msg.Instructions[i]
+		if msg.AccountKeys[ix.ProgramIDIndex] != solana.ComputeBudgetProgramID || ix.Data[0] != want {
+			t.Errorf("instruction %d is not a compute budget instruction", i)
+		}
+	}
+
+	transfer := msg.Instructions[2]
+	if msg.AccountKeys[transfer.ProgramIDIndex] != solana.TokenProgramID {
+		t.Error("transfer is not an SPL token instruction")
+	}
+	if transfer.Data[0] != 12 || binary.LittleEndian.Uint64(transfer.Data[1:9]) != 10000 || transfer.Data[9] != 6 {
+		t.Errorf("TransferChecked data = %x", transfer.Data)
+	}
+	mint := solana.MustPublicKey(accept.Asset)
+	source, _ := solana.AssociatedTokenAddress(owner, mint, solana.TokenProgramID)
+	dest, _ := solana.AssociatedTokenAddress(solana.MustPublicKey(testSolRecipient), mint, solana.TokenProgramID)
+	accounts := []solana.PublicKey{source, mint, dest, owner}
+	for i, want := range accounts {
+		if got := msg.AccountKeys[transfer.Accounts[i]]; got != want {
+			t.Errorf("transfer account %d = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestSVMKeyProvider_PaysOwnFeeWithoutFacilitator(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	p := NewSVMKeyProvider(key)
+	p.Blockhash = fixedBlockhash{9}
+
+	req := &router.PaymentRequirement{
+		Protocol: router.ProtocolX402,
+		X402Accept: &router.X402Accept{
+			Scheme:            "exact",
+			Network:           "solana:devnet",
+			MaxAmountRequired: "1000",
+			PayTo:             testSolRecipient,
+			Asset:             "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU",
+		},
+	}
+	_, value, err := p.Pay(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(value)
+	var payment struct {
+		Payload struct {
+			Transaction string `json:"transaction"`
+		} `json:"payload"`
+	}
+	json.Unmarshal(raw, &payment)
+	txBytes, _ := base64.StdEncoding.DecodeString(payment.Payload.Transaction)
+	tx, err := solana.ParseTransaction(txBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.Signatures) != 1 || tx.Message.AccountKeys[0].String() != p.Address() {
+		t.Error("expected the owner to be the only signer and fee payer")
+	}
+}
+
+func TestSVMKeyProvider_RejectsEVMOption(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	p := NewSVMKeyProvider(key)
+	req := &router.PaymentRequirement{
+		Protocol:   router.ProtocolX402,
+		X402Accept: &router.X402Accept{Scheme: "exact", Network: "eip155:8453", MaxAmountRequired: "1000"},
+	}
+	if _, _, err := p.Pay(context.Background(), req); err == nil {
+		t.Error("expected an error for an EVM option")
+	}
+}
+
+func TestSVMKeyProvider_OnlyUSDC(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	p := NewSVMKeyProvider(key)
+	p.Blockhash = fixedBlockhash{9}
+	for _, accept := range []router.X402Accept{
+		// Devnet USDC named on mainnet
+		{Scheme: "exact", Network: "solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp", MaxAmountRequired: "1000", PayTo: testSolRecipient, Asset: "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU"},
+		// Wrapped SOL has nine decimals
+		{Scheme: "exact", Network: "solana:mainnet", MaxAmountRequired: "1000", PayTo: testSolRecipient, Asset: "So11111111111111111111111111111111111111112"},
+		{Scheme: "exact", Network: "solana:testnet", MaxAmountRequired: "1000", PayTo: testSolRecipient, Asset: "EPjFWdd5AufqSSqeM2qN1xxoxmTbX6CmaVgDaZdgF3jT"},
+	} {
+		req := &router.PaymentRequirement{Protocol: router.ProtocolX402, X402Accept: &accept}
+		if _, _, err := p.EstimateCost(req); err == nil {
+			t.Errorf("%s on %s: priced", accept.Asset, accept.Network)
+		}
+		if _, _, err := p.Pay(context.Background(), req); err == nil {
+			t.Errorf("%s on %s: signed", accept.Asset, accept.Network)
+		}
+	}
+}
+
+func TestRPCBlockhashSource(t *testing.T) {
+	want := solana.MustPublicKey("EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&call)
+		if call.Method != "getLatestBlockhash" {
+			t.Errorf("method = %q", call.Method)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"result": map[string]interface{}{
+				"value": map[string]interface{}{"blockhash": want.String(), "lastValidBlockHeight": 100},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	src := &RPCBlockhashSource{Endpoints: map[string]string{"devnet": srv.URL}}
+	got, err := src.LatestBlockhash(context.Background(), "solana:EtWTRABZaYq6iMfeYKouRu166VL8qxg7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("blockhash = %x", got)
+	}
+
+	if _, err := src.LatestBlockhash(context.Background(), "solana:unknown"); err == nil {
+		t.Error("expected an error for an unknown cluster")
+	}
+}
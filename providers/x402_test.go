@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -17,7 +18,7 @@ func TestX402Provider_EstimateCost(t *testing.T) {
 	tests := []struct {
 		name    string
 		req     *router.PaymentRequirement
-		wantUSD float64
+		wantUSD router.Amount
 		wantErr bool
 	}{
 		{
@@ -32,7 +33,7 @@ func TestX402Provider_EstimateCost(t *testing.T) {
 					}},
 				},
 			},
-			wantUSD: 0.01,
+			wantUSD: router.FromUSD(0.01),
 		},
 		{
 			name: "picks cheapest of multiple options",
@@ -45,7 +46,7 @@ func TestX402Provider_EstimateCost(t *testing.T) {
 					},
 				},
 			},
-			wantUSD: 0.01,
+			wantUSD: router.FromUSD(0.01),
 		},
 		{
 			name: "no accepts",
@@ -70,7 +71,7 @@ func TestX402Provider_EstimateCost(t *testing.T) {
 				t.Fatalf("unexpected error: %v", err)
 			}
 			if usd != tt.wantUSD {
-				t.Errorf("got $%.4f, want $%.4f", usd, tt.wantUSD)
+				t.Errorf("got %s, want %s", usd, tt.wantUSD)
 			}
 		})
 	}
@@ -91,8 +92,13 @@ func TestX402Provider_Pay(t *testing.T) {
 		if err := json.Unmarshal(body, &payload); err != nil {
 			t.Fatalf("invalid JSON payload: %v", err)
 		}
-		if payload["preferredChain"] != "auto" {
-			t.Errorf("expected preferredChain=auto, got %v", payload["preferredChain"])
+		if payload["preferredChain"] != "evm" {
+			t.Errorf("expected preferredChain=evm, got %v", payload["preferredChain"])
+		}
+		raw, _ := base64.StdEncoding.DecodeString(payload["requirement"].(string))
+		var requirement router.X402Requirement
+		if err := json.Unmarshal(raw, &requirement); err != nil || len(requirement.Accepts) != 1 || requirement.Accepts[0].MaxAmountRequired != "10000" {
+			t.Errorf("unexpected requirement %s", raw)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -144,6 +150,9 @@ func TestX402Provider_PayFailure(t *testing.T) {
 	req := &router.PaymentRequirement{
 		Protocol: router.ProtocolX402,
 		Raw:      "req",
+		X402Requirement: &router.X402Requirement{
+			Accepts: []router.X402Accept{{Network: "eip155:84532", MaxAmountRequired: "10000", PayTo: "0xabc"}},
+		},
 	}
 
 	_, _, err := p.Pay(context.Background(), req)
@@ -167,3 +176,105 @@ func containsSubstring(s, sub string) bool {
 	}
 	return false
 }
+
+func TestX402Provider_PreferredChain(t *testing.T) {
+	p := NewX402Provider("http://localhost", "user", "token")
+	p.PreferredChain = "solana"
+
+	req := &router.PaymentRequirement{
+		Protocol: router.ProtocolX402,
+		X402Requirement: &router.X402Requirement{
+			Accepts: []router.X402Accept{
+				{Network: "eip155:8453", MaxAmountRequired: "10000", PayTo: "0xabc"},
+				{Network: "solana:mainnet", MaxAmountRequired: "20000", PayTo: "SoLaNa"},
+			},
+		},
+	}
+
+	usd, desc, err := p.EstimateCost(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usd != router.FromUSD(0.02) {
+		t.Errorf("expected the Solana option ($0.02), got %s (%s)", usd, desc)
+	}
+
+	// An option chosen by the router wins over PreferredChain
+	req.X402Accept = &req.X402Requirement.Accepts[0]
+	usd, _, err = p.EstimateCost(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usd != router.FromUSD(0.01) {
+		t.Errorf("expected the selected Base option ($0.01), got %s", usd)
+	}
+}
+
+func TestX402Provider_PaySelectedChain(t *testing.T) {
+	var gotChain interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		gotChain = payload["preferredChain"]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":          true,
+			"paymentSignature": "sig",
+		})
+	}))
+	defer srv.Close()
+
+	p := NewX402Provider(srv.URL, "testuser", "test-token")
+	accept := router.X402Accept{Network: "solana:devnet", MaxAmountRequired: "1000", PayTo: "SoLaNa"}
+	req := &router.PaymentRequirement{
+		Protocol:        router.ProtocolX402,
+		Raw:             "req",
+		X402Requirement: &router.X402Requirement{Accepts: []router.X402Accept{accept}},
+		X402Accept:      &accept,
+	}
+
+	if _, _, err := p.Pay(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotChain != "solana" {
+		t.Errorf("expected preferredChain=solana for a Solana option, got %v", gotChain)
+	}
+}
+
+func TestX402Provider_PaySendsOnlyChosenAccept(t *testing.T) {
+	var requirement string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		requirement = payload["requirement"]
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "paymentSignature": "sig"})
+	}))
+	defer srv.Close()
+
+	header := `{"x402Version":2,"resource":"https://api.example.com/x","accepts":[` +
+		`{"scheme":"exact","network":"eip155:8453","maxAmountRequired":"10000","payTo":"0xabc"},` +
+		`{"scheme":"exact","network":"eip155:1","maxAmountRequired":"9000000","payTo":"0xabc"}]}`
+	options, err := router.ParseX402Requirement(base64.StdEncoding.EncodeToString([]byte(header)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewX402Provider(srv.URL, "testuser", "test-token")
+	if _, _, err := p.Pay(context.Background(), options[0]); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(requirement)
+	if err != nil {
+		t.Fatalf("requirement is not base64: %q", requirement)
+	}
+	var got struct {
+		X402Version int                 `json:"x402Version"`
+		Resource    string              `json:"resource"`
+		Accepts     []router.X402Accept `json:"accepts"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.X402Version != 2 || got.Resource == "" || len(got.Accepts) != 1 || got.Accepts[0].Network != "eip155:8453" {
+		t.Errorf("requirement = %s", raw)
+	}
+}
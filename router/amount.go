@@ -0,0 +1,167 @@
+package router
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Asset identifies what an Amount is denominated in. Decimals is the number of
+// minor units per whole unit as a power of ten (USDC has 6, BTC has 8 for sats).
+type Asset struct {
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+var (
+	// USD is the router's accounting currency. Budgets and costs are kept in
+	// micro-dollars so they line up 1:1 with USDC minor units.
+	USD = Asset{Symbol: "USD", Decimals: 6}
+	// USDC is the x402 settlement token (6 decimals on every supported chain).
+	USDC = Asset{Symbol: "USDC", Decimals: 6}
+	// Sat denominates bitcoin amounts in satoshis.
+	Sat = Asset{Symbol: "BTC", Decimals: 8}
+	// Msat denominates bitcoin amounts in millisatoshis.
+	Msat = Asset{Symbol: "BTC", Decimals: 11}
+)
+
+// Amount is an exact quantity of an asset expressed in integer minor units.
+// Money math must go through Amount; float64 is only for display.
+type Amount struct {
+	Units int64 `json:"units"`
+	Asset Asset `json:"asset"`
+}
+
+// NewAmount returns an Amount of units minor units of asset.
+func NewAmount(units int64, asset Asset) Amount {
+	return Amount{Units: units, Asset: asset}
+}
+
+// ParseUnits parses an integer string of minor units, as used by x402's
+// maxAmountRequired field.
+func ParseUnits(s string, asset Asset) (Amount, error) {
+	units, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return Amount{}, fmt.Errorf("parse amount %q: %w", s, err)
+	}
+	if units < 0 {
+		return Amount{}, fmt.Errorf("parse amount %q: negative", s)
+	}
+	return Amount{Units: units, Asset: asset}, nil
+}
+
+// ParseDecimal parses a decimal string in whole units ("0.01") into an exact
+// Amount. It fails rather than rounding if s has more precision than asset.
+func ParseDecimal(s string, asset Asset) (Amount, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return Amount{}, fmt.Errorf("parse amount %q: empty", s)
+	}
+	if len(frac) > asset.Decimals {
+		trimmed := strings.TrimRight(frac[asset.Decimals:], "0")
+		if trimmed != "" {
+			return Amount{}, fmt.Errorf("parse amount %q: more than %d decimals", s, asset.Decimals)
+		}
+		frac = frac[:asset.Decimals]
+	}
+	frac += strings.Repeat("0", asset.Decimals-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	return ParseUnits(whole+frac, asset)
+}
+
+// FromUSD converts a float dollar amount from configuration or user input into
+// micro-dollars, rounding to the nearest unit. Use it at input boundaries only.
+func FromUSD(usd float64) Amount {
+	return Amount{Units: int64(math.Round(usd * 1e6)), Asset: USD}
+}
+
+// BTCToUSD values a bitcoin Amount (Sat or Msat) in USD at btcPriceUSD dollars
+// per BTC. The result is rounded up so budget checks never under-count.
+func BTCToUSD(a Amount, btcPriceUSD int64) Amount {
+	n := new(big.Int).Mul(big.NewInt(a.Units), big.NewInt(btcPriceUSD))
+	n.Mul(n, pow10(USD.Decimals))
+	d := pow10(a.Asset.Decimals)
+	q, m := new(big.Int).QuoRem(n, d, new(big.Int))
+	if m.Sign() > 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return Amount{Units: q.Int64(), Asset: USD}
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Add returns a+b. Both amounts must be in the same asset; mixing assets is a
+// programming error and panics.
+func (a Amount) Add(b Amount) Amount {
+	a.mustMatch(b)
+	return Amount{Units: a.Units + b.Units, Asset: a.Asset}
+}
+
+// Sub returns a-b. Both amounts must be in the same asset.
+func (a Amount) Sub(b Amount) Amount {
+	a.mustMatch(b)
+	return Amount{Units: a.Units - b.Units, Asset: a.Asset}
+}
+
+// Cmp compares a and b and returns -1, 0 or +1. Both amounts must be in the
+// same asset.
+func (a Amount) Cmp(b Amount) int {
+	a.mustMatch(b)
+	switch {
+	case a.Units < b.Units:
+		return -1
+	case a.Units > b.Units:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// IsZero reports whether the amount is zero.
+func (a Amount) IsZero() bool {
+	return a.Units == 0
+}
+
+// Float64 returns the amount in whole units. For display only.
+func (a Amount) Float64() float64 {
+	return float64(a.Units) / math.Pow10(a.Asset.Decimals)
+}
+
+// Decimal returns the exact amount in whole units with trailing zeros trimmed.
+func (a Amount) Decimal() string {
+	units := a.Units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	s := strconv.FormatInt(units, 10)
+	if a.Asset.Decimals == 0 {
+		return sign + s
+	}
+	if len(s) <= a.Asset.Decimals {
+		s = strings.Repeat("0", a.Asset.Decimals-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-a.Asset.Decimals], strings.TrimRight(s[len(s)-a.Asset.Decimals:], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+func (a Amount) String() string {
+	return a.Decimal() + " " + a.Asset.Symbol
+}
+
+func (a Amount) mustMatch(b Amount) {
+	if a.Asset != b.Asset {
+		panic(fmt.Sprintf("router: amount asset mismatch: %s vs %s", a, b))
+	}
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		asset   Asset
+		want    int64
+		wantErr bool
+	}{
+		{in: "0.01", asset: USD, want: 10000},
+		{in: "1", asset: USD, want: 1000000},
+		{in: ".5", asset: USDC, want: 500000},
+		{in: "0.0000010", asset: USD, want: 1},
+		{in: "0.0000001", asset: USD, wantErr: true},
+		{in: "21", asset: Sat, want: 2100000000},
+		{in: "-1", asset: USD, wantErr: true},
+		{in: "abc", asset: USD, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDecimal(tt.in, tt.asset)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Units != tt.want || got.Asset != tt.asset {
+				t.Errorf("got %d %s, want %d %s", got.Units, got.Asset.Symbol, tt.want, tt.asset.Symbol)
+			}
+		})
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		amt  Amount
+		want string
+	}{
+		{NewAmount(10000, USD), "0.01 USD"},
+		{NewAmount(1500000, USDC), "1.5 USDC"},
+		{NewAmount(0, USD), "0 USD"},
+		{NewAmount(-250, USD), "-0.00025 USD"},
+		{NewAmount(21, Asset{Symbol: "sat"}), "21 sat"},
+	}
+	for _, tt := range tests {
+		if got := tt.amt.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestBTCToUSD(t *testing.T) {
+	// 10 sats at $100K/BTC = $0.01
+	if got := BTCToUSD(NewAmount(10, Sat), 100000); got != NewAmount(10000, USD) {
+		t.Errorf("10 sats = %s, want 0.01 USD", got)
+	}
+	// 1 msat at $100K/BTC = $0.000001 exactly
+	if got := BTCToUSD(NewAmount(1, Msat), 100000); got != NewAmount(1, USD) {
+		t.Errorf("1 msat = %s, want 0.000001 USD", got)
+	}
+	// Sub-unit results round up so budgets are never under-counted
+	if got := BTCToUSD(NewAmount(1, Msat), 50000); got != NewAmount(1, USD) {
+		t.Errorf("1 msat at $50K = %s, want 0.000001 USD (rounded up)", got)
+	}
+}
+
+func TestAmountMismatchPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic adding USD to sats")
+		}
+	}()
+	NewAmount(1, USD).Add(NewAmount(1, Sat))
+}
+
+func TestRouter_BudgetNoDrift(t *testing.T) {
+	// 1000 payments of $0.001 must land exactly on a $1.00 session budget.
+	// With float64 the running total drifts and the boundary becomes flaky.
+	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 1.0})
+	cost := FromUSD(0.001)
+
+	for i := 0; i < 1000; i++ {
+		if err := r.checkBudget(context.Background(), cost); err != nil {
+			t.Fatalf("payment %d rejected: %v", i+1, err)
+		}
+		if err := r.reserve(cost); err != nil {
+			t.Fatalf("payment %d not reserved: %v", i+1, err)
+		}
+	}
+
+	if r.SessionSpend() != FromUSD(1.0) {
+		t.Errorf("session spend = %s, want exactly 1 USD", r.SessionSpend())
+	}
+	if err := r.checkBudget(context.Background(), NewAmount(1, USD)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected budget exceeded at the boundary, got %v", err)
+	}
+}
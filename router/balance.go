@@ -0,0 +1,133 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BalanceProvider is implemented by providers that can report how much they
+// are able to spend. It is optional: the router skips options a provider
+// cannot afford, and StrategyBalance ranks providers without it last.
+type BalanceProvider interface {
+	// Balance returns the spendable balance for settling req, valued in USD.
+	Balance(ctx context.Context, req *PaymentRequirement) (Amount, error)
+}
+
+// EventType identifies a router event.
+type EventType string
+
+const (
+	// EventLowBalance fires when a wallet's balance drops below Config.LowBalanceUSD.
+	EventLowBalance EventType = "low_balance"
+)
+
+// Event is a notification the router emits while routing payments.
+type Event struct {
+	Type     EventType
+	Protocol Protocol
+	Rail     string
+	// Balance is the wallet's spendable balance valued in USD.
+	Balance Amount
+	// Threshold is the configured warning level.
+	Threshold Amount
+	Message   string
+}
+
+// defaultBalanceTTL is how long a fetched balance is trusted before the
+// provider is asked again.
+const defaultBalanceTTL = time.Minute
+
+// balanceKey identifies one wallet balance: providers can hold different
+// funds per rail (USDC on Base vs Solana).
+type balanceKey struct {
+	provider PaymentProvider
+	rail     string
+}
+
+type cachedBalance struct {
+	amount  Amount
+	fetched time.Time
+}
+
+// OnEvent registers a handler for router events such as low-balance warnings.
+// The handler is called synchronously from Fetch.
+func (r *Router) OnEvent(fn func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onEvent = fn
+}
+
+// balance returns the spendable balance bp reports for req, served from cache
+// when fresh.
+func (r *Router) balance(ctx context.Context, provider PaymentProvider, bp BalanceProvider, req *PaymentRequirement) (Amount, error) {
+	key := balanceKey{provider: provider, rail: req.Rail()}
+	ttl := r.config.BalanceTTL
+	if ttl == 0 {
+		ttl = defaultBalanceTTL
+	}
+
+	r.mu.Lock()
+	c, ok := r.balances[key]
+	r.mu.Unlock()
+	if ok && time.Since(c.fetched) < ttl {
+		return c.amount, nil
+	}
+
+	amt, err := bp.Balance(ctx, req)
+	if err != nil {
+		return Amount{}, err
+	}
+
+	r.mu.Lock()
+	r.balances[key] = cachedBalance{amount: amt, fetched: time.Now()}
+	r.mu.Unlock()
+
+	r.checkLowBalance(key, req.Protocol, amt)
+	return amt, nil
+}
+
+// debitBalance subtracts a settled payment from the cached balance so the
+// next request sees it without refetching.
+func (r *Router) debitBalance(provider PaymentProvider, req *PaymentRequirement, cost Amount) {
+	key := balanceKey{provider: provider, rail: req.Rail()}
+
+	r.mu.Lock()
+	c, ok := r.balances[key]
+	if ok {
+		c.amount = c.amount.Sub(cost)
+		r.balances[key] = c
+	}
+	r.mu.Unlock()
+
+	if ok {
+		r.checkLowBalance(key, req.Protocol, c.amount)
+	}
+}
+
+// checkLowBalance emits EventLowBalance once each time a wallet crosses below
+// the configured threshold.
+func (r *Router) checkLowBalance(key balanceKey, protocol Protocol, amt Amount) {
+	if r.lowBalance.IsZero() {
+		return
+	}
+
+	r.mu.Lock()
+	below := amt.Cmp(r.lowBalance) < 0
+	alreadyWarned := r.lowWarned[key]
+	r.lowWarned[key] = below
+	fn := r.onEvent
+	r.mu.Unlock()
+
+	if below && !alreadyWarned && fn != nil {
+		fn(Event{
+			Type:      EventLowBalance,
+			Protocol:  protocol,
+			Rail:      key.rail,
+			Balance:   amt,
+			Threshold: r.lowBalance,
+			Message: fmt.Sprintf("%s wallet on %s is low: %s (warning below %s)",
+				protocol, key.rail, amt, r.lowBalance),
+		})
+	}
+}
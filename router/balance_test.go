@@ -0,0 +1,119 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// balanceMockProvider is a mockProvider that also reports a wallet balance.
+type balanceMockProvider struct {
+	mockProvider
+	balance Amount
+	calls   int
+}
+
+func (m *balanceMockProvider) Balance(ctx context.Context, req *PaymentRequirement) (Amount, error) {
+	m.calls++
+	return m.balance, nil
+}
+
+func TestRouter_SkipsUnaffordableWallet(t *testing.T) {
+	srv := multiRailServer(t, "eip155:8453")
+	defer srv.Close()
+
+	// L402 is cheaper but the Lightning wallet is empty
+	r := New(Config{MaxPerRequestUSD: 1.0})
+	r.RegisterProvider(&balanceMockProvider{
+		mockProvider: mockProvider{protocol: ProtocolX402, cost: FromUSD(0.01), headerName: "Payment-Signature", headerValue: "sig"},
+		balance:      FromUSD(5),
+	})
+	r.RegisterProvider(&balanceMockProvider{
+		mockProvider: mockProvider{protocol: ProtocolL402, cost: FromUSD(0.005)},
+		balance:      FromUSD(0.001),
+	})
+
+	_, receipt, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Rail != "eip155:8453" {
+		t.Errorf("expected the funded Base wallet, got %s", receipt.Rail)
+	}
+}
+
+func TestRouter_AllWalletsEmpty(t *testing.T) {
+	srv := multiRailServer(t, "eip155:8453")
+	defer srv.Close()
+
+	r := New(Config{MaxPerRequestUSD: 1.0})
+	r.RegisterProvider(&balanceMockProvider{
+		mockProvider: mockProvider{protocol: ProtocolX402, cost: FromUSD(0.01)},
+		balance:      NewAmount(0, USD),
+	})
+
+	_, _, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+	}
+}
+
+func TestRouter_BalanceStrategy(t *testing.T) {
+	srv := multiRailServer(t, "eip155:8453")
+	defer srv.Close()
+
+	r := New(Config{MaxPerRequestUSD: 1.0, Strategy: StrategyBalance})
+	r.RegisterProvider(&balanceMockProvider{
+		mockProvider: mockProvider{protocol: ProtocolX402, cost: FromUSD(0.01), headerName: "Payment-Signature", headerValue: "sig"},
+		balance:      FromUSD(20),
+	})
+	r.RegisterProvider(&balanceMockProvider{
+		mockProvider: mockProvider{protocol: ProtocolL402, cost: FromUSD(0.005), headerName: "Authorization", headerValue: "L402 m:p"},
+		balance:      FromUSD(2),
+	})
+
+	_, receipt, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Protocol != "x402" {
+		t.Errorf("expected the wallet with the most balance (x402), got %s", receipt.Protocol)
+	}
+}
+
+func TestRouter_LowBalanceEventAndCache(t *testing.T) {
+	srv := multiRailServer(t, "eip155:8453")
+	defer srv.Close()
+
+	wallet := &balanceMockProvider{
+		mockProvider: mockProvider{protocol: ProtocolX402, cost: FromUSD(0.01), headerName: "Payment-Signature", headerValue: "sig"},
+		balance:      FromUSD(0.025),
+	}
+	r := New(Config{MaxPerRequestUSD: 1.0, LowBalanceUSD: 0.02})
+	r.RegisterProvider(wallet)
+
+	var events []Event
+	r.OnEvent(func(e Event) { events = append(events, e) })
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil); err != nil {
+			t.Fatalf("fetch %d: %v", i+1, err)
+		}
+	}
+
+	if wallet.calls != 1 {
+		t.Errorf("expected the balance to be fetched once and cached, got %d calls", wallet.calls)
+	}
+	// $0.025 -> $0.015 crosses the $0.02 threshold; -> $0.005 must not warn again
+	if len(events) != 1 {
+		t.Fatalf("expected 1 low-balance event, got %d", len(events))
+	}
+	if events[0].Type != EventLowBalance || events[0].Balance != FromUSD(0.015) {
+		t.Errorf("unexpected event: %+v", events[0])
+	}
+
+	// Third payment would overdraw the cached $0.005
+	if _, _, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("expected ErrInsufficientBalance from cached balance, got %v", err)
+	}
+}
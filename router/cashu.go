@@ -0,0 +1,121 @@
+package router
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/joelklabo/agentpay/internal/cbor"
+)
+
+// ProtocolCashu is Cashu ecash paid per NUT-24: the server sends a NUT-18
+// payment request in the X-Cashu header and the client retries with a token
+// in the same header.
+const ProtocolCashu Protocol = "cashu"
+
+// cashuRequestPrefix marks a version A (CBOR) NUT-18 payment request.
+const cashuRequestPrefix = "creqA"
+
+// CashuRequest is a parsed NUT-18 payment request. It is the Details of every
+// ProtocolCashu PaymentRequirement.
+type CashuRequest struct {
+	ID          string   // optional request ID
+	Amount      int64    // amount in Unit
+	Unit        string   // "sat", "msat", "usd" (cents)...
+	SingleUse   bool     // the request may only be paid once
+	Mints       []string // mints the server accepts tokens from
+	Description string
+}
+
+// Rail implements the rail naming used by PaymentRequirement.Rail.
+func (*CashuRequest) Rail() string { return "cashu" }
+
+// Encode serializes the request as a "creqA..." string.
+func (c *CashuRequest) Encode() (string, error) {
+	m := map[string]any{"a": c.Amount, "u": c.Unit, "m": c.Mints}
+	if c.ID != "" {
+		m["i"] = c.ID
+	}
+	if c.SingleUse {
+		m["s"] = true
+	}
+	if c.Description != "" {
+		m["d"] = c.Description
+	}
+	data, err := cbor.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return cashuRequestPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParseCashuRequest decodes a "creqA..." NUT-18 payment request.
+func ParseCashuRequest(s string) (*CashuRequest, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, cashuRequestPrefix) {
+		return nil, fmt.Errorf("cashu payment request: unsupported encoding")
+	}
+	data, err := decodeBase64URL(s[len(cashuRequestPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("cashu payment request: %w", err)
+	}
+	v, err := cbor.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("cashu payment request: %w", err)
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("cashu payment request: not a map")
+	}
+
+	req := &CashuRequest{}
+	req.ID, _ = m["i"].(string)
+	req.Unit, _ = m["u"].(string)
+	req.SingleUse, _ = m["s"].(bool)
+	req.Description, _ = m["d"].(string)
+	if a, ok := m["a"].(uint64); ok {
+		req.Amount = int64(a)
+	}
+	if mints, ok := m["m"].([]any); ok {
+		for _, mint := range mints {
+			if s, ok := mint.(string); ok {
+				req.Mints = append(req.Mints, s)
+			}
+		}
+	}
+
+	// NUT-24 requires amount, unit and mints so the client can pay blind
+	if req.Amount <= 0 || req.Unit == "" || len(req.Mints) == 0 {
+		return nil, fmt.Errorf("cashu payment request: amount, unit and mints are required")
+	}
+	return req, nil
+}
+
+// decodeBase64URL accepts both padded and unpadded URL-safe base64.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// CashuDetector recognizes NUT-24 challenges in the X-Cashu header.
+type CashuDetector struct{}
+
+// Protocol implements Detector.
+func (CashuDetector) Protocol() Protocol { return ProtocolCashu }
+
+// Detect implements Detector.
+func (CashuDetector) Detect(resp *http.Response, body []byte) ([]*PaymentRequirement, error) {
+	header := resp.Header.Get("X-Cashu")
+	if header == "" {
+		return nil, nil
+	}
+	req, err := ParseCashuRequest(header)
+	if err != nil {
+		return nil, err
+	}
+	return []*PaymentRequirement{{
+		Protocol: ProtocolCashu,
+		Raw:      header,
+		Details:  req,
+	}}, nil
+}
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCashuRequestRoundTrip(t *testing.T) {
+	in := &CashuRequest{
+		ID:          "b7a90176",
+		Amount:      10,
+		Unit:        "sat",
+		SingleUse:   true,
+		Mints:       []string{"https://8333.space:3338"},
+		Description: "weather API",
+	}
+	encoded, err := in.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ParseCashuRequest(encoded)
+	if err != nil {
+		t.Fatalf("parse %s: %v", encoded, err)
+	}
+	if out.ID != in.ID || out.Amount != in.Amount || out.Unit != in.Unit || !out.SingleUse ||
+		out.Description != in.Description || len(out.Mints) != 1 || out.Mints[0] != in.Mints[0] {
+		t.Errorf("round trip changed the request: %+v", out)
+	}
+}
+
+func TestCashuDetector(t *testing.T) {
+	encoded, _ := (&CashuRequest{Amount: 5, Unit: "sat", Mints: []string{"https://mint.example"}}).Encode()
+
+	resp := &http.Response{StatusCode: 402, Header: http.Header{}}
+	resp.Header.Set("X-Cashu", encoded)
+
+	options, err := CashuDetector{}.Detect(resp, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(options) != 1 || options[0].Protocol != ProtocolCashu || options[0].Rail() != "cashu" {
+		t.Fatalf("expected one cashu option, got %+v", options)
+	}
+	if cr := options[0].Details.(*CashuRequest); cr.Amount != 5 {
+		t.Errorf("expected amount 5, got %d", cr.Amount)
+	}
+
+	// NUT-24 requests must name amount, unit and mints
+	incomplete, _ := (&CashuRequest{Amount: 5, Unit: "sat"}).Encode()
+	resp.Header.Set("X-Cashu", incomplete)
+	if _, err := (CashuDetector{}).Detect(resp, nil); err == nil {
+		t.Error("expected an error for a request without mints")
+	}
+}
@@ -0,0 +1,126 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// tokenDetector recognizes a made-up "X-Token-Price" 402 scheme.
+type tokenDetector struct{}
+
+type tokenRequirement struct {
+	Price string
+}
+
+func (tokenRequirement) Rail() string { return "tokens" }
+
+const protocolToken Protocol = "token"
+
+func (tokenDetector) Protocol() Protocol { return protocolToken }
+
+func (tokenDetector) Detect(resp *http.Response, body []byte) ([]*PaymentRequirement, error) {
+	price := resp.Header.Get("X-Token-Price")
+	if price == "" {
+		return nil, nil
+	}
+	if price == "bad" {
+		return nil, errors.New("malformed token price")
+	}
+	return []*PaymentRequirement{{
+		Protocol: protocolToken,
+		Raw:      price,
+		Details:  tokenRequirement{Price: price},
+	}}, nil
+}
+
+func TestRouter_RegisterDetector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") == "paid" {
+			w.Write([]byte(`ok`))
+			return
+		}
+		w.Header().Set("X-Token-Price", "5")
+		w.WriteHeader(402)
+	}))
+	defer srv.Close()
+
+	r := New(Config{MaxPerRequestUSD: 1.0})
+	r.RegisterProvider(&mockProvider{
+		protocol:    protocolToken,
+		cost:        FromUSD(0.05),
+		description: "5 tokens",
+		headerName:  "X-Token",
+		headerValue: "paid",
+	})
+
+	// Without the detector the scheme is unknown
+	if _, _, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil); !errors.Is(err, ErrUnknownProtocol) {
+		t.Fatalf("expected ErrUnknownProtocol before registering, got %v", err)
+	}
+
+	r.RegisterDetector(tokenDetector{})
+	body, receipt, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "ok" {
+		t.Errorf("expected ok, got %q", body)
+	}
+	if receipt.Protocol != "token" || receipt.Rail != "tokens" {
+		t.Errorf("expected token receipt on rail tokens, got %s on %s", receipt.Protocol, receipt.Rail)
+	}
+}
+
+func TestDetect_ErrorOnlyWithoutOptions(t *testing.T) {
+	resp := &http.Response{StatusCode: 402, Header: http.Header{}}
+	resp.Header.Set("X-Token-Price", "bad")
+	detectors := append(DefaultDetectors(), tokenDetector{})
+
+	if _, err := detect(detectors, resp, nil); err == nil || err.Error() != "malformed token price" {
+		t.Errorf("expected the detector's error, got %v", err)
+	}
+
+	// A usable option from another detector wins over the parse error
+	resp.Header.Set("WWW-Authenticate", `L402 macaroon="m", invoice="lnbc10n1pjtest"`)
+	options, err := detect(detectors, resp, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(options) != 1 || options[0].Protocol != ProtocolL402 {
+		t.Errorf("expected the L402 option, got %+v", options)
+	}
+}
+
+func TestX402Detector_Absent(t *testing.T) {
+	resp := &http.Response{StatusCode: 402, Header: http.Header{}}
+	options, err := X402Detector{}.Detect(resp, []byte(`{"invoice":"lnbc1"}`))
+	if err != nil || len(options) != 0 {
+		t.Errorf("expected no options and no error, got %v, %v", options, err)
+	}
+}
+
+func TestL402Detector_MalformedHeaderFallsBackToBody(t *testing.T) {
+	resp := &http.Response{StatusCode: 402, Header: http.Header{}}
+	resp.Header.Set("WWW-Authenticate", `L402 macaroon="m"`) // no invoice
+
+	if _, err := (L402Detector{}).Detect(resp, nil); !errors.Is(err, ErrMissingInvoice) {
+		t.Errorf("expected ErrMissingInvoice, got %v", err)
+	}
+
+	options, err := L402Detector{}.Detect(resp, []byte(`{"pr":"lnbc10n1pjbody"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(options) != 1 || options[0].L402Invoice != "lnbc10n1pjbody" {
+		t.Errorf("expected the body invoice, got %+v", options)
+	}
+}
+
+func TestProtocolString(t *testing.T) {
+	if ProtocolUnknown.String() != "unknown" || ProtocolL402.String() != "L402" {
+		t.Errorf("unexpected protocol names %q, %q", ProtocolUnknown, ProtocolL402)
+	}
+}
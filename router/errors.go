@@ -6,12 +6,14 @@ import (
 )
 
 var (
-	ErrUnknownProtocol = errors.New("unknown payment protocol")
-	ErrMalformedL402   = errors.New("malformed L402 challenge")
-	ErrMissingInvoice  = errors.New("missing invoice in L402 response")
-	ErrBudgetExceeded  = errors.New("payment would exceed budget")
-	ErrPaymentFailed   = errors.New("payment settlement failed")
-	ErrNoProvider      = errors.New("no payment provider configured for protocol")
+	ErrUnknownProtocol     = errors.New("unknown payment protocol")
+	ErrMalformedL402       = errors.New("malformed L402 challenge")
+	ErrMissingInvoice      = errors.New("missing invoice in L402 response")
+	ErrBudgetExceeded      = errors.New("payment would exceed budget")
+	ErrPaymentFailed       = errors.New("payment settlement failed")
+	ErrNoProvider          = errors.New("no payment provider configured for protocol")
+	ErrInsufficientBalance = errors.New("insufficient wallet balance")
+	ErrCircuitOpen         = errors.New("provider circuit open after repeated failures")
 )
 
 // PaymentError wraps a payment failure with protocol and amount context.
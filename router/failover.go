@@ -0,0 +1,146 @@
+package router
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Capabilities describes which payment options a provider can settle.
+// Networks are matched by prefix against PaymentRequirement.Rail ("eip155:"
+// matches every EVM chain); Schemes are matched exactly against the x402
+// scheme. An empty list matches anything.
+type Capabilities struct {
+	Networks []string
+	Schemes  []string
+}
+
+// CapableProvider is implemented by providers that only support some networks
+// or schemes of their protocol. Providers without it are tried for every
+// option of their protocol.
+type CapableProvider interface {
+	Capabilities() Capabilities
+}
+
+// Supports reports whether the capabilities cover req.
+func (c Capabilities) Supports(req *PaymentRequirement) bool {
+	if len(c.Networks) > 0 {
+		rail := req.Rail()
+		ok := false
+		for _, n := range c.Networks {
+			if strings.HasPrefix(rail, n) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(c.Schemes) > 0 && req.X402Accept != nil && req.X402Accept.Scheme != "" {
+		for _, s := range c.Schemes {
+			if s == req.X402Accept.Scheme {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ProviderOption configures a provider registration.
+type ProviderOption func(*registration)
+
+// WithPriority sets the provider's priority within its protocol. Lower values
+// are tried first; providers with equal priority keep registration order.
+func WithPriority(priority int) ProviderOption {
+	return func(reg *registration) { reg.priority = priority }
+}
+
+// registration is a provider plus its routing state.
+type registration struct {
+	provider PaymentProvider
+	priority int
+	seq      int
+
+	// circuit breaker state, guarded by Router.mu
+	failures  int
+	openUntil time.Time
+}
+
+const (
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// breakerOpen reports whether reg's circuit is open. Once the cooldown passes
+// the breaker lets requests through again (half-open); a single further
+// failure reopens it.
+func (r *Router) breakerOpen(reg *registration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Now().Before(reg.openUntil)
+}
+
+// recordResult updates reg's breaker after a Pay attempt. Only retryable
+// failures count: a declined payment says nothing about backend health.
+func (r *Router) recordResult(reg *registration, err error) {
+	threshold := r.config.BreakerThreshold
+	if threshold == 0 {
+		threshold = defaultBreakerThreshold
+	}
+	cooldown := r.config.BreakerCooldown
+	if cooldown == 0 {
+		cooldown = defaultBreakerCooldown
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+		reg.failures = 0
+		reg.openUntil = time.Time{}
+	case IsRetryable(err):
+		reg.failures++
+		if reg.failures >= threshold {
+			reg.openUntil = time.Now().Add(cooldown)
+		}
+	}
+}
+
+// retryableError marks a failure as transient.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Retryable marks err as transient (backend unreachable, 5xx, rate limited) so
+// the router fails over to the next provider instead of giving up. Providers
+// mark only failures they know sent nothing: paying elsewhere after a payment
+// that may still settle could pay twice.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// IsRetryable reports whether err is worth retrying with another provider:
+// only errors marked with Retryable. A timeout or dropped connection leaves
+// the outcome unknown, so it is final.
+func IsRetryable(err error) bool {
+	var re *retryableError
+	return errors.As(err, &re)
+}
+
+// providerName returns a short name for a provider for receipts and errors.
+func providerName(p PaymentProvider) string {
+	t := reflect.TypeOf(p)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
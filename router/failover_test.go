@@ -0,0 +1,162 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingProvider is a mockProvider that counts Pay calls.
+type countingProvider struct {
+	mockProvider
+	caps  *Capabilities
+	calls int
+}
+
+func (c *countingProvider) Pay(ctx context.Context, req *PaymentRequirement) (string, string, error) {
+	c.calls++
+	return c.mockProvider.Pay(ctx, req)
+}
+
+func (c *countingProvider) Capabilities() Capabilities {
+	if c.caps == nil {
+		return Capabilities{}
+	}
+	return *c.caps
+}
+
+func x402Provider(payErr error) *countingProvider {
+	return &countingProvider{mockProvider: mockProvider{
+		protocol:    ProtocolX402,
+		cost:        FromUSD(0.01),
+		description: "$0.01 USDC",
+		headerName:  "Payment-Signature",
+		headerValue: "sig",
+		payErr:      payErr,
+	}}
+}
+
+func TestRouter_FailoverOnRetryableError(t *testing.T) {
+	srv := multiRailServer(t, "eip155:8453")
+	defer srv.Close()
+
+	down := x402Provider(Retryable(errors.New("HTTP 503")))
+	backup := x402Provider(nil)
+
+	r := New(Config{MaxPerRequestUSD: 1.0})
+	r.RegisterProvider(backup, WithPriority(10))
+	r.RegisterProvider(down, WithPriority(1))
+
+	_, receipt, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if down.calls != 1 || backup.calls != 1 {
+		t.Errorf("expected primary then backup, got calls %d/%d", down.calls, backup.calls)
+	}
+	if receipt.Protocol != "x402" {
+		t.Errorf("expected x402 receipt, got %s", receipt.Protocol)
+	}
+
+	var failed *Alternative
+	for i := range receipt.Alternatives {
+		if strings.Contains(receipt.Alternatives[i].Skipped, "HTTP 503") {
+			failed = &receipt.Alternatives[i]
+		}
+	}
+	if failed == nil {
+		t.Errorf("expected the failed attempt in alternatives, got %+v", receipt.Alternatives)
+	}
+}
+
+func TestRouter_NoFailoverOnFinalError(t *testing.T) {
+	srv := multiRailServer(t, "eip155:8453")
+	defer srv.Close()
+
+	declined := x402Provider(errors.New("insufficient funds"))
+	backup := x402Provider(nil)
+
+	r := New(Config{MaxPerRequestUSD: 1.0})
+	r.RegisterProvider(declined, WithPriority(1))
+	r.RegisterProvider(backup, WithPriority(2))
+
+	_, _, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Fatalf("expected the provider's error, got %v", err)
+	}
+	if backup.calls != 0 {
+		t.Error("a non-retryable failure must not fail over")
+	}
+}
+
+func TestRouter_CapabilityMatching(t *testing.T) {
+	srv := multiRailServer(t, "solana:devnet")
+	defer srv.Close()
+
+	evmOnly := x402Provider(nil)
+	evmOnly.caps = &Capabilities{Networks: []string{"eip155:"}}
+	anyChain := x402Provider(nil)
+
+	r := New(Config{MaxPerRequestUSD: 1.0})
+	r.RegisterProvider(evmOnly, WithPriority(1))
+	r.RegisterProvider(anyChain, WithPriority(2))
+
+	if _, _, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if evmOnly.calls != 0 || anyChain.calls != 1 {
+		t.Errorf("Solana option must skip the EVM-only provider, got calls %d/%d", evmOnly.calls, anyChain.calls)
+	}
+}
+
+func TestRouter_CircuitBreaker(t *testing.T) {
+	srv := multiRailServer(t, "eip155:8453")
+	defer srv.Close()
+
+	down := x402Provider(Retryable(errors.New("connection refused")))
+	backup := x402Provider(nil)
+
+	r := New(Config{MaxPerRequestUSD: 1.0, BreakerThreshold: 2, BreakerCooldown: time.Hour})
+	r.RegisterProvider(down, WithPriority(1))
+	r.RegisterProvider(backup, WithPriority(2))
+
+	for i := 0; i < 5; i++ {
+		if _, _, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil); err != nil {
+			t.Fatalf("fetch %d: %v", i+1, err)
+		}
+	}
+	if down.calls != 2 {
+		t.Errorf("breaker should open after 2 failures, got %d calls to the down provider", down.calls)
+	}
+	if backup.calls != 5 {
+		t.Errorf("expected every request to settle via backup, got %d", backup.calls)
+	}
+}
+
+func TestRouter_MultipleProvidersSameProtocol(t *testing.T) {
+	// Registering a second x402 provider must not replace the first
+	r := New(Config{})
+	r.RegisterProvider(x402Provider(nil))
+	r.RegisterProvider(x402Provider(nil))
+	if n := len(r.providers[ProtocolX402]); n != 2 {
+		t.Errorf("expected 2 x402 providers, got %d", n)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	if IsRetryable(errors.New("declined")) {
+		t.Error("plain errors are final")
+	}
+	if !IsRetryable(Retryable(errors.New("503"))) {
+		t.Error("Retryable errors must be retryable")
+	}
+	wrapped := &PaymentError{Protocol: ProtocolX402, Err: Retryable(errors.New("503"))}
+	if !IsRetryable(wrapped) {
+		t.Error("retryable marker must survive wrapping")
+	}
+	if IsRetryable(context.DeadlineExceeded) {
+		t.Error("timeouts leave the payment unknown and are final")
+	}
+}
@@ -0,0 +1,245 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/joelklabo/agentpay/internal/bolt12"
+)
+
+// LNURLPay is a Lightning address (LUD-16) or LNURL-pay link (LUD-06) offered
+// in place of a BOLT11 invoice. It is the Details of an L402
+// PaymentRequirement with an empty L402Invoice; the provider fetches the
+// invoice from the service when it pays.
+type LNURLPay struct {
+	Target     string // "user@domain", "lnurl1..." or an lnurlp:// URL
+	AmountMsat int64  // amount to pay; 0 leaves it to the service's payRequest
+}
+
+// BOLT12Offer is a reusable BOLT12 offer ("lno1...") presented in place of a
+// BOLT11 invoice. Like LNURLPay it is the Details of an L402
+// PaymentRequirement with an empty L402Invoice; the provider asks its
+// backend to fetch an invoice from the offer when it pays.
+type BOLT12Offer struct {
+	Offer      string
+	ID         string // offer id (hex merkle root), recorded on the receipt
+	IssuerID   string // hex node key; empty for offers reached only by blinded paths
+	Currency   string // ISO 4217 code when the offer is priced in fiat
+	OfferMsat  int64  // the offer's own msat amount; 0 if unset or in Currency
+	AmountMsat int64  // amount the challenge asks for; 0 defers to the offer
+}
+
+// Msat returns the amount to pay: the challenge's, else the offer's.
+func (o *BOLT12Offer) Msat() int64 {
+	if o.AmountMsat > 0 {
+		return o.AmountMsat
+	}
+	return o.OfferMsat
+}
+
+// IsLNURLPay reports whether s is a Lightning address or an LNURL-pay link.
+func IsLNURLPay(s string) bool {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "lightning:")
+	if strings.HasPrefix(s, "lnurl1") || strings.HasPrefix(s, "lnurlp://") {
+		return true
+	}
+	user, domain, ok := strings.Cut(s, "@")
+	return ok && user != "" && strings.Contains(domain, ".") &&
+		!strings.ContainsAny(s, " /?#:\"") && !strings.Contains(domain, "@")
+}
+
+// L402Detector recognizes L402 (and legacy LSAT) challenges in the
+// WWW-Authenticate header, and Lightning invoices, BOLT12 offers, addresses
+// or LNURL-pay links in a JSON body.
+type L402Detector struct{}
+
+// Protocol implements Detector.
+func (L402Detector) Protocol() Protocol { return ProtocolL402 }
+
+// Detect implements Detector. A body invoice that repeats the header's is
+// reported once, and a malformed header is only an error if the body has no
+// invoice either.
+func (L402Detector) Detect(resp *http.Response, body []byte) ([]*PaymentRequirement, error) {
+	var options []*PaymentRequirement
+	var headerErr error
+
+	authHeader := resp.Header.Get("WWW-Authenticate")
+	if strings.HasPrefix(authHeader, "LSAT ") || strings.HasPrefix(authHeader, "L402 ") {
+		req, err := parseL402Challenge(authHeader)
+		if err != nil {
+			headerErr = err
+		} else {
+			options = append(options, req)
+		}
+	}
+
+	if len(body) > 0 {
+		if req, err := parseL402Body(body); err == nil && !hasInvoice(options, req) {
+			options = append(options, req)
+		}
+	}
+	if len(options) == 0 {
+		return nil, headerErr
+	}
+	return options, nil
+}
+
+// hasInvoice reports whether one of options already pays what req pays.
+func hasInvoice(options []*PaymentRequirement, req *PaymentRequirement) bool {
+	for _, o := range options {
+		if o.L402Invoice == req.L402Invoice && invoiceSource(o) == invoiceSource(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// invoiceSource returns the offer or LNURL an invoice-less option fetches its
+// invoice from.
+func invoiceSource(req *PaymentRequirement) string {
+	switch d := req.Details.(type) {
+	case *LNURLPay:
+		return d.Target
+	case *BOLT12Offer:
+		return d.Offer
+	}
+	return ""
+}
+
+// offerRequirement builds an L402 option that pays a BOLT12 offer found
+// where an invoice was expected.
+func offerRequirement(raw, offer, amountMsat, hash string) (*PaymentRequirement, error) {
+	offer = trimLightningScheme(offer)
+	o, err := bolt12.DecodeOffer(offer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedL402, err)
+	}
+	msat, _ := strconv.ParseInt(amountMsat, 10, 64)
+	return &PaymentRequirement{
+		Protocol: ProtocolL402,
+		Raw:      raw,
+		L402Hash: hash,
+		Details: &BOLT12Offer{
+			Offer:      offer,
+			ID:         o.ID,
+			IssuerID:   o.IssuerID,
+			Currency:   o.Currency,
+			OfferMsat:  o.AmountMsat(),
+			AmountMsat: msat,
+		},
+	}, nil
+}
+
+// lnurlRequirement builds an L402 option that pays target, an address or
+// LNURL found where an invoice was expected.
+func lnurlRequirement(raw, target, amountMsat, hash string) *PaymentRequirement {
+	msat, _ := strconv.ParseInt(amountMsat, 10, 64)
+	return &PaymentRequirement{
+		Protocol: ProtocolL402,
+		Raw:      raw,
+		L402Hash: hash,
+		Details: &LNURLPay{
+			Target:     trimLightningScheme(target),
+			AmountMsat: msat,
+		},
+	}
+}
+
+func trimLightningScheme(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > 10 && strings.EqualFold(s[:10], "lightning:") {
+		return s[10:]
+	}
+	return s
+}
+
+// l402Credential returns what the payer presents with its preimage: the
+// macaroon (or "token" in later L402 drafts), falling back to the payment
+// hash servers without macaroons identify the invoice by.
+func l402Credential(macaroon, token, paymentHash string) string {
+	for _, c := range []string{macaroon, token} {
+		if c != "" {
+			return c
+		}
+	}
+	return paymentHash
+}
+
+func parseL402Challenge(header string) (*PaymentRequirement, error) {
+	// Format: LSAT macaroon="...", invoice="..."
+	// or: L402 token="...", invoice="..."
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) < 2 {
+		return nil, ErrMalformedL402
+	}
+
+	params := parseHeaderParams(parts[1])
+	invoice := params["invoice"]
+	credential := l402Credential(params["macaroon"], params["token"], params["payment_hash"])
+	for _, offer := range []string{invoice, params["offer"]} {
+		if bolt12.IsOffer(offer) {
+			return offerRequirement(header, offer, params["amount_msat"], credential)
+		}
+	}
+	for _, target := range []string{invoice, params["lnurl"], params["address"]} {
+		if IsLNURLPay(target) {
+			return lnurlRequirement(header, target, params["amount_msat"], credential), nil
+		}
+	}
+	if invoice == "" {
+		return nil, ErrMissingInvoice
+	}
+
+	return &PaymentRequirement{
+		Protocol:    ProtocolL402,
+		Raw:         header,
+		L402Invoice: invoice,
+		L402Hash:    credential,
+	}, nil
+}
+
+func parseL402Body(body []byte) (*PaymentRequirement, error) {
+	var data struct {
+		Invoice     string `json:"invoice"`
+		PaymentHash string `json:"payment_hash"`
+		Macaroon    string `json:"macaroon"`
+		PR          string `json:"pr"`
+		Offer       string `json:"offer"`
+		LNURL       string `json:"lnurl"`
+		Address     string `json:"lightning_address"`
+		AmountMsat  int64  `json:"amount_msat"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, ErrUnknownProtocol
+	}
+
+	credential := l402Credential(data.Macaroon, "", data.PaymentHash)
+	for _, offer := range []string{data.Invoice, data.Offer} {
+		if bolt12.IsOffer(offer) {
+			return offerRequirement(string(body), offer, strconv.FormatInt(data.AmountMsat, 10), credential)
+		}
+	}
+	for _, target := range []string{data.Invoice, data.LNURL, data.Address} {
+		if IsLNURLPay(target) {
+			return lnurlRequirement(string(body), target, strconv.FormatInt(data.AmountMsat, 10), credential), nil
+		}
+	}
+
+	invoice := data.Invoice
+	if invoice == "" {
+		invoice = data.PR
+	}
+	if invoice == "" {
+		return nil, ErrUnknownProtocol
+	}
+
+	return &PaymentRequirement{
+		Protocol:    ProtocolL402,
+		Raw:         string(body),
+		L402Invoice: invoice,
+		L402Hash:    credential,
+	}, nil
+}
@@ -0,0 +1,206 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joelklabo/agentpay/internal/bolt11"
+)
+
+// WellKnownPricingPath is where a paid API publishes its PricingDocument.
+const WellKnownPricingPath = "/.well-known/agentpay"
+
+// ErrUnpinnedPayee is returned for a challenge that pays someone other than
+// the payee the service's pricing document pins.
+var ErrUnpinnedPayee = errors.New("challenge pays a payee the pricing document does not list")
+
+// PricingDocument lists what each route of a paid API costs, so an agent can
+// learn the price before requesting a challenge.
+type PricingDocument struct {
+	Version int           `json:"version"`
+	Routes  []PricedRoute `json:"routes"`
+}
+
+// PricedRoute is one paid route of a PricingDocument.
+type PricedRoute struct {
+	// Method is the HTTP method the price applies to; empty means any.
+	Method string `json:"method,omitempty"`
+	// Path is an http.ServeMux path pattern. A trailing "/" covers the
+	// whole subtree.
+	Path        string       `json:"path"`
+	Description string       `json:"description,omitempty"`
+	Prices      []RoutePrice `json:"prices"`
+}
+
+// RoutePrice is the price of a route on one rail.
+type RoutePrice struct {
+	Protocol string `json:"protocol"` // "x402" or "L402"
+	Rail     string `json:"rail"`     // "lightning" or a CAIP-2 network
+	Amount   Amount `json:"amount"`
+	// Asset is the x402 token contract.
+	Asset string `json:"asset,omitempty"`
+	// PayTo pins the payee: the x402 address, or the public key of the
+	// Lightning node that signs L402 invoices. Agents refuse challenges
+	// that pay anyone else.
+	PayTo string `json:"payTo,omitempty"`
+	// MaxCalls is how many requests one payment buys, when more than one.
+	MaxCalls int `json:"maxCalls,omitempty"`
+}
+
+// Validate checks that d is a version 1 document whose prices are positive
+// amounts in each rail's asset.
+func (d *PricingDocument) Validate() error {
+	if d.Version != 1 {
+		return fmt.Errorf("unsupported pricing document version %d", d.Version)
+	}
+	for i, route := range d.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("route %d: path %q must start with /", i, route.Path)
+		}
+		if route.Method != "" && route.Method != strings.ToUpper(route.Method) {
+			return fmt.Errorf("route %s: method %q must be upper case", route.Path, route.Method)
+		}
+		if len(route.Prices) == 0 {
+			return fmt.Errorf("route %s: no prices", route.Path)
+		}
+		for _, p := range route.Prices {
+			if err := p.validate(); err != nil {
+				return fmt.Errorf("route %s: %w", route.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *RoutePrice) validate() error {
+	if p.Amount.Units <= 0 {
+		return fmt.Errorf("%s price must be positive", p.Protocol)
+	}
+	switch Protocol(p.Protocol) {
+	case ProtocolX402:
+		if !strings.Contains(p.Rail, ":") {
+			return fmt.Errorf("x402 rail %q is not a CAIP-2 network", p.Rail)
+		}
+		if p.Amount.Asset != USDC {
+			return fmt.Errorf("x402 price is in %s, want USDC", p.Amount.Asset.Symbol)
+		}
+	case ProtocolL402:
+		if p.Rail != "lightning" {
+			return fmt.Errorf("L402 rail %q, want lightning", p.Rail)
+		}
+		if p.Amount.Asset != Msat && p.Amount.Asset != Sat {
+			return fmt.Errorf("L402 price must be in sats or msat")
+		}
+	default:
+		return fmt.Errorf("unknown protocol %q", p.Protocol)
+	}
+	return nil
+}
+
+// Route returns the route that prices method and path, or nil if the
+// request is free. Like http.ServeMux, the longest matching path wins and a
+// route for the method beats one for any method.
+func (d *PricingDocument) Route(method, path string) *PricedRoute {
+	var best *PricedRoute
+	for i := range d.Routes {
+		route := &d.Routes[i]
+		if route.Method != "" && route.Method != method {
+			continue
+		}
+		if route.Path != path && !(strings.HasSuffix(route.Path, "/") && strings.HasPrefix(path, route.Path)) {
+			continue
+		}
+		if best == nil || len(route.Path) > len(best.Path) ||
+			len(route.Path) == len(best.Path) && best.Method == "" && route.Method != "" {
+			best = route
+		}
+	}
+	return best
+}
+
+// MinUSD returns the cheapest price of route in USD, valuing USDC at par and
+// bitcoin at btcPriceUSD.
+func (route *PricedRoute) MinUSD(btcPriceUSD int64) Amount {
+	var min Amount
+	for i, p := range route.Prices {
+		usd := NewAmount(p.Amount.Units, USD)
+		if p.Amount.Asset != USDC {
+			usd = BTCToUSD(p.Amount, btcPriceUSD)
+		}
+		if i == 0 || usd.Cmp(min) < 0 {
+			min = usd
+		}
+	}
+	return min
+}
+
+// checkPins fails if the route pins payees for req's protocol and req pays
+// none of them.
+func (route *PricedRoute) checkPins(req *PaymentRequirement) error {
+	var pins []string
+	for _, p := range route.Prices {
+		if Protocol(p.Protocol) == req.Protocol && p.PayTo != "" {
+			pins = append(pins, p.PayTo)
+		}
+	}
+	if len(pins) == 0 {
+		return nil
+	}
+
+	var payee string
+	switch {
+	case req.X402Accept != nil:
+		payee = req.X402Accept.PayTo
+	case req.Protocol == ProtocolL402 && req.L402Invoice != "":
+		if inv, err := bolt11.Decode(req.L402Invoice); err == nil {
+			payee = inv.Payee
+		}
+	}
+	for _, pin := range pins {
+		if payee != "" && strings.EqualFold(pin, payee) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnpinnedPayee, payee)
+}
+
+// FetchPricing fetches and validates the pricing document of the origin of
+// rawURL. Failures that may pass (no response, a server error or rate
+// limiting) are marked Retryable.
+func FetchPricing(ctx context.Context, client *http.Client, rawURL string) (*PricingDocument, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q", rawURL)
+	}
+	docURL := u.Scheme + "://" + u.Host + WellKnownPricingPath
+	req, err := http.NewRequestWithContext(ctx, "GET", docURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build pricing request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, Retryable(fmt.Errorf("fetch %s: %w", docURL, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, Retryable(fmt.Errorf("fetch %s: HTTP %d", docURL, resp.StatusCode))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: HTTP %d", docURL, resp.StatusCode)
+	}
+	var doc PricingDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", docURL, err)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pricing document at %s: %w", docURL, err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,199 @@
+package router
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func testPricingDocument(payTo string) PricingDocument {
+	return PricingDocument{Version: 1, Routes: []PricedRoute{
+		{Path: "/v1/", Prices: []RoutePrice{
+			{Protocol: "x402", Rail: "eip155:84532", Amount: NewAmount(10000, USDC), PayTo: payTo},
+		}},
+		{Method: "POST", Path: "/v1/gen", Prices: []RoutePrice{
+			{Protocol: "x402", Rail: "eip155:84532", Amount: NewAmount(5_000_000, USDC), PayTo: payTo},
+			{Protocol: "L402", Rail: "lightning", Amount: NewAmount(2_000_000, Msat)},
+		}},
+	}}
+}
+
+func TestPricingDocument_Validate(t *testing.T) {
+	doc := testPricingDocument("0xabc123")
+	if err := doc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	bad := map[string]func(d *PricingDocument){
+		"version":    func(d *PricingDocument) { d.Version = 2 },
+		"path":       func(d *PricingDocument) { d.Routes[0].Path = "v1/" },
+		"method":     func(d *PricingDocument) { d.Routes[1].Method = "post" },
+		"no prices":  func(d *PricingDocument) { d.Routes[0].Prices = nil },
+		"zero":       func(d *PricingDocument) { d.Routes[0].Prices[0].Amount.Units = 0 },
+		"x402 asset": func(d *PricingDocument) { d.Routes[0].Prices[0].Amount.Asset = Msat },
+		"x402 rail":  func(d *PricingDocument) { d.Routes[0].Prices[0].Rail = "base" },
+		"L402 asset": func(d *PricingDocument) { d.Routes[1].Prices[1].Amount.Asset = USDC },
+		"L402 rail":  func(d *PricingDocument) { d.Routes[1].Prices[1].Rail = "eip155:1" },
+		"protocol":   func(d *PricingDocument) { d.Routes[0].Prices[0].Protocol = "cashu" },
+	}
+	for name, mutate := range bad {
+		d := testPricingDocument("0xabc123")
+		mutate(&d)
+		if err := d.Validate(); err == nil {
+			t.Errorf("%s: validated", name)
+		}
+	}
+}
+
+func TestPricingDocument_Route(t *testing.T) {
+	doc := testPricingDocument("")
+	tests := []struct {
+		method, path, want string
+	}{
+		{"GET", "/v1/chat", "/v1/"},
+		{"GET", "/v1/gen", "/v1/"},
+		{"POST", "/v1/gen", "/v1/gen"},
+		{"GET", "/v1", ""},
+		{"GET", "/free", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if route := doc.Route(tt.method, tt.path); route != nil {
+			got = route.Path
+		}
+		if got != tt.want {
+			t.Errorf("Route(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	// $5 in USDC, or 2000 sats at $100K/BTC
+	if got := doc.Routes[1].MinUSD(100000); got != FromUSD(2) {
+		t.Errorf("MinUSD = %s, want $2", got)
+	}
+}
+
+// pricedServer publishes doc and answers every other request with an x402
+// challenge paying payTo, counting the requests to paid routes.
+func pricedServer(t *testing.T, doc PricingDocument, payTo string, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == WellKnownPricingPath {
+			json.NewEncoder(w).Encode(doc)
+			return
+		}
+		calls.Add(1)
+		if r.Header.Get("Payment-Signature") != "" {
+			w.Write([]byte(`{"result":"paid"}`))
+			return
+		}
+		data, _ := json.Marshal(X402Requirement{Accepts: []X402Accept{{
+			Scheme:            "exact",
+			Network:           "eip155:84532",
+			MaxAmountRequired: "10000",
+			PayTo:             payTo,
+			Asset:             "USDC",
+		}}})
+		w.Header().Set("Payment-Required", base64.StdEncoding.EncodeToString(data))
+		w.WriteHeader(402)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newDiscoveringRouter() *Router {
+	r := New(Config{MaxPerRequestUSD: 1, MaxSessionUSD: 10, Discover: true})
+	r.RegisterProvider(&mockProvider{
+		protocol:    ProtocolX402,
+		cost:        FromUSD(0.01),
+		description: "$0.01 USDC",
+		headerName:  "Payment-Signature",
+		headerValue: "sig",
+	})
+	return r
+}
+
+func TestRouter_DiscoverBudget(t *testing.T) {
+	var calls atomic.Int32
+	srv := pricedServer(t, testPricingDocument("0xabc123"), "0xabc123", &calls)
+	r := newDiscoveringRouter()
+
+	// $2 at the cheapest is over the $1 per-request limit
+	_, _, err := r.Fetch(context.Background(), "POST", srv.URL+"/v1/gen", nil, nil)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("over-budget route was requested %d times", calls.Load())
+	}
+
+	body, receipt, err := r.Fetch(context.Background(), "GET", srv.URL+"/v1/chat", nil, nil)
+	if err != nil || receipt == nil || string(body) != `{"result":"paid"}` {
+		t.Fatalf("body %s, receipt %+v, err %v", body, receipt, err)
+	}
+}
+
+func TestRouter_DiscoverPins(t *testing.T) {
+	var calls atomic.Int32
+	srv := pricedServer(t, testPricingDocument("0xABC123"), "0xdef456", &calls)
+	r := newDiscoveringRouter()
+
+	_, receipt, err := r.Fetch(context.Background(), "GET", srv.URL+"/v1/chat", nil, nil)
+	if !errors.Is(err, ErrUnpinnedPayee) || receipt != nil {
+		t.Fatalf("receipt %+v, err %v; want ErrUnpinnedPayee", receipt, err)
+	}
+}
+
+func TestRouter_DiscoverWithoutDocument(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	r := newDiscoveringRouter()
+	for range 2 {
+		if _, _, err := r.Fetch(context.Background(), "GET", srv.URL+"/x", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// One lookup for the document, then the two requests
+	if calls.Load() != 3 {
+		t.Errorf("%d requests, want 3", calls.Load())
+	}
+}
+
+func TestRouter_DiscoverRetriesUnavailableDocument(t *testing.T) {
+	var lookups atomic.Int32
+	doc := testPricingDocument("0xabc123")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == WellKnownPricingPath {
+			if lookups.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			json.NewEncoder(w).Encode(doc)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	r := newDiscoveringRouter()
+	// The document is unavailable, so the request goes ahead unchecked
+	if _, _, err := r.Fetch(context.Background(), "POST", srv.URL+"/v1/gen", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	// The next request fetches it again and finds the route over budget
+	_, _, err := r.Fetch(context.Background(), "POST", srv.URL+"/v1/gen", nil, nil)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if lookups.Load() != 2 {
+		t.Errorf("%d document lookups, want 2", lookups.Load())
+	}
+}
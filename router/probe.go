@@ -0,0 +1,201 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/joelklabo/agentpay/internal/bolt11"
+)
+
+// ProbeResult describes what a URL charges and what the router would do
+// about it, without paying.
+type ProbeResult struct {
+	URL        string
+	Method     string
+	StatusCode int
+	// Route is the pricing-document route for the request, when discovery
+	// is on and the service publishes one.
+	Route *PricedRoute
+	// RouteErr is why the route's published price alone would be refused.
+	RouteErr error
+	// Options lists every payment option in the 402 challenge, in the order
+	// the detectors found them, once per provider able to pay it.
+	Options []ProbeOption
+}
+
+// ProbeOption is one payment option of a probed challenge.
+type ProbeOption struct {
+	Requirement *PaymentRequirement
+	// Provider is the provider that quoted the option; empty when none can
+	// pay it.
+	Provider string
+	// Cost is the option's USD cost: the provider's quote, or without a
+	// provider its face value (USDC at par, bitcoin at Config.BTCPriceUSD).
+	// It is zero if Priced is false.
+	Cost   Amount
+	Priced bool
+	// Estimated reports that Cost is a face value rather than a quote.
+	Estimated   bool
+	Description string
+	// Err is why Fetch would not pay this option; nil if it would.
+	Err error
+	// Chosen marks the option Fetch would settle.
+	Chosen bool
+}
+
+// Probe sends a request and, if it is answered with 402, prices every
+// payment option and checks it against the budget, wallet balances, payee
+// pins and trust scoring exactly as Fetch would, but pays nothing. Options
+// that no registered provider can pay are valued at face value and still
+// checked against the budget and trust policy.
+func (r *Router) Probe(ctx context.Context, method, url string, body io.Reader, headers map[string]string) (*ProbeResult, error) {
+	result := &ProbeResult{URL: url, Method: method}
+	if route := r.pricedRoute(ctx, method, url); route != nil {
+		result.Route = route
+		result.RouteErr = r.checkBudget(ctx, route.MinUSD(r.config.BTCPriceUSD))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusPaymentRequired {
+		return result, nil
+	}
+
+	options, err := r.Detect(resp, respBody)
+	if err != nil {
+		return result, fmt.Errorf("detect protocol: %w", err)
+	}
+
+	quotes := r.quote(ctx, options)
+
+	// Options no provider can pay still get the policy checks, at face
+	// value, so the probe says whether a suitable wallet would be allowed
+	// to pay them.
+	noProvider := make(map[*quote]error)
+	for _, q := range quotes {
+		if q.provider != nil {
+			continue
+		}
+		noProvider[q], q.err = q.err, nil
+		if cost, ok := faceValue(q.req, r.config.BTCPriceUSD); ok {
+			q.cost, q.priced = cost, true
+			q.err = r.checkBudget(ctx, cost)
+		}
+	}
+	if result.Route != nil {
+		for _, q := range quotes {
+			if q.err == nil {
+				q.err = result.Route.checkPins(q.req)
+			}
+		}
+	}
+
+	// The option Fetch would settle is chosen before trust scoring: Fetch
+	// checks only that one, and gives up rather than falling back if it
+	// fails.
+	var payable []*quote
+	for _, q := range quotes {
+		if q.provider != nil {
+			payable = append(payable, q)
+		}
+	}
+	var chosen *quote
+	if ranked, err := r.choose(payable); err == nil && len(ranked) > 0 {
+		chosen = ranked[0]
+	}
+	if r.wot != nil {
+		for _, q := range quotes {
+			if q.err != nil || !q.priced {
+				continue
+			}
+			if recipient := extractRecipient(q.req); recipient != "" {
+				if err := r.wot.CheckTrust(recipient, q.cost); err != nil {
+					q.err = fmt.Errorf("trust check failed: %w", err)
+				}
+			}
+		}
+	}
+	if chosen != nil && chosen.err != nil {
+		chosen = nil
+	}
+	for q, err := range noProvider {
+		if q.err == nil {
+			q.err = err
+		}
+	}
+
+	for _, q := range quotes {
+		opt := ProbeOption{
+			Requirement: q.req,
+			Cost:        q.cost,
+			Priced:      q.priced,
+			Estimated:   q.provider == nil && q.priced,
+			Description: q.desc,
+			Err:         q.err,
+			Chosen:      q == chosen,
+		}
+		if q.provider != nil {
+			opt.Provider = providerName(q.provider)
+		}
+		result.Options = append(result.Options, opt)
+	}
+	return result, nil
+}
+
+// faceValue values an option in USD without a provider: USDC at par and
+// bitcoin at btcPriceUSD. It reports false for options it cannot value.
+func faceValue(req *PaymentRequirement, btcPriceUSD int64) (Amount, bool) {
+	var msat int64
+	switch d := req.Details.(type) {
+	case *LNURLPay:
+		msat = d.AmountMsat
+	case *BOLT12Offer:
+		if d.Currency == "" {
+			msat = d.Msat()
+		}
+	case *CashuRequest:
+		switch d.Unit {
+		case "sat":
+			msat = d.Amount * 1000
+		case "msat":
+			msat = d.Amount
+		case "usd":
+			return NewAmount(d.Amount*10000, USD), d.Amount > 0
+		}
+	}
+	switch {
+	case req.X402Accept != nil:
+		usdc, err := ParseUnits(req.X402Accept.MaxAmountRequired, USDC)
+		if err != nil {
+			return Amount{}, false
+		}
+		return NewAmount(usdc.Units, USD), true
+	case req.Protocol == ProtocolL402 && req.L402Invoice != "":
+		inv, err := bolt11.Decode(req.L402Invoice)
+		if err != nil || inv.AmountMsat <= 0 {
+			return Amount{}, false
+		}
+		msat = inv.AmountMsat
+	}
+	if msat <= 0 {
+		return Amount{}, false
+	}
+	return BTCToUSD(NewAmount(msat, Msat), btcPriceUSD), true
+}
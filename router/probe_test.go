@@ -0,0 +1,92 @@
+package router
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// specInvoice is the BOLT11 test vector for 20 mBTC ($2000 at $100K/BTC).
+const specInvoice = "lnbc20m1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqs9qrsgq7ea976txfraylvgzuxs8kgcw23ezlrszfnh8r6qtfpr6cxga50aj6txm9rxrydzd06dfeawfk6swupvz4erwnyutnjq7x39ymw6j38gp7ynn44"
+
+// probeServer challenges every request with two x402 accepts ($0.01 and $5)
+// and an L402 invoice for $2000.
+func probeServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		data, _ := json.Marshal(X402Requirement{Accepts: []X402Accept{
+			{Scheme: "exact", Network: "eip155:8453", MaxAmountRequired: "10000", PayTo: "0xabc"},
+			{Scheme: "exact", Network: "solana:mainnet", MaxAmountRequired: "5000000", PayTo: "So1"},
+		}})
+		w.Header().Set("Payment-Required", base64.StdEncoding.EncodeToString(data))
+		w.Header().Set("WWW-Authenticate", `L402 macaroon="m", invoice="`+specInvoice+`"`)
+		w.WriteHeader(402)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRouter_ProbeWithoutProviders(t *testing.T) {
+	var calls atomic.Int32
+	srv := probeServer(t, &calls)
+	r := New(Config{MaxPerRequestUSD: 1, MaxSessionUSD: 10})
+
+	result, err := r.Probe(context.Background(), "GET", srv.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StatusCode != 402 || len(result.Options) != 3 {
+		t.Fatalf("status %d, %d options", result.StatusCode, len(result.Options))
+	}
+	want := []struct {
+		usd float64
+		err error
+	}{
+		{0.01, ErrNoProvider},
+		{5, ErrBudgetExceeded},
+		{2000, ErrBudgetExceeded},
+	}
+	for i, opt := range result.Options {
+		if !opt.Priced || !opt.Estimated || opt.Cost != FromUSD(want[i].usd) {
+			t.Errorf("option %d: cost %s (priced %v, estimated %v), want $%v", i, opt.Cost, opt.Priced, opt.Estimated, want[i].usd)
+		}
+		if !errors.Is(opt.Err, want[i].err) {
+			t.Errorf("option %d: err %v, want %v", i, opt.Err, want[i].err)
+		}
+		if opt.Chosen {
+			t.Errorf("option %d chosen without a provider", i)
+		}
+	}
+}
+
+func TestRouter_ProbeChoosesWithoutPaying(t *testing.T) {
+	var calls atomic.Int32
+	srv := probeServer(t, &calls)
+	r := New(Config{MaxPerRequestUSD: 1, MaxSessionUSD: 10})
+	r.RegisterProvider(&mockProvider{
+		protocol:    ProtocolX402,
+		cost:        FromUSD(0.01),
+		description: "$0.01 USDC",
+		payErr:      errors.New("Pay called"),
+	})
+
+	result, err := r.Probe(context.Background(), "GET", srv.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Options[0].Chosen || result.Options[0].Err != nil || result.Options[0].Estimated {
+		t.Errorf("first option = %+v, want it chosen", result.Options[0])
+	}
+	if result.Options[0].Provider == "" || result.Options[2].Provider != "" {
+		t.Errorf("providers %q and %q", result.Options[0].Provider, result.Options[2].Provider)
+	}
+	if calls.Load() != 1 || len(r.Receipts()) != 0 {
+		t.Errorf("%d requests and %d receipts, want 1 and none", calls.Load(), len(r.Receipts()))
+	}
+}
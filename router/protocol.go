@@ -1,31 +1,25 @@
 package router
 
 import (
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"strings"
 )
 
-// Protocol represents a payment protocol type.
-type Protocol int
+// Protocol names a payment protocol. Built-in protocols are listed below;
+// detectors for other 402 schemes define their own.
+type Protocol string
 
 const (
-	ProtocolUnknown Protocol = iota
-	ProtocolX402            // USDC via EIP-3009 or Solana SPL
-	ProtocolL402            // Lightning Network invoice
+	ProtocolUnknown Protocol = ""
+	ProtocolX402    Protocol = "x402" // USDC via EIP-3009 or Solana SPL
+	ProtocolL402    Protocol = "L402" // Lightning Network invoice
 )
 
 func (p Protocol) String() string {
-	switch p {
-	case ProtocolX402:
-		return "x402"
-	case ProtocolL402:
-		return "L402"
-	default:
+	if p == ProtocolUnknown {
 		return "unknown"
 	}
+	return string(p)
 }
 
 // PaymentRequirement holds the parsed payment requirement from a 402 response.
@@ -35,127 +29,87 @@ type PaymentRequirement struct {
 
 	// x402 fields
 	X402Requirement *X402Requirement
+	// X402Accept is the single accepts entry this option settles. The x402
+	// detector sets it on every option; when nil a provider picks from X402Requirement.
+	X402Accept *X402Accept
 
 	// L402 fields
 	L402Invoice string
 	L402Hash    string
-}
 
-// X402Requirement represents a parsed x402 payment-required header.
-type X402Requirement struct {
-	Accepts []X402Accept `json:"accepts"`
+	// Details carries the parsed requirement for protocols without dedicated
+	// fields above. Its concrete type is defined by the protocol's detector and
+	// type-asserted by the matching provider. If it has a Rail() string method,
+	// that names the option's rail.
+	Details any
 }
 
-// X402Accept is a single payment option within x402.
-type X402Accept struct {
-	Scheme           string `json:"scheme"`
-	Network          string `json:"network"`
-	MaxAmountRequired string `json:"maxAmountRequired"`
-	Resource         string `json:"resource"`
-	Description      string `json:"description"`
-	MimeType         string `json:"mimeType"`
-	PayTo            string `json:"payTo"`
-	MaxTimeoutSeconds int    `json:"maxTimeoutSeconds"`
-	Asset            string `json:"asset"`
-	Extra            json.RawMessage `json:"extra,omitempty"`
-}
+// Detector recognizes one payment protocol in a 402 response.
+type Detector interface {
+	// Protocol returns the protocol this detector recognizes.
+	Protocol() Protocol
 
-// DetectProtocol examines an HTTP 402 response and determines the payment protocol.
-func DetectProtocol(resp *http.Response, body []byte) (*PaymentRequirement, error) {
-	// Check for x402: payment-required header (v2) or x-payment-required (v1)
-	paymentHeader := resp.Header.Get("Payment-Required")
-	if paymentHeader == "" {
-		paymentHeader = resp.Header.Get("X-Payment-Required")
-	}
-	if paymentHeader != "" {
-		return parseX402Header(paymentHeader)
-	}
-
-	// Check for L402: WWW-Authenticate header with LSAT or L402 challenge
-	authHeader := resp.Header.Get("WWW-Authenticate")
-	if strings.HasPrefix(authHeader, "LSAT ") || strings.HasPrefix(authHeader, "L402 ") {
-		return parseL402Challenge(authHeader)
-	}
-
-	// Try parsing body as JSON for L402-style payment info
-	if len(body) > 0 {
-		return parseL402Body(body)
-	}
-
-	return nil, ErrUnknownProtocol
+	// Detect returns every option for its protocol found in resp and body,
+	// or none if the response does not use it. An error means the response
+	// uses the protocol but the challenge could not be parsed.
+	Detect(resp *http.Response, body []byte) ([]*PaymentRequirement, error)
 }
 
-func parseX402Header(header string) (*PaymentRequirement, error) {
-	// x402 headers are base64-encoded JSON
-	decoded, err := base64.StdEncoding.DecodeString(header)
-	if err != nil {
-		// Try raw JSON
-		decoded = []byte(header)
-	}
-
-	var req X402Requirement
-	if err := json.Unmarshal(decoded, &req); err != nil {
-		// Try as array directly
-		var accepts []X402Accept
-		if err2 := json.Unmarshal(decoded, &accepts); err2 != nil {
-			return nil, fmt.Errorf("parse x402 header: %w", err)
-		}
-		req.Accepts = accepts
-	}
-
-	return &PaymentRequirement{
-		Protocol:        ProtocolX402,
-		Raw:             header,
-		X402Requirement: &req,
-	}, nil
+// DefaultDetectors returns the detectors for the built-in protocols.
+func DefaultDetectors() []Detector {
+	return []Detector{X402Detector{}, L402Detector{}}
 }
 
-func parseL402Challenge(header string) (*PaymentRequirement, error) {
-	// Format: LSAT macaroon="...", invoice="..."
-	// or: L402 token="...", invoice="..."
-	parts := strings.SplitN(header, " ", 2)
-	if len(parts) < 2 {
-		return nil, ErrMalformedL402
-	}
-
-	params := parseHeaderParams(parts[1])
-	invoice := params["invoice"]
-	if invoice == "" {
-		return nil, ErrMissingInvoice
-	}
-
-	return &PaymentRequirement{
-		Protocol:    ProtocolL402,
-		Raw:         header,
-		L402Invoice: invoice,
-		L402Hash:    params["payment_hash"],
-	}, nil
+// DetectProtocol examines an HTTP 402 response with the default detectors and
+// returns every payment option it offers.
+func DetectProtocol(resp *http.Response, body []byte) ([]*PaymentRequirement, error) {
+	return detect(DefaultDetectors(), resp, body)
 }
 
-func parseL402Body(body []byte) (*PaymentRequirement, error) {
-	var data struct {
-		Invoice     string `json:"invoice"`
-		PaymentHash string `json:"payment_hash"`
-		PR          string `json:"pr"`
-	}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, ErrUnknownProtocol
+// detect runs each detector in turn and collects their options. A server may
+// advertise several protocols side by side, so the router can compare them.
+// Options are returned in detector order, then in the order the server lists
+// them. Parse errors only surface when no detector found a usable option.
+func detect(detectors []Detector, resp *http.Response, body []byte) ([]*PaymentRequirement, error) {
+	var options []*PaymentRequirement
+	var firstErr error
+
+	for _, d := range detectors {
+		found, err := d.Detect(resp, body)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		options = append(options, found...)
 	}
 
-	invoice := data.Invoice
-	if invoice == "" {
-		invoice = data.PR
-	}
-	if invoice == "" {
+	if len(options) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
 		return nil, ErrUnknownProtocol
 	}
+	return options, nil
+}
 
-	return &PaymentRequirement{
-		Protocol:    ProtocolL402,
-		Raw:         string(body),
-		L402Invoice: invoice,
-		L402Hash:    data.PaymentHash,
-	}, nil
+// Rail names the settlement network an option pays on: "lightning" for L402,
+// or the CAIP-2 network ("eip155:8453", "solana:devnet") for x402.
+func (p *PaymentRequirement) Rail() string {
+	switch p.Protocol {
+	case ProtocolL402:
+		return "lightning"
+	case ProtocolX402:
+		if p.X402Accept != nil && p.X402Accept.Network != "" {
+			return p.X402Accept.Network
+		}
+	default:
+		if r, ok := p.Details.(interface{ Rail() string }); ok {
+			return r.Rail()
+		}
+	}
+	return p.Protocol.String()
 }
 
 // parseHeaderParams parses key="value" pairs from a header.
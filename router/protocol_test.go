@@ -29,10 +29,14 @@ func TestDetectProtocol_X402Header(t *testing.T) {
 	}
 	resp.Header.Set("Payment-Required", encoded)
 
-	payReq, err := DetectProtocol(resp, nil)
+	options, err := DetectProtocol(resp, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if len(options) != 1 {
+		t.Fatalf("expected 1 option, got %d", len(options))
+	}
+	payReq := options[0]
 	if payReq.Protocol != ProtocolX402 {
 		t.Errorf("expected x402, got %s", payReq.Protocol)
 	}
@@ -54,10 +58,14 @@ func TestDetectProtocol_L402Challenge(t *testing.T) {
 	}
 	resp.Header.Set("WWW-Authenticate", `L402 macaroon="abc123", invoice="lnbc100u1..."`)
 
-	payReq, err := DetectProtocol(resp, nil)
+	options, err := DetectProtocol(resp, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if len(options) != 1 {
+		t.Fatalf("expected 1 option, got %d", len(options))
+	}
+	payReq := options[0]
 	if payReq.Protocol != ProtocolL402 {
 		t.Errorf("expected L402, got %s", payReq.Protocol)
 	}
@@ -66,6 +74,49 @@ func TestDetectProtocol_L402Challenge(t *testing.T) {
 	}
 }
 
+func TestDetectProtocol_LightningAddress(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: 402,
+		Header:     http.Header{},
+	}
+	resp.Header.Set("WWW-Authenticate", `L402 macaroon="abc123", invoice="lightning:Agent@Example.com", amount_msat="21000"`)
+	body := []byte(`{"lnurl":"LNURL1DP68GURN8GHJ7UM9WFMXJCM99E3K7MF0V9CXJ0M385EKVCENXC6R2C35XVUKXEFCV5MKVV34X5EKZD3EV56NYD3HXQURZEPEXEJXXEPNXSCRVWFNV9NXZCN9XQ6XYEFHVGCXXCMYXYMNSERXFQ5FNS"}`)
+
+	options, err := DetectProtocol(resp, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(options) != 2 {
+		t.Fatalf("expected the address and the LNURL, got %d options", len(options))
+	}
+	addr, ok := options[0].Details.(*LNURLPay)
+	if !ok || addr.Target != "Agent@Example.com" || addr.AmountMsat != 21000 || options[0].L402Invoice != "" {
+		t.Errorf("header option = %+v", options[0])
+	}
+	if options[0].Rail() != "lightning" || extractRecipient(options[0]) != "Agent@Example.com" {
+		t.Errorf("rail %s, recipient %s", options[0].Rail(), extractRecipient(options[0]))
+	}
+	if lnurl, ok := options[1].Details.(*LNURLPay); !ok || lnurl.AmountMsat != 0 {
+		t.Errorf("body option = %+v", options[1])
+	}
+}
+
+func TestIsLNURLPay(t *testing.T) {
+	for s, want := range map[string]bool{
+		"agent@example.com":               true,
+		"LIGHTNING:agent@example.com":     true,
+		"lnurlp://example.com/pay/agent":  true,
+		"lnurl1dp68gurn8ghj7um9wfmxjcm99": true,
+		"lnbc100u1pjtest":                 false,
+		"agent@localhost":                 false,
+		"https://user@example.com/x":      false,
+	} {
+		if got := IsLNURLPay(s); got != want {
+			t.Errorf("IsLNURLPay(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
 func TestDetectProtocol_L402Body(t *testing.T) {
 	body := []byte(`{"invoice":"lnbc50u1pj...","payment_hash":"abc123"}`)
 	resp := &http.Response{
@@ -73,10 +124,14 @@ func TestDetectProtocol_L402Body(t *testing.T) {
 		Header:     http.Header{},
 	}
 
-	payReq, err := DetectProtocol(resp, body)
+	options, err := DetectProtocol(resp, body)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if len(options) != 1 {
+		t.Fatalf("expected 1 option, got %d", len(options))
+	}
+	payReq := options[0]
 	if payReq.Protocol != ProtocolL402 {
 		t.Errorf("expected L402, got %s", payReq.Protocol)
 	}
@@ -99,3 +154,73 @@ func TestDetectProtocol_Unknown(t *testing.T) {
 		t.Errorf("expected ErrUnknownProtocol, got %v", err)
 	}
 }
+
+func TestDetectProtocol_MultipleRails(t *testing.T) {
+	req := X402Requirement{
+		Accepts: []X402Accept{
+			{Scheme: "exact", Network: "eip155:8453", MaxAmountRequired: "10000", PayTo: "0xabc"},
+			{Scheme: "exact", Network: "solana:mainnet", MaxAmountRequired: "9000", PayTo: "SoLaNa"},
+		},
+	}
+	data, _ := json.Marshal(req)
+
+	resp := &http.Response{
+		StatusCode: 402,
+		Header:     http.Header{},
+	}
+	resp.Header.Set("Payment-Required", base64.StdEncoding.EncodeToString(data))
+	resp.Header.Set("WWW-Authenticate", `L402 macaroon="abc123", invoice="lnbc100n1pjtest"`)
+	body := []byte(`{"invoice":"lnbc100n1pjtest"}`) // same invoice as the header
+
+	options, err := DetectProtocol(resp, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(options) != 3 {
+		t.Fatalf("expected 3 options (2 x402 accepts + 1 L402), got %d", len(options))
+	}
+
+	wantRails := []string{"eip155:8453", "solana:mainnet", "lightning"}
+	for i, want := range wantRails {
+		if got := options[i].Rail(); got != want {
+			t.Errorf("option %d rail = %q, want %q", i, got, want)
+		}
+	}
+	if options[1].X402Accept.PayTo != "SoLaNa" {
+		t.Errorf("option 1 should carry its own accepts entry, got payTo %q", options[1].X402Accept.PayTo)
+	}
+	if options[0].Raw != options[1].Raw {
+		t.Error("x402 options should share the raw header")
+	}
+}
+
+func TestDetectProtocol_BOLT12Offer(t *testing.T) {
+	// Minimal offer from the BOLT12 test vectors
+	const offer = "lno1pgx9getnwss8vetrw3hhyuckyypwa3eyt44h6txtxquqh7lz5djge4afgfjn7k4rgrkuag0jsd5xvxg"
+	resp := &http.Response{
+		StatusCode: 402,
+		Header:     http.Header{},
+	}
+	resp.Header.Set("WWW-Authenticate", `L402 macaroon="abc123", offer="lightning:`+offer+`", amount_msat="21000"`)
+	body := []byte(`{"offer":"` + offer + `","amount_msat":21000}`)
+
+	options, err := DetectProtocol(resp, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(options) != 1 {
+		t.Fatalf("expected the body to repeat the header's offer, got %d options", len(options))
+	}
+	o, ok := options[0].Details.(*BOLT12Offer)
+	if !ok || o.Offer != offer || o.Msat() != 21000 || len(o.ID) != 64 || options[0].L402Invoice != "" {
+		t.Fatalf("option = %+v", options[0])
+	}
+	if options[0].Rail() != "lightning" || extractRecipient(options[0]) != o.IssuerID || o.IssuerID == "" {
+		t.Errorf("rail %s, recipient %s", options[0].Rail(), extractRecipient(options[0]))
+	}
+
+	resp.Header.Set("WWW-Authenticate", `L402 macaroon="abc123", invoice="lno1qqqq"`)
+	if _, err := DetectProtocol(resp, nil); err == nil {
+		t.Error("expected a malformed offer to be an error")
+	}
+}
@@ -3,9 +3,12 @@ package router
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"sort"
 	"sync"
 	"time"
 )
@@ -18,8 +21,9 @@ type PaymentProvider interface {
 	// Pay settles a payment requirement and returns the proof header name, value, and transaction ID.
 	Pay(ctx context.Context, req *PaymentRequirement) (headerName, headerValue string, err error)
 
-	// EstimateCost returns the estimated cost in USD for a payment requirement.
-	EstimateCost(req *PaymentRequirement) (usdCost float64, description string, err error)
+	// EstimateCost returns the estimated cost of a payment requirement as an
+	// exact USD Amount, plus a human-readable description in the native asset.
+	EstimateCost(req *PaymentRequirement) (usdCost Amount, description string, err error)
 }
 
 // Receipt records a completed payment.
@@ -27,13 +31,33 @@ type Receipt struct {
 	Timestamp   time.Time `json:"timestamp"`
 	URL         string    `json:"url"`
 	Protocol    string    `json:"protocol"`
+	Provider    string    `json:"provider,omitempty"`
 	Amount      string    `json:"amount"`
-	USDCost     float64   `json:"usd_cost"`
+	Cost        Amount    `json:"cost"`
+	USDCost     float64   `json:"usd_cost"` // display only; Cost is authoritative
 	Description string    `json:"description"`
 	TxID        string    `json:"tx_id,omitempty"`
+	// Reference is the server-issued payment reference, if the protocol has one.
+	Reference string `json:"reference,omitempty"`
+	// Preimage is the Lightning payment preimage (hex), proof of payment.
+	Preimage string `json:"preimage,omitempty"`
+	// OfferID is the BOLT12 offer the payment's invoice was fetched from.
+	OfferID string `json:"offer_id,omitempty"`
+	// Fee is the routing or network fee paid on top of the amount, if known.
+	Fee *Amount `json:"fee,omitempty"`
+	// Rail is the settlement network of the chosen option (see PaymentRequirement.Rail).
+	Rail string `json:"rail,omitempty"`
+	// Strategy is the routing strategy that picked this option.
+	Strategy Strategy `json:"strategy,omitempty"`
+	// Alternatives lists the other options the server offered.
+	Alternatives []Alternative `json:"alternatives,omitempty"`
+	// Direction is "incoming" for payments received by a paywall; empty for
+	// payments made.
+	Direction string `json:"direction,omitempty"`
 }
 
 // Config holds router configuration.
+// USD limits are converted to exact Amounts once, in New.
 type Config struct {
 	// MaxPerRequestUSD is the maximum USD amount allowed per single request.
 	MaxPerRequestUSD float64
@@ -43,32 +67,100 @@ type Config struct {
 	DryRun bool
 	// Verbose enables detailed logging.
 	Verbose bool
+	// Strategy picks among multiple payment options. Defaults to StrategyCheapest.
+	Strategy Strategy
+	// PreferredRails orders rails for StrategyPreferred, e.g. "lightning",
+	// "solana", "base", "evm" or a full CAIP-2 network like "eip155:8453".
+	PreferredRails []string
+	// LowBalanceUSD emits EventLowBalance when a wallet drops below it. Zero disables.
+	LowBalanceUSD float64
+	// BalanceTTL is how long provider balances are cached. Defaults to one minute.
+	BalanceTTL time.Duration
+	// BreakerThreshold is how many consecutive retryable failures open a
+	// provider's circuit. Defaults to 3.
+	BreakerThreshold int
+	// BreakerCooldown is how long an open circuit skips the provider. Defaults to 30s.
+	BreakerCooldown time.Duration
+	// Discover fetches each origin's pricing document (see
+	// WellKnownPricingPath) before its first request. Routes priced beyond
+	// the budget are refused without being requested, and challenges must
+	// pay a payee the document pins.
+	Discover bool
+	// BTCPriceUSD values Lightning prices in pricing documents. Defaults to
+	// 100000.
+	BTCPriceUSD int64
 }
 
 // Router handles cross-protocol payment routing.
 type Router struct {
 	config    Config
-	providers map[Protocol]PaymentProvider
+	providers map[Protocol][]*registration
+	detectors []Detector
 	client    *http.Client
 	wot       *WoTChecker
 
+	nextSeq       int
+	maxPerRequest Amount
+	maxSession    Amount
+	lowBalance    Amount
+	onEvent       func(Event)
+
 	mu           sync.Mutex
-	sessionSpend float64
+	sessionSpend Amount
 	receipts     []Receipt
+	balances     map[balanceKey]cachedBalance
+	lowWarned    map[balanceKey]bool
+	pricing      map[string]*PricingDocument // by origin; nil if it has none
 }
 
 // New creates a new payment router.
 func New(cfg Config) *Router {
+	if cfg.Strategy == "" {
+		cfg.Strategy = StrategyCheapest
+	}
+	if cfg.BTCPriceUSD <= 0 {
+		cfg.BTCPriceUSD = 100000
+	}
 	return &Router{
-		config:    cfg,
-		providers: make(map[Protocol]PaymentProvider),
-		client:    &http.Client{Timeout: 30 * time.Second},
+		config:        cfg,
+		providers:     make(map[Protocol][]*registration),
+		detectors:     DefaultDetectors(),
+		client:        &http.Client{Timeout: 30 * time.Second},
+		maxPerRequest: FromUSD(cfg.MaxPerRequestUSD),
+		maxSession:    FromUSD(cfg.MaxSessionUSD),
+		lowBalance:    FromUSD(cfg.LowBalanceUSD),
+		sessionSpend:  NewAmount(0, USD),
+		balances:      make(map[balanceKey]cachedBalance),
+		lowWarned:     make(map[balanceKey]bool),
+		pricing:       make(map[string]*PricingDocument),
 	}
 }
 
-// RegisterProvider adds a payment provider for a protocol.
-func (r *Router) RegisterProvider(p PaymentProvider) {
-	r.providers[p.Protocol()] = p
+// RegisterProvider adds a payment provider for its protocol. Several providers
+// may serve the same protocol; they are tried in priority order and the router
+// fails over to the next one when Pay returns a retryable error.
+func (r *Router) RegisterProvider(p PaymentProvider, opts ...ProviderOption) {
+	reg := &registration{provider: p, seq: r.nextSeq}
+	r.nextSeq++
+	for _, opt := range opts {
+		opt(reg)
+	}
+
+	regs := append(r.providers[p.Protocol()], reg)
+	sort.SliceStable(regs, func(i, j int) bool { return regs[i].priority < regs[j].priority })
+	r.providers[p.Protocol()] = regs
+}
+
+// RegisterDetector adds a detector for another 402 payment scheme. Detectors
+// run in registration order after the built-in x402 and L402 detectors.
+func (r *Router) RegisterDetector(d Detector) {
+	r.detectors = append(r.detectors, d)
+}
+
+// Detect returns every payment option in a 402 response using the router's
+// detectors.
+func (r *Router) Detect(resp *http.Response, body []byte) ([]*PaymentRequirement, error) {
+	return detect(r.detectors, resp, body)
 }
 
 // SetWoTChecker enables trust scoring before payments.
@@ -96,6 +188,12 @@ func (r *Router) Fetch(ctx context.Context, method, url string, body io.Reader,
 		return bytes.NewReader(bodyBytes)
 	}
 
+	// Refuse routes the service prices beyond the budget before asking
+	route, err := r.discover(ctx, method, url)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Build the initial request
 	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader())
 	if err != nil {
@@ -124,61 +222,18 @@ func (r *Router) Fetch(ctx context.Context, method, url string, body io.Reader,
 		return respBody, nil, nil
 	}
 
-	// Detect the payment protocol
-	payReq, err := DetectProtocol(resp, respBody)
+	// Detect every payment option the server offers
+	options, err := r.Detect(resp, respBody)
 	if err != nil {
 		return respBody, nil, fmt.Errorf("detect protocol: %w", err)
 	}
 
-	// Find a provider for this protocol
-	provider, ok := r.providers[payReq.Protocol]
-	if !ok {
-		return respBody, nil, &PaymentError{
-			Protocol: payReq.Protocol,
-			Err:      ErrNoProvider,
-		}
-	}
-
-	// Estimate cost and check budget
-	usdCost, description, err := provider.EstimateCost(payReq)
+	chosen, settlement, quotes, err := r.payBest(ctx, options, route)
 	if err != nil {
-		return respBody, nil, fmt.Errorf("estimate cost: %w", err)
-	}
-
-	if err := r.checkBudget(usdCost); err != nil {
 		return respBody, nil, err
 	}
-
-	// WoT trust check: verify the payment recipient before settling
-	if r.wot != nil {
-		recipientID := extractRecipient(payReq)
-		if recipientID != "" {
-			if err := r.wot.CheckTrust(recipientID, usdCost); err != nil {
-				return respBody, nil, fmt.Errorf("trust check failed: %w", err)
-			}
-		}
-	}
-
-	if r.config.DryRun {
-		receipt := &Receipt{
-			Timestamp:   time.Now(),
-			URL:         url,
-			Protocol:    payReq.Protocol.String(),
-			Amount:      description,
-			USDCost:     usdCost,
-			Description: "DRY RUN — would pay",
-		}
-		return respBody, receipt, nil
-	}
-
-	// Settle the payment
-	headerName, headerValue, err := provider.Pay(ctx, payReq)
-	if err != nil {
-		return respBody, nil, &PaymentError{
-			Protocol: payReq.Protocol,
-			Amount:   description,
-			Err:      err,
-		}
+	if settlement == nil {
+		return respBody, r.newReceipt(url, chosen, quotes, "DRY RUN — would pay"), nil
 	}
 
 	// Retry the request with payment proof (body replayed from buffer)
@@ -189,7 +244,7 @@ func (r *Router) Fetch(ctx context.Context, method, url string, body io.Reader,
 	for k, v := range headers {
 		retryReq.Header.Set(k, v)
 	}
-	retryReq.Header.Set(headerName, headerValue)
+	retryReq.Header.Set(settlement.HeaderName, settlement.HeaderValue)
 
 	retryResp, err := r.client.Do(retryReq)
 	if err != nil {
@@ -205,39 +260,347 @@ func (r *Router) Fetch(ctx context.Context, method, url string, body io.Reader,
 		return retryBody, nil, fmt.Errorf("retry HTTP %d: %s", retryResp.StatusCode, string(retryBody))
 	}
 
-	// Record the payment
-	receipt := &Receipt{
-		Timestamp:   time.Now(),
-		URL:         url,
-		Protocol:    payReq.Protocol.String(),
-		Amount:      description,
-		USDCost:     usdCost,
-		Description: fmt.Sprintf("Paid %s via %s", description, payReq.Protocol),
+	return retryBody, r.recordSettlement(url, chosen, quotes, settlement), nil
+}
+
+// Pay settles one of options outside an HTTP 402 exchange, such as an
+// invoice or address given directly, with the same budget, balance, trust
+// and failover handling as Fetch. resource names what was paid on the
+// receipt. The Settlement carries the proof of payment; for x402 it is a
+// signed authorization that still has to be submitted for settlement; see
+// PayWith. With DryRun nothing is paid and the Settlement is nil.
+func (r *Router) Pay(ctx context.Context, resource string, options []*PaymentRequirement) (*Receipt, *Settlement, error) {
+	return r.PayWith(ctx, resource, options, nil)
+}
+
+// SubmitFunc completes a payment the provider has settled, such as by
+// submitting an x402 authorization through a facilitator. It may fill in the
+// receipt, for instance with the transaction ID.
+type SubmitFunc func(ctx context.Context, receipt *Receipt, settlement *Settlement) error
+
+// PayWith is Pay for payments that need another step before they count.
+// submit runs after the provider settles and before the payment is recorded;
+// if it fails, nothing is recorded and the payment's budget is released.
+func (r *Router) PayWith(ctx context.Context, resource string, options []*PaymentRequirement, submit SubmitFunc) (*Receipt, *Settlement, error) {
+	chosen, settlement, quotes, err := r.payBest(ctx, options, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if settlement == nil {
+		return r.newReceipt(resource, chosen, quotes, "DRY RUN — would pay"), nil, nil
+	}
+	receipt := r.settledReceipt(resource, chosen, quotes, settlement)
+	if submit != nil {
+		if err := submit(ctx, receipt, settlement); err != nil {
+			r.release(chosen.cost)
+			return nil, nil, err
+		}
+	}
+	r.recordReceipt(receipt)
+	r.debitBalance(chosen.provider, chosen.req, chosen.cost)
+	return receipt, settlement, nil
+}
+
+// payBest prices every option, ranks them according to the routing strategy
+// and settles the best candidate, failing over to the next one when a
+// provider's backend is unavailable. It also returns every quote for the
+// receipt. With DryRun it settles nothing and returns a nil Settlement with
+// the quote it would have paid.
+func (r *Router) payBest(ctx context.Context, options []*PaymentRequirement, route *PricedRoute) (*quote, *Settlement, []*quote, error) {
+	quotes := r.quote(ctx, options)
+	if route != nil {
+		for _, q := range quotes {
+			if q.err == nil {
+				q.err = route.checkPins(q.req)
+			}
+		}
+	}
+	ranked, err := r.choose(quotes)
+	if err != nil {
+		return nil, nil, quotes, err
+	}
+
+	for _, q := range ranked {
+		// WoT trust check: verify the payment recipient before settling
+		if r.wot != nil {
+			recipientID := extractRecipient(q.req)
+			if recipientID != "" {
+				if err := r.wot.CheckTrust(recipientID, q.cost); err != nil {
+					return nil, nil, quotes, fmt.Errorf("trust check failed: %w", err)
+				}
+			}
+		}
+
+		if dryRun, _ := ctx.Value(dryRunKey{}).(bool); dryRun || r.config.DryRun {
+			return q, nil, quotes, nil
+		}
+
+		// Another payment may have settled since q was quoted
+		if err = r.reserve(q.cost); err != nil {
+			q.err = err
+			continue
+		}
+		var settlement *Settlement
+		settlement, err = settle(ctx, q.provider, q.req)
+		r.recordResult(q.reg, err)
+		if err == nil {
+			return q, settlement, quotes, nil
+		}
+		r.release(q.cost)
+		q.err = fmt.Errorf("pay via %s: %w", providerName(q.provider), err)
+		payErr := &PaymentError{
+			Protocol: q.req.Protocol,
+			Amount:   q.desc,
+			Err:      err,
+		}
+		if !IsRetryable(err) {
+			return nil, nil, quotes, payErr
+		}
+		err = payErr
+	}
+	return nil, nil, quotes, err
+}
+
+// recordSettlement records the payment of q and returns its receipt.
+func (r *Router) recordSettlement(url string, q *quote, quotes []*quote, settlement *Settlement) *Receipt {
+	receipt := r.settledReceipt(url, q, quotes, settlement)
+	r.recordReceipt(receipt)
+	r.debitBalance(q.provider, q.req, q.cost)
+	return receipt
+}
+
+// settledReceipt returns the receipt for paying q with settlement.
+func (r *Router) settledReceipt(url string, q *quote, quotes []*quote, settlement *Settlement) *Receipt {
+	receipt := r.newReceipt(url, q, quotes,
+		fmt.Sprintf("Paid %s via %s", q.desc, q.req.Protocol))
+	receipt.TxID = settlement.TxID
+	receipt.Reference = settlement.Reference
+	receipt.Preimage = settlement.Preimage
+	receipt.OfferID = settlement.OfferID
+	receipt.Fee = settlement.Fee
+	return receipt
+}
+
+// discover returns the route of the origin's pricing document that prices
+// method and rawURL, checking its cheapest price against the budget. It
+// returns nil when discovery is off, the origin publishes no document, or
+// the route is free.
+func (r *Router) discover(ctx context.Context, method, rawURL string) (*PricedRoute, error) {
+	route := r.pricedRoute(ctx, method, rawURL)
+	if route == nil {
+		return nil, nil
+	}
+	if err := r.checkBudget(ctx, route.MinUSD(r.config.BTCPriceUSD)); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, rawURL, err)
+	}
+	return route, nil
+}
+
+// pricedRoute looks up method and rawURL in the origin's pricing document,
+// fetching it on first use. It returns nil when discovery is off, the origin
+// publishes no document, or the route is free. A fetch that failed for a
+// passing reason is not cached, so the next request tries again.
+func (r *Router) pricedRoute(ctx context.Context, method, rawURL string) *PricedRoute {
+	if !r.config.Discover {
+		return nil
+	}
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return nil // the request itself will fail
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	r.mu.Lock()
+	doc, cached := r.pricing[origin]
+	r.mu.Unlock()
+	if !cached {
+		// A missing or invalid document just means nothing to check
+		var err error
+		doc, err = FetchPricing(ctx, r.client, origin)
+		if IsRetryable(err) {
+			return nil
+		}
+		r.mu.Lock()
+		r.pricing[origin] = doc
+		r.mu.Unlock()
+	}
+	if doc == nil {
+		return nil
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return doc.Route(method, path)
+}
+
+// newReceipt builds the receipt for settling q, listing the other quotes as
+// alternatives.
+func (r *Router) newReceipt(url string, q *quote, quotes []*quote, description string) *Receipt {
+	return &Receipt{
+		Timestamp:    time.Now(),
+		URL:          url,
+		Protocol:     q.req.Protocol.String(),
+		Provider:     providerName(q.provider),
+		Amount:       q.desc,
+		Cost:         q.cost,
+		USDCost:      q.cost.Float64(),
+		Description:  description,
+		Rail:         q.req.Rail(),
+		Strategy:     r.config.Strategy,
+		Alternatives: alternatives(quotes, q),
+	}
+}
+
+// quote prices every option with each provider able to settle it. Options
+// that cannot be paid are kept with their reason so the receipt can show what
+// was passed over.
+func (r *Router) quote(ctx context.Context, options []*PaymentRequirement) []*quote {
+	var quotes []*quote
+	for _, opt := range options {
+		var candidates []*registration
+		for _, reg := range r.providers[opt.Protocol] {
+			if cp, ok := reg.provider.(CapableProvider); ok && !cp.Capabilities().Supports(opt) {
+				continue
+			}
+			candidates = append(candidates, reg)
+		}
+		if len(candidates) == 0 {
+			quotes = append(quotes, &quote{
+				req: opt,
+				err: &PaymentError{Protocol: opt.Protocol, Err: ErrNoProvider},
+			})
+			continue
+		}
+
+		for _, reg := range candidates {
+			quotes = append(quotes, r.quoteWith(ctx, opt, reg))
+		}
+	}
+	return quotes
+}
+
+// quoteWith prices opt with a single provider and checks budget, balance and
+// circuit state.
+func (r *Router) quoteWith(ctx context.Context, opt *PaymentRequirement, reg *registration) *quote {
+	q := &quote{req: opt, reg: reg, provider: reg.provider}
+
+	cost, desc, err := reg.provider.EstimateCost(opt)
+	if err != nil {
+		q.err = fmt.Errorf("estimate cost: %w", err)
+		return q
+	}
+	// Budgets and comparisons are in USD, and mixing assets panics
+	if cost.Asset != USD {
+		q.err = fmt.Errorf("estimate cost: %s is not priced in USD", cost)
+		return q
+	}
+	q.cost, q.desc, q.priced = cost, desc, true
+
+	if err := r.checkBudget(ctx, cost); err != nil {
+		q.err = err
+		return q
+	}
+
+	if r.breakerOpen(reg) {
+		q.err = Retryable(fmt.Errorf("%s: %w", providerName(reg.provider), ErrCircuitOpen))
+		return q
+	}
+
+	// Skip wallets that can't cover the cost. A balance we can't read
+	// doesn't block the payment; the provider will fail if it's short.
+	if bp, ok := reg.provider.(BalanceProvider); ok {
+		if bal, err := r.balance(ctx, reg.provider, bp, opt); err == nil {
+			q.balance = &bal
+			if bal.Cmp(cost) < 0 {
+				q.err = fmt.Errorf("%w: %s available on %s, %s needed",
+					ErrInsufficientBalance, bal, opt.Rail(), cost)
+			}
+		}
+	}
+	return q
+}
+
+// choose returns the eligible quotes best-first. If none is eligible it
+// returns the most actionable reason: over budget, then insufficient balance,
+// then unavailable or unpriceable, then no provider.
+func (r *Router) choose(quotes []*quote) ([]*quote, error) {
+	var eligible []*quote
+	for _, q := range quotes {
+		if q.err == nil {
+			eligible = append(eligible, q)
+		}
+	}
+	if len(eligible) > 0 {
+		r.rank(eligible)
+		return eligible, nil
 	}
-	r.recordPayment(usdCost, receipt)
 
-	return retryBody, receipt, nil
+	var best error
+	bestRank := -1
+	for _, q := range quotes {
+		rank := 0
+		switch {
+		case errors.Is(q.err, ErrBudgetExceeded):
+			rank = 3
+		case errors.Is(q.err, ErrInsufficientBalance):
+			rank = 2
+		case q.provider != nil:
+			rank = 1
+		}
+		if rank > bestRank {
+			best, bestRank = q.err, rank
+		}
+	}
+	return nil, best
 }
 
-func (r *Router) checkBudget(usdCost float64) error {
+func (r *Router) checkBudget(ctx context.Context, usdCost Amount) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.config.MaxPerRequestUSD > 0 && usdCost > r.config.MaxPerRequestUSD {
-		return fmt.Errorf("%w: $%.4f exceeds per-request limit of $%.4f",
-			ErrBudgetExceeded, usdCost, r.config.MaxPerRequestUSD)
+	limit := r.maxPerRequest
+	capped := !limit.IsZero()
+	if max, ok := ctx.Value(maxCostKey{}).(Amount); ok && (!capped || max.Cmp(limit) < 0) {
+		limit, capped = max, true
+	}
+	if capped && usdCost.Cmp(limit) > 0 {
+		return fmt.Errorf("%w: %s exceeds per-request limit of %s",
+			ErrBudgetExceeded, usdCost, limit)
 	}
-	if r.config.MaxSessionUSD > 0 && r.sessionSpend+usdCost > r.config.MaxSessionUSD {
-		return fmt.Errorf("%w: $%.4f would bring session total to $%.4f (limit $%.4f)",
-			ErrBudgetExceeded, usdCost, r.sessionSpend+usdCost, r.config.MaxSessionUSD)
+	total := r.sessionSpend.Add(usdCost)
+	if !r.maxSession.IsZero() && total.Cmp(r.maxSession) > 0 {
+		return fmt.Errorf("%w: %s would bring session total to %s (limit %s)",
+			ErrBudgetExceeded, usdCost, total, r.maxSession)
+	}
+	return nil
+}
+
+// reserve counts usdCost against the session budget before it is settled, so
+// payments settling concurrently can't together exceed the limit. A payment
+// that fails gives its reservation back with release.
+func (r *Router) reserve(usdCost Amount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := r.sessionSpend.Add(usdCost)
+	if !r.maxSession.IsZero() && total.Cmp(r.maxSession) > 0 {
+		return fmt.Errorf("%w: %s would bring session total to %s (limit %s)",
+			ErrBudgetExceeded, usdCost, total, r.maxSession)
 	}
+	r.sessionSpend = total
 	return nil
 }
 
-func (r *Router) recordPayment(usdCost float64, receipt *Receipt) {
+func (r *Router) release(usdCost Amount) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessionSpend = r.sessionSpend.Sub(usdCost)
+}
+
+func (r *Router) recordReceipt(receipt *Receipt) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.sessionSpend += usdCost
 	r.receipts = append(r.receipts, *receipt)
 }
 
@@ -250,8 +613,56 @@ func (r *Router) Receipts() []Receipt {
 	return out
 }
 
-// SessionSpend returns the total USD spent this session.
-func (r *Router) SessionSpend() float64 {
+// Budget is a snapshot of the router's spending limits and what the session
+// has spent against them, including payments still settling. A zero limit is
+// unlimited.
+type Budget struct {
+	MaxPerRequest Amount `json:"max_per_request"`
+	MaxSession    Amount `json:"max_session"`
+	Spent         Amount `json:"spent"`
+	// Remaining is what the session may still spend; nil when MaxSession
+	// is unlimited.
+	Remaining *Amount `json:"remaining,omitempty"`
+	Payments  int     `json:"payments"`
+}
+
+// Budget returns the router's limits and session spend.
+func (r *Router) Budget() Budget {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := Budget{
+		MaxPerRequest: r.maxPerRequest,
+		MaxSession:    r.maxSession,
+		Spent:         r.sessionSpend,
+		Payments:      len(r.receipts),
+	}
+	if !r.maxSession.IsZero() {
+		remaining := r.maxSession.Sub(r.sessionSpend)
+		b.Remaining = &remaining
+	}
+	return b
+}
+
+type maxCostKey struct{}
+
+// WithMaxCost returns a context that lowers the per-request limit to max for
+// the Fetch, Pay or Probe it is passed to. It never raises the router's own
+// limit, and a zero max allows only free requests.
+func WithMaxCost(ctx context.Context, max Amount) context.Context {
+	return context.WithValue(ctx, maxCostKey{}, max)
+}
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context under which Fetch and Pay report what they
+// would pay without settling, as if the router were configured with DryRun.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// SessionSpend returns the exact total USD spent this session, including
+// payments still settling.
+func (r *Router) SessionSpend() Amount {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	return r.sessionSpend
@@ -259,8 +670,20 @@ func (r *Router) SessionSpend() float64 {
 
 // extractRecipient returns the payment recipient identifier from a payment requirement.
 func extractRecipient(req *PaymentRequirement) string {
+	if req.X402Accept != nil {
+		return req.X402Accept.PayTo
+	}
 	if req.X402Requirement != nil && len(req.X402Requirement.Accepts) > 0 {
 		return req.X402Requirement.Accepts[0].PayTo
 	}
+	if lnurl, ok := req.Details.(*LNURLPay); ok {
+		return lnurl.Target
+	}
+	if offer, ok := req.Details.(*BOLT12Offer); ok {
+		if offer.IssuerID != "" {
+			return offer.IssuerID
+		}
+		return offer.Offer
+	}
 	return req.L402Hash
 }
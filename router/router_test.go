@@ -4,18 +4,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // mockProvider is a test payment provider.
 type mockProvider struct {
 	protocol    Protocol
-	cost        float64
+	cost        Amount
 	description string
 	headerName  string
 	headerValue string
@@ -24,7 +27,7 @@ type mockProvider struct {
 
 func (m *mockProvider) Protocol() Protocol { return m.protocol }
 
-func (m *mockProvider) EstimateCost(req *PaymentRequirement) (float64, string, error) {
+func (m *mockProvider) EstimateCost(req *PaymentRequirement) (Amount, string, error) {
 	return m.cost, m.description, nil
 }
 
@@ -89,7 +92,7 @@ func TestRouter_FetchX402(t *testing.T) {
 	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0})
 	r.RegisterProvider(&mockProvider{
 		protocol:    ProtocolX402,
-		cost:        0.01,
+		cost:        FromUSD(0.01),
 		description: "$0.01 USDC",
 		headerName:  "Payment-Signature",
 		headerValue: "sig_test_123",
@@ -132,7 +135,7 @@ func TestRouter_FetchL402(t *testing.T) {
 	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0})
 	r.RegisterProvider(&mockProvider{
 		protocol:    ProtocolL402,
-		cost:        0.001,
+		cost:        FromUSD(0.001),
 		description: "10000 sats",
 		headerName:  "Authorization",
 		headerValue: "L402 hash123:preimage123",
@@ -175,7 +178,7 @@ func TestRouter_BudgetExceeded(t *testing.T) {
 	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 5.0})
 	r.RegisterProvider(&mockProvider{
 		protocol:    ProtocolX402,
-		cost:        10.0,
+		cost:        FromUSD(10.0),
 		description: "$10.00 USDC",
 	})
 
@@ -188,6 +191,24 @@ func TestRouter_BudgetExceeded(t *testing.T) {
 	}
 }
 
+func TestRouter_NonUSDCost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `L402 macaroon="mac", invoice="lnbc10n1ptest"`)
+		w.WriteHeader(402)
+	}))
+	defer srv.Close()
+
+	// A provider that prices in sats instead of USD fails its quote rather
+	// than panicking in the budget check
+	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 5.0})
+	r.RegisterProvider(&mockProvider{protocol: ProtocolL402, cost: NewAmount(1000, Sat)})
+
+	_, _, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "not priced in USD") {
+		t.Errorf("err = %v, want a pricing error", err)
+	}
+}
+
 func TestRouter_DryRun(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		req := X402Requirement{
@@ -206,7 +227,7 @@ func TestRouter_DryRun(t *testing.T) {
 	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0, DryRun: true})
 	r.RegisterProvider(&mockProvider{
 		protocol:    ProtocolX402,
-		cost:        0.01,
+		cost:        FromUSD(0.01),
 		description: "$0.01 USDC",
 	})
 
@@ -220,7 +241,7 @@ func TestRouter_DryRun(t *testing.T) {
 	if !strings.Contains(receipt.Description, "DRY RUN") {
 		t.Errorf("expected dry run receipt, got: %s", receipt.Description)
 	}
-	if r.SessionSpend() != 0 {
+	if !r.SessionSpend().IsZero() {
 		t.Error("dry run should not affect session spend")
 	}
 }
@@ -267,7 +288,7 @@ func TestRouter_SessionSpendTracking(t *testing.T) {
 	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 0.05})
 	r.RegisterProvider(&mockProvider{
 		protocol:    ProtocolX402,
-		cost:        0.01,
+		cost:        FromUSD(0.01),
 		description: "$0.01",
 		headerName:  "Payment-Signature",
 		headerValue: "sig_123",
@@ -281,8 +302,8 @@ func TestRouter_SessionSpendTracking(t *testing.T) {
 		}
 	}
 
-	if r.SessionSpend() != 0.05 {
-		t.Errorf("expected session spend $0.05, got $%.4f", r.SessionSpend())
+	if r.SessionSpend() != FromUSD(0.05) {
+		t.Errorf("expected session spend $0.05, got %s", r.SessionSpend())
 	}
 
 	// 6th request should fail (would exceed $0.05 budget)
@@ -323,7 +344,7 @@ func TestRouter_FetchWithBody(t *testing.T) {
 	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0})
 	r.RegisterProvider(&mockProvider{
 		protocol:    ProtocolX402,
-		cost:        0.01,
+		cost:        FromUSD(0.01),
 		description: "$0.01",
 		headerName:  "Payment-Signature",
 		headerValue: "sig_test",
@@ -384,7 +405,7 @@ func TestRouter_WoTTrustBlock(t *testing.T) {
 	r := New(Config{MaxPerRequestUSD: 100.0, MaxSessionUSD: 1000.0})
 	r.RegisterProvider(&mockProvider{
 		protocol:    ProtocolX402,
-		cost:        1.0,
+		cost:        FromUSD(1.0),
 		description: "$1.00 USDC",
 		headerName:  "Payment-Signature",
 		headerValue: "sig_test",
@@ -438,7 +459,7 @@ func TestRouter_WoTTrustAllow(t *testing.T) {
 	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0})
 	r.RegisterProvider(&mockProvider{
 		protocol:    ProtocolX402,
-		cost:        0.01,
+		cost:        FromUSD(0.01),
 		description: "$0.01 USDC",
 		headerName:  "Payment-Signature",
 		headerValue: "sig_trusted",
@@ -459,3 +480,152 @@ func TestRouter_WoTTrustAllow(t *testing.T) {
 		t.Errorf("expected 2 calls (initial + retry), got %d", callCount)
 	}
 }
+
+func TestRouter_Pay(t *testing.T) {
+	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0})
+	r.RegisterProvider(&mockProvider{
+		protocol:    ProtocolX402,
+		cost:        FromUSD(0.25),
+		description: "$0.25 USDC",
+		headerName:  "Payment",
+		headerValue: "signed",
+	})
+	options := []*PaymentRequirement{{
+		Protocol:   ProtocolX402,
+		X402Accept: &X402Accept{Network: "eip155:8453", MaxAmountRequired: "250000", PayTo: "0xabc123"},
+	}}
+
+	receipt, settlement, err := r.Pay(context.Background(), "0xabc123", options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if settlement == nil || settlement.HeaderValue != "signed" {
+		t.Errorf("settlement = %+v", settlement)
+	}
+	if receipt.URL != "0xabc123" || receipt.Rail != "eip155:8453" {
+		t.Errorf("receipt = %+v", receipt)
+	}
+	if r.SessionSpend() != FromUSD(0.25) {
+		t.Errorf("session spend = %s", r.SessionSpend())
+	}
+
+	// The same budget applies as for Fetch
+	r = New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0})
+	r.RegisterProvider(&mockProvider{protocol: ProtocolX402, cost: FromUSD(5), headerValue: "signed"})
+	if _, _, err := r.Pay(context.Background(), "0xabc123", options); !strings.Contains(fmt.Sprint(err), "budget") {
+		t.Errorf("expected budget error, got: %v", err)
+	}
+}
+
+func TestRouter_WithMaxCostAndBudget(t *testing.T) {
+	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0})
+	r.RegisterProvider(&mockProvider{protocol: ProtocolX402, cost: FromUSD(0.25), headerValue: "signed"})
+	options := []*PaymentRequirement{{
+		Protocol:   ProtocolX402,
+		X402Accept: &X402Accept{Network: "eip155:8453", MaxAmountRequired: "250000", PayTo: "0xabc123"},
+	}}
+
+	ctx := WithMaxCost(context.Background(), FromUSD(0.10))
+	if _, _, err := r.Pay(ctx, "0xabc123", options); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected budget error under a $0.10 cap, got: %v", err)
+	}
+	// A cap above the router's limit doesn't raise it
+	if err := r.checkBudget(WithMaxCost(context.Background(), FromUSD(5)), FromUSD(2)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("cap raised the per-request limit: %v", err)
+	}
+	// A zero cap pays nothing, even on a router without a per-request limit
+	zero := WithMaxCost(context.Background(), Amount{Asset: USD})
+	if err := New(Config{}).checkBudget(zero, FromUSD(0.000001)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("zero cap allowed a payment: %v", err)
+	}
+	if err := r.checkBudget(zero, Amount{Asset: USD}); err != nil {
+		t.Errorf("zero cap refused a free request: %v", err)
+	}
+
+	if _, _, err := r.Pay(context.Background(), "0xabc123", options); err != nil {
+		t.Fatal(err)
+	}
+	b := r.Budget()
+	if b.Spent != FromUSD(0.25) || b.Payments != 1 || b.Remaining == nil || *b.Remaining != FromUSD(9.75) {
+		t.Errorf("budget = %+v", b)
+	}
+	if New(Config{}).Budget().Remaining != nil {
+		t.Error("unlimited session has a remaining budget")
+	}
+}
+
+// slowProvider is a mockProvider whose payments take a while to settle.
+type slowProvider struct {
+	mockProvider
+}
+
+func (s *slowProvider) Pay(ctx context.Context, req *PaymentRequirement) (string, string, error) {
+	time.Sleep(50 * time.Millisecond)
+	return s.mockProvider.Pay(ctx, req)
+}
+
+func TestRouter_ConcurrentPaymentsShareBudget(t *testing.T) {
+	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 1.0})
+	r.RegisterProvider(&slowProvider{mockProvider{protocol: ProtocolX402, cost: FromUSD(0.40), headerValue: "signed"}})
+	options := []*PaymentRequirement{{
+		Protocol:   ProtocolX402,
+		X402Accept: &X402Accept{Network: "eip155:8453", MaxAmountRequired: "400000", PayTo: "0xabc123"},
+	}}
+
+	// Every payment is quoted before the first one settles
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := r.Pay(context.Background(), "0xabc123", options)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	paid := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			paid++
+		case !errors.Is(err, ErrBudgetExceeded):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if paid != 2 || r.SessionSpend() != FromUSD(0.80) {
+		t.Errorf("%d payments spent %s, want 2 spending 0.80 USD", paid, r.SessionSpend())
+	}
+
+	// A failed payment gives its reservation back
+	r = New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 1.0})
+	r.RegisterProvider(&mockProvider{protocol: ProtocolX402, cost: FromUSD(0.40), payErr: errors.New("declined")})
+	if _, _, err := r.Pay(context.Background(), "0xabc123", options); err == nil {
+		t.Fatal("expected the payment to fail")
+	}
+	if !r.SessionSpend().IsZero() {
+		t.Errorf("failed payment left %s reserved", r.SessionSpend())
+	}
+}
+
+func TestRouter_WithDryRun(t *testing.T) {
+	r := New(Config{MaxPerRequestUSD: 1.0, MaxSessionUSD: 10.0})
+	r.RegisterProvider(&mockProvider{protocol: ProtocolX402, cost: FromUSD(0.25), headerValue: "signed"})
+	options := []*PaymentRequirement{{
+		Protocol:   ProtocolX402,
+		X402Accept: &X402Accept{Network: "eip155:8453", MaxAmountRequired: "250000", PayTo: "0xabc123"},
+	}}
+
+	receipt, settlement, err := r.Pay(WithDryRun(context.Background()), "0xabc123", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settlement != nil || !strings.Contains(receipt.Description, "DRY RUN") {
+		t.Errorf("dry run paid: %+v", receipt)
+	}
+	if !r.SessionSpend().IsZero() {
+		t.Error("dry run should not affect session spend")
+	}
+}
@@ -0,0 +1,39 @@
+package router
+
+import "context"
+
+// Settlement is the outcome of a payment: the proof to present when the
+// request is retried, plus details worth keeping on the receipt.
+type Settlement struct {
+	HeaderName  string
+	HeaderValue string
+	// TxID identifies the payment on its rail, e.g. a transaction signature.
+	TxID string
+	// Reference is the key the server issued to find the payment (Solana Pay).
+	Reference string
+	// Preimage proves a Lightning payment (hex).
+	Preimage string
+	// OfferID is the BOLT12 offer the invoice was fetched from (hex).
+	OfferID string
+	// Fee is what the network charged on top of the amount, if known.
+	Fee *Amount
+}
+
+// Settler is implemented by providers that report more about a payment than
+// the proof header. The router calls Settle instead of Pay when it is
+// available.
+type Settler interface {
+	Settle(ctx context.Context, req *PaymentRequirement) (*Settlement, error)
+}
+
+// settle pays req with p, preferring Settle when p implements it.
+func settle(ctx context.Context, p PaymentProvider, req *PaymentRequirement) (*Settlement, error) {
+	if s, ok := p.(Settler); ok {
+		return s.Settle(ctx, req)
+	}
+	name, value, err := p.Pay(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &Settlement{HeaderName: name, HeaderValue: value}, nil
+}
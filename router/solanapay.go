@@ -0,0 +1,168 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joelklabo/agentpay/internal/base58"
+)
+
+// ProtocolSolanaPay is a Solana Pay transfer request: the server names a
+// recipient, amount and token in a "solana:" URI and the client retries with
+// the transaction signature once the transfer lands.
+const ProtocolSolanaPay Protocol = "solana-pay"
+
+// SolanaPayRequest is a parsed Solana Pay transfer request. It is the
+// Details of every ProtocolSolanaPay PaymentRequirement.
+type SolanaPayRequest struct {
+	Recipient  string   // base58 wallet address
+	Amount     string   // decimal amount in the token's units, e.g. "0.01"
+	SPLToken   string   // token mint; empty for native SOL
+	References []string // base58 keys the server uses to find the transaction
+	Label      string
+	Message    string
+	Memo       string
+}
+
+// Rail implements the rail naming used by PaymentRequirement.Rail.
+func (*SolanaPayRequest) Rail() string { return "solana" }
+
+// Reference returns the first reference key, or "".
+func (s *SolanaPayRequest) Reference() string {
+	if len(s.References) == 0 {
+		return ""
+	}
+	return s.References[0]
+}
+
+// String formats the request as a "solana:" URI.
+func (s *SolanaPayRequest) String() string {
+	q := url.Values{}
+	if s.Amount != "" {
+		q.Set("amount", s.Amount)
+	}
+	if s.SPLToken != "" {
+		q.Set("spl-token", s.SPLToken)
+	}
+	for _, ref := range s.References {
+		q.Add("reference", ref)
+	}
+	for k, v := range map[string]string{"label": s.Label, "message": s.Message, "memo": s.Memo} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	uri := "solana:" + s.Recipient
+	if len(q) > 0 {
+		uri += "?" + q.Encode()
+	}
+	return uri
+}
+
+// ParseSolanaPayURL parses a Solana Pay transfer request URI. Transaction
+// requests ("solana:https://...") need an interactive wallet round trip and
+// are rejected.
+func ParseSolanaPayURL(s string) (*SolanaPayRequest, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "solana:") {
+		return nil, fmt.Errorf("solana pay: not a solana: URI")
+	}
+	rest := strings.TrimPrefix(s, "solana:")
+	if strings.HasPrefix(rest, "http") {
+		return nil, fmt.Errorf("solana pay: transaction requests are not supported")
+	}
+
+	recipient, rawQuery, _ := strings.Cut(rest, "?")
+	if !isSolanaKey(recipient) {
+		return nil, fmt.Errorf("solana pay: invalid recipient %q", recipient)
+	}
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("solana pay: %w", err)
+	}
+
+	req := &SolanaPayRequest{
+		Recipient:  recipient,
+		Amount:     q.Get("amount"),
+		SPLToken:   q.Get("spl-token"),
+		References: q["reference"],
+		Label:      q.Get("label"),
+		Message:    q.Get("message"),
+		Memo:       q.Get("memo"),
+	}
+	// A 402 must say how much to pay; open amounts are for donations
+	if req.Amount == "" {
+		return nil, fmt.Errorf("solana pay: amount is required")
+	}
+	if _, err := ParseDecimal(req.Amount, Asset{Decimals: 9}); err != nil {
+		return nil, fmt.Errorf("solana pay: invalid amount %q", req.Amount)
+	}
+	if req.SPLToken != "" && !isSolanaKey(req.SPLToken) {
+		return nil, fmt.Errorf("solana pay: invalid spl-token %q", req.SPLToken)
+	}
+	for _, ref := range req.References {
+		if !isSolanaKey(ref) {
+			return nil, fmt.Errorf("solana pay: invalid reference %q", ref)
+		}
+	}
+	return req, nil
+}
+
+// isSolanaKey reports whether s is a base58 32-byte public key.
+func isSolanaKey(s string) bool {
+	b, err := base58.Decode(s)
+	return err == nil && len(b) == 32
+}
+
+// SolanaPayDetector finds Solana Pay transfer requests in the Solana-Pay (or
+// X-Solana-Pay) header, or in the body: either the URI itself or a JSON
+// object carrying it in one of its top-level string fields.
+type SolanaPayDetector struct{}
+
+// Protocol implements Detector.
+func (SolanaPayDetector) Protocol() Protocol { return ProtocolSolanaPay }
+
+// Detect implements Detector.
+func (SolanaPayDetector) Detect(resp *http.Response, body []byte) ([]*PaymentRequirement, error) {
+	uri := resp.Header.Get("Solana-Pay")
+	if uri == "" {
+		uri = resp.Header.Get("X-Solana-Pay")
+	}
+	if uri == "" {
+		uri = solanaPayURIFromBody(body)
+	}
+	if uri == "" {
+		return nil, nil
+	}
+
+	req, err := ParseSolanaPayURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	return []*PaymentRequirement{{
+		Protocol: ProtocolSolanaPay,
+		Raw:      uri,
+		Details:  req,
+	}}, nil
+}
+
+func solanaPayURIFromBody(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if strings.HasPrefix(text, "solana:") {
+		return text
+	}
+
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return ""
+	}
+	for _, key := range []string{"solana_pay", "solanaPay", "payment_url", "paymentUrl", "uri", "url"} {
+		if s, ok := fields[key].(string); ok && strings.HasPrefix(s, "solana:") {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+)
+
+const (
+	testRecipient = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	testReference = "82ZJ7nbGpixjeDCmEhUcmwXYfvurzAgGdtSMuHnUgyny"
+	testUSDCMint  = "EPjFWdd5AufqSSqeM2qN1xxoxmTbX6CmaVgDaZdgF3jT"
+)
+
+func TestParseSolanaPayURL(t *testing.T) {
+	uri := "solana:" + testRecipient + "?amount=0.01&spl-token=" + testUSDCMint +
+		"&reference=" + testReference + "&label=Weather+API&memo=order-42"
+
+	req, err := ParseSolanaPayURL(uri)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Recipient != testRecipient || req.Amount != "0.01" || req.SPLToken != testUSDCMint {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if req.Reference() != testReference || req.Label != "Weather API" || req.Memo != "order-42" {
+		t.Errorf("unexpected optional fields %+v", req)
+	}
+
+	again, err := ParseSolanaPayURL(req.String())
+	if err != nil || again.Reference() != testReference || again.Amount != "0.01" {
+		t.Errorf("String round trip failed: %v, %+v", err, again)
+	}
+
+	for _, bad := range []string{
+		"solana:https://merchant.example/pay",                 // transaction request
+		"solana:" + testRecipient,                             // no amount
+		"solana:notakey?amount=1",                             // bad recipient
+		"solana:" + testRecipient + "?amount=-1",              // bad amount
+		"solana:" + testRecipient + "?amount=1&reference=xyz", // bad reference
+		"bitcoin:bc1qxyz?amount=1",
+	} {
+		if _, err := ParseSolanaPayURL(bad); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestSolanaPayDetector(t *testing.T) {
+	uri := "solana:" + testRecipient + "?amount=0.5&spl-token=" + testUSDCMint + "&reference=" + testReference
+
+	tests := []struct {
+		name   string
+		header string
+		body   string
+	}{
+		{"header", uri, ""},
+		{"plain body", "", uri},
+		{"json body", "", `{"error":"payment required","solana_pay":"` + uri + `"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: 402, Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Solana-Pay", tt.header)
+			}
+			options, err := SolanaPayDetector{}.Detect(resp, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(options) != 1 || options[0].Protocol != ProtocolSolanaPay || options[0].Rail() != "solana" {
+				t.Fatalf("expected one solana-pay option, got %+v", options)
+			}
+			if spr := options[0].Details.(*SolanaPayRequest); spr.Amount != "0.5" {
+				t.Errorf("expected amount 0.5, got %s", spr.Amount)
+			}
+		})
+	}
+
+	resp := &http.Response{StatusCode: 402, Header: http.Header{}}
+	if options, err := (SolanaPayDetector{}).Detect(resp, []byte(`{"invoice":"lnbc1"}`)); err != nil || len(options) != 0 {
+		t.Errorf("expected nothing for a non-Solana body, got %v, %v", options, err)
+	}
+}
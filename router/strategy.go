@@ -0,0 +1,188 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Strategy decides which payment option the router settles when a server
+// offers several (e.g. x402 on Base and Solana plus an L402 invoice).
+type Strategy string
+
+const (
+	// StrategyCheapest picks the option with the lowest USD cost. This is the default.
+	StrategyCheapest Strategy = "cheapest"
+	// StrategyPreferred picks the first option matching Config.PreferredRails.
+	StrategyPreferred Strategy = "preferred"
+	// StrategyFastest picks the option with the shortest expected settlement time.
+	StrategyFastest Strategy = "fastest"
+	// StrategyBalance picks the option whose wallet has the most spendable balance.
+	StrategyBalance Strategy = "balance"
+)
+
+// ParseStrategy validates a strategy name from config or flags.
+// An empty string selects StrategyCheapest.
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(s) {
+	case "":
+		return StrategyCheapest, nil
+	case StrategyCheapest, StrategyPreferred, StrategyFastest, StrategyBalance:
+		return Strategy(s), nil
+	default:
+		return "", fmt.Errorf("unknown routing strategy %q (want cheapest, preferred, fastest or balance)", s)
+	}
+}
+
+// Alternative records a payment option the router saw but did not settle.
+type Alternative struct {
+	Protocol    string  `json:"protocol"`
+	Provider    string  `json:"provider,omitempty"`
+	Rail        string  `json:"rail"`
+	Cost        *Amount `json:"cost,omitempty"`
+	Description string  `json:"description,omitempty"`
+	// Skipped explains why the option was ineligible. Empty means it was
+	// eligible but ranked below the chosen option.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// railAliases lets users name rails loosely in Config.PreferredRails.
+var railAliases = map[string][]string{
+	"evm":  {"eip155:"},
+	"base": {"eip155:8453", "eip155:84532"},
+	"l402": {"lightning"},
+	"ln":   {"lightning"},
+}
+
+// settlementTimes are rough expected times to final settlement per rail,
+// matched by longest prefix. They only need to rank rails sensibly.
+var settlementTimes = map[string]time.Duration{
+	"lightning":    1 * time.Second,
+	"cashu":        500 * time.Millisecond, // bearer token, no settlement round trip
+	"solana":       2 * time.Second,
+	"eip155:8453":  2 * time.Second, // Base
+	"eip155:84532": 2 * time.Second, // Base Sepolia
+	"eip155:":      15 * time.Second,
+}
+
+// SettlementTime estimates how long settling on rail takes.
+func SettlementTime(rail string) time.Duration {
+	best, bestLen := 30*time.Second, -1
+	for prefix, d := range settlementTimes {
+		if strings.HasPrefix(rail, prefix) && len(prefix) > bestLen {
+			best, bestLen = d, len(prefix)
+		}
+	}
+	return best
+}
+
+// railRank returns the index of the first preferred rail matching rail, or
+// len(prefs) if none match.
+func railRank(rail string, prefs []string) int {
+	for i, pref := range prefs {
+		pref = strings.ToLower(pref)
+		patterns := railAliases[pref]
+		if patterns == nil {
+			patterns = []string{pref}
+		}
+		for _, p := range patterns {
+			if strings.HasPrefix(strings.ToLower(rail), p) {
+				return i
+			}
+		}
+	}
+	return len(prefs)
+}
+
+// quote is one payment option priced by the provider that would settle it.
+type quote struct {
+	req      *PaymentRequirement
+	reg      *registration
+	provider PaymentProvider
+	cost     Amount
+	desc     string
+	priced   bool
+	balance  *Amount
+	err      error // non-nil if the option is ineligible
+}
+
+// rank orders eligible quotes best-first according to the configured strategy.
+// Every strategy breaks ties by USD cost, then provider priority.
+func (r *Router) rank(quotes []*quote) {
+	cheaper := func(a, b *quote) bool {
+		if c := a.cost.Cmp(b.cost); c != 0 {
+			return c < 0
+		}
+		if a.reg.priority != b.reg.priority {
+			return a.reg.priority < b.reg.priority
+		}
+		return a.reg.seq < b.reg.seq
+	}
+
+	var less func(a, b *quote) bool
+	switch r.config.Strategy {
+	case StrategyPreferred:
+		less = func(a, b *quote) bool {
+			ra, rb := railRank(a.req.Rail(), r.config.PreferredRails), railRank(b.req.Rail(), r.config.PreferredRails)
+			if ra != rb {
+				return ra < rb
+			}
+			return cheaper(a, b)
+		}
+	case StrategyFastest:
+		less = func(a, b *quote) bool {
+			ta, tb := SettlementTime(a.req.Rail()), SettlementTime(b.req.Rail())
+			if ta != tb {
+				return ta < tb
+			}
+			return cheaper(a, b)
+		}
+	case StrategyBalance:
+		less = func(a, b *quote) bool {
+			switch {
+			case a.balance == nil && b.balance == nil:
+				return cheaper(a, b)
+			case a.balance == nil:
+				return false
+			case b.balance == nil:
+				return true
+			}
+			if c := a.balance.Cmp(*b.balance); c != 0 {
+				return c > 0
+			}
+			return cheaper(a, b)
+		}
+	default:
+		less = cheaper
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool { return less(quotes[i], quotes[j]) })
+}
+
+// alternatives describes every quote except the chosen one for the receipt.
+func alternatives(quotes []*quote, chosen *quote) []Alternative {
+	var out []Alternative
+	for _, q := range quotes {
+		if q == chosen {
+			continue
+		}
+		alt := Alternative{
+			Protocol:    q.req.Protocol.String(),
+			Rail:        q.req.Rail(),
+			Description: q.desc,
+		}
+		if q.provider != nil {
+			alt.Provider = providerName(q.provider)
+		}
+		if q.priced {
+			cost := q.cost
+			alt.Cost = &cost
+		}
+		if q.err != nil {
+			alt.Skipped = q.err.Error()
+		}
+		out = append(out, alt)
+	}
+	return out
+}
@@ -0,0 +1,156 @@
+package router
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// multiRailServer offers x402 on network and an L402 invoice, and accepts
+// either proof on retry.
+func multiRailServer(t *testing.T, network string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Payment-Signature") != "" || r.Header.Get("Authorization") != "" {
+			w.Write([]byte(`ok`))
+			return
+		}
+		req := X402Requirement{
+			Accepts: []X402Accept{{
+				Network:           network,
+				MaxAmountRequired: "10000",
+				PayTo:             "0xabc123",
+			}},
+		}
+		data, _ := json.Marshal(req)
+		w.Header().Set("Payment-Required", base64.StdEncoding.EncodeToString(data))
+		w.Header().Set("WWW-Authenticate", `L402 macaroon="m", invoice="lnbc10n1pjtest"`)
+		w.WriteHeader(402)
+	}))
+}
+
+func newMultiRailRouter(cfg Config) *Router {
+	r := New(cfg)
+	r.RegisterProvider(&mockProvider{
+		protocol:    ProtocolX402,
+		cost:        FromUSD(0.01),
+		description: "$0.01 USDC",
+		headerName:  "Payment-Signature",
+		headerValue: "sig",
+	})
+	r.RegisterProvider(&mockProvider{
+		protocol:    ProtocolL402,
+		cost:        FromUSD(0.005),
+		description: "50 sats",
+		headerName:  "Authorization",
+		headerValue: "L402 m:preimage",
+	})
+	return r
+}
+
+func TestRouter_Strategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		network  string
+		cfg      Config
+		wantRail string
+	}{
+		{
+			name:     "cheapest picks lightning",
+			network:  "eip155:8453",
+			cfg:      Config{},
+			wantRail: "lightning",
+		},
+		{
+			name:     "preferred rail overrides price",
+			network:  "eip155:8453",
+			cfg:      Config{Strategy: StrategyPreferred, PreferredRails: []string{"base", "lightning"}},
+			wantRail: "eip155:8453",
+		},
+		{
+			name:     "preferred falls through unmatched rails",
+			network:  "eip155:8453",
+			cfg:      Config{Strategy: StrategyPreferred, PreferredRails: []string{"solana", "lightning"}},
+			wantRail: "lightning",
+		},
+		{
+			name:     "fastest avoids mainnet",
+			network:  "eip155:1",
+			cfg:      Config{Strategy: StrategyFastest},
+			wantRail: "lightning",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := multiRailServer(t, tt.network)
+			defer srv.Close()
+
+			tt.cfg.MaxPerRequestUSD = 1.0
+			r := newMultiRailRouter(tt.cfg)
+			_, receipt, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if receipt.Rail != tt.wantRail {
+				t.Errorf("rail = %q, want %q", receipt.Rail, tt.wantRail)
+			}
+			if len(receipt.Alternatives) != 1 {
+				t.Fatalf("expected 1 alternative, got %d", len(receipt.Alternatives))
+			}
+			if receipt.Alternatives[0].Cost == nil || receipt.Alternatives[0].Skipped != "" {
+				t.Errorf("alternative should be priced and eligible: %+v", receipt.Alternatives[0])
+			}
+		})
+	}
+}
+
+func TestRouter_SkipsOverBudgetRail(t *testing.T) {
+	srv := multiRailServer(t, "eip155:8453")
+	defer srv.Close()
+
+	// L402 at $0.05 is over the $0.02 per-request limit, so only x402 is eligible
+	r := New(Config{MaxPerRequestUSD: 0.02})
+	r.RegisterProvider(&mockProvider{
+		protocol:    ProtocolX402,
+		cost:        FromUSD(0.01),
+		headerName:  "Payment-Signature",
+		headerValue: "sig",
+	})
+	r.RegisterProvider(&mockProvider{
+		protocol: ProtocolL402,
+		cost:     FromUSD(0.05),
+	})
+
+	_, receipt, err := r.Fetch(context.Background(), "GET", srv.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Protocol != "x402" {
+		t.Errorf("expected x402 (L402 over budget), got %s", receipt.Protocol)
+	}
+	if len(receipt.Alternatives) != 1 || receipt.Alternatives[0].Skipped == "" {
+		t.Errorf("expected skipped L402 alternative, got %+v", receipt.Alternatives)
+	}
+}
+
+func TestSettlementTime(t *testing.T) {
+	if SettlementTime("eip155:8453") >= SettlementTime("eip155:1") {
+		t.Error("Base should settle faster than Ethereum mainnet")
+	}
+	if SettlementTime("lightning") >= SettlementTime("eip155:8453") {
+		t.Error("Lightning should settle faster than Base")
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	if s, err := ParseStrategy(""); err != nil || s != StrategyCheapest {
+		t.Errorf("empty strategy = %q, %v; want cheapest", s, err)
+	}
+	if _, err := ParseStrategy("random"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
@@ -37,8 +37,8 @@ func NewWoTChecker(endpoint string) *WoTChecker {
 
 // CheckTrust verifies the trust score for a payment recipient.
 // Returns nil if trusted or below threshold, error if untrusted.
-func (w *WoTChecker) CheckTrust(recipientID string, usdAmount float64) error {
-	if usdAmount < w.ThresholdUSD {
+func (w *WoTChecker) CheckTrust(recipientID string, usdAmount Amount) error {
+	if usdAmount.Cmp(FromUSD(w.ThresholdUSD)) < 0 {
 		return nil // small payments skip trust check
 	}
 
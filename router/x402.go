@@ -0,0 +1,128 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// X402Requirement represents a parsed x402 payment-required header.
+type X402Requirement struct {
+	Accepts []X402Accept `json:"accepts"`
+}
+
+// X402Accept is a single payment option within x402.
+type X402Accept struct {
+	Scheme            string          `json:"scheme"`
+	Network           string          `json:"network"`
+	MaxAmountRequired string          `json:"maxAmountRequired"`
+	Resource          string          `json:"resource"`
+	Description       string          `json:"description"`
+	MimeType          string          `json:"mimeType"`
+	PayTo             string          `json:"payTo"`
+	MaxTimeoutSeconds int             `json:"maxTimeoutSeconds"`
+	Asset             string          `json:"asset"`
+	Extra             json.RawMessage `json:"extra,omitempty"`
+}
+
+// usdcContracts are the USDC token contracts x402 pays with on each network.
+var usdcContracts = map[string]string{
+	"eip155:8453":  "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+	"eip155:84532": "0x036CbD53842c5426634e7929541eC2318f3dCF7e",
+}
+
+// USDCContract returns the USDC token contract on a CAIP-2 network, or "" if
+// none is known.
+func USDCContract(network string) string {
+	return usdcContracts[network]
+}
+
+// USDCNetworks returns the CAIP-2 networks with a known USDC contract, sorted.
+func USDCNetworks() []string {
+	networks := make([]string, 0, len(usdcContracts))
+	for n := range usdcContracts {
+		networks = append(networks, n)
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// X402Detector recognizes x402 challenges in the Payment-Required (v2) or
+// X-Payment-Required (v1) header. It returns one option per accepts entry.
+type X402Detector struct{}
+
+// Protocol implements Detector.
+func (X402Detector) Protocol() Protocol { return ProtocolX402 }
+
+// Detect implements Detector.
+func (X402Detector) Detect(resp *http.Response, body []byte) ([]*PaymentRequirement, error) {
+	header := resp.Header.Get("Payment-Required")
+	if header == "" {
+		header = resp.Header.Get("X-Payment-Required")
+	}
+	if header == "" {
+		return nil, nil
+	}
+
+	req, err := parseX402Header(header)
+	if err != nil {
+		return nil, err
+	}
+	return req.splitAccepts(), nil
+}
+
+// splitAccepts returns one option per x402 accepts entry. Each option keeps
+// the full requirement (providers such as AgentWallet need the raw header).
+func (p *PaymentRequirement) splitAccepts() []*PaymentRequirement {
+	if p.X402Requirement == nil || len(p.X402Requirement.Accepts) == 0 {
+		return []*PaymentRequirement{p}
+	}
+	out := make([]*PaymentRequirement, len(p.X402Requirement.Accepts))
+	for i := range p.X402Requirement.Accepts {
+		opt := *p
+		opt.X402Accept = &p.X402Requirement.Accepts[i]
+		out[i] = &opt
+	}
+	return out
+}
+
+// ParseX402Requirement parses a Payment-Required header value (base64 or
+// raw JSON) into one option per accepts entry, as the x402 detector does.
+func ParseX402Requirement(header string) ([]*PaymentRequirement, error) {
+	req, err := parseX402Header(strings.TrimSpace(header))
+	if err != nil {
+		return nil, err
+	}
+	if len(req.X402Requirement.Accepts) == 0 {
+		return nil, fmt.Errorf("parse x402 header: no accepts")
+	}
+	return req.splitAccepts(), nil
+}
+
+func parseX402Header(header string) (*PaymentRequirement, error) {
+	// x402 headers are base64-encoded JSON
+	decoded, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		// Try raw JSON
+		decoded = []byte(header)
+	}
+
+	var req X402Requirement
+	if err := json.Unmarshal(decoded, &req); err != nil {
+		// Try as array directly
+		var accepts []X402Accept
+		if err2 := json.Unmarshal(decoded, &accepts); err2 != nil {
+			return nil, fmt.Errorf("parse x402 header: %w", err)
+		}
+		req.Accepts = accepts
+	}
+
+	return &PaymentRequirement{
+		Protocol:        ProtocolX402,
+		Raw:             header,
+		X402Requirement: &req,
+	}, nil
+}